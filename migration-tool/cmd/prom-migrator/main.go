@@ -8,10 +8,13 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"math"
 	"os"
+	"os/signal"
 	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/inhies/go-bytesize"
@@ -22,6 +25,7 @@ import (
 	plan "github.com/timescale/promscale/migration-tool/pkg/planner"
 	"github.com/timescale/promscale/migration-tool/pkg/reader"
 	"github.com/timescale/promscale/migration-tool/pkg/utils"
+	"github.com/timescale/promscale/migration-tool/pkg/wal"
 	"github.com/timescale/promscale/migration-tool/pkg/writer"
 )
 
@@ -35,6 +39,9 @@ const (
 	defaultStartTime       = "1970-01-01T00:00:00+00:00" // RFC3339 based time.Unix from 0 seconds.
 	defaultMaxReadDuration = time.Hour * 2
 	defaultLaIncrement     = time.Minute
+	defaultWALPositionFile = "prom-migrator-wal-position.json"
+	defaultWALPollInterval = time.Second * 5
+	defaultWALBatchSize    = 10000
 	version                = "0.0.6"
 )
 
@@ -71,6 +78,11 @@ type config struct {
 	progressMetricAuth   utils.Auth
 	readerMetricsMatcher string
 	readerLabelsMatcher  []*labels.Matcher
+	walDir               string
+	walPositionFile      string
+	walFollow            bool
+	walPollInterval      time.Duration
+	walBatchSize         int
 }
 
 func main() {
@@ -94,6 +106,15 @@ func main() {
 	}
 	log.Info("msg", fmt.Sprintf("%v+", conf))
 
+	if conf.walDir != "" {
+		if err := runWAL(conf); err != nil {
+			log.Error("msg", fmt.Errorf("tailing WAL: %w", err).Error())
+			os.Exit(2)
+		}
+		log.Info("msg", "exiting!")
+		return
+	}
+
 	planConfig := &plan.Config{
 		Mint:                 conf.mint,
 		Maxt:                 conf.maxt,
@@ -181,6 +202,41 @@ loop:
 	log.Info("msg", "exiting!")
 }
 
+// runWAL pushes the samples of the WAL to the remote-write storage, resuming
+// from the persisted position. In follow mode, it runs until interrupted.
+func runWAL(conf *config) error {
+	cont, cancelFunc := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancelFunc()
+
+	write, err := writer.New(writer.Config{
+		Context:              cont,
+		ClientConfig:         conf.writerClientConfig,
+		HTTPConfig:           conf.writerAuth.ToHTTPClientConfig(),
+		GarbageCollectOnPush: conf.garbageCollectOnPush,
+		MigrationJobName:     conf.name,
+		ConcurrentPush:       conf.concurrentPush,
+	})
+	if err != nil {
+		return fmt.Errorf("could not create writer: %w", err)
+	}
+	tailer, err := wal.New(wal.Config{
+		Context:      cont,
+		Dir:          conf.walDir,
+		PositionFile: conf.walPositionFile,
+		Follow:       conf.walFollow,
+		PollInterval: conf.walPollInterval,
+		Mint:         conf.mint,
+		Maxt:         conf.maxt,
+		BatchSize:    conf.walBatchSize,
+		Push:         write.Push,
+	})
+	if err != nil {
+		return fmt.Errorf("could not create WAL tailer: %w", err)
+	}
+	log.Info("msg", "tailing WAL", "dir", conf.walDir, "follow", conf.walFollow)
+	return tailer.Run()
+}
+
 var headers utils.HeadersFlag
 
 func parseFlags(conf *config, args []string) {
@@ -263,6 +319,17 @@ func parseFlags(conf *config, args []string) {
 		"carry out migration with the same time-range. If this is enabled, the migrator will resume the migration from the last time, where it was stopped/interrupted. "+
 		"If you do not want any extra metric(s) while migration, you can set this to false. But, setting this to false will disable progress-metric and hence, the ability to resume migration.")
 
+	// WAL tailing.
+	flag.StringVar(&conf.walDir, "wal-dir", "", "Path to the WAL directory of a Prometheus server or agent, like 'data/wal'. "+
+		"If set, the samples of the WAL are pushed to the remote-write storage instead of migrating from the remote-read storage. "+
+		"The reader and progress-metric flags are ignored then and 'start' and 'end' only filter the pushed samples.")
+	flag.StringVar(&conf.walPositionFile, "wal-position-file", defaultWALPositionFile, "File that persists the segment and offset of the last pushed WAL record, "+
+		"so tailing resumes from there after a restart.")
+	flag.BoolVar(&conf.walFollow, "wal-follow", true, "Keep tailing the WAL for new records and segments once its end is reached. "+
+		"If false, the migrator exits after pushing the last segment.")
+	flag.DurationVar(&conf.walPollInterval, "wal-poll-interval", defaultWALPollInterval, "Interval to check for new WAL records once its end is reached, when 'wal-follow' is set.")
+	flag.IntVar(&conf.walBatchSize, "wal-batch-size", defaultWALBatchSize, "Maximum number of WAL samples pushed to the remote-write storage at a time.")
+
 	// Authentication.
 	flag.StringVar(&conf.readerAuth.Username, "reader-auth-username", "", "Auth username for remote-read storage.")
 	flag.StringVar(&conf.readerAuth.Password, "reader-auth-password", "", "Auth password for remote-read storage. Mutually exclusive with password-file.")
@@ -410,9 +477,15 @@ func convertMetricsMatcherStrToLabelMatchers(conf *config) error {
 }
 
 func validateConf(conf *config) error {
+	endSet := conf.end != ""
 	if err := convertTimeStrFlagsToTs(conf); err != nil {
 		return fmt.Errorf("validate time flags: %w", err)
 	}
+	if conf.walDir != "" && !endSet {
+		// The WAL is tailed without an upper bound, unlike a migration that
+		// stops at the time it began.
+		conf.maxt = math.MaxInt64
+	}
 	if err := utils.ParseClientInfo(&conf.readerClientConfig); err != nil {
 		return fmt.Errorf("parsing reader-client info: %w", err)
 	}
@@ -423,6 +496,18 @@ func validateConf(conf *config) error {
 		return fmt.Errorf("validate '-reader-metrics-matcher': %w", err)
 	}
 	switch {
+	case conf.walDir != "" && strings.TrimSpace(conf.walPositionFile) == "":
+		return fmt.Errorf("'wal-position-file' needs to be specified to tail the WAL")
+	case conf.walDir != "" && conf.walBatchSize < 1:
+		return fmt.Errorf("'wal-batch-size' should be at least 1")
+	case conf.walDir != "" && conf.walFollow && conf.walPollInterval <= 0:
+		return fmt.Errorf("'wal-poll-interval' should be positive")
+	case conf.walDir != "" && strings.TrimSpace(conf.writerClientConfig.URL) == "":
+		return fmt.Errorf("remote write storage url needs to be specified. Without write storage url, the WAL cannot be pushed")
+	case conf.walDir != "" && conf.mint > conf.maxt:
+		return fmt.Errorf("invalid input: minimum timestamp value (start) cannot be greater than the maximum timestamp value (end)")
+	case conf.walDir != "":
+		// The reader, progress-metric and slab settings don't apply to the WAL.
 	case conf.start == defaultStartTime:
 		return fmt.Errorf("'start' should be provided for the migration to begin")
 	case conf.mintSec > conf.maxtSec:
@@ -7,6 +7,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"math"
 	"os"
 	"testing"
 	"time"
@@ -75,6 +76,10 @@ func TestParseFlags(t *testing.T) {
 				maxSlabSize:        "500MB",
 				concurrentPush:     1,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				progressEnabled:    false,
 			},
 			failsValidation: false,
@@ -120,6 +125,10 @@ func TestParseFlags(t *testing.T) {
 				maxSlabSize:        "500MB",
 				concurrentPush:     1,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				progressEnabled:    false,
 			},
 			failsValidation: false,
@@ -162,6 +171,10 @@ func TestParseFlags(t *testing.T) {
 				maxSlabSize:        "500MB",
 				concurrentPush:     1,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				progressEnabled:    false,
 			},
 			failsValidation: false,
@@ -200,6 +213,10 @@ func TestParseFlags(t *testing.T) {
 				maxReadDuration:    defaultMaxReadDuration,
 				laIncrement:        defaultLaIncrement,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				maxSlabSizeBytes:   104857600,
 				humanReadableTime:  true,
 				maxSlabSize:        "100MB",
@@ -243,6 +260,10 @@ func TestParseFlags(t *testing.T) {
 				maxReadDuration:    defaultMaxReadDuration,
 				laIncrement:        defaultLaIncrement,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				maxSlabSizeBytes:   104857600,
 				maxSlabSize:        "100 MB",
 				concurrentPush:     1,
@@ -285,6 +306,10 @@ func TestParseFlags(t *testing.T) {
 				maxReadDuration:    defaultMaxReadDuration,
 				laIncrement:        defaultLaIncrement,
 				concurrentPull:     16,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				maxSlabSizeBytes:   524288000,
 				maxSlabSize:        "500MB",
 				concurrentPush:     8,
@@ -329,6 +354,10 @@ func TestParseFlags(t *testing.T) {
 				maxSlabSize:        "100MBB",
 				concurrentPush:     1,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				progressEnabled:    false,
 			},
 			failsValidation: true,
@@ -372,6 +401,10 @@ func TestParseFlags(t *testing.T) {
 				concurrentPush:     1,
 				progressEnabled:    false,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 			},
 			failsValidation: true,
 			errMessage:      `parsing byte-size: Unrecognized size suffix PP`,
@@ -412,6 +445,10 @@ func TestParseFlags(t *testing.T) {
 				laIncrement:        defaultLaIncrement,
 				progressEnabled:    false,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				maxSlabSizeBytes:   524288000,
 				maxSlabSize:        "500MB",
 				concurrentPush:     1,
@@ -454,6 +491,10 @@ func TestParseFlags(t *testing.T) {
 				laIncrement:        defaultLaIncrement,
 				progressEnabled:    false,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				maxSlabSize:        "500MB",
 				concurrentPush:     1,
 			},
@@ -496,6 +537,10 @@ func TestParseFlags(t *testing.T) {
 				laIncrement:        defaultLaIncrement,
 				progressEnabled:    false,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				maxSlabSize:        "500MB",
 				concurrentPush:     1,
 			},
@@ -536,6 +581,10 @@ func TestParseFlags(t *testing.T) {
 				laIncrement:        defaultLaIncrement,
 				progressEnabled:    true,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				maxSlabSize:        "500MB",
 				concurrentPush:     1,
 			},
@@ -577,6 +626,10 @@ func TestParseFlags(t *testing.T) {
 				laIncrement:        defaultLaIncrement,
 				progressEnabled:    true,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				maxSlabSize:        "500MB",
 				concurrentPush:     1,
 			},
@@ -619,6 +672,10 @@ func TestParseFlags(t *testing.T) {
 				laIncrement:        defaultLaIncrement,
 				progressEnabled:    true,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				maxSlabSize:        "500MB",
 				concurrentPush:     1,
 			},
@@ -661,6 +718,10 @@ func TestParseFlags(t *testing.T) {
 				laIncrement:        defaultLaIncrement,
 				progressEnabled:    true,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				maxSlabSize:        "500MB",
 				concurrentPush:     1,
 			},
@@ -703,6 +764,10 @@ func TestParseFlags(t *testing.T) {
 				laIncrement:        defaultLaIncrement,
 				progressEnabled:    true,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				maxSlabSize:        "500MB",
 				concurrentPush:     1,
 			},
@@ -745,6 +810,10 @@ func TestParseFlags(t *testing.T) {
 				laIncrement:        defaultLaIncrement,
 				progressEnabled:    true,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				maxSlabSize:        "500MB",
 				concurrentPush:     1,
 			},
@@ -787,6 +856,10 @@ func TestParseFlags(t *testing.T) {
 				laIncrement:        defaultLaIncrement,
 				progressEnabled:    true,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				maxSlabSize:        "500MB",
 				concurrentPush:     1,
 			},
@@ -829,6 +902,10 @@ func TestParseFlags(t *testing.T) {
 				progressMetricURL:  "http://localhost:9201/read",
 				progressEnabled:    true,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				maxSlabSizeBytes:   524288000,
 				maxSlabSize:        "500MB",
 				concurrentPush:     1,
@@ -877,6 +954,10 @@ func TestParseFlags(t *testing.T) {
 				concurrentPush:     1,
 				progressEnabled:    false,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				readerAuth:         utils.Auth{Password: "password"},
 			},
 			failsValidation: false,
@@ -920,6 +1001,10 @@ func TestParseFlags(t *testing.T) {
 				concurrentPush:     1,
 				progressEnabled:    false,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				readerAuth:         utils.Auth{BearerToken: "token"},
 			},
 			failsValidation: false,
@@ -962,6 +1047,10 @@ func TestParseFlags(t *testing.T) {
 				concurrentPush:     1,
 				progressEnabled:    false,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				readerAuth:         utils.Auth{Password: "password", BearerToken: "token"},
 			},
 			failsValidation: true,
@@ -1021,10 +1110,109 @@ func TestParseFlags(t *testing.T) {
 				maxSlabSize:        "500MB",
 				concurrentPush:     1,
 				concurrentPull:     1,
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
 				progressEnabled:    false,
 			},
 			failsValidation: false,
 		},
+		{
+			name:  "pass_wal",
+			input: []string{"-wal-dir=data/wal", "-writer-url=http://localhost:9201/write"},
+			expectedConf: &config{
+				name:                 "prom-migrator",
+				start:                defaultStartTime,
+				end:                  fmt.Sprintf("%d", currentTime),
+				humanReadableTime:    true,
+				mint:                 0,
+				mintSec:              0,
+				maxt:                 math.MaxInt64,
+				maxtSec:              currentTime,
+				readerMetricsMatcher: `{__name__=~".+"}`,
+				readerLabelsMatcher:  getReaderLabelsMatcher(labels.MatchRegexp, ".+"),
+				readerClientConfig: utils.ClientConfig{
+					URL:           "",
+					Timeout:       defaultTimeout,
+					Delay:         defaultRetryDelay,
+					OnTimeoutStr:  "retry",
+					OnErrStr:      "abort",
+					CustomHeaders: map[string][]string{},
+				},
+				writerClientConfig: utils.ClientConfig{
+					URL:           "http://localhost:9201/write",
+					Timeout:       defaultTimeout,
+					Delay:         defaultRetryDelay,
+					OnTimeoutStr:  "retry",
+					OnErrStr:      "abort",
+					CustomHeaders: map[string][]string{},
+				},
+				progressMetricName: "prom_migrator_progress",
+				progressMetricURL:  "",
+				maxReadDuration:    defaultMaxReadDuration,
+				laIncrement:        defaultLaIncrement,
+				maxSlabSize:        "500MB",
+				maxSlabSizeBytes:   524288000,
+				concurrentPush:     1,
+				concurrentPull:     1,
+				walDir:             "data/wal",
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
+				progressEnabled:    true,
+			},
+			failsValidation: false,
+		},
+		{
+			name:  "fail_wal_without_writer_url",
+			input: []string{"-wal-dir=data/wal"},
+			expectedConf: &config{
+				name:                 "prom-migrator",
+				start:                defaultStartTime,
+				end:                  fmt.Sprintf("%d", currentTime),
+				humanReadableTime:    true,
+				mint:                 0,
+				mintSec:              0,
+				maxt:                 math.MaxInt64,
+				maxtSec:              currentTime,
+				readerMetricsMatcher: `{__name__=~".+"}`,
+				readerLabelsMatcher:  getReaderLabelsMatcher(labels.MatchRegexp, ".+"),
+				readerClientConfig: utils.ClientConfig{
+					URL:           "",
+					Timeout:       defaultTimeout,
+					Delay:         defaultRetryDelay,
+					OnTimeoutStr:  "retry",
+					OnErrStr:      "abort",
+					CustomHeaders: map[string][]string{},
+				},
+				writerClientConfig: utils.ClientConfig{
+					URL:           "",
+					Timeout:       defaultTimeout,
+					Delay:         defaultRetryDelay,
+					OnTimeoutStr:  "retry",
+					OnErrStr:      "abort",
+					CustomHeaders: map[string][]string{},
+				},
+				progressMetricName: "prom_migrator_progress",
+				progressMetricURL:  "",
+				maxReadDuration:    defaultMaxReadDuration,
+				laIncrement:        defaultLaIncrement,
+				maxSlabSize:        "500MB",
+				maxSlabSizeBytes:   0,
+				concurrentPush:     1,
+				concurrentPull:     1,
+				walDir:             "data/wal",
+				walPositionFile:    defaultWALPositionFile,
+				walFollow:          true,
+				walPollInterval:    defaultWALPollInterval,
+				walBatchSize:       defaultWALBatchSize,
+				progressEnabled:    true,
+			},
+			failsValidation: true,
+			errMessage:      "remote write storage url needs to be specified. Without write storage url, the WAL cannot be pushed",
+		},
 	}
 
 	for _, c := range cases {
@@ -0,0 +1,59 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package wal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Position is the end of the last WAL record whose samples were pushed.
+type Position struct {
+	Segment int   `json:"segment"`
+	Offset  int64 `json:"offset"`
+}
+
+// noPosition means no samples were pushed yet, not even those of the checkpoint.
+var noPosition = Position{Segment: -1}
+
+// after tells if a record of the segment ending at offset is after the position.
+func (p Position) after(segment int, offset int64) bool {
+	return segment > p.Segment || (segment == p.Segment && offset > p.Offset)
+}
+
+// readPosition reads the position from the file, noPosition if the file doesn't
+// exist.
+func readPosition(file string) (Position, error) {
+	b, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return noPosition, nil
+	}
+	if err != nil {
+		return noPosition, fmt.Errorf("reading position file: %w", err)
+	}
+	var p Position
+	if err = json.Unmarshal(b, &p); err != nil {
+		return noPosition, fmt.Errorf("parsing position file: %w", err)
+	}
+	return p, nil
+}
+
+// writePosition replaces the position file atomically, so a crash never leaves a
+// partially written position.
+func writePosition(file string, p Position) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err = os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("writing position file: %w", err)
+	}
+	if err = os.Rename(tmp, file); err != nil {
+		return fmt.Errorf("replacing position file: %w", err)
+	}
+	return nil
+}
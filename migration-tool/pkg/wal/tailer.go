@@ -0,0 +1,325 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package wal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/record"
+	"github.com/prometheus/prometheus/tsdb/wal"
+
+	"github.com/timescale/promscale/migration-tool/pkg/log"
+)
+
+// Config is config for the WAL tailer.
+type Config struct {
+	Context context.Context
+	// Dir is the WAL directory of a Prometheus server or agent, usually
+	// data/wal or data-agent/wal.
+	Dir string
+	// PositionFile persists the position of the last pushed record, so
+	// tailing resumes where it stopped.
+	PositionFile string
+	// Follow keeps tailing new records and segments once the end of the WAL
+	// is reached, checking for them every PollInterval.
+	Follow       bool
+	PollInterval time.Duration
+	// Samples outside of [Mint, Maxt) are skipped.
+	Mint, Maxt int64
+	// BatchSize is the number of samples pushed at once.
+	BatchSize int
+	// Push pushes a batch of time-series to the remote-write storage.
+	Push func([]prompb.TimeSeries) error
+}
+
+// Tailer reads the series and samples records of a Prometheus WAL, starting
+// with the last checkpoint, and pushes the samples in batches.
+//
+// Series records are always decoded from the beginning since samples only
+// reference series, but samples are only pushed if their record is past the
+// persisted position. The samples of the checkpoint are only pushed if no
+// position was persisted yet, or if the segment of the position was
+// checkpointed since.
+type Tailer struct {
+	Config
+	position Position
+	dec      record.Decoder
+	series   map[chunks.HeadSeriesRef]walSeries
+	// checkpointIndex is the index of the last checkpoint read, -1 if none.
+	checkpointIndex int
+	readerMetrics   *wal.LiveReaderMetrics
+
+	batch        map[chunks.HeadSeriesRef]*prompb.TimeSeries
+	batchSamples int
+	// batchEnd is the position after the last record of the batch.
+	batchEnd Position
+}
+
+// New returns a new WAL tailer.
+func New(config Config) (*Tailer, error) {
+	position, err := readPosition(config.PositionFile)
+	if err != nil {
+		return nil, err
+	}
+	return &Tailer{
+		Config:          config,
+		position:        position,
+		series:          make(map[chunks.HeadSeriesRef]walSeries),
+		checkpointIndex: -1,
+		readerMetrics:   wal.NewLiveReaderMetrics(nil),
+		batch:           make(map[chunks.HeadSeriesRef]*prompb.TimeSeries),
+	}, nil
+}
+
+// walSeries is a series of the WAL and the segment it was last logged in.
+type walSeries struct {
+	lset    labels.Labels
+	segment int
+}
+
+// Run reads the checkpoint and the segments of the WAL. Without Follow it
+// returns once the end of the last segment is reached, otherwise it tails the
+// WAL until the context is done.
+func (t *Tailer) Run() error {
+	if t.position != noPosition {
+		log.Info("msg", "resuming from position", "segment", t.position.Segment, "offset", t.position.Offset)
+	}
+	checkpoint, checkpointIndex, err := wal.LastCheckpoint(t.Dir)
+	if err != nil && err != record.ErrNotFound {
+		return fmt.Errorf("finding last checkpoint: %w", err)
+	}
+	if err == nil {
+		// Checkpoint samples are older than any segment, so they are pushed
+		// only when nothing has been pushed yet, unless the records past the
+		// position were checkpointed and are only left in the checkpoint.
+		push := t.position == noPosition
+		if t.position != noPosition && t.position.Segment <= checkpointIndex {
+			log.Warn("msg", "the segment of the persisted position was checkpointed, pushing the samples of the checkpoint again",
+				"segment", t.position.Segment, "checkpoint", checkpoint)
+			push = true
+		}
+		if err = t.readCheckpoint(checkpoint, checkpointIndex, push); err != nil {
+			return fmt.Errorf("reading checkpoint %s: %w", checkpoint, err)
+		}
+		// Push the checkpoint samples before the segments. The position is
+		// persisted with the first batch of segment records.
+		if err = t.push(); err != nil {
+			return err
+		}
+	}
+	first, _, err := wal.Segments(t.Dir)
+	if err != nil {
+		return fmt.Errorf("listing segments: %w", err)
+	}
+	if first < 0 {
+		return fmt.Errorf("no WAL segments found in %s", t.Dir)
+	}
+	// Segments up to the checkpoint index are already in the checkpoint.
+	if checkpoint != "" && first <= checkpointIndex {
+		first = checkpointIndex + 1
+	}
+	for segment := first; ; segment++ {
+		if err = t.pruneSeries(); err != nil {
+			return err
+		}
+		done, err := t.readSegment(segment)
+		if err != nil {
+			return fmt.Errorf("reading segment %d: %w", segment, err)
+		}
+		if done {
+			return t.flush()
+		}
+	}
+}
+
+// readCheckpoint reads the records of the checkpoint of the segments up to
+// index, adding its samples to the batch if push is true.
+func (t *Tailer) readCheckpoint(dir string, index int, push bool) error {
+	r, err := wal.NewSegmentsReader(dir)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	reader := wal.NewReader(r)
+	for reader.Next() {
+		if err = t.decode(reader.Record(), index, push); err != nil {
+			return err
+		}
+	}
+	if err = reader.Err(); err != nil {
+		return err
+	}
+	t.checkpointIndex = index
+	return nil
+}
+
+// pruneSeries drops the series which were removed from the WAL once a newer
+// checkpoint was written. Series last logged in a checkpointed segment are
+// only kept if they are in the checkpoint.
+func (t *Tailer) pruneSeries() error {
+	checkpoint, index, err := wal.LastCheckpoint(t.Dir)
+	if err == record.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("finding last checkpoint: %w", err)
+	}
+	if index <= t.checkpointIndex {
+		return nil
+	}
+	for ref, s := range t.series {
+		if s.segment <= index {
+			delete(t.series, ref)
+		}
+	}
+	if err = t.readCheckpoint(checkpoint, index, false); err != nil {
+		return fmt.Errorf("reading checkpoint %s: %w", checkpoint, err)
+	}
+	return nil
+}
+
+// readSegment reads the records of the segment. It returns true if the last
+// segment was read and the WAL isn't followed.
+func (t *Tailer) readSegment(segment int) (done bool, err error) {
+	s, err := wal.OpenReadSegment(wal.SegmentName(t.Dir, segment))
+	if err != nil {
+		return false, err
+	}
+	defer s.Close()
+	reader := wal.NewLiveReader(log.GetLogger(), t.readerMetrics, s)
+	newerSegment := false
+	for {
+		for reader.Next() {
+			push := t.position.after(segment, reader.Offset())
+			if err = t.decode(reader.Record(), segment, push); err != nil {
+				return false, err
+			}
+			t.batchEnd = Position{Segment: segment, Offset: reader.Offset()}
+			if t.batchSamples >= t.BatchSize {
+				if err = t.flush(); err != nil {
+					return false, err
+				}
+			}
+		}
+		if err = reader.Err(); err != nil && err != io.EOF {
+			return false, err
+		}
+		// The end of the segment is reached. Move on if there is a newer
+		// segment, the current one is complete then.
+		_, last, err := wal.Segments(t.Dir)
+		if err != nil {
+			return false, fmt.Errorf("listing segments: %w", err)
+		}
+		if last > segment {
+			if newerSegment {
+				return false, nil
+			}
+			// Records written between reaching the end and listing the
+			// segments are read before moving on.
+			newerSegment = true
+			continue
+		}
+		if !t.Follow {
+			return true, nil
+		}
+		// Push what was read before waiting for more.
+		if err = t.flush(); err != nil {
+			return false, err
+		}
+		select {
+		case <-t.Context.Done():
+			return true, nil
+		case <-time.After(t.PollInterval):
+		}
+	}
+}
+
+// decode decodes the record of the segment, adding its samples to the batch
+// if push is true.
+func (t *Tailer) decode(rec []byte, segment int, push bool) error {
+	switch t.dec.Type(rec) {
+	case record.Series:
+		series, err := t.dec.Series(rec, nil)
+		if err != nil {
+			return fmt.Errorf("decoding series: %w", err)
+		}
+		for _, s := range series {
+			t.series[s.Ref] = walSeries{lset: s.Labels, segment: segment}
+		}
+	case record.Samples:
+		if !push {
+			return nil
+		}
+		samples, err := t.dec.Samples(rec, nil)
+		if err != nil {
+			return fmt.Errorf("decoding samples: %w", err)
+		}
+		for _, s := range samples {
+			if s.T < t.Mint || s.T >= t.Maxt {
+				continue
+			}
+			ts, ok := t.batch[s.Ref]
+			if !ok {
+				series, ok := t.series[s.Ref]
+				if !ok {
+					// The series was deleted before the checkpoint.
+					continue
+				}
+				ts = &prompb.TimeSeries{Labels: labelsToPrompb(series.lset)}
+				t.batch[s.Ref] = ts
+			}
+			ts.Samples = append(ts.Samples, prompb.Sample{Timestamp: s.T, Value: s.V})
+			t.batchSamples++
+		}
+	}
+	// Tombstones and exemplars aren't pushed.
+	return nil
+}
+
+// flush pushes the batch and persists the position after it.
+func (t *Tailer) flush() error {
+	if err := t.push(); err != nil {
+		return err
+	}
+	if !t.position.after(t.batchEnd.Segment, t.batchEnd.Offset) {
+		return nil
+	}
+	if err := writePosition(t.PositionFile, t.batchEnd); err != nil {
+		return err
+	}
+	t.position = t.batchEnd
+	return nil
+}
+
+func (t *Tailer) push() error {
+	if t.batchSamples == 0 {
+		return nil
+	}
+	ts := make([]prompb.TimeSeries, 0, len(t.batch))
+	for _, s := range t.batch {
+		ts = append(ts, *s)
+	}
+	if err := t.Push(ts); err != nil {
+		return fmt.Errorf("pushing samples: %w", err)
+	}
+	log.Debug("msg", "pushed WAL samples", "series", len(ts), "samples", t.batchSamples)
+	t.batch = make(map[chunks.HeadSeriesRef]*prompb.TimeSeries)
+	t.batchSamples = 0
+	return nil
+}
+
+func labelsToPrompb(lset labels.Labels) []prompb.Label {
+	res := make([]prompb.Label, 0, len(lset))
+	for _, l := range lset {
+		res = append(res, prompb.Label{Name: l.Name, Value: l.Value})
+	}
+	return res
+}
@@ -0,0 +1,203 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package wal
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/record"
+	"github.com/prometheus/prometheus/tsdb/wal"
+	"github.com/stretchr/testify/require"
+)
+
+type pushed map[string][]prompb.Sample
+
+func (p pushed) push(ts []prompb.TimeSeries) error {
+	for _, s := range ts {
+		name := s.Labels[0].Value
+		p[name] = append(p[name], s.Samples...)
+	}
+	return nil
+}
+
+func (p pushed) timestamps(name string) []int64 {
+	var res []int64
+	for _, s := range p[name] {
+		res = append(res, s.Timestamp)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
+
+func logSamples(t *testing.T, w *wal.WAL, ref chunks.HeadSeriesRef, ts ...int64) {
+	var enc record.Encoder
+	samples := make([]record.RefSample, 0, len(ts))
+	for _, t := range ts {
+		samples = append(samples, record.RefSample{Ref: ref, T: t, V: float64(t)})
+	}
+	require.NoError(t, w.Log(enc.Samples(samples, nil)))
+}
+
+func TestTailer(t *testing.T) {
+	dir := t.TempDir()
+	walDir := filepath.Join(dir, "wal")
+	w, err := wal.New(nil, nil, walDir, false)
+	require.NoError(t, err)
+	defer w.Close()
+
+	var enc record.Encoder
+	require.NoError(t, w.Log(enc.Series([]record.RefSeries{
+		{Ref: 1, Labels: labels.FromStrings("__name__", "a")},
+		{Ref: 2, Labels: labels.FromStrings("__name__", "b")},
+	}, nil)))
+	logSamples(t, w, 1, 1, 2)
+	logSamples(t, w, 2, 1)
+	require.NoError(t, w.NextSegment())
+	logSamples(t, w, 1, 3)
+	logSamples(t, w, 2, 2, 3)
+
+	// Segment 0 is checkpointed, its series must still be resolved.
+	_, err = wal.Checkpoint(log.NewNopLogger(), w, 0, 0, func(chunks.HeadSeriesRef) bool { return true }, 0)
+	require.NoError(t, err)
+
+	config := Config{
+		Context:      context.Background(),
+		Dir:          walDir,
+		PositionFile: filepath.Join(dir, "position.json"),
+		Mint:         0,
+		Maxt:         math.MaxInt64,
+		BatchSize:    2,
+	}
+	run := func() pushed {
+		p := pushed{}
+		config.Push = p.push
+		tailer, err := New(config)
+		require.NoError(t, err)
+		require.NoError(t, tailer.Run())
+		return p
+	}
+
+	p := run()
+	require.Equal(t, []int64{1, 2, 3}, p.timestamps("a"))
+	require.Equal(t, []int64{1, 2, 3}, p.timestamps("b"))
+
+	// Resuming pushes only the new records.
+	p = run()
+	require.Empty(t, p)
+	logSamples(t, w, 1, 4)
+	require.NoError(t, w.NextSegment())
+	logSamples(t, w, 2, 4, 5)
+	p = run()
+	require.Equal(t, []int64{4}, p.timestamps("a"))
+	require.Equal(t, []int64{4, 5}, p.timestamps("b"))
+
+	position, err := readPosition(config.PositionFile)
+	require.NoError(t, err)
+	require.Equal(t, 2, position.Segment)
+
+	// Samples outside of the time range are skipped.
+	logSamples(t, w, 1, 5, 10)
+	config.Maxt = 10
+	p = run()
+	require.Equal(t, []int64{5}, p.timestamps("a"))
+}
+
+func TestTailerCheckpointedPosition(t *testing.T) {
+	dir := t.TempDir()
+	walDir := filepath.Join(dir, "wal")
+	w, err := wal.New(nil, nil, walDir, false)
+	require.NoError(t, err)
+	defer w.Close()
+
+	var enc record.Encoder
+	require.NoError(t, w.Log(enc.Series([]record.RefSeries{
+		{Ref: 1, Labels: labels.FromStrings("__name__", "a")},
+	}, nil)))
+	logSamples(t, w, 1, 1)
+
+	p := pushed{}
+	config := Config{
+		Context:      context.Background(),
+		Dir:          walDir,
+		PositionFile: filepath.Join(dir, "position.json"),
+		Maxt:         math.MaxInt64,
+		BatchSize:    10,
+		Push:         p.push,
+	}
+	tailer, err := New(config)
+	require.NoError(t, err)
+	require.NoError(t, tailer.Run())
+	require.Equal(t, []int64{1}, p.timestamps("a"))
+
+	// Segment 0 is checkpointed and truncated with records past the
+	// position, they are only left in the checkpoint.
+	logSamples(t, w, 1, 2)
+	require.NoError(t, w.NextSegment())
+	logSamples(t, w, 1, 3)
+	_, err = wal.Checkpoint(log.NewNopLogger(), w, 0, 0, func(chunks.HeadSeriesRef) bool { return true }, 0)
+	require.NoError(t, err)
+	require.NoError(t, w.Truncate(1))
+
+	p = pushed{}
+	config.Push = p.push
+	tailer, err = New(config)
+	require.NoError(t, err)
+	require.NoError(t, tailer.Run())
+	require.Equal(t, []int64{1, 2, 3}, p.timestamps("a"))
+}
+
+func TestTailerPruneSeries(t *testing.T) {
+	dir := t.TempDir()
+	walDir := filepath.Join(dir, "wal")
+	w, err := wal.New(nil, nil, walDir, false)
+	require.NoError(t, err)
+	defer w.Close()
+
+	var enc record.Encoder
+	require.NoError(t, w.Log(enc.Series([]record.RefSeries{
+		{Ref: 1, Labels: labels.FromStrings("__name__", "a")},
+		{Ref: 2, Labels: labels.FromStrings("__name__", "b")},
+	}, nil)))
+	require.NoError(t, w.NextSegment())
+	require.NoError(t, w.Log(enc.Series([]record.RefSeries{
+		{Ref: 3, Labels: labels.FromStrings("__name__", "c")},
+	}, nil)))
+
+	tailer, err := New(Config{Context: context.Background(), Dir: walDir, PositionFile: filepath.Join(dir, "position.json"), Maxt: math.MaxInt64, BatchSize: 10, Push: pushed{}.push})
+	require.NoError(t, err)
+	require.NoError(t, tailer.Run())
+	require.Len(t, tailer.series, 3)
+
+	// Series 2 isn't in the checkpoint of segment 0 anymore.
+	_, err = wal.Checkpoint(log.NewNopLogger(), w, 0, 0, func(ref chunks.HeadSeriesRef) bool { return ref != 2 }, 0)
+	require.NoError(t, err)
+	require.NoError(t, tailer.pruneSeries())
+	require.Len(t, tailer.series, 2)
+	require.Contains(t, tailer.series, chunks.HeadSeriesRef(1))
+	require.Contains(t, tailer.series, chunks.HeadSeriesRef(3))
+}
+
+func TestTailerCorruptSegment(t *testing.T) {
+	dir := t.TempDir()
+	walDir := filepath.Join(dir, "wal")
+	w, err := wal.New(nil, nil, walDir, false)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// A record header of a full record longer than a page.
+	require.NoError(t, os.WriteFile(wal.SegmentName(walDir, 0), []byte{1, 0xff, 0xf0, 0, 0, 0, 0, 0}, 0o666))
+	tailer, err := New(Config{Context: context.Background(), Dir: walDir, PositionFile: filepath.Join(dir, "position.json"), Maxt: math.MaxInt64, BatchSize: 10, Push: pushed{}.push})
+	require.NoError(t, err)
+	require.Error(t, tailer.Run())
+}
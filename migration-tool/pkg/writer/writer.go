@@ -131,6 +131,24 @@ func (w *Write) Run(errChan chan<- error) {
 	}()
 }
 
+// Push pushes the time-series through the shards and waits until all shards are done.
+// It is used by sources other than the remote-reader and must not be used along with Run.
+func (w *Write) Push(ts []prompb.TimeSeries) error {
+	numSigExpected := w.shardsSet.scheduleTS(ts)
+	var err error
+	for i := 0; i < numSigExpected; i++ {
+		// Wait for all the shards, so that none is still pushing on return.
+		if e := <-w.shardsSet.errChan; e != nil && err == nil {
+			err = fmt.Errorf("remote-write push: %w", e)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	w.collectGarbage()
+	return nil
+}
+
 var gcRunner sync.Once
 
 func (w *Write) collectGarbage() {
@@ -17,16 +17,22 @@ import (
 	"github.com/grafana/regexp"
 
 	"github.com/prometheus/common/model"
-	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/util/httputil"
 	"github.com/timescale/promscale/pkg/log"
 	pgmodel "github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/promql/parser"
+	"github.com/timescale/promscale/pkg/query"
 	"github.com/timescale/promscale/pkg/rules"
 	"github.com/timescale/promscale/pkg/tenancy"
 )
 
+const (
+	defaultPushRefreshInterval = time.Minute
+	defaultPushMaxGroups       = 10000
+)
+
 var (
 	minTimeFormatted = pgmodel.MinTime.Format(time.RFC3339Nano)
 	maxTimeFormatted = pgmodel.MaxTime.Format(time.RFC3339Nano)
@@ -39,20 +45,39 @@ type Config struct {
 	AdminAPIEnabled  bool
 	TelemetryPath    string
 
+	// PushRefreshInterval is the interval at which the last values pushed to
+	// the Pushgateway API are written again, 0 disables it.
+	PushRefreshInterval time.Duration
+	PushMaxGroups       int
+
 	MultiTenancy tenancy.Authorizer
 	Rules        *rules.Manager
+	SlowQueryLog *query.SlowQueryLog
+	// QueryScheduler admits queries for evaluation, nil if unlimited.
+	QueryScheduler *query.Scheduler
+	// Logs searches the stored logs, nil if logs are disabled.
+	Logs LogSearcher
 }
 
 func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
 	fs.BoolVar(&cfg.ReadOnly, "db.read-only", false, "Read-only mode for the connector. Operations related to writing or updating the database are disallowed. It is used when pointing the connector to a TimescaleDB read replica.")
 	fs.BoolVar(&cfg.HighAvailability, "metrics.high-availability", false, "Enable external_labels based HA.")
-	fs.BoolVar(&cfg.AdminAPIEnabled, "web.enable-admin-api", false, "Allow operations via API that are for advanced users. Currently, these operations are limited to deletion of series.")
+	fs.BoolVar(&cfg.AdminAPIEnabled, "web.enable-admin-api", false, "Allow operations via API that are for advanced users. Currently, these operations are limited to deletion of series and the slow queries endpoint.")
 	fs.StringVar(&cfg.TelemetryPath, "web.telemetry-path", "/metrics", "Web endpoint for exposing Promscale's Prometheus metrics.")
+	fs.DurationVar(&cfg.PushRefreshInterval, "metrics.push.refresh-interval", defaultPushRefreshInterval, "Interval at which the last values pushed to the Pushgateway API are written again, so they don't go stale after the query lookback delta. "+
+		"Setting it to `0` disables it, the pushed values are then only returned by queries for the lookback delta after the push.")
+	fs.IntVar(&cfg.PushMaxGroups, "metrics.push.max-groups", defaultPushMaxGroups, "Maximum number of grouping keys of the Pushgateway API kept in memory. Pushes to new groups are rejected beyond it.")
 
 	return cfg
 }
 
 func Validate(cfg *Config) error {
+	if cfg.PushRefreshInterval < 0 {
+		return fmt.Errorf("metrics.push.refresh-interval must not be negative: %s", cfg.PushRefreshInterval)
+	}
+	if cfg.PushMaxGroups < 1 {
+		return fmt.Errorf("metrics.push.max-groups must be at least 1: %d", cfg.PushMaxGroups)
+	}
 	return nil
 }
 
@@ -113,9 +138,9 @@ func respondQuery(w http.ResponseWriter, res *promql.Result, warnings storage.Wa
 	}
 }
 
-func respondExemplar(w http.ResponseWriter, data []pgmodel.ExemplarQueryResult) {
+func respondExemplar(w http.ResponseWriter, data []pgmodel.ExemplarQueryResult, traces [][]*exemplarTrace) {
 	setResponseHeaders(w, nil, true, nil)
-	_ = marshalExemplarResponse(w, data)
+	_ = marshalExemplarResponse(w, data, traces)
 }
 
 func respond(w http.ResponseWriter, status int, message interface{}) {
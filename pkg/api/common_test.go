@@ -194,7 +194,7 @@ func TestMarshalExemplar(t *testing.T) {
 	}
 	for _, tc := range tcs {
 		var s strings.Builder
-		err := marshalExemplarResponse(&s, tc.result)
+		err := marshalExemplarResponse(&s, tc.result, nil)
 		require.NoError(t, err, tc.name)
 		response := s.String()
 		require.Equal(t, tc.expectedStr, response, tc.name)
@@ -9,12 +9,12 @@ import (
 	"net/http"
 
 	"github.com/NYTimes/gziphandler"
-	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/pgclient"
 	"github.com/timescale/promscale/pkg/pgmodel/common/errors"
 	deletePkg "github.com/timescale/promscale/pkg/pgmodel/delete"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/promql/parser"
 )
 
 func Delete(conf *Config, client *pgclient.Client) http.Handler {
@@ -0,0 +1,181 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/NYTimes/gziphandler"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/promql/parser"
+	"github.com/timescale/promscale/pkg/query"
+)
+
+func Federate(conf *Config, promqlConf *query.Config, queryable promql.Queryable, updateMetrics updateMetricCallback) http.Handler {
+	hf := corsWrapper(conf, federate(promqlConf.LookBackDelta, queryable, conf.QueryScheduler, updateMetrics))
+	return gziphandler.GzipHandler(hf)
+}
+
+// federate returns the latest sample within the lookback delta of the series
+// selected by the match[] parameters, in the text or OpenMetrics exposition
+// format. Tenancy is enforced by the read authorizer of the querier.
+func federate(lookbackDelta time.Duration, queryable promql.Queryable, scheduler *query.Scheduler, updateMetrics updateMetricCallback) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statusCode := "400"
+		begin := time.Now()
+		defer func() {
+			updateMetrics("/federate", statusCode, "", time.Since(begin).Seconds())
+		}()
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, fmt.Sprintf("error parsing form values: %v", err), http.StatusBadRequest)
+			return
+		}
+		var matcherSets [][]*labels.Matcher
+		for _, s := range r.Form["match[]"] {
+			matchers, err := parser.ParseMetricSelector(s)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			matcherSets = append(matcherSets, matchers)
+		}
+
+		release, ok := acquireQuery(r.Context(), w, r, scheduler, query.PriorityBackground)
+		if !ok {
+			statusCode = "503"
+			return
+		}
+		defer release()
+
+		now := time.Now()
+		mint := timestamp.FromTime(now.Add(-lookbackDelta))
+		maxt := timestamp.FromTime(now)
+		q, err := queryable.SamplesQuerier(r.Context(), mint, maxt)
+		if err != nil {
+			statusCode = "500"
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer q.Close()
+
+		hints := &storage.SelectHints{Start: mint, End: maxt}
+		var sets []storage.SeriesSet
+		for _, mset := range matcherSets {
+			s, _ := q.Select(true, hints, nil, nil, mset...)
+			sets = append(sets, s)
+		}
+		set := storage.NewMergeSeriesSet(sets, storage.ChainedSeriesMerge)
+
+		var vec promql.Vector
+		for set.Next() {
+			s := set.At()
+			t, v, ok := lastSample(s)
+			// The exposition formats don't support stale markers.
+			if !ok || value.IsStaleNaN(v) {
+				continue
+			}
+			vec = append(vec, promql.Sample{Metric: s.Labels(), Point: promql.Point{T: t, V: v}})
+		}
+		if ws := set.Warnings(); len(ws) > 0 {
+			log.Debug("msg", "Federation select returned warnings", "warnings", ws)
+		}
+		if set.Err() != nil {
+			statusCode = "500"
+			log.Error("msg", "Federation error", "err", set.Err())
+			http.Error(w, set.Err().Error(), http.StatusInternalServerError)
+			return
+		}
+		sort.SliceStable(vec, func(i, j int) bool {
+			return vec[i].Metric.Get(labels.MetricName) < vec[j].Metric.Get(labels.MetricName)
+		})
+
+		format := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+		w.Header().Set("Content-Type", string(format))
+		statusCode = "2xx"
+		enc := expfmt.NewEncoder(w, format)
+		for _, family := range metricFamilies(vec) {
+			if err := enc.Encode(family); err != nil {
+				log.Error("msg", "Federation failed", "err", err)
+				return
+			}
+		}
+		if closer, ok := enc.(expfmt.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Error("msg", "Federation failed", "err", err)
+			}
+		}
+	}
+}
+
+// lastSample returns the latest sample of the series.
+func lastSample(s storage.Series) (t int64, v float64, ok bool) {
+	it := s.Iterator()
+	for it.Next() {
+		t, v = it.At()
+		ok = true
+	}
+	return t, v, ok && it.Err() == nil
+}
+
+// metricFamilies groups the samples of vec, sorted by metric name, into
+// untyped metric families. Like in Prometheus an empty instance label is added
+// to series without one, so the scraping server doesn't attach its own.
+func metricFamilies(vec promql.Vector) []*dto.MetricFamily {
+	var (
+		families []*dto.MetricFamily
+		family   *dto.MetricFamily
+	)
+	for _, s := range vec {
+		name := s.Metric.Get(labels.MetricName)
+		if name == "" {
+			log.Warn("msg", "Ignoring nameless metric during federation", "metric", s.Metric)
+			continue
+		}
+		if family == nil || family.GetName() != name {
+			family = &dto.MetricFamily{
+				Name: stringPtr(name),
+				Type: dto.MetricType_UNTYPED.Enum(),
+			}
+			families = append(families, family)
+		}
+		m := &dto.Metric{
+			Untyped:     &dto.Untyped{Value: float64Ptr(s.V)},
+			TimestampMs: int64Ptr(s.T),
+		}
+		hasInstance := false
+		for _, l := range s.Metric {
+			// Empty values are unset labels.
+			if l.Name == labels.MetricName || l.Value == "" {
+				continue
+			}
+			if l.Name == model.InstanceLabel {
+				hasInstance = true
+			}
+			m.Label = append(m.Label, &dto.LabelPair{Name: stringPtr(l.Name), Value: stringPtr(l.Value)})
+		}
+		if !hasInstance {
+			m.Label = append(m.Label, &dto.LabelPair{Name: stringPtr(model.InstanceLabel), Value: stringPtr("")})
+		}
+		family.Metric = append(family.Metric, m)
+	}
+	return families
+}
+
+func stringPtr(s string) *string    { return &s }
+func float64Ptr(f float64) *float64 { return &f }
+func int64Ptr(i int64) *int64       { return &i }
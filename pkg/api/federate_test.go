@@ -0,0 +1,72 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/promql"
+)
+
+func TestFederate(t *testing.T) {
+	storage := promql.NewTestStorage(t)
+	defer storage.Close()
+
+	now := time.Now()
+	ts := timestamp.FromTime(now.Add(-time.Minute))
+	app := storage.Appender(context.Background())
+	for _, s := range []struct {
+		lbls labels.Labels
+		t    int64
+		v    float64
+	}{
+		{labels.FromStrings("__name__", "up", "job", "a"), ts - 30000, 0},
+		{labels.FromStrings("__name__", "up", "job", "a"), ts, 1},
+		{labels.FromStrings("__name__", "up", "job", "b", "instance", "i"), ts, 2},
+		{labels.FromStrings("__name__", "requests", "job", "a"), ts, 3},
+		{labels.FromStrings("__name__", "old", "job", "a"), timestamp.FromTime(now.Add(-time.Hour)), 4},
+	} {
+		_, err := app.Append(0, s.lbls, s.t, s.v)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+
+	handler := federate(5*time.Minute, storage, nil, mockUpdaterForQuery(&mockMetric{}, nil))
+	target := "/federate?" + url.Values{"match[]": {`up`, `{__name__=~"requests|old"}`}}.Encode()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+	expected := fmt.Sprintf(`# TYPE requests untyped
+requests{job="a",instance=""} 3 %[1]d
+# TYPE up untyped
+up{instance="i",job="b"} 2 %[1]d
+up{job="a",instance=""} 1 %[1]d
+`, ts)
+	require.Equal(t, expected, w.Body.String())
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=0.0.1")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Header().Get("Content-Type"), "application/openmetrics-text")
+	require.Contains(t, w.Body.String(), "# TYPE up unknown\n")
+	require.Contains(t, w.Body.String(), "# EOF\n")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/federate?match[]=up{", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,61 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+
+	"github.com/timescale/promscale/pkg/api/parser"
+	"github.com/timescale/promscale/pkg/api/parser/influx"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+)
+
+// InfluxWrite returns an http.Handler ingesting the InfluxDB line protocol sent
+// to the InfluxDB v1 and v2 write endpoints.
+func InfluxWrite(
+	inserter ingestor.DBInserter,
+	dataParser *parser.DefaultParser,
+	updateMetrics func(code string, duration, receivedSamples, receivedMetadata float64),
+) http.Handler {
+	wh := writeHandler{}
+	wh.addStages(
+		influxFormat,
+		decodeGzip,
+		ingest(inserter, dataParser, updateMetrics),
+		influxNoContent,
+	)
+	return wh.handler()
+}
+
+// influxFormat makes the request parsed as line protocol, since InfluxDB
+// clients send it as text/plain or without a Content-Type.
+func influxFormat(_ http.ResponseWriter, r *http.Request) bool {
+	r.Header.Set("Content-Type", influx.MediaType)
+	return true
+}
+
+func decodeGzip(w http.ResponseWriter, r *http.Request) bool {
+	if !strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
+		return true
+	}
+	gz, err := gzip.NewReader(r.Body)
+	if err != nil {
+		invalidRequestError(w, "gzip decode error", err.Error(), metrics)
+		return false
+	}
+	r.Body = &readCloser{
+		reader: gz,
+		closer: r.Body,
+	}
+	return true
+}
+
+// influxNoContent responds like InfluxDB to successful writes.
+func influxNoContent(w http.ResponseWriter, _ *http.Request) bool {
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
@@ -0,0 +1,59 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/timescale/promscale/pkg/api/parser"
+)
+
+func TestInfluxWrite(t *testing.T) {
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	_, err := gz.Write([]byte("cpu,host=a usage=1 1000\n"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	testCases := []struct {
+		name     string
+		target   string
+		body     []byte
+		encoding string
+		code     int
+		series   int
+	}{
+		{name: "v2", target: "/influx/api/v2/write?bucket=b&precision=ms", body: []byte("cpu,host=a usage=1,idle=2 1000"), code: http.StatusNoContent, series: 2},
+		{name: "v1 gzip", target: "/write?db=d&precision=ms", body: gzipped.Bytes(), encoding: "gzip", code: http.StatusNoContent, series: 1},
+		{name: "invalid gzip", target: "/write?db=d", body: []byte("cpu usage=1"), encoding: "gzip", code: http.StatusBadRequest},
+		{name: "invalid line", target: "/write?db=d", body: []byte("cpu"), code: http.StatusBadRequest},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mock := &mockInserter{}
+			handler := InfluxWrite(mock, parser.NewParser(), mockUpdaterForIngest(&mockMetric{}, nil, nil, nil))
+			req := httptest.NewRequest(http.MethodPost, c.target, bytes.NewReader(c.body))
+			// InfluxDB clients send text/plain, which would otherwise be parsed as the Prometheus format.
+			req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+			if c.encoding != "" {
+				req.Header.Set("Content-Encoding", c.encoding)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			require.Equal(t, c.code, w.Code, w.Body.String())
+			require.Len(t, mock.ts, c.series)
+			if c.series > 0 {
+				require.True(t, strings.HasPrefix(mock.ts[0].Labels[0].Value, "cpu_"))
+			}
+		})
+	}
+}
@@ -6,12 +6,13 @@ package api
 
 import (
 	"fmt"
-	"math"
 	"net/http"
 
 	"github.com/NYTimes/gziphandler"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
 	"github.com/timescale/promscale/pkg/promql"
 )
 
@@ -27,15 +28,21 @@ func labelValues(queryable promql.Queryable) http.HandlerFunc {
 			respondError(w, http.StatusBadRequest, fmt.Errorf("invalid label name: %s", name), "bad_data")
 			return
 		}
-		querier, err := queryable.SamplesQuerier(r.Context(), math.MinInt64, math.MaxInt64)
+		params, err := parseLabelsParams(r)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			return
+		}
+		querier, err := queryable.SamplesQuerier(r.Context(), params.mint, params.maxt)
 		if err != nil {
 			respondError(w, http.StatusInternalServerError, err, "internal")
 			return
 		}
 		defer querier.Close()
 
-		var values labelsValue
-		values, warnings, err := querier.LabelValues(name)
+		values, warnings, err := queryLabels(params, func(matchers ...*labels.Matcher) ([]string, storage.Warnings, error) {
+			return querier.LabelValues(name, matchers...)
+		})
 		if err != nil {
 			respondError(w, http.StatusInternalServerError, err, "internal")
 			return
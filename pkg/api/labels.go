@@ -6,14 +6,19 @@ package api
 
 import (
 	"encoding/json"
-	"math"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/NYTimes/gziphandler"
-	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
 	"github.com/prometheus/prometheus/storage"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/promql/parser"
 )
 
 type labelsValue []string
@@ -26,6 +31,85 @@ func (l labelsValue) String() string {
 	return strings.Join(l, "\n")
 }
 
+// labelsParams are the parameters shared by the label names and label values
+// APIs.
+type labelsParams struct {
+	mint, maxt  int64
+	matcherSets [][]*labels.Matcher
+	// limit is the maximum number of returned labels, 0 means no limit.
+	limit int
+}
+
+func parseLabelsParams(r *http.Request) (labelsParams, error) {
+	var params labelsParams
+	if err := r.ParseForm(); err != nil {
+		return params, errors.Wrap(err, "error parsing form values")
+	}
+	start, err := parseTimeParam(r, "start", model.MinTime)
+	if err != nil {
+		return params, err
+	}
+	end, err := parseTimeParam(r, "end", model.MaxTime)
+	if err != nil {
+		return params, err
+	}
+	if end.Before(start) {
+		return params, errors.New("end timestamp must not be before start time")
+	}
+	params.mint, params.maxt = timestamp.FromTime(start), timestamp.FromTime(end)
+
+	for _, s := range r.Form["match[]"] {
+		matchers, err := parser.ParseMetricSelector(s)
+		if err != nil {
+			return params, err
+		}
+		params.matcherSets = append(params.matcherSets, matchers)
+	}
+
+	if s := r.FormValue("limit"); s != "" {
+		params.limit, err = strconv.Atoi(s)
+		if err != nil || params.limit < 0 {
+			return params, errors.Errorf("invalid limit %q, it must be a non-negative integer", s)
+		}
+	}
+	return params, nil
+}
+
+// queryLabels runs the query once for each matcher set, or once without
+// matchers if there are none, and merges the results.
+func queryLabels(params labelsParams, query func(...*labels.Matcher) ([]string, storage.Warnings, error)) (labelsValue, storage.Warnings, error) {
+	if len(params.matcherSets) == 0 {
+		res, warnings, err := query()
+		return truncateLabels(res, params.limit), warnings, err
+	}
+
+	var warnings storage.Warnings
+	set := make(map[string]struct{})
+	for _, matchers := range params.matcherSets {
+		res, w, err := query(matchers...)
+		if err != nil {
+			return nil, nil, err
+		}
+		warnings = append(warnings, w...)
+		for _, s := range res {
+			set[s] = struct{}{}
+		}
+	}
+	res := make([]string, 0, len(set))
+	for s := range set {
+		res = append(res, s)
+	}
+	sort.Strings(res)
+	return truncateLabels(res, params.limit), warnings, nil
+}
+
+func truncateLabels(res []string, limit int) labelsValue {
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
+
 func Labels(conf *Config, queryable promql.Queryable) http.Handler {
 	hf := corsWrapper(conf, labelsHandler(queryable))
 	return gziphandler.GzipHandler(hf)
@@ -33,14 +117,18 @@ func Labels(conf *Config, queryable promql.Queryable) http.Handler {
 
 func labelsHandler(queryable promql.Queryable) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		querier, err := queryable.SamplesQuerier(r.Context(), math.MinInt64, math.MaxInt64)
+		params, err := parseLabelsParams(r)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			return
+		}
+		querier, err := queryable.SamplesQuerier(r.Context(), params.mint, params.maxt)
 		if err != nil {
 			respondError(w, http.StatusInternalServerError, err, "internal")
 			return
 		}
 		defer querier.Close()
-		var names labelsValue
-		names, warnings, err := querier.LabelNames()
+		names, warnings, err := queryLabels(params, querier.LabelNames)
 		if err != nil {
 			respondError(w, http.StatusInternalServerError, err, "internal")
 			return
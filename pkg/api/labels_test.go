@@ -11,10 +11,15 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"testing"
 
+	"github.com/gorilla/mux"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/stretchr/testify/require"
 	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/query"
 )
 
@@ -77,6 +82,116 @@ func TestLabels(t *testing.T) {
 
 }
 
+func TestLabelsParams(t *testing.T) {
+	testCases := []struct {
+		name           string
+		path           string
+		params         url.Values
+		labelsReader   *mockLabelsReader
+		expectCode     int
+		expectData     []string
+		expectMatchers int
+		expectMint     int64
+		expectMaxt     int64
+	}{
+		{
+			name:         "no params uses the labels reader",
+			path:         "/labels",
+			labelsReader: &mockLabelsReader{labelNames: []string{"a", "b"}},
+			expectCode:   http.StatusOK,
+			expectData:   []string{"a", "b"},
+		},
+		{
+			name:         "limit",
+			path:         "/labels",
+			params:       url.Values{"limit": {"1"}},
+			labelsReader: &mockLabelsReader{labelNames: []string{"a", "b"}},
+			expectCode:   http.StatusOK,
+			expectData:   []string{"a"},
+		},
+		{
+			name:           "matchers are merged",
+			path:           "/labels",
+			params:         url.Values{"match[]": {"m1", `{__name__="m2"}`}, "start": {"1"}, "end": {"2"}},
+			expectCode:     http.StatusOK,
+			expectData:     []string{"__name__", "a", "b"},
+			expectMatchers: 2,
+			expectMint:     1000,
+			expectMaxt:     2000,
+		},
+		{
+			name:           "time range without matchers",
+			path:           "/labels",
+			params:         url.Values{"start": {"1"}, "limit": {"2"}},
+			expectCode:     http.StatusOK,
+			expectData:     []string{"__name__", "c"},
+			expectMatchers: 1,
+			expectMint:     1000,
+			expectMaxt:     timestamp.FromTime(model.MaxTime),
+		},
+		{
+			name:           "label values",
+			path:           "/label/job/values",
+			params:         url.Values{"match[]": {"m1"}},
+			expectCode:     http.StatusOK,
+			expectData:     []string{"__name__", "a"},
+			expectMatchers: 1,
+			expectMint:     timestamp.FromTime(model.MinTime),
+			expectMaxt:     timestamp.FromTime(model.MaxTime),
+		},
+		{
+			name:       "invalid matcher",
+			path:       "/labels",
+			params:     url.Values{"match[]": {"{"}},
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid limit",
+			path:       "/label/job/values",
+			params:     url.Values{"limit": {"-1"}},
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			name:       "end before start",
+			path:       "/labels",
+			params:     url.Values{"start": {"2"}, "end": {"1"}},
+			expectCode: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			labelsQuerier := &mockLabelsQuerier{labels: map[string][]string{
+				"m1": {"__name__", "a"},
+				"m2": {"__name__", "b"},
+				"":   {"__name__", "c", "d"},
+			}}
+			queryable := query.NewQueryable(&mockQuerier{labelsQuerier: labelsQuerier}, tc.labelsReader)
+			router := mux.NewRouter()
+			router.Path("/labels").HandlerFunc(labelsHandler(queryable))
+			router.Path("/label/{name}/values").HandlerFunc(labelValues(queryable))
+
+			req := httptest.NewRequest(http.MethodGet, tc.path+"?"+tc.params.Encode(), nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, tc.expectCode, w.Code, w.Body.String())
+			if tc.expectCode != http.StatusOK {
+				return
+			}
+
+			var res struct {
+				Data []string `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			require.Equal(t, tc.expectData, res.Data)
+			require.Len(t, labelsQuerier.matchers, tc.expectMatchers)
+			if tc.expectMatchers > 0 {
+				require.Equal(t, tc.expectMint, labelsQuerier.mint)
+				require.Equal(t, tc.expectMaxt, labelsQuerier.maxt)
+			}
+		})
+	}
+}
+
 func doLabels(t *testing.T, queryHandler http.Handler) *httptest.ResponseRecorder {
 	req, err := http.NewRequestWithContext(context.Background(), "GET", "http://localhost:9090/labels", nil)
 	if err != nil {
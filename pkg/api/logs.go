@@ -0,0 +1,156 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"context"
+	encodinghex "encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NYTimes/gziphandler"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/logs"
+)
+
+const (
+	defaultLogSearchRange = time.Hour
+	defaultLogSearchLimit = 100
+	maxLogSearchLimit     = 5000
+)
+
+// LogSearcher searches the logs stored in the ps_log schema.
+type LogSearcher interface {
+	SearchLogs(ctx context.Context, q *logs.Query) ([]logs.Record, error)
+}
+
+type logResult struct {
+	Time           time.Time              `json:"time"`
+	ObservedTime   time.Time              `json:"observedTime"`
+	TraceID        string                 `json:"traceID,omitempty"`
+	SpanID         string                 `json:"spanID,omitempty"`
+	Flags          uint32                 `json:"flags"`
+	SeverityNumber int                    `json:"severityNumber"`
+	SeverityText   string                 `json:"severityText,omitempty"`
+	Body           string                 `json:"body"`
+	Attributes     map[string]interface{} `json:"attributes"`
+	Resource       map[string]interface{} `json:"resource"`
+	ScopeName      string                 `json:"scopeName,omitempty"`
+	ScopeVersion   string                 `json:"scopeVersion,omitempty"`
+}
+
+func LogSearch(conf *Config, searcher LogSearcher) http.Handler {
+	hf := corsWrapper(conf, logSearch(searcher))
+	return gziphandler.GzipHandler(hf)
+}
+
+func logSearch(searcher LogSearcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseLogQuery(r)
+		if err != nil {
+			log.Info("msg", "Log search bad request:"+err.Error())
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			return
+		}
+		records, err := searcher.SearchLogs(r.Context(), q)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err, "execution")
+			return
+		}
+		results := make([]logResult, 0, len(records))
+		for _, rec := range records {
+			res := logResult{
+				Time:           rec.Time,
+				ObservedTime:   rec.ObservedTime,
+				Flags:          rec.Flags,
+				SeverityNumber: rec.SeverityNumber,
+				SeverityText:   rec.SeverityText,
+				Body:           rec.Body,
+				Attributes:     rec.Attributes,
+				Resource:       rec.Resource,
+				ScopeName:      rec.ScopeName,
+				ScopeVersion:   rec.ScopeVersion,
+			}
+			if !rec.TraceID.IsEmpty() {
+				res.TraceID = rec.TraceID.HexString()
+			}
+			if !rec.SpanID.IsEmpty() {
+				res.SpanID = rec.SpanID.HexString()
+			}
+			results = append(results, res)
+		}
+		respond(w, http.StatusOK, results)
+	}
+}
+
+// parseLogQuery parses the time range from start and end, the maximum number
+// of logs from limit, the minimum severity from severity, the matchers of the
+// resource and log attributes from the repeated resource and attribute
+// parameters, and the trace_id, span_id and body filters.
+func parseLogQuery(r *http.Request) (*logs.Query, error) {
+	var (
+		q   = &logs.Query{Limit: defaultLogSearchLimit}
+		err error
+	)
+	if q.End, err = parseTimeParam(r, "end", time.Now()); err != nil {
+		return nil, err
+	}
+	if q.Start, err = parseTimeParam(r, "start", q.End.Add(-defaultLogSearchRange)); err != nil {
+		return nil, err
+	}
+	if q.End.Before(q.Start) {
+		return nil, errors.New("end timestamp must not be before start time")
+	}
+	if l := r.FormValue("limit"); l != "" {
+		if q.Limit, err = strconv.Atoi(l); err != nil || q.Limit < 1 || q.Limit > maxLogSearchLimit {
+			return nil, errors.Errorf("limit must be an integer between 1 and %d", maxLogSearchLimit)
+		}
+	}
+	if s := r.FormValue("severity"); s != "" {
+		if q.MinSeverity, err = logs.ParseSeverity(s); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range r.Form["resource"] {
+		m, err := logs.ParseMatcher(s)
+		if err != nil {
+			return nil, err
+		}
+		q.ResourceMatchers = append(q.ResourceMatchers, m)
+	}
+	for _, s := range r.Form["attribute"] {
+		m, err := logs.ParseMatcher(s)
+		if err != nil {
+			return nil, err
+		}
+		q.AttributeMatchers = append(q.AttributeMatchers, m)
+	}
+	if s := r.FormValue("trace_id"); s != "" {
+		if q.TraceID, err = parseTempoTraceID(s); err != nil {
+			return nil, err
+		}
+	}
+	if s := r.FormValue("span_id"); s != "" {
+		if q.SpanID, err = parseSpanID(s); err != nil {
+			return nil, err
+		}
+	}
+	q.Body = r.FormValue("body")
+	return q, nil
+}
+
+func parseSpanID(s string) (pcommon.SpanID, error) {
+	var id [8]byte
+	b, err := encodinghex.DecodeString(s)
+	if err != nil || len(b) != len(id) {
+		return pcommon.SpanID(id), errors.Errorf("invalid span ID %q", s)
+	}
+	copy(id[:], b)
+	return pcommon.SpanID(id), nil
+}
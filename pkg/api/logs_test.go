@@ -0,0 +1,171 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/timescale/promscale/pkg/logs"
+)
+
+type mockLogSearcher struct {
+	records []logs.Record
+	err     error
+	query   *logs.Query
+}
+
+func (m *mockLogSearcher) SearchLogs(_ context.Context, q *logs.Query) ([]logs.Record, error) {
+	m.query = q
+	return m.records, m.err
+}
+
+func TestLogSearch(t *testing.T) {
+	logTime := time.Unix(1000, 0).UTC()
+	record := logs.Record{
+		Time:           logTime,
+		ObservedTime:   logTime,
+		TraceID:        pcommon.TraceID([16]byte{1, 2, 3}),
+		SpanID:         pcommon.SpanID([8]byte{4}),
+		SeverityNumber: 17,
+		SeverityText:   "ERROR",
+		Body:           "request failed",
+		Attributes:     map[string]interface{}{"http.route": "/api"},
+		Resource:       map[string]interface{}{"service.name": "api"},
+	}
+	testCases := []struct {
+		name       string
+		params     url.Values
+		searcher   *mockLogSearcher
+		expectCode int
+	}{
+		{
+			name:       "invalid limit",
+			params:     url.Values{"limit": {"0"}},
+			searcher:   &mockLogSearcher{},
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid severity",
+			params:     url.Values{"severity": {"loud"}},
+			searcher:   &mockLogSearcher{},
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid matcher",
+			params:     url.Values{"resource": {"service.name"}},
+			searcher:   &mockLogSearcher{},
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid span ID",
+			params:     url.Values{"span_id": {"04"}},
+			searcher:   &mockLogSearcher{},
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			name:       "search error",
+			params:     url.Values{},
+			searcher:   &mockLogSearcher{err: fmt.Errorf("some error")},
+			expectCode: http.StatusInternalServerError,
+		},
+		{
+			name: "all good",
+			params: url.Values{
+				"start":     {"10"},
+				"end":       {"20"},
+				"limit":     {"5"},
+				"severity":  {"error"},
+				"resource":  {"service.name=api"},
+				"attribute": {"http.route=~/api.*", "http.method!=GET"},
+				"trace_id":  {"01020300000000000000000000000000"},
+				"span_id":   {"0400000000000000"},
+				"body":      {"failed"},
+			},
+			searcher:   &mockLogSearcher{records: []logs.Record{record}},
+			expectCode: http.StatusOK,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/logs?"+tc.params.Encode(), nil)
+			w := httptest.NewRecorder()
+			logSearch(tc.searcher).ServeHTTP(w, req)
+			require.Equal(t, tc.expectCode, w.Code, w.Body.String())
+			if tc.expectCode != http.StatusOK {
+				return
+			}
+
+			q := tc.searcher.query
+			require.True(t, time.Unix(10, 0).Equal(q.Start))
+			require.True(t, time.Unix(20, 0).Equal(q.End))
+			require.Equal(t, 5, q.Limit)
+			require.Equal(t, 17, q.MinSeverity)
+			require.Equal(t, []logs.Matcher{{Key: "service.name", Type: logs.MatchEqual, Value: "api"}}, q.ResourceMatchers)
+			require.Equal(t, []logs.Matcher{
+				{Key: "http.route", Type: logs.MatchRegexp, Value: "/api.*"},
+				{Key: "http.method", Type: logs.MatchNotEqual, Value: "GET"},
+			}, q.AttributeMatchers)
+			require.Equal(t, record.TraceID, q.TraceID)
+			require.Equal(t, record.SpanID, q.SpanID)
+			require.Equal(t, "failed", q.Body)
+
+			var res struct {
+				Status string      `json:"status"`
+				Data   []logResult `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			require.Equal(t, "success", res.Status)
+			require.Equal(t, []logResult{{
+				Time:           logTime,
+				ObservedTime:   logTime,
+				TraceID:        "01020300000000000000000000000000",
+				SpanID:         "0400000000000000",
+				SeverityNumber: 17,
+				SeverityText:   "ERROR",
+				Body:           "request failed",
+				Attributes:     map[string]interface{}{"http.route": "/api"},
+				Resource:       map[string]interface{}{"service.name": "api"},
+			}}, res.Data)
+		})
+	}
+}
+
+func TestOTLPLogWrite(t *testing.T) {
+	logs := plog.NewLogs()
+	record := logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	record.Body().SetStr("request failed")
+	body, err := plogotlp.NewRequestFromLogs(logs).MarshalJSON()
+	require.NoError(t, err)
+
+	mock := &mockTraceInserter{traces: ptrace.NewTraces(), logs: plog.NewLogs()}
+	req := httptest.NewRequest(http.MethodPost, "/v1/logs", bytes.NewReader(body))
+	req.Header.Set("Content-Type", otlpJSON)
+	w := httptest.NewRecorder()
+	OTLPLogWrite(mock).ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, otlpJSON, w.Header().Get("Content-Type"))
+	require.Equal(t, 1, mock.logs.LogRecordCount())
+
+	mock.err = fmt.Errorf("some error")
+	req = httptest.NewRequest(http.MethodPost, "/v1/logs", bytes.NewReader(body))
+	req.Header.Set("Content-Type", otlpJSON)
+	w = httptest.NewRecorder()
+	OTLPLogWrite(mock).ServeHTTP(w, req)
+	require.Equal(t, http.StatusInternalServerError, w.Code, w.Body.String())
+}
@@ -9,12 +9,13 @@ import (
 	"io"
 	"math"
 	"strconv"
+	"time"
 	"unicode/utf8"
 
 	"github.com/prometheus/prometheus/model/labels"
-	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/promql/parser"
 )
 
 func marshalVectorResponse(writer io.Writer, data promql.Vector, warnings []string) error {
@@ -25,10 +26,12 @@ func marshalVectorResponse(writer io.Writer, data promql.Vector, warnings []stri
 	return out.err
 }
 
-func marshalExemplarResponse(writer io.Writer, data []model.ExemplarQueryResult) error {
+// marshalExemplarResponse marshals the exemplars, with the traces they link to
+// if traces isn't nil.
+func marshalExemplarResponse(writer io.Writer, data []model.ExemplarQueryResult, traces [][]*exemplarTrace) error {
 	out := &errorWrapper{writer: writer}
 	marshalCommonHeader(out)
-	marshalExemplarData(out, data)
+	marshalExemplarData(out, data, traces)
 	marshalCommonFooter(out, nil, false)
 	return out.err
 }
@@ -87,13 +90,17 @@ func marshalMatrixData(out *errorWrapper, data promql.Matrix) {
 	out.WriteStrings(`]}`)
 }
 
-func marshalExemplarData(out *errorWrapper, data []model.ExemplarQueryResult) {
+func marshalExemplarData(out *errorWrapper, data []model.ExemplarQueryResult, traces [][]*exemplarTrace) {
 	out.WriteStrings(`[`)
 	for i := range data {
 		resultRow := data[i]
+		var seriesTraces []*exemplarTrace
+		if traces != nil {
+			seriesTraces = traces[i]
+		}
 		out.WriteStrings(`{`)
 		writeExemplarSeriesLabels(out, resultRow.SeriesLabels)
-		writeExemplarData(out, resultRow.Exemplars)
+		writeExemplarData(out, resultRow.Exemplars, seriesTraces)
 		out.WriteStrings(`}`)
 		if i != len(data)-1 {
 			out.WriteStrings(`,`)
@@ -102,7 +109,7 @@ func marshalExemplarData(out *errorWrapper, data []model.ExemplarQueryResult) {
 	out.WriteStrings(`]`)
 }
 
-func writeExemplarData(out *errorWrapper, data []model.ExemplarData) {
+func writeExemplarData(out *errorWrapper, data []model.ExemplarData, traces []*exemplarTrace) {
 	out.WriteStrings(`"exemplars":[`)
 	for i := range data {
 		d := data[i]
@@ -112,6 +119,9 @@ func writeExemplarData(out *errorWrapper, data []model.ExemplarData) {
 		out.writeFloat(d.Value)
 		out.WriteStrings(`","timestamp":`)
 		out.WriteStrings(fmt.Sprintf("%.3f", float64(d.Ts)/1000))
+		if traces != nil && traces[i] != nil {
+			writeExemplarTrace(out, traces[i])
+		}
 		out.WriteStrings(`}`)
 		if i != len(data)-1 {
 			out.WriteStrings(`,`)
@@ -120,6 +130,22 @@ func writeExemplarData(out *errorWrapper, data []model.ExemplarData) {
 	out.WriteStrings(`]`)
 }
 
+func writeExemplarTrace(out *errorWrapper, trace *exemplarTrace) {
+	out.WriteStrings(`,"trace":{"traceID":"`)
+	out.WriteEscapedString(trace.TraceID, true)
+	if !trace.Found {
+		out.WriteStrings(`","found":false}`)
+		return
+	}
+	out.WriteStrings(`","found":true,"rootServiceName":"`)
+	out.WriteEscapedString(trace.RootServiceName, true)
+	out.WriteStrings(`","rootSpanName":"`)
+	out.WriteEscapedString(trace.RootSpanName, true)
+	out.WriteStrings(`","startTime":"`, trace.StartTime.UTC().Format(time.RFC3339Nano), `","durationMs":`)
+	out.writeFloat(durationMs(trace.Duration))
+	out.WriteStrings(`,"spanCount":`, strconv.Itoa(trace.SpanCount), `}`)
+}
+
 func writeExemplarSeriesLabels(out *errorWrapper, seriesLbls labels.Labels) {
 	out.WriteStrings(`"seriesLabels":{`)
 	marshalLabels(out, seriesLbls)
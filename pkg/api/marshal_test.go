@@ -10,8 +10,8 @@ import (
 	"testing"
 
 	"github.com/prometheus/prometheus/model/labels"
-	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/promql/parser"
 )
 
 func TestMarshalVector(t *testing.T) {
@@ -6,9 +6,27 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
 
+	"github.com/timescale/promscale/pkg/log"
+	pgmodelErrs "github.com/timescale/promscale/pkg/pgmodel/common/errors"
 	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	otlpProtobuf = "application/x-protobuf"
+	otlpJSON     = "application/json"
 )
 
 func NewTraceServer(i ingestor.DBInserter) ptraceotlp.GRPCServer {
@@ -21,6 +39,205 @@ type tracesServer struct {
 	ingestor ingestor.DBInserter
 }
 
+// Export ingests the valid spans of the request. The spans that can't be
+// stored, without trace or span ID, are rejected and reported as a partial
+// success instead of failing the whole request.
 func (t *tracesServer) Export(ctx context.Context, tr ptraceotlp.Request) (ptraceotlp.Response, error) {
-	return ptraceotlp.NewResponse(), t.ingestor.IngestTraces(ctx, tr.Traces())
+	traces := tr.Traces()
+	rejected, reason := rejectInvalidSpans(traces)
+	if traces.SpanCount() > 0 {
+		if err := t.ingestor.IngestTraces(ctx, traces); err != nil {
+			return ptraceotlp.NewResponse(), err
+		}
+	}
+	return partialSuccess(rejected, reason)
+}
+
+// rejectInvalidSpans removes the spans without trace or span ID, or with a
+// link without span ID, and returns their number and the first reason.
+func rejectInvalidSpans(traces ptrace.Traces) (rejected int64, reason string) {
+	reject := func(msg string) bool {
+		if rejected == 0 {
+			reason = msg
+		}
+		rejected++
+		return true
+	}
+	traces.ResourceSpans().RemoveIf(func(rs ptrace.ResourceSpans) bool {
+		rs.ScopeSpans().RemoveIf(func(ss ptrace.ScopeSpans) bool {
+			ss.Spans().RemoveIf(func(span ptrace.Span) bool {
+				switch {
+				case span.TraceID().IsEmpty():
+					return reject("span without trace ID")
+				case span.SpanID().IsEmpty():
+					return reject("span without span ID")
+				}
+				for i := 0; i < span.Links().Len(); i++ {
+					if span.Links().At(i).SpanID().IsEmpty() {
+						return reject("span link without span ID")
+					}
+				}
+				return false
+			})
+			return ss.Spans().Len() == 0
+		})
+		return rs.ScopeSpans().Len() == 0
+	})
+	return rejected, reason
+}
+
+// partialSuccess returns a response reporting the rejected spans.
+func partialSuccess(rejected int64, reason string) (ptraceotlp.Response, error) {
+	resp := ptraceotlp.NewResponse()
+	if rejected == 0 {
+		return resp, nil
+	}
+	// The partial success of the response has no setter, it is set through
+	// its JSON encoding instead.
+	b, err := json.Marshal(map[string]interface{}{
+		"partialSuccess": map[string]interface{}{
+			"rejectedSpans": fmt.Sprint(rejected),
+			"errorMessage":  fmt.Sprintf("%d spans rejected: %s", rejected, reason),
+		},
+	})
+	if err != nil {
+		return resp, err
+	}
+	return resp, resp.UnmarshalJSON(b)
+}
+
+func NewLogServer(i ingestor.DBInserter) plogotlp.GRPCServer {
+	return &logsServer{
+		ingestor: i,
+	}
+}
+
+type logsServer struct {
+	ingestor ingestor.DBInserter
+}
+
+func (l *logsServer) Export(ctx context.Context, lr plogotlp.Request) (plogotlp.Response, error) {
+	logs := lr.Logs()
+	if logs.LogRecordCount() == 0 {
+		return plogotlp.NewResponse(), nil
+	}
+	return plogotlp.NewResponse(), l.ingestor.IngestLogs(ctx, logs)
+}
+
+// otlpMessage is implemented by the requests and responses of the OTLP
+// exports of every signal.
+type otlpMessage interface {
+	MarshalProto() ([]byte, error)
+	UnmarshalProto(data []byte) error
+	MarshalJSON() ([]byte, error)
+	UnmarshalJSON(data []byte) error
+}
+
+// OTLPTraceWrite returns an http.Handler ingesting traces sent with OTLP/HTTP
+// to /v1/traces, encoded in protobuf or JSON.
+func OTLPTraceWrite(inserter ingestor.DBInserter) http.Handler {
+	server := &tracesServer{ingestor: inserter}
+	wh := writeHandler{}
+	wh.addStages(
+		decodeGzip,
+		exportOTLP("traces",
+			func() otlpMessage { return ptraceotlp.NewRequest() },
+			func(ctx context.Context, req otlpMessage) (otlpMessage, error) {
+				return server.Export(ctx, req.(ptraceotlp.Request))
+			},
+		),
+	)
+	return wh.handler()
+}
+
+// OTLPLogWrite returns an http.Handler ingesting logs sent with OTLP/HTTP to
+// /v1/logs, encoded in protobuf or JSON.
+func OTLPLogWrite(inserter ingestor.DBInserter) http.Handler {
+	server := &logsServer{ingestor: inserter}
+	wh := writeHandler{}
+	wh.addStages(
+		decodeGzip,
+		exportOTLP("logs",
+			func() otlpMessage { return plogotlp.NewRequest() },
+			func(ctx context.Context, req otlpMessage) (otlpMessage, error) {
+				return server.Export(ctx, req.(plogotlp.Request))
+			},
+		),
+	)
+	return wh.handler()
+}
+
+// exportOTLP decodes a request of the signal, exports it and responds in the
+// encoding of the request.
+func exportOTLP(signal string, newRequest func() otlpMessage, export func(context.Context, otlpMessage) (otlpMessage, error)) writeStage {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != otlpProtobuf && mediaType != otlpJSON {
+			otlpError(w, otlpJSON, http.StatusUnsupportedMediaType, codes.InvalidArgument, fmt.Sprintf("unsupported content type %q", mediaType))
+			return false
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			otlpError(w, mediaType, http.StatusBadRequest, codes.InvalidArgument, fmt.Sprintf("error reading request body: %s", err))
+			return false
+		}
+		req := newRequest()
+		if mediaType == otlpProtobuf {
+			err = req.UnmarshalProto(body)
+		} else {
+			err = req.UnmarshalJSON(body)
+		}
+		if err != nil {
+			otlpError(w, mediaType, http.StatusBadRequest, codes.InvalidArgument, fmt.Sprintf("error decoding OTLP request: %s", err))
+			return false
+		}
+
+		resp, err := export(r.Context(), req)
+		if err != nil {
+			log.Warn("msg", "Error ingesting OTLP "+signal, "err", err)
+			// Clients retry on 503 but not on 500.
+			if pgmodelErrs.IsUnavailable(err) {
+				otlpError(w, mediaType, http.StatusServiceUnavailable, codes.Unavailable, err.Error())
+			} else {
+				otlpError(w, mediaType, http.StatusInternalServerError, codes.Internal, err.Error())
+			}
+			return false
+		}
+		var b []byte
+		if mediaType == otlpProtobuf {
+			b, err = resp.MarshalProto()
+		} else {
+			b, err = resp.MarshalJSON()
+		}
+		if err != nil {
+			otlpError(w, mediaType, http.StatusInternalServerError, codes.Internal, err.Error())
+			return false
+		}
+		w.Header().Set("Content-Type", mediaType)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(b)
+		return true
+	}
+}
+
+// otlpError responds with a google.rpc.Status, as required by OTLP/HTTP.
+func otlpError(w http.ResponseWriter, mediaType string, httpCode int, code codes.Code, msg string) {
+	var (
+		st  = status.New(code, msg).Proto()
+		b   []byte
+		err error
+	)
+	if mediaType == otlpProtobuf {
+		b, err = proto.Marshal(st)
+	} else {
+		mediaType = otlpJSON
+		b, err = protojson.Marshal(st)
+	}
+	if err != nil {
+		http.Error(w, msg, httpCode)
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(httpCode)
+	_, _ = w.Write(b)
 }
@@ -0,0 +1,119 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func otlpTestRequest(invalid bool) ptraceotlp.Request {
+	traces := ptrace.NewTraces()
+	spans := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	span := spans.AppendEmpty()
+	span.SetTraceID(pcommon.TraceID([16]byte{1}))
+	span.SetSpanID(pcommon.SpanID([8]byte{1}))
+	span.SetName("valid")
+	if invalid {
+		span = spans.AppendEmpty()
+		span.SetTraceID(pcommon.TraceID([16]byte{1}))
+		span.SetName("without span ID")
+	}
+	return ptraceotlp.NewRequestFromTraces(traces)
+}
+
+func TestOTLPTraceWrite(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contentType string
+		invalid     bool
+		gzip        bool
+		body        string
+		err         error
+		code        int
+		spans       int
+		rejected    int64
+	}{
+		{name: "protobuf", contentType: otlpProtobuf, code: http.StatusOK, spans: 1},
+		{name: "json", contentType: otlpJSON, code: http.StatusOK, spans: 1},
+		{name: "gzip", contentType: otlpProtobuf, gzip: true, code: http.StatusOK, spans: 1},
+		{name: "partial success", contentType: otlpJSON, invalid: true, code: http.StatusOK, spans: 1, rejected: 1},
+		{name: "unsupported content type", contentType: "text/plain", code: http.StatusUnsupportedMediaType},
+		{name: "invalid body", contentType: otlpJSON, body: "{", code: http.StatusBadRequest},
+		{name: "ingest error", contentType: otlpProtobuf, err: fmt.Errorf("some error"), code: http.StatusInternalServerError, spans: 1},
+		{name: "unavailable", contentType: otlpProtobuf, err: io.ErrUnexpectedEOF, code: http.StatusServiceUnavailable, spans: 1},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			var (
+				body []byte
+				err  error
+			)
+			switch {
+			case c.body != "":
+				body = []byte(c.body)
+			case c.contentType == otlpJSON:
+				body, err = otlpTestRequest(c.invalid).MarshalJSON()
+			default:
+				body, err = otlpTestRequest(c.invalid).MarshalProto()
+			}
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/v1/traces", bytes.NewReader(body))
+			if c.gzip {
+				var buf bytes.Buffer
+				gz := gzip.NewWriter(&buf)
+				_, err = gz.Write(body)
+				require.NoError(t, err)
+				require.NoError(t, gz.Close())
+				req = httptest.NewRequest(http.MethodPost, "/v1/traces", &buf)
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			req.Header.Set("Content-Type", c.contentType)
+
+			mock := &mockTraceInserter{mockInserter: mockInserter{err: c.err}, traces: ptrace.NewTraces()}
+			w := httptest.NewRecorder()
+			OTLPTraceWrite(mock).ServeHTTP(w, req)
+			require.Equal(t, c.code, w.Code, w.Body.String())
+			require.Equal(t, c.spans, mock.traces.SpanCount())
+
+			if c.code != http.StatusOK {
+				if c.contentType == otlpProtobuf {
+					var st spb.Status
+					require.NoError(t, proto.Unmarshal(w.Body.Bytes(), &st))
+					require.NotEmpty(t, st.Message)
+				}
+				return
+			}
+			require.Equal(t, c.contentType, w.Header().Get("Content-Type"))
+			resp := ptraceotlp.NewResponse()
+			if c.contentType == otlpJSON {
+				require.NoError(t, resp.UnmarshalJSON(w.Body.Bytes()))
+			} else {
+				require.NoError(t, resp.UnmarshalProto(w.Body.Bytes()))
+			}
+			out, err := resp.MarshalJSON()
+			require.NoError(t, err)
+			if c.rejected > 0 {
+				require.Contains(t, string(out), fmt.Sprintf(`"rejectedSpans":"%d"`, c.rejected))
+				require.True(t, strings.Contains(string(out), "span without span ID"), string(out))
+			} else {
+				require.NotContains(t, string(out), "rejectedSpans")
+			}
+		})
+	}
+}
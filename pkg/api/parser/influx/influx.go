@@ -0,0 +1,230 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+// Package influx parses the InfluxDB line protocol into Prometheus time-series.
+//
+// Each numeric or boolean field of a line becomes a series named
+// <measurement>_<field>, or <measurement> if the field is named "value", with
+// the tags of the line as labels. A tag named __name__ becomes the
+// exported___name__ label, like conflicting target labels in Prometheus. String
+// fields can't be represented and are skipped.
+package influx
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/util/strutil"
+
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+// MediaType is the format the InfluxDB write endpoints are parsed with,
+// whatever the Content-Type sent by the client.
+const MediaType = "application/x-influxdb-line-protocol"
+
+// exportedLabelPrefix prefixes the tags conflicting with the metric name label.
+const exportedLabelPrefix = "exported_"
+
+var timeProvider = time.Now
+
+// precisions maps the InfluxDB v1 and v2 precision parameter values to their
+// duration.
+var precisions = map[string]time.Duration{
+	"":   time.Nanosecond,
+	"n":  time.Nanosecond,
+	"ns": time.Nanosecond,
+	"u":  time.Microsecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// ParseRequest parses an incoming HTTP request body in the InfluxDB line
+// protocol, with the timestamp precision given by the precision parameter.
+func ParseRequest(r *http.Request, wr *prompb.WriteRequest) error {
+	precision, ok := precisions[r.URL.Query().Get("precision")]
+	if !ok {
+		return fmt.Errorf("invalid precision: %s", r.URL.Query().Get("precision"))
+	}
+	defTime := timeProvider().UnixNano() / int64(time.Millisecond)
+
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		if err := parseLine(string(line), precision, defTime, wr); err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading request body: %w", err)
+	}
+	return nil
+}
+
+// parseLine parses a line formatted as
+// measurement[,tag=value...] field=value[,field=value...] [timestamp]
+func parseLine(line string, precision time.Duration, defTime int64, wr *prompb.WriteRequest) error {
+	key, rest := splitUnescaped(line, ' ')
+	fieldSet, timestamp := splitFields(rest, ' ')
+	if fieldSet == "" {
+		return fmt.Errorf("missing fields")
+	}
+
+	t := defTime
+	if timestamp = strings.TrimSpace(timestamp); timestamp != "" {
+		v, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp %q: %w", timestamp, err)
+		}
+		t = v * int64(precision) / int64(time.Millisecond)
+	}
+
+	measurement, tagSet := splitUnescaped(key, ',')
+	measurement = unescape(measurement)
+	if measurement == "" {
+		return fmt.Errorf("missing measurement")
+	}
+	var tags []prompb.Label
+	for tagSet != "" {
+		var tag string
+		tag, tagSet = splitUnescaped(tagSet, ',')
+		k, v := splitUnescaped(tag, '=')
+		if k == "" || v == "" {
+			return fmt.Errorf("invalid tag %q", tag)
+		}
+		name := sanitize(unescape(k))
+		if name == model.MetricNameLabel {
+			name = exportedLabelPrefix + name
+		}
+		tags = append(tags, prompb.Label{Name: name, Value: unescape(v)})
+	}
+
+	for fieldSet != "" {
+		var field string
+		field, fieldSet = splitFields(fieldSet, ',')
+		k, v := splitUnescaped(field, '=')
+		if k == "" || v == "" {
+			return fmt.Errorf("invalid field %q", field)
+		}
+		value, ok, err := parseFieldValue(v)
+		if err != nil {
+			return fmt.Errorf("field %q: %w", k, err)
+		}
+		if !ok {
+			continue
+		}
+		name := measurement
+		if k = unescape(k); k != "value" {
+			name += "_" + k
+		}
+		labels := make([]prompb.Label, 0, len(tags)+1)
+		labels = append(labels, prompb.Label{Name: model.MetricNameLabel, Value: sanitize(name)})
+		labels = append(labels, tags...)
+		sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+		wr.Timeseries = append(wr.Timeseries, prompb.TimeSeries{
+			Labels:  labels,
+			Samples: []prompb.Sample{{Timestamp: t, Value: value}},
+		})
+	}
+	return nil
+}
+
+// parseFieldValue parses a float, integer, unsigned or boolean field value.
+// It returns false for string values.
+func parseFieldValue(v string) (float64, bool, error) {
+	switch {
+	case v[0] == '"':
+		if len(v) < 2 || v[len(v)-1] != '"' {
+			return 0, false, fmt.Errorf("unterminated string")
+		}
+		return 0, false, nil
+	case v == "t" || v == "T" || v == "true" || v == "True" || v == "TRUE":
+		return 1, true, nil
+	case v == "f" || v == "F" || v == "false" || v == "False" || v == "FALSE":
+		return 0, true, nil
+	case strings.HasSuffix(v, "i"):
+		i, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+		return float64(i), true, err
+	case strings.HasSuffix(v, "u"):
+		u, err := strconv.ParseUint(v[:len(v)-1], 10, 64)
+		return float64(u), true, err
+	default:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, true, err
+	}
+}
+
+// splitUnescaped splits s at the first sep not escaped by a backslash.
+func splitUnescaped(s string, sep byte) (string, string) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			return s[:i], s[i+1:]
+		}
+	}
+	return s, ""
+}
+
+// splitFields splits s at the first unescaped sep outside of a string field
+// value.
+func splitFields(s string, sep byte) (string, string) {
+	inString := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			inString = !inString
+		case sep:
+			if !inString {
+				return s[:i], s[i+1:]
+			}
+		}
+	}
+	return s, ""
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case ',', '=', ' ', '\\':
+				i++
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// sanitize turns s into a valid Prometheus metric or label name.
+func sanitize(s string) string {
+	s = strutil.SanitizeLabelName(s)
+	if s[0] >= '0' && s[0] <= '9' {
+		s = "_" + s
+	}
+	return s
+}
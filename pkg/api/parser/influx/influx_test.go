@@ -0,0 +1,107 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package influx
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+func series(t int64, v float64, lbls ...string) prompb.TimeSeries {
+	ts := prompb.TimeSeries{Samples: []prompb.Sample{{Timestamp: t, Value: v}}}
+	for i := 0; i < len(lbls); i += 2 {
+		ts.Labels = append(ts.Labels, prompb.Label{Name: lbls[i], Value: lbls[i+1]})
+	}
+	return ts
+}
+
+func TestParseRequest(t *testing.T) {
+	now := time.Unix(1000, 0)
+	timeProvider = func() time.Time { return now }
+
+	testCases := []struct {
+		name      string
+		input     string
+		precision string
+		result    []prompb.TimeSeries
+		err       string
+	}{
+		{
+			name: "happy path",
+			input: `# comment
+cpu,host=a,region=eu-west usage_user=1.5,usage_system=2i 1000000000000
+
+mem,host=a used=3u,active=true
+`,
+			result: []prompb.TimeSeries{
+				series(1000000, 1.5, "__name__", "cpu_usage_user", "host", "a", "region", "eu-west"),
+				series(1000000, 2, "__name__", "cpu_usage_system", "host", "a", "region", "eu-west"),
+				series(1000000, 3, "__name__", "mem_used", "host", "a"),
+				series(1000000, 1, "__name__", "mem_active", "host", "a"),
+			},
+		},
+		{
+			name:      "precision",
+			input:     "temp value=21.5 1000",
+			precision: "s",
+			result:    []prompb.TimeSeries{series(1000000, 21.5, "__name__", "temp")},
+		},
+		{
+			name:  "escapes, strings and sanitized names",
+			input: `disk\ io,mount\ point=/var\,log,1dev=sda msg="a, b=c d",read.bytes=10 1000000000000`,
+			result: []prompb.TimeSeries{
+				series(1000000, 10, "_1dev", "sda", "__name__", "disk_io_read_bytes", "mount_point", "/var,log"),
+			},
+		},
+		{
+			name:  "metric name tag",
+			input: `cpu,__name__=x,host=a value=1 1000000000000`,
+			result: []prompb.TimeSeries{
+				series(1000000, 1, "__name__", "cpu", "exported___name__", "x", "host", "a"),
+			},
+		},
+		{
+			name:      "invalid precision",
+			input:     "temp value=1",
+			precision: "d",
+			err:       "invalid precision: d",
+		},
+		{
+			name:  "missing fields",
+			input: "temp,host=a",
+			err:   "line 1: missing fields",
+		},
+		{
+			name:  "invalid value",
+			input: "temp value=abc",
+			err:   `line 1: field "value": strconv.ParseFloat: parsing "abc": invalid syntax`,
+		},
+		{
+			name:  "invalid timestamp",
+			input: "temp value=1 abc",
+			err:   `line 1: invalid timestamp "abc": strconv.ParseInt: parsing "abc": invalid syntax`,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/influx/api/v2/write?precision="+c.precision, strings.NewReader(c.input))
+			wr := &prompb.WriteRequest{}
+			err := ParseRequest(r, wr)
+			if c.err != "" {
+				require.EqualError(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.result, wr.Timeseries)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"mime"
 	"net/http"
 
+	"github.com/timescale/promscale/pkg/api/parser/influx"
 	"github.com/timescale/promscale/pkg/api/parser/json"
 	"github.com/timescale/promscale/pkg/api/parser/protobuf"
 	"github.com/timescale/promscale/pkg/api/parser/text"
@@ -34,6 +35,7 @@ func NewParser() *DefaultParser {
 			"application/json":             json.ParseRequest,
 			"text/plain":                   text.ParseRequest,
 			"application/openmetrics-text": text.ParseRequest,
+			influx.MediaType:               influx.ParseRequest,
 		},
 	}
 }
@@ -49,6 +51,15 @@ func (p *DefaultParser) AddPreprocessor(pre Preprocessor) {
 // ParseRequest runs the correct parser on the format of the request and runs the
 // preprocessors on the payload afterwards.
 func (d DefaultParser) ParseRequest(r *http.Request, req *prompb.WriteRequest) error {
+	if err := d.ParseFormat(r, req); err != nil {
+		return err
+	}
+	return d.Preprocess(r, req)
+}
+
+// ParseFormat runs the correct parser on the format of the request, without
+// running the preprocessors.
+func (d DefaultParser) ParseFormat(r *http.Request, req *prompb.WriteRequest) error {
 	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
 	if err != nil {
 		return fmt.Errorf("parser error: unable to parse format: %w", err)
@@ -61,7 +72,11 @@ func (d DefaultParser) ParseRequest(r *http.Request, req *prompb.WriteRequest) e
 	if err := parser(r, req); err != nil {
 		return fmt.Errorf("parser error: %w", err)
 	}
+	return nil
+}
 
+// Preprocess runs the preprocessors on the payload.
+func (d DefaultParser) Preprocess(r *http.Request, req *prompb.WriteRequest) error {
 	if len(req.Timeseries) == 0 {
 		return nil
 	}
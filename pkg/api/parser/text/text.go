@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/exemplar"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/textparse"
 	"github.com/timescale/promscale/pkg/prompb"
@@ -15,7 +17,26 @@ import (
 
 var timeProvider = time.Now
 
+// seriesKey identifies the series of a metric family with the same labels,
+// ignoring the metric name and the bucket label.
+type seriesKey struct {
+	family string
+	hash   uint64
+}
+
+// createdSuffixes are the suffixes of the series of counters, histograms and
+// summaries that start from zero at their created timestamp.
+var createdSuffixes = map[textparse.MetricType][]string{
+	textparse.MetricTypeCounter:   {"_total"},
+	textparse.MetricTypeHistogram: {"_bucket", "_count", "_sum"},
+	textparse.MetricTypeSummary:   {"_count", "_sum"},
+}
+
 // ParseRequest parses an incoming HTTP request as a Prometheus text format.
+// The HELP, TYPE and UNIT of the metric families are added to the metadata of
+// the write request, and the exemplars to their series. The OpenMetrics
+// _created series are not ingested: a zero sample is added at the created
+// timestamp to the series of the counters, histograms and summaries instead.
 func ParseRequest(r *http.Request, wr *prompb.WriteRequest) error {
 	b, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -25,7 +46,23 @@ func ParseRequest(r *http.Request, wr *prompb.WriteRequest) error {
 	var (
 		et      textparse.Entry
 		defTime = int64(model.TimeFromUnixNano(timeProvider().UnixNano()))
+
+		metadata   = make(map[string]int)
+		family     string
+		familyType textparse.MetricType
+		created    = make(map[seriesKey]int64)
+		startsAt   = make(map[int]seriesKey)
+		hashBuf    []byte
 	)
+	getMetadata := func(name []byte) *prompb.MetricMetadata {
+		i, ok := metadata[string(name)]
+		if !ok {
+			i = len(wr.Metadata)
+			metadata[string(name)] = i
+			wr.Metadata = append(wr.Metadata, prompb.MetricMetadata{MetricFamilyName: string(name)})
+		}
+		return &wr.Metadata[i]
+	}
 
 	p, err := textparse.New(b, r.Header.Get("Content-Type"))
 	if err != nil {
@@ -41,10 +78,20 @@ func ParseRequest(r *http.Request, wr *prompb.WriteRequest) error {
 		}
 
 		switch et {
-		case textparse.EntryType,
-			textparse.EntryHelp,
-			textparse.EntryUnit,
-			textparse.EntryComment:
+		case textparse.EntryType:
+			name, typ := p.Type()
+			getMetadata(name).Type = metricType(typ)
+			family, familyType = string(name), typ
+			continue
+		case textparse.EntryHelp:
+			name, help := p.Help()
+			getMetadata(name).Help = string(help)
+			continue
+		case textparse.EntryUnit:
+			name, unit := p.Unit()
+			getMetadata(name).Unit = string(unit)
+			continue
+		case textparse.EntryComment:
 			continue
 		default:
 		}
@@ -58,18 +105,60 @@ func ParseRequest(r *http.Request, wr *prompb.WriteRequest) error {
 		var lset labels.Labels
 		_ = p.Metric(&lset)
 
-		ll := util.LabelToPrompbLabels(lset)
+		suffixes, hasCreated := createdSuffixes[familyType]
+		if hasCreated {
+			name := lset.Get(labels.MetricName)
+			var key seriesKey
+			key.family = family
+			key.hash, hashBuf = lset.HashWithoutLabels(hashBuf, labels.BucketLabel)
+			if name == family+"_created" {
+				created[key] = int64(v * 1000)
+				continue
+			}
+			for _, suffix := range suffixes {
+				if name == family+suffix {
+					startsAt[len(wr.Timeseries)] = key
+				}
+			}
+		}
 
-		wr.Timeseries = append(wr.Timeseries, prompb.TimeSeries{
-			Labels: ll,
+		ts := prompb.TimeSeries{
+			Labels: util.LabelToPrompbLabels(lset),
 			Samples: []prompb.Sample{
 				{
 					Timestamp: t,
 					Value:     v,
 				},
 			},
-		})
+		}
+		var e exemplar.Exemplar
+		if p.Exemplar(&e) {
+			if !e.HasTs {
+				e.Ts = t
+			}
+			ts.Exemplars = append(ts.Exemplars, prompb.Exemplar{
+				Labels:    util.LabelToPrompbLabels(e.Labels),
+				Value:     e.Value,
+				Timestamp: e.Ts,
+			})
+		}
+		wr.Timeseries = append(wr.Timeseries, ts)
+	}
+
+	for i, key := range startsAt {
+		ct, ok := created[key]
+		if !ok || ct >= wr.Timeseries[i].Samples[0].Timestamp {
+			continue
+		}
+		wr.Timeseries[i].Samples = append([]prompb.Sample{{Timestamp: ct, Value: 0}}, wr.Timeseries[i].Samples...)
 	}
 
 	return nil
 }
+
+func metricType(t textparse.MetricType) prompb.MetricMetadata_MetricType {
+	if v, ok := prompb.MetricMetadata_MetricType_value[strings.ToUpper(string(t))]; ok {
+		return prompb.MetricMetadata_MetricType(v)
+	}
+	return prompb.MetricMetadata_UNKNOWN
+}
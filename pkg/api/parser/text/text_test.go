@@ -28,6 +28,7 @@ func TestDBIngestorParseTextData(t *testing.T) {
 	testCases := []struct {
 		name        string
 		input       string
+		contentType string
 		result      prompb.WriteRequest
 		readerError bool
 		err         string
@@ -63,6 +64,20 @@ _metric_starting_with_underscore 1`,
 						},
 					},
 				},
+				Metadata: []prompb.MetricMetadata{
+					{
+						Type:             prompb.MetricMetadata_SUMMARY,
+						MetricFamilyName: "go_gc_duration_seconds",
+						Help:             "A summary of the GC invocation durations.",
+					},
+					{MetricFamilyName: "nohelp1"},
+					{MetricFamilyName: "nohelp2"},
+					{
+						Type:             prompb.MetricMetadata_GAUGE,
+						MetricFamilyName: "go_goroutines",
+						Help:             "Number of goroutines that currently exist.",
+					},
+				},
 			},
 		},
 		{
@@ -197,6 +212,52 @@ bat{custom_label="foo"} 4`,
 				},
 			},
 		},
+		{
+			name:        "open metrics metadata, exemplars and created timestamps",
+			contentType: "application/openmetrics-text; version=1.0.0; charset=utf-8",
+			input: `# HELP jobs Processed jobs.
+# TYPE jobs counter
+jobs_total{queue="a"} 10 20.000 # {trace_id="abc"} 1 15.000
+jobs_created{queue="a"} 5.000 20.000
+# TYPE job_duration_seconds histogram
+# UNIT job_duration_seconds seconds
+job_duration_seconds_bucket{le="1"} 1 20.000 # {trace_id="def"} 0.5
+job_duration_seconds_bucket{le="+Inf"} 2 20.000
+job_duration_seconds_count 2 20.000
+job_duration_seconds_sum 3 20.000
+job_duration_seconds_created 30.000 20.000
+# EOF`,
+			result: prompb.WriteRequest{
+				Timeseries: []prompb.TimeSeries{
+					{
+						Labels:    []prompb.Label{{Name: model.MetricNameLabelName, Value: "jobs_total"}, {Name: "queue", Value: "a"}},
+						Samples:   []prompb.Sample{{Timestamp: 5000, Value: 0}, {Timestamp: 20000, Value: 10}},
+						Exemplars: []prompb.Exemplar{{Labels: []prompb.Label{{Name: "trace_id", Value: "abc"}}, Value: 1, Timestamp: 15000}},
+					},
+					{
+						Labels:    []prompb.Label{{Name: model.MetricNameLabelName, Value: "job_duration_seconds_bucket"}, {Name: "le", Value: "1"}},
+						Samples:   []prompb.Sample{{Timestamp: 20000, Value: 1}},
+						Exemplars: []prompb.Exemplar{{Labels: []prompb.Label{{Name: "trace_id", Value: "def"}}, Value: 0.5, Timestamp: 20000}},
+					},
+					{
+						Labels:  []prompb.Label{{Name: model.MetricNameLabelName, Value: "job_duration_seconds_bucket"}, {Name: "le", Value: "+Inf"}},
+						Samples: []prompb.Sample{{Timestamp: 20000, Value: 2}},
+					},
+					{
+						Labels:  []prompb.Label{{Name: model.MetricNameLabelName, Value: "job_duration_seconds_count"}},
+						Samples: []prompb.Sample{{Timestamp: 20000, Value: 2}},
+					},
+					{
+						Labels:  []prompb.Label{{Name: model.MetricNameLabelName, Value: "job_duration_seconds_sum"}},
+						Samples: []prompb.Sample{{Timestamp: 20000, Value: 3}},
+					},
+				},
+				Metadata: []prompb.MetricMetadata{
+					{Type: prompb.MetricMetadata_COUNTER, MetricFamilyName: "jobs", Help: "Processed jobs."},
+					{Type: prompb.MetricMetadata_HISTOGRAM, MetricFamilyName: "job_duration_seconds", Unit: "seconds"},
+				},
+			},
+		},
 		{
 			name:        "body reader error",
 			readerError: true,
@@ -211,6 +272,7 @@ bat{custom_label="foo"} 4`,
 				r = &errorReader{}
 			}
 			req, _ := http.NewRequest("", "", r)
+			req.Header.Set("Content-Type", c.contentType)
 			wr := ingestor.NewWriteRequest()
 			defer ingestor.FinishWriteRequest(wr)
 
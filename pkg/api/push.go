@@ -0,0 +1,471 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/model/value"
+
+	"github.com/timescale/promscale/pkg/api/parser"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/prompb"
+	"github.com/timescale/promscale/pkg/tracer"
+)
+
+const (
+	pushPathPrefix = "/metrics/"
+	pushTimeMetric = "push_time_seconds"
+	base64Suffix   = "@base64"
+)
+
+// errTooManyPushGroups is returned when a push would create a group beyond
+// the maximum number of groups.
+var errTooManyPushGroups = errors.New("too many push groups, delete the unused ones or increase metrics.push.max-groups")
+
+// pushedSeries is a series last pushed to a group.
+type pushedSeries struct {
+	labels []prompb.Label
+	value  float64
+}
+
+// pushGroup holds the series last pushed to a grouping key, by metric name
+// and labels, to write staleness markers for them once they are replaced or
+// deleted, and to refresh their values.
+type pushGroup struct {
+	mu     sync.Mutex
+	series map[string]map[string]pushedSeries
+	// request is the last push without its body. The preprocessors get it
+	// when the series are refreshed.
+	request *http.Request
+	// deleted is set once the group is removed from the handler.
+	deleted bool
+}
+
+type pushHandler struct {
+	inserter      ingestor.DBInserter
+	dataParser    *parser.DefaultParser
+	updateMetrics func(code string, duration, receivedSamples, receivedMetadata float64)
+	maxGroups     int
+
+	mu     sync.Mutex
+	groups map[string]*pushGroup
+}
+
+// Push returns an http.Handler implementing the Pushgateway push API:
+// PUT /metrics/job/{job}{/label/value...} replaces all the metrics of the
+// group, POST replaces the metrics with the same names and DELETE deletes the
+// metrics of the group, by writing staleness markers for the series pushed
+// before. The last pushed values are written again every refresh interval,
+// like a Pushgateway exposes them to every scrape, so they don't go stale. The
+// groups are kept in memory, so the series pushed before a restart are
+// neither refreshed nor marked stale.
+func Push(
+	inserter ingestor.DBInserter,
+	dataParser *parser.DefaultParser,
+	updateMetrics func(code string, duration, receivedSamples, receivedMetadata float64),
+	refreshInterval time.Duration,
+	maxGroups int,
+) http.Handler {
+	h := &pushHandler{
+		inserter:      inserter,
+		dataParser:    dataParser,
+		updateMetrics: updateMetrics,
+		maxGroups:     maxGroups,
+		groups:        make(map[string]*pushGroup),
+	}
+	if refreshInterval > 0 {
+		go h.refreshEvery(refreshInterval)
+	}
+	return h
+}
+
+// lockGroup returns the locked group of the grouping key, created if needed.
+// Unless it is for a deletion, the group isn't created beyond the maximum
+// number of groups.
+func (h *pushHandler) lockGroup(key string, deletion bool) (*pushGroup, error) {
+	for {
+		g, err := h.getGroup(key, deletion)
+		if err != nil {
+			return nil, err
+		}
+		g.mu.Lock()
+		if !g.deleted {
+			return g, nil
+		}
+		// Deleted while waiting for the lock, the key gets a new group.
+		g.mu.Unlock()
+	}
+}
+
+func (h *pushHandler) getGroup(key string, deletion bool) (*pushGroup, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	g, ok := h.groups[key]
+	if !ok {
+		if !deletion && h.maxGroups > 0 && len(h.groups) >= h.maxGroups {
+			return nil, errTooManyPushGroups
+		}
+		g = &pushGroup{series: make(map[string]map[string]pushedSeries)}
+		h.groups[key] = g
+	}
+	return g, nil
+}
+
+// deleteGroup removes the locked group of the grouping key.
+func (h *pushHandler) deleteGroup(key string, g *pushGroup) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.groups, key)
+	g.deleted = true
+}
+
+func (h *pushHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var (
+		begin               = time.Now()
+		statusCode          = "400"
+		numSamplesReceived  int
+		numMetadataReceived int
+	)
+	defer func() {
+		h.updateMetrics(statusCode, time.Since(begin).Seconds(), float64(numSamplesReceived), float64(numMetadataReceived))
+	}()
+	ctx, span := tracer.Default().Start(r.Context(), "push")
+	defer span.End()
+
+	groupLabels, err := parseGroupingKey(r.URL.EscapedPath())
+	if err != nil {
+		invalidRequestError(w, "invalid grouping key", err.Error(), metrics)
+		return
+	}
+	groupKey := labelsKey(groupLabels)
+	group, err := h.lockGroup(groupKey, r.Method == http.MethodDelete)
+	if err != nil {
+		statusCode = "429"
+		log.Warn("msg", "Rejecting push", "err", err)
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	defer group.mu.Unlock()
+
+	var (
+		now      = timestamp.FromTime(begin)
+		req      = ingestor.NewWriteRequest()
+		pushTime = append([]prompb.Label{{Name: model.MetricNameLabel, Value: pushTimeMetric}}, groupLabels...)
+		pushed   map[string]map[string]pushedSeries
+	)
+	sortLabels(pushTime)
+	if r.Method == http.MethodDelete {
+		req.Timeseries = group.staleMarkers(func(string) bool { return true }, nil, now)
+		if _, ok := group.series[pushTimeMetric]; !ok {
+			req.Timeseries = append(req.Timeseries, staleMarker(pushTime, now))
+		}
+	} else {
+		if err = pushFormat(r); err == nil {
+			err = h.dataParser.ParseFormat(r, req)
+		}
+		if err == nil {
+			err = applyGroupingKey(req.Timeseries, groupLabels)
+		}
+		if err != nil {
+			ingestor.FinishWriteRequest(req)
+			invalidRequestError(w, "push parser error", err.Error(), metrics)
+			return
+		}
+		// The push time and staleness markers aren't received samples.
+		numSamplesReceived = getTotalSamples(req)
+		numMetadataReceived = len(req.Metadata)
+		req.Timeseries = append(req.Timeseries, prompb.TimeSeries{
+			Labels:  pushTime,
+			Samples: []prompb.Sample{{Timestamp: now, Value: float64(now) / 1000}},
+		})
+		pushed = seriesByName(req.Timeseries)
+		// PUT replaces all the metrics of the group, POST only the ones pushed.
+		replaced := func(string) bool { return true }
+		if r.Method == http.MethodPost {
+			replaced = func(name string) bool { return pushed[name] != nil }
+		}
+		req.Timeseries = append(req.Timeseries, group.staleMarkers(replaced, pushed, now)...)
+	}
+
+	if err = h.dataParser.Preprocess(r, req); err != nil {
+		ingestor.FinishWriteRequest(req)
+		invalidRequestError(w, "push preprocessor error", err.Error(), metrics)
+		return
+	}
+	if len(req.Timeseries) == 0 && len(req.Metadata) == 0 {
+		ingestor.FinishWriteRequest(req)
+	} else if numSamples, _, err := h.inserter.IngestMetrics(ctx, req); err != nil {
+		statusCode = "500"
+		log.Warn("msg", "Error sending pushed samples to remote storage", "err", err, "num_samples", numSamples)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodDelete:
+		h.deleteGroup(groupKey, group)
+	case http.MethodPut:
+		group.series = pushed
+	default:
+		for name, series := range pushed {
+			group.series[name] = series
+		}
+	}
+	if r.Method != http.MethodDelete {
+		group.request = r.Clone(context.Background())
+		group.request.Body = http.NoBody
+	}
+	statusCode = "2xx"
+	if r.Method == http.MethodDelete {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// staleMarkers returns staleness markers for the series of the replaced
+// metrics that are not pushed again.
+func (g *pushGroup) staleMarkers(replaced func(name string) bool, pushed map[string]map[string]pushedSeries, now int64) []prompb.TimeSeries {
+	var markers []prompb.TimeSeries
+	for name, series := range g.series {
+		if !replaced(name) {
+			continue
+		}
+		for key, s := range series {
+			if _, ok := pushed[name][key]; ok {
+				continue
+			}
+			markers = append(markers, staleMarker(s.labels, now))
+		}
+	}
+	return markers
+}
+
+func (h *pushHandler) refreshEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		h.refresh(ctx, now)
+		cancel()
+	}
+}
+
+// refresh writes the last pushed values of the groups again at now.
+func (h *pushHandler) refresh(ctx context.Context, now time.Time) {
+	h.mu.Lock()
+	groups := make([]*pushGroup, 0, len(h.groups))
+	for _, g := range h.groups {
+		groups = append(groups, g)
+	}
+	h.mu.Unlock()
+	for _, g := range groups {
+		if err := h.refreshGroup(ctx, g, timestamp.FromTime(now)); err != nil {
+			log.Warn("msg", "Error refreshing pushed samples", "err", err)
+		}
+	}
+}
+
+func (h *pushHandler) refreshGroup(ctx context.Context, g *pushGroup, now int64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.deleted || g.request == nil {
+		return nil
+	}
+	req := ingestor.NewWriteRequest()
+	for _, series := range g.series {
+		for _, s := range series {
+			req.Timeseries = append(req.Timeseries, prompb.TimeSeries{
+				Labels:  append([]prompb.Label(nil), s.labels...),
+				Samples: []prompb.Sample{{Timestamp: now, Value: s.value}},
+			})
+		}
+	}
+	if err := h.dataParser.Preprocess(g.request, req); err != nil {
+		ingestor.FinishWriteRequest(req)
+		return err
+	}
+	if len(req.Timeseries) == 0 {
+		ingestor.FinishWriteRequest(req)
+		return nil
+	}
+	_, _, err := h.inserter.IngestMetrics(ctx, req)
+	return err
+}
+
+func staleMarker(lbls []prompb.Label, now int64) prompb.TimeSeries {
+	return prompb.TimeSeries{
+		Labels:  append([]prompb.Label(nil), lbls...),
+		Samples: []prompb.Sample{{Timestamp: now, Value: math.Float64frombits(value.StaleNaN)}},
+	}
+}
+
+// seriesByName copies the labels and last value of the series, by metric name
+// and labels, before the preprocessors change them.
+func seriesByName(ts []prompb.TimeSeries) map[string]map[string]pushedSeries {
+	result := make(map[string]map[string]pushedSeries)
+	for i := range ts {
+		var name string
+		for _, l := range ts[i].Labels {
+			if l.Name == model.MetricNameLabel {
+				name = l.Value
+			}
+		}
+		if result[name] == nil {
+			result[name] = make(map[string]pushedSeries)
+		}
+		s := pushedSeries{labels: append([]prompb.Label(nil), ts[i].Labels...)}
+		if n := len(ts[i].Samples); n > 0 {
+			s.value = ts[i].Samples[n-1].Value
+		}
+		result[name][labelsKey(ts[i].Labels)] = s
+	}
+	return result
+}
+
+// applyGroupingKey adds the labels of the grouping key to the series. Like the
+// Pushgateway, it rejects the series with a different value for them.
+func applyGroupingKey(ts []prompb.TimeSeries, groupLabels []prompb.Label) error {
+	for i := range ts {
+	groupLabel:
+		for _, gl := range groupLabels {
+			for _, l := range ts[i].Labels {
+				if l.Name != gl.Name {
+					continue
+				}
+				if l.Value != gl.Value {
+					return fmt.Errorf("pushed series %s has label %s=%q conflicting with the grouping key", labelsKey(ts[i].Labels), l.Name, l.Value)
+				}
+				continue groupLabel
+			}
+			ts[i].Labels = append(ts[i].Labels, gl)
+		}
+		sortLabels(ts[i].Labels)
+	}
+	return nil
+}
+
+// parseGroupingKey parses the grouping key of a /metrics/job/{job}{/label/value...}
+// path. A label name with the @base64 suffix has a base64url encoded value.
+func parseGroupingKey(path string) ([]prompb.Label, error) {
+	if !strings.HasPrefix(path, pushPathPrefix) {
+		return nil, fmt.Errorf("invalid path %q", path)
+	}
+	parts := strings.Split(strings.TrimPrefix(path, pushPathPrefix), "/")
+	if len(parts)%2 != 0 {
+		return nil, fmt.Errorf("missing value for label %q", parts[len(parts)-1])
+	}
+	var (
+		result = make([]prompb.Label, 0, len(parts)/2)
+		seen   = make(map[string]struct{}, len(parts)/2)
+	)
+	for i := 0; i < len(parts); i += 2 {
+		name, err := url.PathUnescape(parts[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid label name %q: %w", parts[i], err)
+		}
+		val, err := url.PathUnescape(parts[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for label %q: %w", name, err)
+		}
+		if strings.HasSuffix(name, base64Suffix) {
+			name = strings.TrimSuffix(name, base64Suffix)
+			b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(val, "="))
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 value for label %q: %w", name, err)
+			}
+			val = string(b)
+		}
+		if i == 0 && name != "job" {
+			return nil, fmt.Errorf("the grouping key must start with the job label")
+		}
+		if !model.LabelName(name).IsValid() {
+			return nil, fmt.Errorf("invalid label name %q", name)
+		}
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("duplicate label %q", name)
+		}
+		seen[name] = struct{}{}
+		result = append(result, prompb.Label{Name: name, Value: val})
+	}
+	if result[0].Value == "" {
+		return nil, fmt.Errorf("the job label must not be empty")
+	}
+	sortLabels(result)
+	return result, nil
+}
+
+// pushFormat makes the request parsed as the text format when it has no
+// Content-Type, and converts the delimited protobuf format sent by the Go
+// client to the text format.
+func pushFormat(r *http.Request) error {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		r.Header.Set("Content-Type", "text/plain")
+		return nil
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != expfmt.ProtoType || params["encoding"] != "delimited" {
+		return nil
+	}
+	var (
+		buf bytes.Buffer
+		dec = expfmt.NewDecoder(r.Body, expfmt.FmtProtoDelim)
+	)
+	for {
+		var mf dto.MetricFamily
+		if err := dec.Decode(&mf); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("error decoding protobuf metric families: %w", err)
+		}
+		if _, err := expfmt.MetricFamilyToText(&buf, &mf); err != nil {
+			return fmt.Errorf("error converting protobuf metric family %s: %w", mf.GetName(), err)
+		}
+	}
+	r.Body = io.NopCloser(&buf)
+	r.Header.Set("Content-Type", "text/plain")
+	return nil
+}
+
+func sortLabels(lbls []prompb.Label) {
+	sort.Slice(lbls, func(i, j int) bool { return lbls[i].Name < lbls[j].Name })
+}
+
+// labelsKey returns a string identifying sorted labels.
+func labelsKey(lbls []prompb.Label) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, l := range lbls {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l.Name)
+		b.WriteByte('=')
+		b.WriteString(fmt.Sprintf("%q", l.Value))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
@@ -0,0 +1,239 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/timescale/promscale/pkg/api/parser"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+func TestParseGroupingKey(t *testing.T) {
+	testCases := []struct {
+		path   string
+		result string
+		err    string
+	}{
+		{path: "/metrics/job/backup", result: `{job="backup"}`},
+		{path: "/metrics/job/backup/instance/db-1/env/prod", result: `{env="prod",instance="db-1",job="backup"}`},
+		{path: "/metrics/job@base64/YS9i/path@base64/", result: `{job="a/b",path=""}`},
+		{path: "/metrics/job/a%20b", result: `{job="a b"}`},
+		{path: "/metrics/job/backup/instance", err: `missing value for label "instance"`},
+		{path: "/metrics/jobs/backup", err: "the grouping key must start with the job label"},
+		{path: "/metrics/job/", err: "the job label must not be empty"},
+		{path: "/metrics/job/a/1x/b", err: `invalid label name "1x"`},
+		{path: "/metrics/job/a/job/b", err: `duplicate label "job"`},
+	}
+	for _, c := range testCases {
+		t.Run(c.path, func(t *testing.T) {
+			lbls, err := parseGroupingKey(c.path)
+			if c.err != "" {
+				require.EqualError(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.result, labelsKey(lbls))
+		})
+	}
+}
+
+// ingestedValues returns the series ingested by the last request, as keys
+// mapped to their last value.
+func ingestedValues(mock *mockInserter) map[string]float64 {
+	result := make(map[string]float64)
+	for _, ts := range mock.ts {
+		result[labelsKey(ts.Labels)] = ts.Samples[len(ts.Samples)-1].Value
+	}
+	return result
+}
+
+func staleKeys(series map[string]float64) []string {
+	var keys []string
+	for k, v := range series {
+		if value.IsStaleNaN(v) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestPush(t *testing.T) {
+	mock := &mockInserter{}
+	received := &mockMetric{}
+	handler := Push(mock, parser.NewParser(), mockUpdaterForIngest(&mockMetric{}, nil, received, nil), 0, 0).(*pushHandler)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mock.ts = nil
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPut, "/metrics/job/backup/instance/db", "# TYPE last_success gauge\nlast_success 10\nrows{table=\"a\"} 1\nrows{table=\"b\"} 2\n")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	series := ingestedValues(mock)
+	require.Len(t, series, 4)
+	require.Equal(t, 10.0, series[`{__name__="last_success",instance="db",job="backup"}`])
+	require.Contains(t, series, `{__name__="push_time_seconds",instance="db",job="backup"}`)
+	require.Empty(t, staleKeys(series))
+
+	// POST only replaces the pushed metrics.
+	w = do(http.MethodPost, "/metrics/job/backup/instance/db", "rows{table=\"a\"} 3\n")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	series = ingestedValues(mock)
+	require.Equal(t, 3.0, series[`{__name__="rows",instance="db",job="backup",table="a"}`])
+	require.Equal(t, []string{`{__name__="rows",instance="db",job="backup",table="b"}`}, staleKeys(series))
+	// Only the samples of the body are received.
+	require.Equal(t, 1.0, received.value)
+
+	// PUT replaces all the metrics of the group.
+	w = do(http.MethodPut, "/metrics/job/backup/instance/db", "rows{table=\"c\"} 4\n")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, []string{
+		`{__name__="last_success",instance="db",job="backup"}`,
+		`{__name__="rows",instance="db",job="backup",table="a"}`,
+	}, staleKeys(ingestedValues(mock)))
+
+	// Other groups are not affected.
+	w = do(http.MethodPut, "/metrics/job/backup/instance/other", "rows 5\n")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Empty(t, staleKeys(ingestedValues(mock)))
+
+	w = do(http.MethodDelete, "/metrics/job/backup/instance/db", "")
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	require.Equal(t, []string{
+		`{__name__="push_time_seconds",instance="db",job="backup"}`,
+		`{__name__="rows",instance="db",job="backup",table="c"}`,
+	}, staleKeys(ingestedValues(mock)))
+	require.Equal(t, 0.0, received.value)
+	require.Len(t, handler.groups, 1)
+	require.NotContains(t, handler.groups, `{instance="db",job="backup"}`)
+
+	// Deleting an unknown group marks its push time stale.
+	w = do(http.MethodDelete, "/metrics/job/unknown", "")
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	require.Equal(t, []string{`{__name__="push_time_seconds",job="unknown"}`}, staleKeys(ingestedValues(mock)))
+	require.Len(t, handler.groups, 1)
+
+	w = do(http.MethodPut, "/metrics/job/backup", "rows{job=\"other\"} 1\n")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "conflicting with the grouping key")
+
+	w = do(http.MethodPut, "/metrics/job/backup/instance", "rows 1\n")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPushLockDeletedGroup(t *testing.T) {
+	h := Push(&mockInserter{}, parser.NewParser(), nil, 0, 0).(*pushHandler)
+	g, err := h.lockGroup("a", false)
+	require.NoError(t, err)
+	locked := make(chan *pushGroup)
+	go func() {
+		g, _ := h.lockGroup("a", false)
+		locked <- g
+	}()
+
+	// A request waiting for a deleted group gets a new one.
+	h.deleteGroup("a", g)
+	g.mu.Unlock()
+	next := <-locked
+	require.NotSame(t, g, next)
+	require.False(t, next.deleted)
+	require.Same(t, next, h.groups["a"])
+	next.mu.Unlock()
+}
+
+// headerLabel adds the value of a request header as a label, like the tenancy
+// write authorizer.
+type headerLabel string
+
+func (h headerLabel) Process(r *http.Request, wr *prompb.WriteRequest) error {
+	for i := range wr.Timeseries {
+		wr.Timeseries[i].Labels = append(wr.Timeseries[i].Labels, prompb.Label{Name: string(h), Value: r.Header.Get(string(h))})
+		sortLabels(wr.Timeseries[i].Labels)
+	}
+	return nil
+}
+
+func TestPushRefresh(t *testing.T) {
+	mock := &mockInserter{}
+	dataParser := parser.NewParser()
+	dataParser.AddPreprocessor(headerLabel("tenant"))
+	handler := Push(mock, dataParser, mockUpdaterForIngest(&mockMetric{}, nil, nil, nil), 0, 2).(*pushHandler)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("tenant", "t1")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPut, "/metrics/job/a", "m 1\nm 3\n")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = do(http.MethodPut, "/metrics/job/b", "m 2\n")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// New groups are rejected beyond the maximum, deletions aren't.
+	w = do(http.MethodPut, "/metrics/job/c", "m 4\n")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	w = do(http.MethodDelete, "/metrics/job/c", "")
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	w = do(http.MethodDelete, "/metrics/job/b", "")
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	require.Len(t, handler.groups, 1)
+
+	// The last pushed values are written again with the labels of the
+	// preprocessors.
+	mock.ts = nil
+	refreshTime := time.Unix(1000, 0)
+	handler.refresh(context.Background(), refreshTime)
+	series := ingestedValues(mock)
+	require.Len(t, series, 2)
+	require.Equal(t, 3.0, series[`{__name__="m",job="a",tenant="t1"}`])
+	require.Contains(t, series, `{__name__="push_time_seconds",job="a",tenant="t1"}`)
+	for _, ts := range mock.ts {
+		require.Equal(t, refreshTime.UnixMilli(), ts.Samples[0].Timestamp)
+	}
+}
+
+func TestPushProtobuf(t *testing.T) {
+	var body bytes.Buffer
+	enc := expfmt.NewEncoder(&body, expfmt.FmtProtoDelim)
+	require.NoError(t, enc.Encode(&dto.MetricFamily{
+		Name: proto.String("processed_total"),
+		Type: dto.MetricType_COUNTER.Enum(),
+		Metric: []*dto.Metric{{
+			Label:   []*dto.LabelPair{{Name: proto.String("queue"), Value: proto.String("a")}},
+			Counter: &dto.Counter{Value: proto.Float64(7)},
+		}},
+	}))
+
+	mock := &mockInserter{}
+	handler := Push(mock, parser.NewParser(), mockUpdaterForIngest(&mockMetric{}, nil, nil, nil), 0, 0)
+	req := httptest.NewRequest(http.MethodPut, "/metrics/job/batch", &body)
+	req.Header.Set("Content-Type", string(expfmt.FmtProtoDelim))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	series := ingestedValues(mock)
+	require.Equal(t, 7.0, series[`{__name__="processed_total",job="batch",queue="a"}`])
+	require.False(t, math.IsNaN(series[`{__name__="push_time_seconds",job="batch"}`]))
+}
@@ -12,15 +12,17 @@ import (
 	"github.com/NYTimes/gziphandler"
 
 	"github.com/timescale/promscale/pkg/log"
+	pgQuerier "github.com/timescale/promscale/pkg/pgmodel/querier"
 	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/query"
 )
 
 func Query(conf *Config, queryEngine *promql.Engine, queryable promql.Queryable, updateMetrics updateMetricCallback) http.Handler {
-	hf := corsWrapper(conf, queryHandler(queryEngine, queryable, updateMetrics))
+	hf := corsWrapper(conf, queryHandler(queryEngine, queryable, conf.SlowQueryLog, conf.QueryScheduler, updateMetrics))
 	return gziphandler.GzipHandler(hf)
 }
 
-func queryHandler(queryEngine *promql.Engine, queryable promql.Queryable, updateMetrics updateMetricCallback) http.HandlerFunc {
+func queryHandler(queryEngine *promql.Engine, queryable promql.Queryable, slowLog *query.SlowQueryLog, scheduler *query.Scheduler, updateMetrics updateMetricCallback) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		statusCode := "400"
 		errReason := ""
@@ -58,7 +60,17 @@ func queryHandler(queryEngine *promql.Engine, queryable promql.Queryable, update
 			return
 		}
 
-		res := qry.Exec(ctx)
+		release, ok := acquireQuery(ctx, w, r, scheduler, query.PriorityAPI)
+		if !ok {
+			statusCode = "503"
+			errReason = errQueue
+			return
+		}
+		defer release()
+		stats := &pgQuerier.QueryStats{}
+		execBegin := time.Now()
+		res := qry.Exec(pgQuerier.WithQueryStats(ctx, stats))
+		logSlowQuery(slowLog, r, "/api/v1/query", qry, stats, execBegin, res.Err)
 		if res.Err != nil {
 			log.Error("msg", res.Err, "endpoint", "query")
 			switch res.Err.(type) {
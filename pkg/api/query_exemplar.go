@@ -7,22 +7,50 @@ package api
 import (
 	"context"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/NYTimes/gziphandler"
 	"github.com/pkg/errors"
 
+	jaegerStore "github.com/timescale/promscale/pkg/jaeger/store"
 	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/pgmodel/exemplar"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/promql"
 )
 
-func QueryExemplar(conf *Config, queryable promql.Queryable, updateMetrics updateMetricCallback) http.Handler {
-	hf := corsWrapper(conf, queryExemplar(queryable, updateMetrics))
+const defaultExemplarTraceIDLabel = "trace_id"
+
+// ExemplarTraceLinker summarizes the stored traces the exemplars link to.
+type ExemplarTraceLinker interface {
+	GetTraceSummaries(ctx context.Context, refs []jaegerStore.TraceRef) ([]jaegerStore.TraceSummary, error)
+}
+
+// exemplarTrace is the trace an exemplar links to with its trace ID label.
+// The other fields are only set if the trace was found.
+type exemplarTrace struct {
+	TraceID         string
+	Found           bool
+	RootServiceName string
+	RootSpanName    string
+	StartTime       time.Time
+	Duration        time.Duration
+	SpanCount       int
+}
+
+type exemplarTraceParams struct {
+	link         bool
+	existingOnly bool
+	label        string
+}
+
+func QueryExemplar(conf *Config, queryable promql.Queryable, linker ExemplarTraceLinker, updateMetrics updateMetricCallback) http.Handler {
+	hf := corsWrapper(conf, queryExemplar(queryable, linker, updateMetrics))
 	return gziphandler.GzipHandler(hf)
 }
 
-func queryExemplar(queryable promql.Queryable, updateMetrics updateMetricCallback) http.HandlerFunc {
+func queryExemplar(queryable promql.Queryable, linker ExemplarTraceLinker, updateMetrics updateMetricCallback) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		statusCode := "400"
 		begin := time.Now()
@@ -48,6 +76,16 @@ func queryExemplar(queryable promql.Queryable, updateMetrics updateMetricCallbac
 			return
 		}
 
+		traceParams, err := parseExemplarTraceParams(r)
+		if err == nil && traceParams.link && linker == nil {
+			err = errors.New("linking exemplars to traces requires tracing to be enabled")
+		}
+		if err != nil {
+			log.Info("msg", "Exemplar query bad request:", "error", err)
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			return
+		}
+
 		ctx := r.Context()
 		if timeout := r.FormValue("timeout"); timeout != "" {
 			// Note: Prometheus does not implement timeout for querying exemplars.
@@ -71,7 +109,117 @@ func queryExemplar(queryable promql.Queryable, updateMetrics updateMetricCallbac
 			respondError(w, http.StatusInternalServerError, err, "bad_data")
 			return
 		}
+		var traces [][]*exemplarTrace
+		if traceParams.link {
+			results, traces, err = linkExemplarTraces(ctx, linker, results, traceParams)
+			if err != nil {
+				statusCode = "500"
+				log.Error("msg", err, "endpoint", "query_exemplars")
+				respondError(w, http.StatusInternalServerError, err, "execution")
+				return
+			}
+		}
 		statusCode = "2xx"
-		respondExemplar(w, results)
+		respondExemplar(w, results, traces)
+	}
+}
+
+func parseExemplarTraceParams(r *http.Request) (exemplarTraceParams, error) {
+	var (
+		p   = exemplarTraceParams{label: defaultExemplarTraceIDLabel}
+		err error
+	)
+	if v := r.FormValue("link_traces"); v != "" {
+		if p.link, err = strconv.ParseBool(v); err != nil {
+			return p, errors.Errorf("invalid link_traces %q", v)
+		}
+	}
+	if v := r.FormValue("existing_traces_only"); v != "" {
+		if p.existingOnly, err = strconv.ParseBool(v); err != nil {
+			return p, errors.Errorf("invalid existing_traces_only %q", v)
+		}
+	}
+	// Only returning the exemplars with existing traces requires looking
+	// them up, so it links them too.
+	p.link = p.link || p.existingOnly
+	if v := r.FormValue("trace_id_label"); v != "" {
+		p.label = v
+	}
+	return p, nil
+}
+
+// linkExemplarTraces looks up the traces of the exemplars with the trace ID
+// label, around the time of the exemplars. It returns the traces of the
+// exemplars in the order of the results, nil for the exemplars without trace
+// ID. If only the exemplars with existing traces are kept, the others and the
+// series left without exemplars are removed from the results.
+func linkExemplarTraces(ctx context.Context, linker ExemplarTraceLinker, results []model.ExemplarQueryResult, p exemplarTraceParams) ([]model.ExemplarQueryResult, [][]*exemplarTrace, error) {
+	var (
+		refs    []jaegerStore.TraceRef
+		indexes = make(map[string]int)
+	)
+	for _, res := range results {
+		for _, e := range res.Exemplars {
+			id := e.Labels.Get(p.label)
+			if id == "" {
+				continue
+			}
+			if _, ok := indexes[id]; ok {
+				continue
+			}
+			traceID, err := parseTempoTraceID(id)
+			if err != nil {
+				// Not a trace ID, so there is no trace to find.
+				indexes[id] = -1
+				continue
+			}
+			indexes[id] = len(refs)
+			refs = append(refs, jaegerStore.TraceRef{TraceID: traceID, Time: time.UnixMilli(e.Ts)})
+		}
+	}
+
+	var summaries []jaegerStore.TraceSummary
+	if len(refs) > 0 {
+		var err error
+		if summaries, err = linker.GetTraceSummaries(ctx, refs); err != nil {
+			return nil, nil, errors.WithMessage(err, "getting exemplar traces")
+		}
+		if len(summaries) != len(refs) {
+			return nil, nil, errors.Errorf("got %d exemplar traces, expected %d", len(summaries), len(refs))
+		}
+	}
+
+	linked := make([]model.ExemplarQueryResult, 0, len(results))
+	traces := make([][]*exemplarTrace, 0, len(results))
+	for _, res := range results {
+		exemplars := make([]model.ExemplarData, 0, len(res.Exemplars))
+		seriesTraces := make([]*exemplarTrace, 0, len(res.Exemplars))
+		for _, e := range res.Exemplars {
+			var trace *exemplarTrace
+			if id := e.Labels.Get(p.label); id != "" {
+				trace = &exemplarTrace{TraceID: id}
+				if i := indexes[id]; i >= 0 && summaries[i].SpanCount > 0 {
+					s := summaries[i]
+					trace.Found = true
+					trace.RootServiceName = s.RootServiceName
+					trace.RootSpanName = s.RootSpanName
+					trace.StartTime = s.StartTime
+					trace.Duration = s.Duration
+					trace.SpanCount = s.SpanCount
+				}
+			}
+			if p.existingOnly && (trace == nil || !trace.Found) {
+				continue
+			}
+			exemplars = append(exemplars, e)
+			seriesTraces = append(seriesTraces, trace)
+		}
+		if p.existingOnly && len(exemplars) == 0 {
+			continue
+		}
+		res.Exemplars = exemplars
+		linked = append(linked, res)
+		traces = append(traces, seriesTraces)
 	}
+	return linked, traces, nil
 }
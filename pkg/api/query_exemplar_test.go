@@ -5,13 +5,21 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+
+	jaegerStore "github.com/timescale/promscale/pkg/jaeger/store"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/query"
 )
 
@@ -94,7 +102,7 @@ func TestQueryExemplar(t *testing.T) {
 
 	queryable := query.NewQueryable(mockQuerier{}, nil)
 	for _, tc := range tcs {
-		handler := queryExemplar(queryable, mockUpdaterForQuery(&mockMetric{}, nil))
+		handler := queryExemplar(queryable, nil, mockUpdaterForQuery(&mockMetric{}, nil))
 		preparedURL := constructQueryExemplarRequest(tc.query, tc.start, tc.end, tc.timeout)
 		r := doExemplarQuery(t, "GET", preparedURL, handler)
 		require.Equal(t, tc.statusCode, r.Code, fmt.Sprintf("received code %d, expected %d", r.Code, tc.statusCode), tc.name)
@@ -123,3 +131,118 @@ func doExemplarQuery(t *testing.T, method, url string, handler http.Handler) *ht
 	handler.ServeHTTP(w, req)
 	return w
 }
+
+type mockTraceLinker struct {
+	summaries map[pcommon.TraceID]jaegerStore.TraceSummary
+	refs      []jaegerStore.TraceRef
+	err       error
+}
+
+func (m *mockTraceLinker) GetTraceSummaries(_ context.Context, refs []jaegerStore.TraceRef) ([]jaegerStore.TraceSummary, error) {
+	m.refs = refs
+	res := make([]jaegerStore.TraceSummary, len(refs))
+	for i, ref := range refs {
+		res[i] = m.summaries[ref.TraceID]
+	}
+	return res, m.err
+}
+
+func TestQueryExemplarTraces(t *testing.T) {
+	traceID := pcommon.TraceID([16]byte{15: 1})
+	exemplars := []model.ExemplarQueryResult{
+		{
+			SeriesLabels: labels.FromStrings("__name__", "latency"),
+			Exemplars: []model.ExemplarData{
+				{Labels: labels.FromStrings("trace_id", "1"), Value: 0.5, Ts: 1000},
+				{Labels: labels.FromStrings("trace_id", "2"), Value: 0.6, Ts: 2000},
+				{Labels: labels.FromStrings("trace_id", "1"), Value: 0.7, Ts: 3000},
+			},
+		},
+		{
+			SeriesLabels: labels.FromStrings("__name__", "errors"),
+			Exemplars: []model.ExemplarData{
+				{Labels: labels.FromStrings("trace_id", "not-an-id"), Value: 1, Ts: 1000},
+				{Labels: labels.Labels{}, Value: 2, Ts: 2000},
+			},
+		},
+	}
+	linker := &mockTraceLinker{summaries: map[pcommon.TraceID]jaegerStore.TraceSummary{
+		traceID: {
+			TraceID:         traceID,
+			RootServiceName: "api",
+			RootSpanName:    "GET /",
+			StartTime:       time.Unix(1, 0),
+			Duration:        1500 * time.Microsecond,
+			SpanCount:       3,
+		},
+	}}
+	tcs := []struct {
+		name       string
+		params     string
+		noLinker   bool
+		statusCode int
+		expected   string
+		refs       int
+	}{
+		{
+			name:       "not linked",
+			statusCode: http.StatusOK,
+			expected:   `{"status":"success","data":[{"seriesLabels":{"__name__":"latency"},"exemplars":[{"labels":{"trace_id":"1"},"value":"0.5","timestamp":1.000},{"labels":{"trace_id":"2"},"value":"0.6","timestamp":2.000},{"labels":{"trace_id":"1"},"value":"0.7","timestamp":3.000}]},{"seriesLabels":{"__name__":"errors"},"exemplars":[{"labels":{"trace_id":"not-an-id"},"value":"1","timestamp":1.000},{"labels":{},"value":"2","timestamp":2.000}]}]}`,
+		},
+		{
+			name:       "linked",
+			params:     "&link_traces=true",
+			statusCode: http.StatusOK,
+			expected:   `{"status":"success","data":[{"seriesLabels":{"__name__":"latency"},"exemplars":[{"labels":{"trace_id":"1"},"value":"0.5","timestamp":1.000,"trace":{"traceID":"1","found":true,"rootServiceName":"api","rootSpanName":"GET /","startTime":"1970-01-01T00:00:01Z","durationMs":1.5,"spanCount":3}},{"labels":{"trace_id":"2"},"value":"0.6","timestamp":2.000,"trace":{"traceID":"2","found":false}},{"labels":{"trace_id":"1"},"value":"0.7","timestamp":3.000,"trace":{"traceID":"1","found":true,"rootServiceName":"api","rootSpanName":"GET /","startTime":"1970-01-01T00:00:01Z","durationMs":1.5,"spanCount":3}}]},{"seriesLabels":{"__name__":"errors"},"exemplars":[{"labels":{"trace_id":"not-an-id"},"value":"1","timestamp":1.000,"trace":{"traceID":"not-an-id","found":false}},{"labels":{},"value":"2","timestamp":2.000}]}]}`,
+			refs:       2,
+		},
+		{
+			name:       "existing traces only",
+			params:     "&existing_traces_only=true",
+			statusCode: http.StatusOK,
+			expected:   `{"status":"success","data":[{"seriesLabels":{"__name__":"latency"},"exemplars":[{"labels":{"trace_id":"1"},"value":"0.5","timestamp":1.000,"trace":{"traceID":"1","found":true,"rootServiceName":"api","rootSpanName":"GET /","startTime":"1970-01-01T00:00:01Z","durationMs":1.5,"spanCount":3}},{"labels":{"trace_id":"1"},"value":"0.7","timestamp":3.000,"trace":{"traceID":"1","found":true,"rootServiceName":"api","rootSpanName":"GET /","startTime":"1970-01-01T00:00:01Z","durationMs":1.5,"spanCount":3}}]}]}`,
+			refs:       2,
+		},
+		{
+			name:       "other trace ID label",
+			params:     "&link_traces=true&trace_id_label=traceID",
+			statusCode: http.StatusOK,
+			expected:   `{"status":"success","data":[{"seriesLabels":{"__name__":"latency"},"exemplars":[{"labels":{"trace_id":"1"},"value":"0.5","timestamp":1.000},{"labels":{"trace_id":"2"},"value":"0.6","timestamp":2.000},{"labels":{"trace_id":"1"},"value":"0.7","timestamp":3.000}]},{"seriesLabels":{"__name__":"errors"},"exemplars":[{"labels":{"trace_id":"not-an-id"},"value":"1","timestamp":1.000},{"labels":{},"value":"2","timestamp":2.000}]}]}`,
+		},
+		{
+			name:       "invalid param",
+			params:     "&link_traces=maybe",
+			statusCode: http.StatusBadRequest,
+			expected:   `{"status":"error","errorType":"bad_data","error":"invalid link_traces \"maybe\""}`,
+		},
+		{
+			name:       "tracing disabled",
+			params:     "&link_traces=true",
+			noLinker:   true,
+			statusCode: http.StatusBadRequest,
+			expected:   `{"status":"error","errorType":"bad_data","error":"linking exemplars to traces requires tracing to be enabled"}`,
+		},
+	}
+
+	queryable := query.NewQueryable(mockQuerier{exemplars: exemplars}, nil)
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			linker.refs = nil
+			var l ExemplarTraceLinker = linker
+			if tc.noLinker {
+				l = nil
+			}
+			handler := queryExemplar(queryable, l, mockUpdaterForQuery(&mockMetric{}, nil))
+			r := doExemplarQuery(t, "GET", constructQueryExemplarRequest("latency", "0", "10", "")+tc.params, handler)
+			require.Equal(t, tc.statusCode, r.Code)
+			b, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, strings.TrimSpace(string(b)))
+			require.Len(t, linker.refs, tc.refs)
+			if tc.refs > 0 {
+				require.Equal(t, traceID, linker.refs[0].TraceID)
+				require.Equal(t, time.UnixMilli(1000), linker.refs[0].Time)
+			}
+		})
+	}
+}
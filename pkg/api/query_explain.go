@@ -0,0 +1,247 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/gziphandler"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/timestamp"
+
+	"github.com/timescale/promscale/pkg/log"
+	pgmodel "github.com/timescale/promscale/pkg/pgmodel/model"
+	pgQuerier "github.com/timescale/promscale/pkg/pgmodel/querier"
+	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/promql/parser"
+	"github.com/timescale/promscale/pkg/query"
+)
+
+// explainResult is the response of the query explain API.
+type explainResult struct {
+	ResultType  parser.ValueType `json:"resultType"`
+	SeriesCount int              `json:"seriesCount"`
+	AST         *explainNode     `json:"ast"`
+}
+
+// explainNode is a node of the PromQL AST. Vector selectors are annotated
+// with how their samples were fetched.
+type explainNode struct {
+	Type     string           `json:"type"`
+	Expr     string           `json:"expr"`
+	Selector *explainSelector `json:"selector,omitempty"`
+	Children []*explainNode   `json:"children,omitempty"`
+}
+
+type explainSelector struct {
+	Matchers   []string           `json:"matchers"`
+	Start      time.Time          `json:"start"`
+	End        time.Time          `json:"end"`
+	Pushdown   string             `json:"pushdown,omitempty"`
+	Statements []explainStatement `json:"statements"`
+}
+
+type explainStatement struct {
+	Metric    string             `json:"metric,omitempty"`
+	SQL       string             `json:"sql"`
+	Params    []interface{}      `json:"params"`
+	SeriesIDs []pgmodel.SeriesID `json:"seriesIds,omitempty"`
+	Plan      []string           `json:"plan,omitempty"`
+	PlanError string             `json:"planError,omitempty"`
+}
+
+func QueryExplain(conf *Config, promqlConf *query.Config, queryEngine *promql.Engine, queryable promql.Queryable, updateMetrics updateMetricCallback) http.Handler {
+	hf := corsWrapper(conf, queryExplain(promqlConf, queryEngine, queryable, conf.QueryScheduler, updateMetrics))
+	return gziphandler.GzipHandler(hf)
+}
+
+// queryExplain evaluates an instant query, or a range query if start is
+// given, and returns the SQL statements run for its selectors.
+func queryExplain(promqlConf *query.Config, queryEngine *promql.Engine, queryable promql.Queryable, scheduler *query.Scheduler, updateMetrics updateMetricCallback) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statusCode := "400"
+		errReason := ""
+		begin := time.Now()
+		defer func() {
+			updateMetrics("/api/v1/query_explain", statusCode, errReason, time.Since(begin).Seconds())
+		}()
+
+		explain := &pgQuerier.Explain{}
+		if a := r.FormValue("analyze"); a != "" {
+			var err error
+			explain.Analyze, err = strconv.ParseBool(a)
+			if err != nil {
+				log.Info("msg", "Query bad request:"+err.Error())
+				respondError(w, http.StatusBadRequest, errors.Wrap(err, "param analyze"), "bad_data")
+				return
+			}
+		}
+
+		qry, err := newExplainQuery(r, promqlConf, queryEngine, queryable)
+		if err != nil {
+			log.Info("msg", "Query bad request:"+err.Error())
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			return
+		}
+		defer qry.Close()
+
+		ctx := pgQuerier.WithExplain(r.Context(), explain)
+		if to := r.FormValue("timeout"); to != "" {
+			var cancel context.CancelFunc
+			timeout, err := parseDuration(to)
+			if err != nil {
+				log.Info("msg", "Query bad request"+err.Error())
+				respondError(w, http.StatusBadRequest, err, "bad_data")
+				return
+			}
+
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		release, ok := acquireQuery(ctx, w, r, scheduler, query.PriorityAPI)
+		if !ok {
+			statusCode = "503"
+			errReason = errQueue
+			return
+		}
+		defer release()
+		res := qry.Exec(ctx)
+		if res.Err != nil {
+			log.Error("msg", res.Err, "endpoint", "query_explain")
+			switch res.Err.(type) {
+			case promql.ErrQueryCanceled:
+				statusCode = "503"
+				errReason = errCanceled
+				respondError(w, http.StatusServiceUnavailable, res.Err, errCanceled)
+				return
+			case promql.ErrQueryTimeout:
+				statusCode = "503"
+				errReason = errTimeout
+				respondError(w, http.StatusServiceUnavailable, res.Err, errTimeout)
+				return
+			case promql.ErrStorage:
+				statusCode = "500"
+				respondError(w, http.StatusInternalServerError, res.Err, "internal")
+				return
+			}
+			statusCode = "422"
+			respondError(w, http.StatusUnprocessableEntity, res.Err, "execution")
+			return
+		}
+
+		selectors := make(map[parser.Node]*pgQuerier.SelectorExplain)
+		for _, s := range explain.Selectors() {
+			selectors[s.Node] = s
+		}
+		statusCode = "2xx"
+		respond(w, http.StatusOK, explainResult{
+			ResultType:  res.Value.Type(),
+			SeriesCount: seriesCount(res.Value),
+			AST:         newExplainNode(qry.Statement().(*parser.EvalStmt).Expr, selectors),
+		})
+	}
+}
+
+func newExplainQuery(r *http.Request, promqlConf *query.Config, queryEngine *promql.Engine, queryable promql.Queryable) (promql.Query, error) {
+	opts := &promql.QueryOpts{EnablePerStepStats: true}
+	if r.FormValue("start") == "" {
+		ts, err := parseTimeParam(r, "time", time.Now())
+		if err != nil {
+			return nil, err
+		}
+		return queryEngine.NewInstantQuery(queryable, opts, r.FormValue("query"), ts)
+	}
+
+	start, err := parseTime(r.FormValue("start"))
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseTime(r.FormValue("end"))
+	if err != nil {
+		return nil, err
+	}
+	if end.Before(start) {
+		return nil, errors.New("end timestamp must not be before start time")
+	}
+	step, err := parseDuration(r.FormValue("step"))
+	if err != nil {
+		return nil, errors.Wrap(err, "param step")
+	}
+	if step <= 0 {
+		return nil, errors.New("zero or negative query resolution step widths are not accepted. Try a positive integer")
+	}
+	if int64(end.Sub(start)/step) > promqlConf.MaxPointsPerTs {
+		return nil, fmt.Errorf("exceeded maximum resolution of %d points per timeseries. Try decreasing the query resolution (?step=XX) or "+
+			"increasing the 'promql-max-points-per-ts' limit", promqlConf.MaxPointsPerTs)
+	}
+	return queryEngine.NewRangeQuery(queryable, opts, r.FormValue("query"), start, end, step)
+}
+
+func newExplainNode(node parser.Node, selectors map[parser.Node]*pgQuerier.SelectorExplain) *explainNode {
+	// Step invariant expressions are added by the engine, they are left out
+	// to keep the tree in the shape of the query.
+	if n, ok := node.(*parser.StepInvariantExpr); ok {
+		return newExplainNode(n.Expr, selectors)
+	}
+	en := &explainNode{
+		Type: strings.TrimPrefix(fmt.Sprintf("%T", node), "*parser."),
+		Expr: node.String(),
+	}
+	if s, ok := selectors[node]; ok {
+		en.Selector = newExplainSelector(s)
+	}
+	for _, child := range parser.Children(node) {
+		en.Children = append(en.Children, newExplainNode(child, selectors))
+	}
+	return en
+}
+
+func newExplainSelector(s *pgQuerier.SelectorExplain) *explainSelector {
+	es := &explainSelector{
+		Matchers:   make([]string, len(s.Matchers)),
+		Start:      timestamp.Time(s.Start).UTC(),
+		End:        timestamp.Time(s.End).UTC(),
+		Statements: make([]explainStatement, len(s.Statements)),
+	}
+	for i, m := range s.Matchers {
+		es.Matchers[i] = m.String()
+	}
+	if s.Pushdown != nil {
+		es.Pushdown = s.Pushdown.String()
+	}
+	for i, stmt := range s.Statements {
+		es.Statements[i] = explainStatement{
+			Metric:    stmt.Metric,
+			SQL:       stmt.SQL,
+			Params:    stmt.Params,
+			SeriesIDs: stmt.SeriesIDs,
+			Plan:      stmt.Plan,
+		}
+		if es.Statements[i].Params == nil {
+			es.Statements[i].Params = []interface{}{}
+		}
+		if stmt.PlanErr != nil {
+			es.Statements[i].PlanError = stmt.PlanErr.Error()
+		}
+	}
+	return es
+}
+
+func seriesCount(v parser.Value) int {
+	switch v := v.(type) {
+	case promql.Vector:
+		return len(v)
+	case promql.Matrix:
+		return len(v)
+	default:
+		return 1
+	}
+}
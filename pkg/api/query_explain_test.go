@@ -0,0 +1,140 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/log"
+	pgQuerier "github.com/timescale/promscale/pkg/pgmodel/querier"
+	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/promql/parser"
+	"github.com/timescale/promscale/pkg/query"
+)
+
+func TestQueryExplain(t *testing.T) {
+	_ = log.Init(log.Config{
+		Level: "debug",
+	})
+	testCases := []struct {
+		name        string
+		params      url.Values
+		querier     *mockQuerier
+		expectCode  int
+		expectError string
+	}{
+		{
+			name:        "Analyze is unparsable",
+			params:      url.Values{"query": {"m"}, "analyze": {"maybe"}},
+			querier:     &mockQuerier{},
+			expectCode:  http.StatusBadRequest,
+			expectError: "bad_data",
+		}, {
+			name:        "Step is missing",
+			params:      url.Values{"query": {"m"}, "start": {"1"}, "end": {"2"}},
+			querier:     &mockQuerier{},
+			expectCode:  http.StatusBadRequest,
+			expectError: "bad_data",
+		}, {
+			name:        "Too many points",
+			params:      url.Values{"query": {"m"}, "start": {"1"}, "end": {"100000"}, "step": {"1"}},
+			querier:     &mockQuerier{},
+			expectCode:  http.StatusBadRequest,
+			expectError: "bad_data",
+		}, {
+			name:        "Select error",
+			params:      url.Values{"query": {"m"}, "time": {"1"}},
+			querier:     &mockQuerier{selectErr: fmt.Errorf("some error")},
+			expectCode:  http.StatusUnprocessableEntity,
+			expectError: "execution",
+		}, {
+			name:       "Instant query",
+			params:     url.Values{"query": {"sum(rate(m[5m]))"}, "time": {"1"}},
+			querier:    &mockQuerier{},
+			expectCode: http.StatusOK,
+		}, {
+			name:       "Range query",
+			params:     url.Values{"query": {"sum(rate(m[5m]))"}, "start": {"1"}, "end": {"100"}, "step": {"10"}, "analyze": {"true"}},
+			querier:    &mockQuerier{},
+			expectCode: http.StatusOK,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			engine := promql.NewEngine(
+				promql.EngineOpts{
+					Logger:     log.GetLogger(),
+					Reg:        prometheus.NewRegistry(),
+					MaxSamples: math.MaxInt32,
+					Timeout:    time.Minute,
+				},
+			)
+			handler := queryExplain(&query.Config{MaxPointsPerTs: 11000}, engine, query.NewQueryable(tc.querier, nil), nil, mockUpdaterForQuery(&mockMetric{}, nil))
+			w := doQuery(t, handler, "http://localhost:9090/query_explain?"+tc.params.Encode(), false)
+			require.Equal(t, tc.expectCode, w.Code, w.Body.String())
+
+			if tc.expectError != "" {
+				var er errResponse
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &er))
+				require.Equal(t, tc.expectError, er.ErrorType)
+				return
+			}
+			var res struct {
+				Data explainResult `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			ast := res.Data.AST
+			require.Equal(t, "AggregateExpr", ast.Type)
+			require.Equal(t, "sum(rate(m[5m]))", ast.Expr)
+			require.Len(t, ast.Children, 1)
+			require.Equal(t, "Call", ast.Children[0].Type)
+			require.Equal(t, "MatrixSelector", ast.Children[0].Children[0].Type)
+			require.Equal(t, "VectorSelector", ast.Children[0].Children[0].Children[0].Type)
+		})
+	}
+}
+
+func TestNewExplainNode(t *testing.T) {
+	expr, err := parser.ParseExpr(`rate(m{job="a"}[5m])`)
+	require.NoError(t, err)
+	vs := expr.(*parser.Call).Args[0].(*parser.MatrixSelector).VectorSelector
+
+	matcher := labels.MustNewMatcher(labels.MatchEqual, "job", "a")
+	selectors := map[parser.Node]*pgQuerier.SelectorExplain{
+		vs: {
+			Node:     vs,
+			Matchers: []*labels.Matcher{matcher},
+			Start:    1000,
+			End:      2000,
+			Pushdown: expr,
+			Statements: []pgQuerier.ExplainStatement{
+				{Metric: "m", SQL: "SELECT 1", Plan: []string{"Result"}, PlanErr: fmt.Errorf("some error")},
+			},
+		},
+	}
+
+	node := newExplainNode(&parser.StepInvariantExpr{Expr: expr}, selectors)
+	require.Equal(t, "Call", node.Type)
+	require.Nil(t, node.Selector)
+
+	s := node.Children[0].Children[0].Selector
+	require.NotNil(t, s)
+	require.Equal(t, []string{`job="a"`}, s.Matchers)
+	require.Equal(t, int64(1000), s.Start.UnixMilli())
+	require.Equal(t, int64(2000), s.End.UnixMilli())
+	require.Equal(t, `rate(m{job="a"}[5m])`, s.Pushdown)
+	require.Equal(t, []explainStatement{
+		{Metric: "m", SQL: "SELECT 1", Params: []interface{}{}, Plan: []string{"Result"}, PlanError: "some error"},
+	}, s.Statements)
+}
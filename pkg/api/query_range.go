@@ -14,16 +14,23 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/timescale/promscale/pkg/log"
+	pgQuerier "github.com/timescale/promscale/pkg/pgmodel/querier"
 	"github.com/timescale/promscale/pkg/promql"
 	"github.com/timescale/promscale/pkg/query"
 )
 
-func QueryRange(conf *Config, promqlConf *query.Config, queryEngine *promql.Engine, queryable promql.Queryable, updateMetrics updateMetricCallback) http.Handler {
-	hf := corsWrapper(conf, queryRange(promqlConf, queryEngine, queryable, updateMetrics))
+// rangeQueryEngine creates range queries. It is implemented by promql.Engine
+// and query.Frontend.
+type rangeQueryEngine interface {
+	NewRangeQuery(q promql.Queryable, opts *promql.QueryOpts, qs string, start, end time.Time, interval time.Duration) (promql.Query, error)
+}
+
+func QueryRange(conf *Config, promqlConf *query.Config, queryEngine rangeQueryEngine, queryable promql.Queryable, updateMetrics updateMetricCallback) http.Handler {
+	hf := corsWrapper(conf, queryRange(promqlConf, queryEngine, queryable, conf.SlowQueryLog, conf.QueryScheduler, updateMetrics))
 	return gziphandler.GzipHandler(hf)
 }
 
-func queryRange(promqlConf *query.Config, queryEngine *promql.Engine, queryable promql.Queryable, updateMetrics updateMetricCallback) http.HandlerFunc {
+func queryRange(promqlConf *query.Config, queryEngine rangeQueryEngine, queryable promql.Queryable, slowLog *query.SlowQueryLog, scheduler *query.Scheduler, updateMetrics updateMetricCallback) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		statusCode := "400"
 		errReason := ""
@@ -103,7 +110,17 @@ func queryRange(promqlConf *query.Config, queryEngine *promql.Engine, queryable
 			return
 		}
 
-		res := qry.Exec(ctx)
+		release, ok := acquireQuery(ctx, w, r, scheduler, query.PriorityAPI)
+		if !ok {
+			statusCode = "503"
+			errReason = errQueue
+			return
+		}
+		defer release()
+		stats := &pgQuerier.QueryStats{}
+		execBegin := time.Now()
+		res := qry.Exec(pgQuerier.WithQueryStats(query.WithQueryClass(ctx, query.PriorityAPI, queueKey(r, scheduler)), stats))
+		logSlowQuery(slowLog, r, "/api/v1/query_range", qry, stats, execBegin, res.Err)
 
 		if res.Err != nil {
 			log.Error("msg", res.Err, "endpoint", "query_range")
@@ -162,7 +162,7 @@ func TestRangedQuery(t *testing.T) {
 				},
 			)
 
-			handler := queryRange(&query.Config{MaxPointsPerTs: 11000}, engine, query.NewQueryable(tc.querier, nil), mockUpdaterForQuery(&mockMetric{}, nil))
+			handler := queryRange(&query.Config{MaxPointsPerTs: 11000}, engine, query.NewQueryable(tc.querier, nil), nil, nil, mockUpdaterForQuery(&mockMetric{}, nil))
 			queryUrl := constructRangedQuery(tc.metric, tc.start, tc.end, tc.step, tc.timeout)
 			w := doRangedQuery(t, handler, queryUrl, tc.canceled)
 
@@ -0,0 +1,37 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/query"
+)
+
+const errQueue = "queue"
+
+// acquireQuery waits until the scheduler admits the query of the request,
+// queued by the value of the fairness header. It responds with 503 and returns
+// false if the query is rejected.
+func acquireQuery(ctx context.Context, w http.ResponseWriter, r *http.Request, scheduler *query.Scheduler, p query.Priority) (release func(), ok bool) {
+	release, err := scheduler.Acquire(ctx, p, queueKey(r, scheduler))
+	if err != nil {
+		log.Warn("msg", "Query rejected by the query scheduler", "priority", p, "err", err)
+		respondError(w, http.StatusServiceUnavailable, fmt.Errorf("query not admitted: %w", err), "unavailable")
+		return nil, false
+	}
+	return release, true
+}
+
+// queueKey returns the value of the fairness header of the request.
+func queueKey(r *http.Request, scheduler *query.Scheduler) string {
+	if h := scheduler.FairnessHeader(); h != "" {
+		return r.Header.Get(h)
+	}
+	return ""
+}
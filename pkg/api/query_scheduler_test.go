@@ -0,0 +1,52 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/query"
+)
+
+func TestQuerySchedulerRejects(t *testing.T) {
+	_ = log.Init(log.Config{
+		Level: "debug",
+	})
+	engine := promql.NewEngine(
+		promql.EngineOpts{
+			Logger:     log.GetLogger(),
+			Reg:        prometheus.NewRegistry(),
+			MaxSamples: math.MaxInt32,
+			Timeout:    time.Minute,
+		},
+	)
+	scheduler := query.NewScheduler(&query.Config{MaxConcurrentQueries: 1, MaxQueuedQueries: 0})
+	queryable := query.NewQueryable(&mockQuerier{}, nil)
+	metric := &mockMetric{}
+	handler := queryHandler(engine, queryable, nil, scheduler, mockUpdaterForQuery(metric, nil))
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/query?"+url.Values{"query": {"m"}, "time": {"100"}}.Encode(), nil)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	release, err := scheduler.Acquire(context.Background(), query.PriorityRules, "")
+	require.NoError(t, err)
+	defer release()
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Contains(t, w.Body.String(), query.ErrQueueFull.Error())
+}
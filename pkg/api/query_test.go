@@ -17,13 +17,13 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/prometheus/model/labels"
-	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/pgmodel/querier"
 	"github.com/timescale/promscale/pkg/prompb"
 	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/promql/parser"
 	"github.com/timescale/promscale/pkg/query"
 )
 
@@ -52,6 +52,8 @@ func (m mockSeriesSet) Close() {}
 type mockQuerier struct {
 	timeToSleepOnSelect time.Duration
 	selectErr           error
+	labelsQuerier       *mockLabelsQuerier
+	exemplars           []model.ExemplarQueryResult
 }
 
 var _ querier.Querier = (*mockQuerier)(nil)
@@ -75,14 +77,39 @@ func (m mockQuerier) RemoteReadQuerier(_ context.Context) querier.RemoteReadQuer
 }
 
 func (m mockQuerier) ExemplarsQuerier(_ context.Context) querier.ExemplarQuerier {
-	return mockExemplarQuerier{}
+	return mockExemplarQuerier{results: m.exemplars}
 }
 
-type mockExemplarQuerier struct{}
+func (m mockQuerier) LabelsQuerier(_ context.Context) querier.LabelsQuerier {
+	return m.labelsQuerier
+}
+
+type mockExemplarQuerier struct {
+	results []model.ExemplarQueryResult
+}
 
 // Select implements the querier.ExemplarQuerier interface.
 func (me mockExemplarQuerier) Select(_, _ time.Time, _ ...[]*labels.Matcher) ([]model.ExemplarQueryResult, error) {
-	return nil, nil
+	return me.results, nil
+}
+
+type mockLabelsQuerier struct {
+	labels     map[string][]string
+	mint, maxt int64
+	matchers   [][]*labels.Matcher
+}
+
+func (m *mockLabelsQuerier) LabelNames(mint, maxt int64, ms ...*labels.Matcher) ([]string, error) {
+	m.mint, m.maxt = mint, maxt
+	m.matchers = append(m.matchers, ms)
+	if len(ms) == 0 {
+		return m.labels[""], nil
+	}
+	return m.labels[ms[0].Value], nil
+}
+
+func (m *mockLabelsQuerier) LabelValues(_ string, mint, maxt int64, ms ...*labels.Matcher) ([]string, error) {
+	return m.LabelNames(mint, maxt, ms...)
 }
 
 type mockLabelsReader struct {
@@ -226,7 +253,7 @@ func TestQuery(t *testing.T) {
 				},
 			)
 
-			handler := queryHandler(engine, query.NewQueryable(tc.querier, tc.labelsReader), mockUpdaterForQuery(&mockMetric{}, nil))
+			handler := queryHandler(engine, query.NewQueryable(tc.querier, tc.labelsReader), nil, nil, mockUpdaterForQuery(&mockMetric{}, nil))
 			queryURL := constructQuery(tc.metric, tc.time, tc.timeout)
 			w := doQuery(t, handler, queryURL, tc.canceled)
 
@@ -17,6 +17,7 @@ import (
 	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/pgmodel/querier"
 	"github.com/timescale/promscale/pkg/prompb"
+	"github.com/timescale/promscale/pkg/query"
 )
 
 func Read(config *Config, reader querier.Reader, metrics *Metrics, updateMetrics updateMetricCallback) http.Handler {
@@ -66,6 +67,12 @@ func Read(config *Config, reader querier.Reader, metrics *Metrics, updateMetrics
 			}
 		}
 
+		release, ok := acquireQuery(r.Context(), w, r, config.QueryScheduler, query.PriorityBackground)
+		if !ok {
+			statusCode = "503"
+			return
+		}
+		defer release()
 		var resp *prompb.ReadResponse
 		resp, err = reader.Read(r.Context(), &req)
 		if err != nil {
@@ -53,9 +53,24 @@ func GenerateRouter(apiConf *Config, promqlConf *query.Config, client *pgclient.
 
 	writeHandler := timeHandler(metrics.HTTPRequestDuration, "write", otelhttp.NewHandler(Write(client, dataParser, updateIngestMetrics), "write-metrics"))
 
+	influxWriteHandler := timeHandler(metrics.HTTPRequestDuration, "influx_write", otelhttp.NewHandler(InfluxWrite(client, dataParser, updateIngestMetrics), "write-influx"))
+
+	pushHandler := timeHandler(metrics.HTTPRequestDuration, "push", otelhttp.NewHandler(Push(client, dataParser, updateIngestMetrics, apiConf.PushRefreshInterval, apiConf.PushMaxGroups), "push-metrics"))
+
+	zipkinHandler := timeHandler(metrics.HTTPRequestDuration, "zipkin_spans", otelhttp.NewHandler(ZipkinWrite(client), "write-zipkin-spans"))
+
+	otlpTraceHandler := timeHandler(metrics.HTTPRequestDuration, "otlp_traces", otelhttp.NewHandler(OTLPTraceWrite(client), "write-otlp-traces"))
+
+	otlpLogHandler := timeHandler(metrics.HTTPRequestDuration, "otlp_logs", otelhttp.NewHandler(OTLPLogWrite(client), "write-otlp-logs"))
+
 	// If we are running in read-only mode, log and send NotFound status.
 	if apiConf.ReadOnly {
 		writeHandler = withWarnLog("trying to send metrics to write API while connector is in read-only mode", http.NotFoundHandler())
+		influxWriteHandler = withWarnLog("trying to send metrics to InfluxDB write API while connector is in read-only mode", http.NotFoundHandler())
+		pushHandler = withWarnLog("trying to push metrics to Pushgateway API while connector is in read-only mode", http.NotFoundHandler())
+		zipkinHandler = withWarnLog("trying to send Zipkin spans while connector is in read-only mode", http.NotFoundHandler())
+		otlpTraceHandler = withWarnLog("trying to send OTLP traces while connector is in read-only mode", http.NotFoundHandler())
+		otlpLogHandler = withWarnLog("trying to send OTLP logs while connector is in read-only mode", http.NotFoundHandler())
 	}
 
 	router := mux.NewRouter().UseEncodedPath()
@@ -63,7 +78,16 @@ func GenerateRouter(apiConf *Config, promqlConf *query.Config, client *pgclient.
 		router.Use(authWrapper)
 	}
 
+	// InfluxDB v1 clients write to /write too, with the database as parameter.
+	router.Path("/write").Methods(http.MethodPost).Queries("db", "{db}").HandlerFunc(influxWriteHandler)
 	router.Path("/write").Methods(http.MethodPost).HandlerFunc(writeHandler)
+	router.Path("/influx/api/v2/write").Methods(http.MethodPost).HandlerFunc(influxWriteHandler)
+	router.PathPrefix("/metrics/job").Methods(http.MethodPut, http.MethodPost, http.MethodDelete).HandlerFunc(pushHandler)
+	router.Path("/api/v2/spans").Methods(http.MethodPost).HandlerFunc(zipkinHandler)
+	router.Path("/v1/traces").Methods(http.MethodPost).HandlerFunc(otlpTraceHandler)
+	if apiConf.Logs != nil {
+		router.Path("/v1/logs").Methods(http.MethodPost).HandlerFunc(otlpLogHandler)
+	}
 
 	readHandler := timeHandler(metrics.HTTPRequestDuration, "read", Read(apiConf, client, metrics, updateQueryMetrics))
 	router.Path("/read").Methods(http.MethodGet, http.MethodPost).HandlerFunc(readHandler)
@@ -78,10 +102,20 @@ func GenerateRouter(apiConf *Config, promqlConf *query.Config, client *pgclient.
 	queryHandler := timeHandler(metrics.HTTPRequestDuration, "query", Query(apiConf, queryEngine, queryable, updateQueryMetrics))
 	apiV1.Path("/query").Methods(http.MethodGet, http.MethodPost).HandlerFunc(queryHandler)
 
-	queryRangeHandler := timeHandler(metrics.HTTPRequestDuration, "query_range", QueryRange(apiConf, promqlConf, queryEngine, queryable, updateQueryMetrics))
+	queryRangeHandler := timeHandler(metrics.HTTPRequestDuration, "query_range", QueryRange(apiConf, promqlConf, client.QueryFrontend(), queryable, updateQueryMetrics))
 	apiV1.Path("/query_range").Methods(http.MethodGet, http.MethodPost).HandlerFunc(queryRangeHandler)
 
-	exemplarQueryHandler := timeHandler(metrics.HTTPRequestDuration, "query_exemplar", QueryExemplar(apiConf, queryable, updateQueryMetrics))
+	queryExplainHandler := timeHandler(metrics.HTTPRequestDuration, "query_explain", QueryExplain(apiConf, promqlConf, queryEngine, queryable, updateQueryMetrics))
+	apiV1.Path("/query_explain").Methods(http.MethodGet, http.MethodPost).HandlerFunc(queryExplainHandler)
+
+	slowQueriesHandler := timeHandler(metrics.HTTPRequestDuration, "status/slow_queries", SlowQueries(apiConf))
+	apiV1.Path("/status/slow_queries").Methods(http.MethodGet).HandlerFunc(slowQueriesHandler)
+
+	var exemplarTraceLinker ExemplarTraceLinker
+	if store != nil {
+		exemplarTraceLinker = store
+	}
+	exemplarQueryHandler := timeHandler(metrics.HTTPRequestDuration, "query_exemplar", QueryExemplar(apiConf, queryable, exemplarTraceLinker, updateQueryMetrics))
 	apiV1.Path("/query_exemplars").Methods(http.MethodGet, http.MethodPost).HandlerFunc(exemplarQueryHandler)
 
 	seriesHandler := timeHandler(metrics.HTTPRequestDuration, "series", Series(apiConf, queryable))
@@ -99,17 +133,47 @@ func GenerateRouter(apiConf *Config, promqlConf *query.Config, client *pgclient.
 	alertsHandler := timeHandler(metrics.HTTPRequestDuration, "alerts", Alerts(apiConf, updateQueryMetrics))
 	apiV1.Path("/alerts").Methods(http.MethodGet).HandlerFunc(alertsHandler)
 
+	if apiConf.Logs != nil {
+		logSearchHandler := timeHandler(metrics.HTTPRequestDuration, "logs", LogSearch(apiConf, apiConf.Logs))
+		apiV1.Path("/logs").Methods(http.MethodGet, http.MethodPost).HandlerFunc(logSearchHandler)
+	}
+
 	labelValuesHandler := timeHandler(metrics.HTTPRequestDuration, "label/:name/values", LabelValues(apiConf, queryable))
 	apiV1.Path("/label/{name}/values").Methods(http.MethodGet).HandlerFunc(labelValuesHandler)
 
 	healthChecker := func() error { return client.HealthCheck() }
 	router.Path("/healthz").Methods(http.MethodGet, http.MethodOptions, http.MethodHead).HandlerFunc(Health(healthChecker))
+
+	federateHandler := timeHandler(metrics.HTTPRequestDuration, "federate", Federate(apiConf, promqlConf, queryable, updateQueryMetrics))
+	router.Path("/federate").Methods(http.MethodGet).HandlerFunc(federateHandler)
+
 	router.Path(apiConf.TelemetryPath).Methods(http.MethodGet).HandlerFunc(promhttp.Handler().ServeHTTP)
 
 	reloadHandler := timeHandler(metrics.HTTPRequestDuration, "/-/reload", Reload(reload, apiConf.AdminAPIEnabled))
 	router.Path("/-/reload").Methods(http.MethodPost).HandlerFunc(reloadHandler)
 
 	if store != nil {
+		traceSearchHandler := timeHandler(metrics.HTTPRequestDuration, "traces/search", TraceSearch(apiConf, store))
+		apiV1.Path("/traces/search").Methods(http.MethodGet, http.MethodPost).HandlerFunc(traceSearchHandler)
+
+		// The Tempo routes are registered first so they take precedence
+		// over the Jaeger ones.
+		router.Path("/api/echo").Methods(http.MethodGet).HandlerFunc(TempoEcho)
+		tempoTraceHandler := timeHandler(metrics.HTTPRequestDuration, "tempo/traces/:id", TempoTraceByID(apiConf, store))
+		router.Path("/api/traces/{traceID}").Methods(http.MethodGet).MatcherFunc(acceptsProtobuf).HandlerFunc(tempoTraceHandler)
+		tempoTraceV2Handler := timeHandler(metrics.HTTPRequestDuration, "tempo/v2/traces/:id", TempoTraceByIDV2(apiConf, store))
+		router.Path("/api/v2/traces/{traceID}").Methods(http.MethodGet).HandlerFunc(tempoTraceV2Handler)
+		tempoSearchHandler := timeHandler(metrics.HTTPRequestDuration, "tempo/search", TempoSearch(apiConf, store))
+		router.Path("/api/search").Methods(http.MethodGet).HandlerFunc(tempoSearchHandler)
+		tempoTagsHandler := timeHandler(metrics.HTTPRequestDuration, "tempo/search/tags", TempoSearchTags(apiConf, store))
+		router.Path("/api/search/tags").Methods(http.MethodGet).HandlerFunc(tempoTagsHandler)
+		tempoTagValuesHandler := timeHandler(metrics.HTTPRequestDuration, "tempo/search/tag/:name/values", TempoSearchTagValues(apiConf, store))
+		router.Path("/api/search/tag/{name}/values").Methods(http.MethodGet).HandlerFunc(tempoTagValuesHandler)
+		tempoTagsV2Handler := timeHandler(metrics.HTTPRequestDuration, "tempo/v2/search/tags", TempoSearchTagsV2(apiConf, store))
+		router.Path("/api/v2/search/tags").Methods(http.MethodGet).HandlerFunc(tempoTagsV2Handler)
+		tempoTagValuesV2Handler := timeHandler(metrics.HTTPRequestDuration, "tempo/v2/search/tag/:name/values", TempoSearchTagValuesV2(apiConf, store))
+		router.Path("/api/v2/search/tag/{name}/values").Methods(http.MethodGet).HandlerFunc(tempoTagValuesV2Handler)
+
 		jaeger.ExtendQueryAPIs(router, client.ReadOnlyConnection(), store)
 	}
 
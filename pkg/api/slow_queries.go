@@ -0,0 +1,116 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/gziphandler"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/timescale/promscale/pkg/log"
+	pgQuerier "github.com/timescale/promscale/pkg/pgmodel/querier"
+	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/promql/parser"
+	"github.com/timescale/promscale/pkg/query"
+	"github.com/timescale/promscale/pkg/tenancy"
+)
+
+func SlowQueries(conf *Config) http.Handler {
+	hf := corsWrapper(conf, slowQueries(conf.SlowQueryLog, conf.AdminAPIEnabled))
+	return gziphandler.GzipHandler(hf)
+}
+
+// slowQueries returns the most recent entries of the slow query log, the most
+// recent first. The number of entries is limited by the limit parameter. The
+// entries hold the queries of every tenant, so they are only served when the
+// admin API is enabled.
+func slowQueries(slowLog *query.SlowQueryLog, webAdmin bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !webAdmin {
+			err := fmt.Errorf("slow queries requested but web admin is disabled. To enable, start Promscale with '-web.enable-admin-api' flag")
+			log.Error("msg", err.Error())
+			respondError(w, http.StatusUnauthorized, err, "unauthorized")
+			return
+		}
+		limit := 0
+		if l := r.FormValue("limit"); l != "" {
+			var err error
+			limit, err = strconv.Atoi(l)
+			if err != nil || limit < 0 {
+				err = fmt.Errorf("invalid limit %q: must be a non-negative integer", l)
+				log.Info("msg", "Slow queries bad request:"+err.Error())
+				respondError(w, http.StatusBadRequest, err, "bad_data")
+				return
+			}
+		}
+		respond(w, http.StatusOK, slowLog.Recent(limit))
+	}
+}
+
+// logSlowQuery records the query in the slow query log if it took longer than
+// the threshold. stats are the statistics the querier recorded for it.
+func logSlowQuery(slowLog *query.SlowQueryLog, r *http.Request, endpoint string, qry promql.Query, stats *pgQuerier.QueryStats, begin time.Time, err error) {
+	duration := time.Since(begin)
+	if !slowLog.IsSlow(duration) {
+		return
+	}
+	roundTrips, sqlDuration := stats.RoundTrips()
+	q := query.SlowQuery{
+		Time:  begin,
+		Query: r.FormValue("query"),
+		Caller: query.Caller{
+			Endpoint:     endpoint,
+			RemoteAddr:   r.RemoteAddr,
+			UserAgent:    r.UserAgent(),
+			Referer:      r.Referer(),
+			DashboardUID: r.Header.Get("X-Dashboard-Uid"),
+			PanelID:      r.Header.Get("X-Panel-Id"),
+		},
+		DurationSeconds:    duration.Seconds(),
+		SeriesFetched:      stats.SeriesFetched(),
+		SQLRoundTrips:      roundTrips,
+		SQLDurationSeconds: sqlDuration.Seconds(),
+	}
+	if s := qry.Stats(); s != nil && s.Samples != nil {
+		q.SamplesTouched = s.Samples.TotalSamples
+	}
+	if stmt, ok := qry.Statement().(*parser.EvalStmt); ok {
+		q.Start, q.End = stmt.Start, stmt.End
+		q.StepSeconds = stmt.Interval.Seconds()
+		q.Tenant = queryTenant(r, stmt.Expr)
+	}
+	if err != nil {
+		q.Error = err.Error()
+	}
+	slowLog.Log(q)
+}
+
+// queryTenant returns the tenant of the TENANT header, or else the tenants the
+// query selects with equality matchers.
+func queryTenant(r *http.Request, expr parser.Expr) string {
+	if t := r.Header.Get("TENANT"); t != "" {
+		return t
+	}
+	tenants := make(map[string]struct{})
+	for _, ms := range parser.ExtractSelectors(expr) {
+		for _, m := range ms {
+			if m.Name == tenancy.TenantLabelKey && m.Type == labels.MatchEqual {
+				tenants[m.Value] = struct{}{}
+			}
+		}
+	}
+	res := make([]string, 0, len(tenants))
+	for t := range tenants {
+		res = append(res, t)
+	}
+	sort.Strings(res)
+	return strings.Join(res, ",")
+}
@@ -0,0 +1,139 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/promql/parser"
+	"github.com/timescale/promscale/pkg/query"
+)
+
+func TestSlowQueries(t *testing.T) {
+	_ = log.Init(log.Config{
+		Level: "debug",
+	})
+	slowLog, err := query.NewSlowQueryLog(&query.Config{SlowQueryThreshold: time.Nanosecond, SlowQueryLogRecent: 10}, nil)
+	require.NoError(t, err)
+	defer slowLog.Close()
+
+	engine := promql.NewEngine(
+		promql.EngineOpts{
+			Logger:     log.GetLogger(),
+			Reg:        prometheus.NewRegistry(),
+			MaxSamples: math.MaxInt32,
+			Timeout:    time.Minute,
+		},
+	)
+	queryable := query.NewQueryable(&mockQuerier{}, nil)
+	instant := queryHandler(engine, queryable, slowLog, nil, mockUpdaterForQuery(&mockMetric{}, nil))
+	ranged := queryRange(&query.Config{MaxPointsPerTs: 11000}, engine, queryable, slowLog, nil, mockUpdaterForQuery(&mockMetric{}, nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/query?"+url.Values{"query": {`sum(m{__tenant__="t1"})`}, "time": {"100"}}.Encode(), nil)
+	req.Header.Set("X-Dashboard-Uid", "dash")
+	req.Header.Set("X-Panel-Id", "2")
+	req.Header.Set("User-Agent", "Grafana/9.3.0")
+	instant.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/query_range?"+url.Values{"query": {"m"}, "start": {"0"}, "end": {"100"}, "step": {"10"}}.Encode(), nil)
+	req.Header.Set("TENANT", "t2")
+	ranged.ServeHTTP(httptest.NewRecorder(), req)
+
+	testCases := []struct {
+		name          string
+		limit         string
+		adminDisabled bool
+		expectCode    int
+		expect        []string
+	}{
+		{
+			name:       "All entries",
+			expectCode: http.StatusOK,
+			expect:     []string{"m", `sum(m{__tenant__="t1"})`},
+		}, {
+			name:       "Limited",
+			limit:      "1",
+			expectCode: http.StatusOK,
+			expect:     []string{"m"},
+		}, {
+			name:       "Invalid limit",
+			limit:      "-1",
+			expectCode: http.StatusBadRequest,
+		}, {
+			name:          "Admin API disabled",
+			adminDisabled: true,
+			expectCode:    http.StatusUnauthorized,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			slowQueries(slowLog, !tc.adminDisabled).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/status/slow_queries?limit="+tc.limit, nil))
+			require.Equal(t, tc.expectCode, w.Code)
+			if tc.expectCode != http.StatusOK {
+				return
+			}
+			var res struct {
+				Data []query.SlowQuery `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			queries := make([]string, len(res.Data))
+			for i, q := range res.Data {
+				queries[i] = q.Query
+			}
+			require.Equal(t, tc.expect, queries)
+		})
+	}
+
+	recent := slowLog.Recent(0)
+	rangeQuery, instantQuery := recent[0], recent[1]
+	require.Equal(t, "t1", instantQuery.Tenant)
+	require.Equal(t, query.Caller{
+		Endpoint:     "/api/v1/query",
+		RemoteAddr:   "192.0.2.1:1234",
+		UserAgent:    "Grafana/9.3.0",
+		DashboardUID: "dash",
+		PanelID:      "2",
+	}, instantQuery.Caller)
+	require.Equal(t, time.Unix(100, 0), instantQuery.Start.Local())
+	require.Zero(t, instantQuery.StepSeconds)
+
+	require.Equal(t, "t2", rangeQuery.Tenant)
+	require.Equal(t, "/api/v1/query_range", rangeQuery.Caller.Endpoint)
+	require.Equal(t, float64(10), rangeQuery.StepSeconds)
+	require.Equal(t, time.Unix(100, 0), rangeQuery.End.Local())
+}
+
+func TestQueryTenant(t *testing.T) {
+	testCases := []struct {
+		query  string
+		header string
+		expect string
+	}{
+		{query: "up"},
+		{query: `up{__tenant__=~"a|b"}`},
+		{query: `up{__tenant__="b"} + up{__tenant__="a"} + down{__tenant__="b"}`, expect: "a,b"},
+		{query: `up{__tenant__="b"}`, header: "a", expect: "a"},
+	}
+	for _, tc := range testCases {
+		expr, err := parser.ParseExpr(tc.query)
+		require.NoError(t, err)
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.header != "" {
+			r.Header.Set("TENANT", tc.header)
+		}
+		require.Equal(t, tc.expect, queryTenant(r, expr), tc.query)
+	}
+}
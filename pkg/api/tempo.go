@@ -0,0 +1,409 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"context"
+	encodinghex "encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/gziphandler"
+	"github.com/go-logfmt/logfmt"
+	"github.com/gorilla/mux"
+	"github.com/jaegertracing/jaeger/storage/spanstore"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	jaegerStore "github.com/timescale/promscale/pkg/jaeger/store"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/traceql"
+)
+
+const (
+	protobufContentType = "application/protobuf"
+
+	defaultTempoSpansPerSpanSet = 3
+	maxTempoTagValues           = 1000
+)
+
+var (
+	tracesProtoMarshaler = ptrace.NewProtoMarshaler()
+	tracesJSONMarshaler  = ptrace.NewJSONMarshaler()
+)
+
+// TempoQuerier serves the queries of the Tempo HTTP API.
+type TempoQuerier interface {
+	TraceSearcher
+	GetOTLPTrace(ctx context.Context, traceID pcommon.TraceID) (ptrace.Traces, error)
+	SearchTagNames(ctx context.Context) (jaegerStore.TagNames, error)
+	SearchTagValues(ctx context.Context, field traceql.Field, limit int) ([]jaegerStore.TagValue, error)
+}
+
+// acceptsProtobuf matches the requests of Tempo clients asking for protobuf
+// responses. The Tempo trace by ID endpoint shares its path with the Jaeger
+// query API, which is used for every other request.
+func acceptsProtobuf(r *http.Request, _ *mux.RouteMatch) bool {
+	return strings.Contains(r.Header.Get("Accept"), protobufContentType)
+}
+
+type tempoSearchResponse struct {
+	Traces  []tempoTraceMetadata `json:"traces"`
+	Metrics struct{}             `json:"metrics"`
+}
+
+type tempoTraceMetadata struct {
+	TraceID           string        `json:"traceID"`
+	RootServiceName   string        `json:"rootServiceName"`
+	RootTraceName     string        `json:"rootTraceName"`
+	StartTimeUnixNano string        `json:"startTimeUnixNano"`
+	DurationMs        int64         `json:"durationMs"`
+	SpanSet           *tempoSpanSet `json:"spanSet,omitempty"`
+}
+
+type tempoSpanSet struct {
+	Spans   []tempoSpan `json:"spans"`
+	Matched int         `json:"matched"`
+}
+
+type tempoSpan struct {
+	SpanID            string `json:"spanID"`
+	StartTimeUnixNano string `json:"startTimeUnixNano"`
+	DurationNanos     string `json:"durationNanos"`
+}
+
+type tempoTagScope struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+type tempoTagValue struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+func TempoTraceByID(conf *Config, querier TempoQuerier) http.Handler {
+	hf := corsWrapper(conf, tempoTraceByID(querier, false))
+	return gziphandler.GzipHandler(hf)
+}
+
+// TempoTraceByIDV2 wraps the trace in a response object, like the v2 endpoint
+// of Tempo does.
+func TempoTraceByIDV2(conf *Config, querier TempoQuerier) http.Handler {
+	hf := corsWrapper(conf, tempoTraceByID(querier, true))
+	return gziphandler.GzipHandler(hf)
+}
+
+func TempoSearch(conf *Config, querier TempoQuerier) http.Handler {
+	hf := corsWrapper(conf, tempoSearch(querier))
+	return gziphandler.GzipHandler(hf)
+}
+
+func TempoSearchTags(conf *Config, querier TempoQuerier) http.Handler {
+	hf := corsWrapper(conf, tempoSearchTags(querier, false))
+	return gziphandler.GzipHandler(hf)
+}
+
+// TempoSearchTagsV2 groups the tag names by scope.
+func TempoSearchTagsV2(conf *Config, querier TempoQuerier) http.Handler {
+	hf := corsWrapper(conf, tempoSearchTags(querier, true))
+	return gziphandler.GzipHandler(hf)
+}
+
+func TempoSearchTagValues(conf *Config, querier TempoQuerier) http.Handler {
+	hf := corsWrapper(conf, tempoSearchTagValues(querier, false))
+	return gziphandler.GzipHandler(hf)
+}
+
+// TempoSearchTagValuesV2 takes TraceQL field names and returns typed values.
+func TempoSearchTagValuesV2(conf *Config, querier TempoQuerier) http.Handler {
+	hf := corsWrapper(conf, tempoSearchTagValues(querier, true))
+	return gziphandler.GzipHandler(hf)
+}
+
+// TempoEcho is used by Grafana to test the Tempo datasource.
+func TempoEcho(w http.ResponseWriter, _ *http.Request) {
+	_, _ = w.Write([]byte("echo"))
+}
+
+func tempoTraceByID(querier TempoQuerier, v2 bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		traceID, err := parseTempoTraceID(mux.Vars(r)["traceID"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		traces, err := querier.GetOTLPTrace(r.Context(), traceID)
+		if err != nil {
+			if errors.Is(err, spanstore.ErrTraceNotFound) {
+				http.Error(w, "trace not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// The v1 route only matches protobuf requests, JSON ones are
+		// served by the Jaeger query API.
+		if !v2 || strings.Contains(r.Header.Get("Accept"), protobufContentType) {
+			// The Tempo trace message has the same encoding as OTLP
+			// TracesData, the v2 response holds it in field 1.
+			b, err := tracesProtoMarshaler.MarshalTraces(traces)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if v2 {
+				b = protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), b)
+			}
+			w.Header().Set("Content-Type", protobufContentType)
+			_, _ = w.Write(b)
+			return
+		}
+
+		b, err := tracesJSONMarshaler.MarshalTraces(traces)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondTempoJSON(w, map[string]json.RawMessage{"trace": b})
+	}
+}
+
+func tempoSearch(querier TempoQuerier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params, err := parseTempoSearchParams(r)
+		if err != nil {
+			log.Info("msg", "Tempo search bad request:"+err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		spansPerSpanSet := defaultTempoSpansPerSpanSet
+		if s := r.FormValue("spss"); s != "" {
+			if spansPerSpanSet, err = strconv.Atoi(s); err != nil || spansPerSpanSet < 1 {
+				http.Error(w, "spss must be a positive integer", http.StatusBadRequest)
+				return
+			}
+		}
+
+		matches, err := querier.SearchTraces(r.Context(), params.query, params.start, params.end, params.limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res := tempoSearchResponse{Traces: make([]tempoTraceMetadata, 0, len(matches))}
+		for _, m := range matches {
+			spanSet := &tempoSpanSet{Matched: len(m.Spans), Spans: []tempoSpan{}}
+			for i := 0; i < len(m.Spans) && i < spansPerSpanSet; i++ {
+				s := m.Spans[i]
+				spanSet.Spans = append(spanSet.Spans, tempoSpan{
+					SpanID:            s.SpanID.HexString(),
+					StartTimeUnixNano: strconv.FormatInt(s.StartTime.UnixNano(), 10),
+					DurationNanos:     strconv.FormatInt(s.Duration.Nanoseconds(), 10),
+				})
+			}
+			res.Traces = append(res.Traces, tempoTraceMetadata{
+				TraceID:           m.TraceID.HexString(),
+				RootServiceName:   m.RootServiceName,
+				RootTraceName:     m.RootSpanName,
+				StartTimeUnixNano: strconv.FormatInt(m.StartTime.UnixNano(), 10),
+				DurationMs:        m.Duration.Milliseconds(),
+				SpanSet:           spanSet,
+			})
+		}
+		respondTempoJSON(w, res)
+	}
+}
+
+func tempoSearchTags(querier TempoQuerier, v2 bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		names, err := querier.SearchTagNames(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !v2 {
+			respondTempoJSON(w, map[string][]string{"tagNames": mergeSorted(names.Span, names.Resource)})
+			return
+		}
+
+		scopes := []tempoTagScope{
+			{Name: "span", Tags: names.Span},
+			{Name: "resource", Tags: names.Resource},
+			{Name: "intrinsic", Tags: []string{traceql.IntrinsicDuration, traceql.IntrinsicKind, traceql.IntrinsicName, traceql.IntrinsicStatus}},
+		}
+		if scope := r.FormValue("scope"); scope != "" && scope != "all" {
+			filtered := scopes[:0]
+			for _, s := range scopes {
+				if s.Name == scope {
+					filtered = append(filtered, s)
+				}
+			}
+			if len(filtered) == 0 {
+				http.Error(w, fmt.Sprintf("unknown scope %q", scope), http.StatusBadRequest)
+				return
+			}
+			scopes = filtered
+		}
+		respondTempoJSON(w, map[string][]tempoTagScope{"scopes": scopes})
+	}
+}
+
+func tempoSearchTagValues(querier TempoQuerier, v2 bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, err := url.PathUnescape(mux.Vars(r)["name"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Tempo v1 tag names are attribute names of any scope.
+		field := traceql.Field{Scope: traceql.ScopeAny, Name: name}
+		if v2 {
+			if field, err = traceql.ParseField(name); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		values, err := querier.SearchTagValues(r.Context(), field, maxTempoTagValues)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if !v2 {
+			res := make([]string, 0, len(values))
+			for _, v := range values {
+				res = append(res, v.Value)
+			}
+			respondTempoJSON(w, map[string][]string{"tagValues": res})
+			return
+		}
+		res := make([]tempoTagValue, 0, len(values))
+		for _, v := range values {
+			res = append(res, tempoTagValue{Type: tempoValueType(v), Value: v.Value})
+		}
+		respondTempoJSON(w, map[string][]tempoTagValue{"tagValues": res})
+	}
+}
+
+// parseTempoSearchParams parses a TraceQL query from q or else builds one
+// matching spans with the tags, given in logfmt, in traces lasting between
+// minDuration and maxDuration.
+func parseTempoSearchParams(r *http.Request) (traceSearchParams, error) {
+	if r.FormValue("q") != "" {
+		return parseTraceSearchParams(r, "q")
+	}
+
+	var expr traceql.FieldExpr
+	and := func(e traceql.FieldExpr) {
+		if expr == nil {
+			expr = e
+			return
+		}
+		expr = &traceql.LogicalExpr{Op: traceql.LogicalAnd, LHS: expr, RHS: e}
+	}
+	d := logfmt.NewDecoder(strings.NewReader(r.FormValue("tags")))
+	for d.ScanRecord() {
+		for d.ScanKeyval() {
+			and(tempoTagComparison(string(d.Key()), string(d.Value())))
+		}
+	}
+	if err := d.Err(); err != nil {
+		return traceSearchParams{}, fmt.Errorf("invalid tags: %w", err)
+	}
+	query := &traceql.Query{Spans: &traceql.SpansetFilter{Expr: expr}}
+	for _, bound := range []struct {
+		param string
+		dur   *time.Duration
+	}{{"minDuration", &query.MinTraceDuration}, {"maxDuration", &query.MaxTraceDuration}} {
+		s := r.FormValue(bound.param)
+		if s == "" {
+			continue
+		}
+		dur, err := time.ParseDuration(s)
+		if err != nil {
+			return traceSearchParams{}, fmt.Errorf("invalid %s: %w", bound.param, err)
+		}
+		*bound.dur = dur
+	}
+	return parseTraceSearchWindow(r, query)
+}
+
+// tempoTagComparison matches an attribute of the span or its resource. Tag
+// values are strings, so numeric attributes are matched too when the value is
+// a number.
+func tempoTagComparison(key, value string) traceql.FieldExpr {
+	field := traceql.Field{Scope: traceql.ScopeAny, Name: key}
+	if key == traceql.IntrinsicName {
+		field.Scope = traceql.ScopeIntrinsic
+	}
+	var e traceql.FieldExpr = &traceql.Comparison{Field: field, Op: traceql.OpEqual, Value: traceql.Value{Type: traceql.TypeString, Str: value}}
+	if f, err := strconv.ParseFloat(value, 64); err == nil && field.Scope == traceql.ScopeAny {
+		e = &traceql.LogicalExpr{
+			Op:  traceql.LogicalOr,
+			LHS: e,
+			RHS: &traceql.Comparison{Field: field, Op: traceql.OpEqual, Value: traceql.Value{Type: traceql.TypeNumber, Number: f}},
+		}
+	}
+	return e
+}
+
+func parseTempoTraceID(s string) (pcommon.TraceID, error) {
+	var id [16]byte
+	if len(s) == 0 || len(s) > 32 {
+		return pcommon.TraceID(id), fmt.Errorf("invalid trace ID %q", s)
+	}
+	// Tempo trace IDs can have their leading zeros trimmed.
+	b, err := encodinghex.DecodeString(strings.Repeat("0", 32-len(s)) + s)
+	if err != nil {
+		return pcommon.TraceID(id), fmt.Errorf("invalid trace ID %q", s)
+	}
+	copy(id[:], b)
+	return pcommon.TraceID(id), nil
+}
+
+func tempoValueType(v jaegerStore.TagValue) string {
+	switch v.Type {
+	case "number":
+		if _, err := strconv.ParseInt(v.Value, 10, 64); err == nil {
+			return "int"
+		}
+		return "float"
+	case "boolean":
+		return "bool"
+	}
+	return v.Type
+}
+
+func mergeSorted(a, b []string) []string {
+	set := make(map[string]struct{}, len(a)+len(b))
+	for _, s := range a {
+		set[s] = struct{}{}
+	}
+	for _, s := range b {
+		set[s] = struct{}{}
+	}
+	res := make([]string, 0, len(set))
+	for s := range set {
+		res = append(res, s)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func respondTempoJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error("msg", "error writing Tempo response", "err", err)
+	}
+}
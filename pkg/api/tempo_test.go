@@ -0,0 +1,256 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaegertracing/jaeger/storage/spanstore"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	jaegerStore "github.com/timescale/promscale/pkg/jaeger/store"
+	"github.com/timescale/promscale/pkg/traceql"
+)
+
+type mockTempoQuerier struct {
+	mockTraceSearcher
+	traces  map[pcommon.TraceID]ptrace.Traces
+	names   jaegerStore.TagNames
+	values  []jaegerStore.TagValue
+	field   traceql.Field
+	traceID pcommon.TraceID
+}
+
+func (m *mockTempoQuerier) GetOTLPTrace(_ context.Context, traceID pcommon.TraceID) (ptrace.Traces, error) {
+	m.traceID = traceID
+	t, ok := m.traces[traceID]
+	if !ok {
+		return ptrace.Traces{}, spanstore.ErrTraceNotFound
+	}
+	return t, nil
+}
+
+func (m *mockTempoQuerier) SearchTagNames(context.Context) (jaegerStore.TagNames, error) {
+	return m.names, nil
+}
+
+func (m *mockTempoQuerier) SearchTagValues(_ context.Context, field traceql.Field, _ int) ([]jaegerStore.TagValue, error) {
+	m.field = field
+	return m.values, nil
+}
+
+func newTempoTestRouter(querier TempoQuerier) *mux.Router {
+	router := mux.NewRouter().UseEncodedPath()
+	router.Path("/api/traces/{traceID}").MatcherFunc(acceptsProtobuf).HandlerFunc(tempoTraceByID(querier, false))
+	router.Path("/api/traces/{traceID}").HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("jaeger"))
+	})
+	router.Path("/api/v2/traces/{traceID}").HandlerFunc(tempoTraceByID(querier, true))
+	router.Path("/api/search").HandlerFunc(tempoSearch(querier))
+	router.Path("/api/search/tags").HandlerFunc(tempoSearchTags(querier, false))
+	router.Path("/api/search/tag/{name}/values").HandlerFunc(tempoSearchTagValues(querier, false))
+	router.Path("/api/v2/search/tags").HandlerFunc(tempoSearchTags(querier, true))
+	router.Path("/api/v2/search/tag/{name}/values").HandlerFunc(tempoSearchTagValues(querier, true))
+	return router
+}
+
+func doTempoRequest(router http.Handler, path, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestTempoTraceByID(t *testing.T) {
+	traceID := pcommon.TraceID([16]byte{15: 0xab})
+	traces := ptrace.NewTraces()
+	span := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(traceID)
+	span.SetName("GET /")
+	querier := &mockTempoQuerier{traces: map[pcommon.TraceID]ptrace.Traces{traceID: traces}}
+	router := newTempoTestRouter(querier)
+
+	// Without asking for protobuf the Jaeger API is used.
+	w := doTempoRequest(router, "/api/traces/ab", "")
+	require.Equal(t, "jaeger", w.Body.String())
+
+	// Leading zeros can be omitted.
+	w = doTempoRequest(router, "/api/traces/ab", protobufContentType)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, protobufContentType, w.Header().Get("Content-Type"))
+	require.Equal(t, traceID, querier.traceID)
+	got, err := ptrace.NewProtoUnmarshaler().UnmarshalTraces(w.Body.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, traces, got)
+
+	w = doTempoRequest(router, "/api/v2/traces/000000000000000000000000000000ab", protobufContentType)
+	require.Equal(t, http.StatusOK, w.Code)
+	num, typ, n := protowire.ConsumeTag(w.Body.Bytes())
+	require.Equal(t, protowire.Number(1), num)
+	require.Equal(t, protowire.BytesType, typ)
+	b, _ := protowire.ConsumeBytes(w.Body.Bytes()[n:])
+	got, err = ptrace.NewProtoUnmarshaler().UnmarshalTraces(b)
+	require.NoError(t, err)
+	require.Equal(t, traces, got)
+
+	w = doTempoRequest(router, "/api/v2/traces/ab", "application/json")
+	require.Equal(t, http.StatusOK, w.Code)
+	var v2 map[string]map[string][]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &v2))
+	require.Len(t, v2["trace"]["resourceSpans"], 1)
+
+	w = doTempoRequest(router, "/api/v2/traces/cd", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doTempoRequest(router, "/api/v2/traces/xyz", "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w = doTempoRequest(router, "/api/v2/traces/000000000000000000000000000000000", "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestTempoSearch(t *testing.T) {
+	startTime := time.Unix(100, 0)
+	querier := &mockTempoQuerier{mockTraceSearcher: mockTraceSearcher{matches: []jaegerStore.TraceMatch{{
+		TraceID:         pcommon.TraceID([16]byte{1}),
+		RootServiceName: "api",
+		RootSpanName:    "GET /",
+		StartTime:       startTime,
+		Duration:        2 * time.Second,
+		Spans: []jaegerStore.SpanMatch{
+			{SpanID: pcommon.SpanID([8]byte{1}), StartTime: startTime, Duration: time.Second},
+			{SpanID: pcommon.SpanID([8]byte{2}), StartTime: startTime, Duration: time.Second},
+		},
+	}}}}
+	router := newTempoTestRouter(querier)
+
+	testCases := []struct {
+		name        string
+		path        string
+		expectCode  int
+		expectQuery string
+		expectMin   time.Duration
+		expectMax   time.Duration
+	}{
+		{
+			name:        "no filter",
+			path:        "/api/search",
+			expectCode:  http.StatusOK,
+			expectQuery: `{}`,
+		},
+		{
+			name:        "tags and durations",
+			path:        `/api/search?tags=service.name%3D%22my%20api%22+name%3DGET+http.status_code%3D500&minDuration=1m30s&maxDuration=2h`,
+			expectCode:  http.StatusOK,
+			expectQuery: `{ ((.service.name = "my api" && name = "GET") && (.http.status_code = "500" || .http.status_code = 500)) }`,
+			expectMin:   90 * time.Second,
+			expectMax:   2 * time.Hour,
+		},
+		{
+			name:        "TraceQL",
+			path:        `/api/search?q=%7B+status+%3D+error+%7D&tags=ignored%3D1`,
+			expectCode:  http.StatusOK,
+			expectQuery: `{ status = error }`,
+		},
+		{
+			name:       "invalid TraceQL",
+			path:       `/api/search?q=%7B`,
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid duration",
+			path:       `/api/search?minDuration=1`,
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid spss",
+			path:       `/api/search?spss=0`,
+			expectCode: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := doTempoRequest(router, tc.path, "")
+			require.Equal(t, tc.expectCode, w.Code, w.Body.String())
+			if tc.expectCode != http.StatusOK {
+				return
+			}
+			require.Equal(t, tc.expectQuery, querier.query.String())
+			// Duration bounds apply to whole traces, not to each span.
+			require.Equal(t, tc.expectMin, querier.query.MinTraceDuration)
+			require.Equal(t, tc.expectMax, querier.query.MaxTraceDuration)
+		})
+	}
+
+	w := doTempoRequest(router, "/api/search?spss=1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{
+		"traces": [{
+			"traceID": "01000000000000000000000000000000",
+			"rootServiceName": "api",
+			"rootTraceName": "GET /",
+			"startTimeUnixNano": "100000000000",
+			"durationMs": 2000,
+			"spanSet": {
+				"spans": [{"spanID": "0100000000000000", "startTimeUnixNano": "100000000000", "durationNanos": "1000000000"}],
+				"matched": 2
+			}
+		}],
+		"metrics": {}
+	}`, w.Body.String())
+}
+
+func TestTempoSearchTags(t *testing.T) {
+	querier := &mockTempoQuerier{
+		names: jaegerStore.TagNames{Span: []string{"http.method", "service.name"}, Resource: []string{"service.name"}},
+		values: []jaegerStore.TagValue{
+			{Type: "string", Value: "a"},
+			{Type: "number", Value: "1"},
+			{Type: "number", Value: "1.5"},
+			{Type: "boolean", Value: "true"},
+		},
+	}
+	router := newTempoTestRouter(querier)
+
+	w := doTempoRequest(router, "/api/search/tags", "")
+	require.JSONEq(t, `{"tagNames": ["http.method", "service.name"]}`, w.Body.String())
+
+	w = doTempoRequest(router, "/api/v2/search/tags?scope=resource", "")
+	require.JSONEq(t, `{"scopes": [{"name": "resource", "tags": ["service.name"]}]}`, w.Body.String())
+	w = doTempoRequest(router, "/api/v2/search/tags", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var scopes map[string][]tempoTagScope
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &scopes))
+	require.Len(t, scopes["scopes"], 3)
+	w = doTempoRequest(router, "/api/v2/search/tags?scope=link", "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doTempoRequest(router, "/api/search/tag/http.method/values", "")
+	require.JSONEq(t, `{"tagValues": ["a", "1", "1.5", "true"]}`, w.Body.String())
+	require.Equal(t, traceql.Field{Scope: traceql.ScopeAny, Name: "http.method"}, querier.field)
+
+	w = doTempoRequest(router, "/api/v2/search/tag/resource.service.name/values", "")
+	require.JSONEq(t, `{"tagValues": [
+		{"type": "string", "value": "a"},
+		{"type": "int", "value": "1"},
+		{"type": "float", "value": "1.5"},
+		{"type": "bool", "value": "true"}
+	]}`, w.Body.String())
+	require.Equal(t, traceql.Field{Scope: traceql.ScopeResource, Name: "service.name"}, querier.field)
+
+	w = doTempoRequest(router, "/api/v2/search/tag/unknown/values", "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
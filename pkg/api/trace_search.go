@@ -0,0 +1,137 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NYTimes/gziphandler"
+	"github.com/pkg/errors"
+
+	jaegerStore "github.com/timescale/promscale/pkg/jaeger/store"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/traceql"
+)
+
+const (
+	defaultTraceSearchRange = time.Hour
+	defaultTraceSearchLimit = 20
+	maxTraceSearchLimit     = 1000
+)
+
+// TraceSearcher searches traces with a TraceQL-style query.
+type TraceSearcher interface {
+	SearchTraces(ctx context.Context, query *traceql.Query, start, end time.Time, limit int) ([]jaegerStore.TraceMatch, error)
+}
+
+type traceSearchResult struct {
+	TraceID         string            `json:"traceID"`
+	RootServiceName string            `json:"rootServiceName"`
+	RootSpanName    string            `json:"rootSpanName"`
+	StartTime       time.Time         `json:"startTime"`
+	DurationMs      float64           `json:"durationMs"`
+	SpanCount       int               `json:"spanCount"`
+	MatchedSpans    []traceSearchSpan `json:"matchedSpans"`
+}
+
+type traceSearchSpan struct {
+	SpanID     string    `json:"spanID"`
+	StartTime  time.Time `json:"startTime"`
+	DurationMs float64   `json:"durationMs"`
+}
+
+func TraceSearch(conf *Config, searcher TraceSearcher) http.Handler {
+	hf := corsWrapper(conf, traceSearch(searcher))
+	return gziphandler.GzipHandler(hf)
+}
+
+func traceSearch(searcher TraceSearcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params, err := parseTraceSearchParams(r, "query")
+		if err != nil {
+			log.Info("msg", "Trace search bad request:"+err.Error())
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			return
+		}
+
+		matches, err := searcher.SearchTraces(r.Context(), params.query, params.start, params.end, params.limit)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err, "execution")
+			return
+		}
+		results := make([]traceSearchResult, 0, len(matches))
+		for _, m := range matches {
+			res := traceSearchResult{
+				TraceID:         m.TraceID.HexString(),
+				RootServiceName: m.RootServiceName,
+				RootSpanName:    m.RootSpanName,
+				StartTime:       m.StartTime,
+				DurationMs:      durationMs(m.Duration),
+				SpanCount:       m.SpanCount,
+				MatchedSpans:    make([]traceSearchSpan, 0, len(m.Spans)),
+			}
+			for _, s := range m.Spans {
+				res.MatchedSpans = append(res.MatchedSpans, traceSearchSpan{
+					SpanID:     s.SpanID.HexString(),
+					StartTime:  s.StartTime,
+					DurationMs: durationMs(s.Duration),
+				})
+			}
+			results = append(results, res)
+		}
+		respond(w, http.StatusOK, results)
+	}
+}
+
+type traceSearchParams struct {
+	query      *traceql.Query
+	start, end time.Time
+	limit      int
+}
+
+// parseTraceSearchParams parses the query from the queryParam form value, the
+// time range from start and end, and the maximum number of traces from limit.
+func parseTraceSearchParams(r *http.Request, queryParam string) (traceSearchParams, error) {
+	q := r.FormValue(queryParam)
+	if q == "" {
+		return traceSearchParams{}, errors.Errorf("no %s parameter provided", queryParam)
+	}
+	query, err := traceql.Parse(q)
+	if err != nil {
+		return traceSearchParams{}, err
+	}
+	return parseTraceSearchWindow(r, query)
+}
+
+// parseTraceSearchWindow parses the time range and the limit of a search.
+func parseTraceSearchWindow(r *http.Request, query *traceql.Query) (traceSearchParams, error) {
+	var (
+		p   = traceSearchParams{query: query}
+		err error
+	)
+	if p.end, err = parseTimeParam(r, "end", time.Now()); err != nil {
+		return p, err
+	}
+	if p.start, err = parseTimeParam(r, "start", p.end.Add(-defaultTraceSearchRange)); err != nil {
+		return p, err
+	}
+	if p.end.Before(p.start) {
+		return p, errors.New("end timestamp must not be before start time")
+	}
+	p.limit = defaultTraceSearchLimit
+	if l := r.FormValue("limit"); l != "" {
+		if p.limit, err = strconv.Atoi(l); err != nil || p.limit < 1 || p.limit > maxTraceSearchLimit {
+			return p, errors.Errorf("limit must be an integer between 1 and %d", maxTraceSearchLimit)
+		}
+	}
+	return p, nil
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
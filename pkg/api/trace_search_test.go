@@ -0,0 +1,133 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+
+	jaegerStore "github.com/timescale/promscale/pkg/jaeger/store"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/traceql"
+)
+
+type mockTraceSearcher struct {
+	matches    []jaegerStore.TraceMatch
+	err        error
+	query      *traceql.Query
+	start, end time.Time
+	limit      int
+}
+
+func (m *mockTraceSearcher) SearchTraces(_ context.Context, query *traceql.Query, start, end time.Time, limit int) ([]jaegerStore.TraceMatch, error) {
+	m.query, m.start, m.end, m.limit = query, start, end, limit
+	return m.matches, m.err
+}
+
+func TestTraceSearch(t *testing.T) {
+	_ = log.Init(log.Config{
+		Level: "debug",
+	})
+	startTime := time.Unix(1000, 0).UTC()
+	match := jaegerStore.TraceMatch{
+		TraceID:         pcommon.TraceID([16]byte{1, 2, 3}),
+		RootServiceName: "api",
+		RootSpanName:    "GET /",
+		StartTime:       startTime,
+		Duration:        1500 * time.Microsecond,
+		SpanCount:       3,
+		Spans: []jaegerStore.SpanMatch{
+			{SpanID: pcommon.SpanID([8]byte{4}), StartTime: startTime, Duration: time.Millisecond},
+		},
+	}
+	testCases := []struct {
+		name        string
+		params      url.Values
+		searcher    *mockTraceSearcher
+		expectCode  int
+		expectLimit int
+	}{
+		{
+			name:       "missing query",
+			params:     url.Values{},
+			searcher:   &mockTraceSearcher{},
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid query",
+			params:     url.Values{"query": {`{ foo = "a" }`}},
+			searcher:   &mockTraceSearcher{},
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid limit",
+			params:     url.Values{"query": {`{}`}, "limit": {"0"}},
+			searcher:   &mockTraceSearcher{},
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			name:       "end before start",
+			params:     url.Values{"query": {`{}`}, "start": {"20"}, "end": {"10"}},
+			searcher:   &mockTraceSearcher{},
+			expectCode: http.StatusBadRequest,
+		},
+		{
+			name:       "search error",
+			params:     url.Values{"query": {`{}`}},
+			searcher:   &mockTraceSearcher{err: fmt.Errorf("some error")},
+			expectCode: http.StatusInternalServerError,
+		},
+		{
+			name:        "all good",
+			params:      url.Values{"query": {`{ status = error }`}, "start": {"10"}, "end": {"20"}, "limit": {"5"}},
+			searcher:    &mockTraceSearcher{matches: []jaegerStore.TraceMatch{match}},
+			expectCode:  http.StatusOK,
+			expectLimit: 5,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/traces/search?"+tc.params.Encode(), nil)
+			w := httptest.NewRecorder()
+			traceSearch(tc.searcher).ServeHTTP(w, req)
+			require.Equal(t, tc.expectCode, w.Code, w.Body.String())
+			if tc.expectCode != http.StatusOK {
+				return
+			}
+
+			require.Equal(t, `{ status = error }`, tc.searcher.query.String())
+			require.True(t, time.Unix(10, 0).Equal(tc.searcher.start))
+			require.True(t, time.Unix(20, 0).Equal(tc.searcher.end))
+			require.Equal(t, tc.expectLimit, tc.searcher.limit)
+
+			var res struct {
+				Status string              `json:"status"`
+				Data   []traceSearchResult `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			require.Equal(t, "success", res.Status)
+			require.Equal(t, []traceSearchResult{{
+				TraceID:         "01020300000000000000000000000000",
+				RootServiceName: "api",
+				RootSpanName:    "GET /",
+				StartTime:       startTime,
+				DurationMs:      1.5,
+				SpanCount:       3,
+				MatchedSpans: []traceSearchSpan{
+					{SpanID: "0400000000000000", StartTime: startTime, DurationMs: 1},
+				},
+			}}, res.Data)
+		})
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	io_prometheus_client "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 
 	"github.com/timescale/promscale/pkg/api/parser"
@@ -280,6 +281,9 @@ type mockInserter struct {
 func (m *mockInserter) IngestTraces(_ context.Context, _ ptrace.Traces) error {
 	panic("not implemented") // TODO: Implement
 }
+func (m *mockInserter) IngestLogs(_ context.Context, _ plog.Logs) error {
+	panic("not implemented") // TODO: Implement
+}
 func (m *mockInserter) IngestMetrics(_ context.Context, r *prompb.WriteRequest) (uint64, uint64, error) {
 	m.ts = r.Timeseries
 	return uint64(m.result), 0, m.err
@@ -0,0 +1,61 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/tracer"
+	"github.com/timescale/promscale/pkg/zipkin"
+)
+
+// ZipkinWrite returns an http.Handler ingesting the Zipkin v2 spans sent to
+// the /api/v2/spans endpoint of the Zipkin API, in JSON or protobuf.
+func ZipkinWrite(inserter ingestor.DBInserter) http.Handler {
+	wh := writeHandler{}
+	wh.addStages(
+		decodeGzip,
+		ingestZipkin(inserter),
+	)
+	return wh.handler()
+}
+
+func ingestZipkin(inserter ingestor.DBInserter) writeStage {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		ctx, span := tracer.Default().Start(r.Context(), "ingest-zipkin")
+		defer span.End()
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			invalidRequestError(w, "error reading Zipkin spans", err.Error(), metrics)
+			return false
+		}
+		decode := zipkin.DecodeJSON
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/x-protobuf" {
+			decode = zipkin.DecodeProto
+		}
+		spans, err := decode(body)
+		if err != nil {
+			invalidRequestError(w, "Zipkin decode error", err.Error(), metrics)
+			return false
+		}
+		traces, err := zipkin.ToTraces(spans)
+		if err != nil {
+			invalidRequestError(w, "Zipkin conversion error", err.Error(), metrics)
+			return false
+		}
+		if err = inserter.IngestTraces(ctx, traces); err != nil {
+			log.Warn("msg", "Error ingesting Zipkin spans", "err", err, "num_spans", len(spans))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return false
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return true
+	}
+}
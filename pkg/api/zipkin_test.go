@@ -0,0 +1,60 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+type mockTraceInserter struct {
+	mockInserter
+	traces ptrace.Traces
+	logs   plog.Logs
+}
+
+func (m *mockTraceInserter) IngestLogs(_ context.Context, l plog.Logs) error {
+	m.logs = l
+	return m.err
+}
+
+func (m *mockTraceInserter) IngestTraces(_ context.Context, tr ptrace.Traces) error {
+	m.traces = tr
+	return m.err
+}
+
+func TestZipkinWrite(t *testing.T) {
+	testCases := []struct {
+		name  string
+		body  string
+		err   error
+		code  int
+		spans int
+	}{
+		{name: "json", body: `[{"traceId":"1","id":"2","name":"a","timestamp":1000,"localEndpoint":{"serviceName":"s"}}]`, code: http.StatusAccepted, spans: 1},
+		{name: "invalid json", body: `{`, code: http.StatusBadRequest},
+		{name: "invalid span", body: `[{"traceId":"x","id":"2"}]`, code: http.StatusBadRequest},
+		{name: "ingest error", body: `[{"traceId":"1","id":"2"}]`, err: fmt.Errorf("db down"), code: http.StatusInternalServerError, spans: 1},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mock := &mockTraceInserter{mockInserter: mockInserter{err: c.err}, traces: ptrace.NewTraces()}
+			req := httptest.NewRequest(http.MethodPost, "/api/v2/spans", strings.NewReader(c.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			ZipkinWrite(mock).ServeHTTP(w, req)
+			require.Equal(t, c.code, w.Code, w.Body.String())
+			require.Equal(t, c.spans, mock.traces.SpanCount())
+		})
+	}
+}
@@ -0,0 +1,55 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package graphite
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+type Config struct {
+	ListenAddress       string
+	PickleListenAddress string
+	MappingFile         string
+	StrictMatch         bool
+	Mappings            []MappingConfig
+}
+
+// Enabled tells if any Graphite listener is configured.
+func (cfg *Config) Enabled() bool {
+	return cfg.ListenAddress != "" || cfg.PickleListenAddress != ""
+}
+
+func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
+	fs.StringVar(&cfg.ListenAddress, "metrics.graphite.listen-address", "", "Address to listen on for the Graphite plaintext protocol, over both TCP and UDP. Disabled if empty.")
+	fs.StringVar(&cfg.PickleListenAddress, "metrics.graphite.pickle-listen-address", "", "Address to listen on for the Graphite pickle protocol over TCP. Disabled if empty.")
+	fs.StringVar(&cfg.MappingFile, "metrics.graphite.mapping-file", "", "Path to the YAML file with the mappings of Graphite metric paths to Prometheus metric names and labels. "+
+		"Without a matching mapping, the dots of the path are replaced with underscores.")
+	fs.BoolVar(&cfg.StrictMatch, "metrics.graphite.strict-match", false, "Only ingest the Graphite metrics matching a mapping.")
+	return cfg
+}
+
+func Validate(cfg *Config) error {
+	if !cfg.Enabled() {
+		return nil
+	}
+	if cfg.StrictMatch && cfg.MappingFile == "" {
+		return fmt.Errorf("metrics.graphite.mapping-file is required when metrics.graphite.strict-match is set")
+	}
+	if cfg.MappingFile == "" {
+		return nil
+	}
+	content, err := os.ReadFile(cfg.MappingFile)
+	if err != nil {
+		return fmt.Errorf("error reading Graphite mapping file: %w", err)
+	}
+	mappings, err := ParseMappings(content)
+	if err != nil {
+		return fmt.Errorf("error parsing Graphite mapping file: %w", err)
+	}
+	cfg.Mappings = mappings
+	return nil
+}
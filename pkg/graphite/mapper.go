@@ -0,0 +1,157 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package graphite
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grafana/regexp"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/util/strutil"
+	"gopkg.in/yaml.v2"
+)
+
+// MatchType is the kind of pattern of a mapping.
+type MatchType string
+
+const (
+	// MatchTypeGlob patterns are dotted paths where * matches one path
+	// component or a part of it.
+	MatchTypeGlob MatchType = "glob"
+	// MatchTypeRegex patterns are regular expressions matching the whole path.
+	MatchTypeRegex MatchType = "regex"
+)
+
+// Action is what is done with the metrics matching a mapping.
+type Action string
+
+const (
+	ActionMap  Action = "map"
+	ActionDrop Action = "drop"
+)
+
+// MappingConfig maps the Graphite paths matching a pattern to a metric name
+// and labels. The name and label values can reference the parts matched by the
+// wildcards of a glob, or the groups of a regex, with $1, $2...
+type MappingConfig struct {
+	Match     string            `yaml:"match"`
+	MatchType MatchType         `yaml:"match_type,omitempty"`
+	Name      string            `yaml:"name"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+	Action    Action            `yaml:"action,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+// MappingsConfig is the content of the mapping file.
+type MappingsConfig struct {
+	Mappings []MappingConfig `yaml:"mappings"`
+}
+
+// ParseMappings parses and validates the YAML content of a mapping file.
+func ParseMappings(content []byte) ([]MappingConfig, error) {
+	var mc MappingsConfig
+	if err := yaml.UnmarshalStrict(content, &mc); err != nil {
+		return nil, err
+	}
+	for i := range mc.Mappings {
+		m := &mc.Mappings[i]
+		if err := m.validate(); err != nil {
+			return nil, fmt.Errorf("mapping %q: %w", m.Match, err)
+		}
+	}
+	return mc.Mappings, nil
+}
+
+func (m *MappingConfig) validate() error {
+	if m.Match == "" {
+		return fmt.Errorf("match is required")
+	}
+	if m.Action == "" {
+		m.Action = ActionMap
+	}
+	switch m.Action {
+	case ActionMap:
+		if m.Name == "" {
+			return fmt.Errorf("name is required")
+		}
+	case ActionDrop:
+	default:
+		return fmt.Errorf("unknown action %q", m.Action)
+	}
+	for name := range m.Labels {
+		if !model.LabelName(name).IsValid() || name == model.MetricNameLabel {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	pattern := m.Match
+	switch m.MatchType {
+	case "", MatchTypeGlob:
+		m.MatchType = MatchTypeGlob
+		pattern = globToRegex(m.Match)
+	case MatchTypeRegex:
+	default:
+		return fmt.Errorf("unknown match type %q", m.MatchType)
+	}
+	r, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid match: %w", err)
+	}
+	m.regex = r
+	return nil
+}
+
+// globToRegex turns a glob into a regex with a group per wildcard.
+func globToRegex(glob string) string {
+	parts := strings.Split(glob, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return strings.Join(parts, "([^.]*)")
+}
+
+// Mapper maps Graphite paths to Prometheus metric names and labels using the
+// first matching mapping.
+type Mapper struct {
+	mappings []MappingConfig
+}
+
+func NewMapper(mappings []MappingConfig) *Mapper {
+	return &Mapper{mappings: mappings}
+}
+
+// Map returns the metric name and labels of the path, and the mapping that
+// matched it, or nil if none did, in which case the name is the path with
+// invalid characters replaced with underscores. It returns false if the path
+// must be dropped.
+func (m *Mapper) Map(path string) (name string, labels map[string]string, mapping *MappingConfig, keep bool) {
+	for i := range m.mappings {
+		mapping = &m.mappings[i]
+		match := mapping.regex.FindStringSubmatchIndex(path)
+		if match == nil {
+			continue
+		}
+		if mapping.Action == ActionDrop {
+			return "", nil, mapping, false
+		}
+		name = string(mapping.regex.ExpandString(nil, mapping.Name, path, match))
+		labels = make(map[string]string, len(mapping.Labels))
+		for k, v := range mapping.Labels {
+			labels[k] = string(mapping.regex.ExpandString(nil, v, path, match))
+		}
+		return sanitizeName(name), labels, mapping, true
+	}
+	return sanitizeName(path), nil, nil, true
+}
+
+// sanitizeName turns s into a valid Prometheus metric or label name.
+func sanitizeName(s string) string {
+	s = strutil.SanitizeLabelName(s)
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		s = "_" + s
+	}
+	return s
+}
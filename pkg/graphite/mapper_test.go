@@ -0,0 +1,95 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package graphite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMappings(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{
+			name: "valid",
+			content: `
+mappings:
+- match: servers.*.cpu.*
+  name: cpu_${2}
+  labels:
+    host: $1
+- match: '^apps\.(\w+)\.requests$'
+  match_type: regex
+  name: requests_total
+- match: debug.*
+  action: drop
+`,
+		},
+		{name: "missing name", content: "mappings:\n- match: a.*\n", err: `mapping "a.*": name is required`},
+		{name: "invalid label", content: "mappings:\n- match: a.*\n  name: a\n  labels:\n    1x: y\n", err: `mapping "a.*": invalid label name "1x"`},
+		{name: "invalid regex", content: "mappings:\n- match: a(\n  match_type: regex\n  name: a\n", err: "invalid match"},
+		{name: "unknown field", content: "mappings:\n- match: a\n  nme: a\n", err: "field nme not found"},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ParseMappings([]byte(c.content))
+			if c.err != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestMapper(t *testing.T) {
+	mappings, err := ParseMappings([]byte(`
+mappings:
+- match: servers.*.cpu.*
+  name: cpu_${2}_seconds
+  labels:
+    host: $1
+- match: 'apps\.(\w+)\.(get|post)\.requests'
+  match_type: regex
+  name: requests_total
+  labels:
+    app: $1
+    method: $2
+- match: debug.*
+  action: drop
+`))
+	require.NoError(t, err)
+	mapper := NewMapper(mappings)
+
+	testCases := []struct {
+		path    string
+		name    string
+		labels  map[string]string
+		matched bool
+		keep    bool
+	}{
+		{path: "servers.web-1.cpu.user", name: "cpu_user_seconds", labels: map[string]string{"host": "web-1"}, matched: true, keep: true},
+		{path: "apps.shop.get.requests", name: "requests_total", labels: map[string]string{"app": "shop", "method": "get"}, matched: true, keep: true},
+		{path: "servers.web-1.cpu.user.extra", name: "servers_web_1_cpu_user_extra", keep: true},
+		{path: "1.metric", name: "_1_metric", keep: true},
+		{path: "debug.anything", matched: true},
+	}
+	for _, c := range testCases {
+		t.Run(c.path, func(t *testing.T) {
+			name, labels, mapping, keep := mapper.Map(c.path)
+			require.Equal(t, c.keep, keep)
+			require.Equal(t, c.matched, mapping != nil)
+			require.Equal(t, c.name, name)
+			if c.labels != nil || labels != nil {
+				require.Equal(t, c.labels, labels)
+			}
+		})
+	}
+}
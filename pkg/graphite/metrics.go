@@ -0,0 +1,72 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package graphite
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/timescale/promscale/pkg/util"
+)
+
+var (
+	linesReceived = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "graphite",
+			Name:      "lines_total",
+			Help:      "Total number of Graphite metric lines received.",
+		}, []string{"protocol"},
+	)
+	invalidLines = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "graphite",
+			Name:      "invalid_lines_total",
+			Help:      "Total number of Graphite metric lines that could not be parsed.",
+		}, []string{"protocol"},
+	)
+	unmatchedLines = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "graphite",
+			Name:      "unmatched_lines_total",
+			Help:      "Total number of Graphite metric lines not matching any mapping.",
+		},
+	)
+	mappedLines = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "graphite",
+			Name:      "mapped_lines_total",
+			Help:      "Total number of Graphite metric lines matching a mapping, by match pattern.",
+		}, []string{"mapping"},
+	)
+	droppedLines = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "graphite",
+			Name:      "dropped_lines_total",
+			Help:      "Total number of Graphite metric lines dropped by a drop mapping or by strict matching.",
+		}, []string{"reason"},
+	)
+	ingestErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "graphite",
+			Name:      "ingest_errors_total",
+			Help:      "Total number of batches of Graphite metrics that failed to be ingested.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		linesReceived,
+		invalidLines,
+		unmatchedLines,
+		mappedLines,
+		droppedLines,
+		ingestErrors,
+	)
+}
@@ -0,0 +1,441 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package graphite
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Pickle opcodes used by the Graphite pickle protocol, a list of
+// (path, (timestamp, value)) tuples pickled with any protocol version.
+const (
+	opMark           = '('
+	opStop           = '.'
+	opPop            = '0'
+	opPopMark        = '1'
+	opDup            = '2'
+	opFloat          = 'F'
+	opInt            = 'I'
+	opBinInt         = 'J'
+	opBinInt1        = 'K'
+	opLong           = 'L'
+	opBinInt2        = 'M'
+	opNone           = 'N'
+	opString         = 'S'
+	opBinString      = 'T'
+	opShortBinString = 'U'
+	opUnicode        = 'V'
+	opBinUnicode     = 'X'
+	opAppend         = 'a'
+	opBinFloat       = 'G'
+	opAppends        = 'e'
+	opGet            = 'g'
+	opBinGet         = 'h'
+	opLongBinGet     = 'j'
+	opList           = 'l'
+	opEmptyList      = ']'
+	opPut            = 'p'
+	opBinPut         = 'q'
+	opLongBinPut     = 'r'
+	opTuple          = 't'
+	opEmptyTuple     = ')'
+	opBinBytes       = 'B'
+	opShortBinBytes  = 'C'
+	opProto          = 0x80
+	opTuple1         = 0x85
+	opTuple2         = 0x86
+	opTuple3         = 0x87
+	opNewTrue        = 0x88
+	opNewFalse       = 0x89
+	opLong1          = 0x8a
+	opLong4          = 0x8b
+	opShortBinUnicod = 0x8c
+	opBinUnicode8    = 0x8d
+	opBinBytes8      = 0x8e
+	opMemoize        = 0x94
+	opFrame          = 0x95
+)
+
+type mark struct{}
+
+// pickleList is mutable, unlike tuples, so it is referenced by pointer from
+// the stack and the memo.
+type pickleList struct {
+	items []interface{}
+}
+
+// unpickle decodes the subset of the pickle format made of lists, tuples,
+// strings and numbers.
+func unpickle(data []byte) (interface{}, error) {
+	r := bufio.NewReader(bytes.NewReader(data))
+	var (
+		stack []interface{}
+		memo  = make(map[int]interface{})
+	)
+	pop := func() (interface{}, error) {
+		if len(stack) == 0 {
+			return nil, errors.New("pickle: stack underflow")
+		}
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return v, nil
+	}
+	popMark := func() ([]interface{}, error) {
+		for i := len(stack) - 1; i >= 0; i-- {
+			if _, ok := stack[i].(mark); ok {
+				items := append([]interface{}(nil), stack[i+1:]...)
+				stack = stack[:i]
+				return items, nil
+			}
+		}
+		return nil, errors.New("pickle: mark not found")
+	}
+	top := func() (*pickleList, error) {
+		if len(stack) == 0 {
+			return nil, errors.New("pickle: stack underflow")
+		}
+		l, ok := stack[len(stack)-1].(*pickleList)
+		if !ok {
+			return nil, errors.New("pickle: append to a non-list")
+		}
+		return l, nil
+	}
+	readN := func(n int) ([]byte, error) {
+		if n < 0 || n > len(data) {
+			return nil, errors.New("pickle: invalid length")
+		}
+		b := make([]byte, n)
+		_, err := io.ReadFull(r, b)
+		return b, err
+	}
+	readUint := func(n int) (uint64, error) {
+		b, err := readN(n)
+		if err != nil {
+			return 0, err
+		}
+		var v uint64
+		for i := n - 1; i >= 0; i-- {
+			v = v<<8 | uint64(b[i])
+		}
+		return v, nil
+	}
+	readLine := func() (string, error) {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		return line[:len(line)-1], nil
+	}
+
+	for {
+		op, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("pickle: %w", err)
+		}
+		var (
+			v      interface{}
+			push   = true
+			memoID = -1
+		)
+		switch op {
+		case opStop:
+			return pop()
+		case opProto:
+			_, err = r.ReadByte()
+			push = false
+		case opFrame:
+			_, err = readN(8)
+			push = false
+		case opMark:
+			v = mark{}
+		case opPop:
+			_, err = pop()
+			push = false
+		case opPopMark:
+			_, err = popMark()
+			push = false
+		case opDup:
+			if len(stack) == 0 {
+				return nil, errors.New("pickle: stack underflow")
+			}
+			v = stack[len(stack)-1]
+		case opNone:
+			v = nil
+		case opNewTrue:
+			v = int64(1)
+		case opNewFalse:
+			v = int64(0)
+		case opInt:
+			var line string
+			if line, err = readLine(); err == nil {
+				// Protocol 0 pickles booleans as 01 and 00.
+				v, err = strconv.ParseInt(line, 10, 64)
+			}
+		case opLong:
+			var line string
+			if line, err = readLine(); err == nil {
+				i, ok := new(big.Int).SetString(trimSuffix(line, "L"), 10)
+				if !ok {
+					return nil, fmt.Errorf("pickle: invalid long %q", line)
+				}
+				v = bigToNumber(i)
+			}
+		case opBinInt:
+			var u uint64
+			u, err = readUint(4)
+			v = int64(int32(u))
+		case opBinInt1:
+			var u uint64
+			u, err = readUint(1)
+			v = int64(u)
+		case opBinInt2:
+			var u uint64
+			u, err = readUint(2)
+			v = int64(u)
+		case opLong1, opLong4:
+			n := 1
+			if op == opLong4 {
+				n = 4
+			}
+			var size uint64
+			if size, err = readUint(n); err == nil {
+				var b []byte
+				if b, err = readN(int(size)); err == nil {
+					v = decodeLong(b)
+				}
+			}
+		case opFloat:
+			var line string
+			if line, err = readLine(); err == nil {
+				v, err = strconv.ParseFloat(line, 64)
+			}
+		case opBinFloat:
+			var b []byte
+			if b, err = readN(8); err == nil {
+				v = math.Float64frombits(binary.BigEndian.Uint64(b))
+			}
+		case opString, opUnicode:
+			var line string
+			if line, err = readLine(); err == nil {
+				if op == opString {
+					v, err = unquote(line)
+				} else {
+					v = line
+				}
+			}
+		case opShortBinString, opShortBinBytes, opShortBinUnicod, opBinString, opBinBytes, opBinUnicode, opBinUnicode8, opBinBytes8:
+			n := 4
+			switch op {
+			case opShortBinString, opShortBinBytes, opShortBinUnicod:
+				n = 1
+			case opBinUnicode8, opBinBytes8:
+				n = 8
+			}
+			var size uint64
+			if size, err = readUint(n); err == nil {
+				var b []byte
+				if b, err = readN(int(size)); err == nil {
+					v = string(b)
+				}
+			}
+		case opEmptyList:
+			v = &pickleList{}
+		case opList:
+			var items []interface{}
+			items, err = popMark()
+			v = &pickleList{items: items}
+		case opEmptyTuple:
+			v = []interface{}{}
+		case opTuple:
+			v, err = popMark()
+		case opTuple1, opTuple2, opTuple3:
+			n := int(op-opTuple1) + 1
+			if len(stack) < n {
+				return nil, errors.New("pickle: stack underflow")
+			}
+			v = append([]interface{}(nil), stack[len(stack)-n:]...)
+			stack = stack[:len(stack)-n]
+		case opAppend:
+			var item interface{}
+			if item, err = pop(); err == nil {
+				var l *pickleList
+				if l, err = top(); err == nil {
+					l.items = append(l.items, item)
+				}
+			}
+			push = false
+		case opAppends:
+			var items []interface{}
+			if items, err = popMark(); err == nil {
+				var l *pickleList
+				if l, err = top(); err == nil {
+					l.items = append(l.items, items...)
+				}
+			}
+			push = false
+		case opPut:
+			var line string
+			if line, err = readLine(); err == nil {
+				memoID, err = strconv.Atoi(line)
+			}
+			push = false
+		case opBinPut, opLongBinPut:
+			n := 1
+			if op == opLongBinPut {
+				n = 4
+			}
+			var id uint64
+			id, err = readUint(n)
+			memoID = int(id)
+			push = false
+		case opMemoize:
+			memoID = len(memo)
+			push = false
+		case opGet, opBinGet, opLongBinGet:
+			var id int
+			switch op {
+			case opGet:
+				var line string
+				if line, err = readLine(); err == nil {
+					id, err = strconv.Atoi(line)
+				}
+			case opBinGet:
+				var u uint64
+				u, err = readUint(1)
+				id = int(u)
+			default:
+				var u uint64
+				u, err = readUint(4)
+				id = int(u)
+			}
+			if err == nil {
+				var ok bool
+				if v, ok = memo[id]; !ok {
+					err = fmt.Errorf("memo %d not found", id)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("pickle: unsupported opcode 0x%x", op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("pickle: %w", err)
+		}
+		if push {
+			stack = append(stack, v)
+		}
+		if memoID >= 0 {
+			if len(stack) == 0 {
+				return nil, errors.New("pickle: memoize with empty stack")
+			}
+			memo[memoID] = stack[len(stack)-1]
+		}
+	}
+}
+
+func trimSuffix(s, suffix string) string {
+	if len(s) > 0 && s[len(s)-len(suffix):] == suffix {
+		return s[:len(s)-len(suffix)]
+	}
+	return s
+}
+
+// unquote decodes a protocol 0 string, the repr of a Python 2 string quoted
+// with single or double quotes. The escapes are decoded like Python's
+// string-escape codec does, unknown ones are kept with their backslash.
+func unquote(s string) (string, error) {
+	if len(s) < 2 || (s[0] != '\'' && s[0] != '"') || s[len(s)-1] != s[0] {
+		return "", fmt.Errorf("invalid string %q", s)
+	}
+	s = s[1 : len(s)-1]
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", fmt.Errorf("invalid string %q: trailing backslash", s)
+		}
+		switch c := s[i]; c {
+		case '\n':
+			// Escaped newlines are line continuations.
+		case '\\', '\'', '"':
+			b.WriteByte(c)
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case 'x':
+			if i+2 >= len(s) {
+				return "", fmt.Errorf("invalid string %q: truncated \\x escape", s)
+			}
+			u, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("invalid string %q: invalid \\x escape", s)
+			}
+			b.WriteByte(byte(u))
+			i += 2
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			// Up to 3 octal digits, truncated to a byte.
+			u := c - '0'
+			for n := 1; n < 3 && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '7'; n++ {
+				i++
+				u = u<<3 | (s[i] - '0')
+			}
+			b.WriteByte(u)
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// decodeLong decodes a little-endian two's complement integer.
+func decodeLong(b []byte) interface{} {
+	if len(b) == 0 {
+		return int64(0)
+	}
+	be := make([]byte, len(b))
+	for i := range b {
+		be[len(b)-1-i] = b[i]
+	}
+	i := new(big.Int).SetBytes(be)
+	if b[len(b)-1]&0x80 != 0 {
+		i.Sub(i, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	return bigToNumber(i)
+}
+
+func bigToNumber(i *big.Int) interface{} {
+	if i.IsInt64() {
+		return i.Int64()
+	}
+	f, _ := new(big.Float).SetInt(i).Float64()
+	return f
+}
@@ -0,0 +1,78 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package graphite
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePickle(t *testing.T) {
+	now := time.Unix(2000, 0)
+	expected := []sample{
+		{path: "a.b.c", value: 1.5, timestamp: 1000000},
+		{path: "x", tags: map[string]string{"env": "prod"}, value: 2, timestamp: 1000500},
+		{path: "y", value: 3, timestamp: 2000000},
+	}
+	// Generated with pickle.dumps([("a.b.c", (1000, 1.5)), ("x;env=prod", (1000.5, 2)), ("y", (-1, "3"))], protocol=n).
+	testCases := map[string]string{
+		"protocol 0": "286c70300a2856612e622e630a70310a2849313030300a46312e350a7470320a7470330a612856783b656e763d70726f640a70340a2846313030302e350a49320a7470350a7470360a612856790a70370a28492d310a56330a70380a7470390a747031300a612e",
+		"protocol 2": "80025d7100285805000000612e622e6371014de803473ff8000000000000867102867103580a000000783b656e763d70726f64710447408f4400000000004b0286710586710658010000007971074affffffff580100000033710886710986710a652e",
+		"protocol 4": "8004954a000000000000005d94288c05612e622e63944de803473ff8000000000000869486948c0a783b656e763d70726f649447408f4400000000004b02869486948c0179944affffffff8c01339486948694652e",
+	}
+	for name, payload := range testCases {
+		t.Run(name, func(t *testing.T) {
+			b, err := hex.DecodeString(payload)
+			require.NoError(t, err)
+			samples, err := parsePickle(b, now)
+			require.NoError(t, err)
+			require.Equal(t, expected, samples)
+		})
+	}
+}
+
+func TestParsePickleErrors(t *testing.T) {
+	testCases := map[string][]byte{
+		"truncated":          []byte("(lp0\n"),
+		"unsupported opcode": []byte("c__builtin__\neval\n."),
+		"not a list":         []byte("I1\n."),
+		"invalid tuple":      []byte("(lp0\nI1\na."),
+		"invalid long":       []byte("(lp0\n(S'x'\n(L12x\nF1.5\ntta."),
+	}
+	for name, payload := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parsePickle(payload, time.Now())
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	testCases := map[string]string{
+		`'a.b'`:               "a.b",
+		`'a"b'`:               `a"b`,
+		`"it's"`:              "it's",
+		`'it\'s "x"'`:         `it's "x"`,
+		`'\\ \n\t\x41\101\q'`: "\\ \n\tAA\\q",
+		`"a\"b"`:              `a"b`,
+	}
+	for input, expected := range testCases {
+		s, err := unquote(input)
+		require.NoError(t, err, input)
+		require.Equal(t, expected, s, input)
+	}
+	for _, input := range []string{`'a`, `a`, `'a"`, `'a\'`, `'\x4'`, `'\xzz'`} {
+		_, err := unquote(input)
+		require.Error(t, err, input)
+	}
+
+	// Generated with pickle.dumps([('a"b', (1000, 1))], protocol=0) in Python 2.
+	samples, err := parsePickle([]byte("(lp0\n(S'a\"b'\np1\n(I1000\nI1\ntp2\ntp3\na."), time.Now())
+	require.NoError(t, err)
+	require.Equal(t, []sample{{path: `a"b`, value: 1, timestamp: 1000000}}, samples)
+}
@@ -0,0 +1,152 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package graphite
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sample is a single Graphite data point.
+type sample struct {
+	path  string
+	tags  map[string]string
+	value float64
+	// timestamp in milliseconds.
+	timestamp int64
+}
+
+// parsePath splits the Graphite tags off a path;tag=value;... path.
+func parsePath(s string) (path string, tags map[string]string, err error) {
+	parts := strings.Split(s, ";")
+	path = parts[0]
+	if path == "" {
+		return "", nil, fmt.Errorf("empty path")
+	}
+	for _, tag := range parts[1:] {
+		name, value, ok := strings.Cut(tag, "=")
+		if !ok || name == "" || value == "" {
+			return "", nil, fmt.Errorf("invalid tag %q", tag)
+		}
+		if tags == nil {
+			tags = make(map[string]string, len(parts)-1)
+		}
+		tags[name] = value
+	}
+	return path, tags, nil
+}
+
+// parseTimestamp converts a Graphite timestamp in seconds to milliseconds.
+// Graphite uses -1 for the time of reception.
+func parseTimestamp(seconds float64, now time.Time) (int64, error) {
+	if math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return 0, fmt.Errorf("invalid timestamp %v", seconds)
+	}
+	if seconds == -1 {
+		return now.UnixMilli(), nil
+	}
+	return int64(math.Round(seconds * 1000)), nil
+}
+
+// parseLine parses a line of the plaintext protocol:
+//
+//	path[;tag=value...] value [timestamp]
+func parseLine(line string, now time.Time) (sample, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 && len(fields) != 3 {
+		return sample{}, fmt.Errorf("invalid line %q", line)
+	}
+	path, tags, err := parsePath(fields[0])
+	if err != nil {
+		return sample{}, err
+	}
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return sample{}, fmt.Errorf("invalid value %q: %w", fields[1], err)
+	}
+	ts := now.UnixMilli()
+	if len(fields) == 3 {
+		seconds, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return sample{}, fmt.Errorf("invalid timestamp %q: %w", fields[2], err)
+		}
+		if ts, err = parseTimestamp(seconds, now); err != nil {
+			return sample{}, err
+		}
+	}
+	return sample{path: path, tags: tags, value: value, timestamp: ts}, nil
+}
+
+// parsePickle parses the payload of the pickle protocol, a list of
+// (path, (timestamp, value)) tuples.
+func parsePickle(payload []byte, now time.Time) ([]sample, error) {
+	v, err := unpickle(payload)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := v.(*pickleList)
+	if !ok {
+		return nil, fmt.Errorf("pickle: expected a list, got %T", v)
+	}
+	samples := make([]sample, 0, len(list.items))
+	for _, item := range list.items {
+		s, err := pickleSample(item, now)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
+
+func pickleSample(item interface{}, now time.Time) (sample, error) {
+	metric, ok := item.([]interface{})
+	if !ok || len(metric) != 2 {
+		return sample{}, fmt.Errorf("pickle: expected a (path, (timestamp, value)) tuple, got %v", item)
+	}
+	point, ok := metric[1].([]interface{})
+	if !ok || len(point) != 2 {
+		return sample{}, fmt.Errorf("pickle: expected a (timestamp, value) tuple, got %v", metric[1])
+	}
+	name, ok := metric[0].(string)
+	if !ok {
+		return sample{}, fmt.Errorf("pickle: expected a string path, got %v", metric[0])
+	}
+	path, tags, err := parsePath(name)
+	if err != nil {
+		return sample{}, err
+	}
+	seconds, err := pickleNumber(point[0])
+	if err != nil {
+		return sample{}, fmt.Errorf("invalid timestamp: %w", err)
+	}
+	ts, err := parseTimestamp(seconds, now)
+	if err != nil {
+		return sample{}, err
+	}
+	value, err := pickleNumber(point[1])
+	if err != nil {
+		return sample{}, fmt.Errorf("invalid value: %w", err)
+	}
+	return sample{path: path, tags: tags, value: value, timestamp: ts}, nil
+}
+
+// pickleNumber converts a pickled number, or a number sent as a string, to a
+// float.
+func pickleNumber(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	case string:
+		return strconv.ParseFloat(n, 64)
+	default:
+		return 0, fmt.Errorf("not a number: %v", v)
+	}
+}
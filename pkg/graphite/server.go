@@ -0,0 +1,306 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package graphite
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+const (
+	protocolTCP    = "tcp"
+	protocolUDP    = "udp"
+	protocolPickle = "pickle"
+
+	// maxBatchSize is the maximum number of lines ingested in a single write
+	// request.
+	maxBatchSize = 1000
+	// maxPickleSize bounds the size of a pickle payload, as announced by its
+	// length header.
+	maxPickleSize = 16 << 20
+	maxUDPPacket  = 65535
+)
+
+// timeProvider returns the time of reception of the lines without timestamp.
+var timeProvider = time.Now
+
+// Server receives metrics with the Graphite plaintext protocol over TCP and
+// UDP, and the pickle protocol over TCP, and ingests them with the DBIngestor
+// after mapping their paths to metric names and labels.
+type Server struct {
+	cfg      *Config
+	inserter ingestor.DBInserter
+	mapper   *Mapper
+
+	mu        sync.Mutex
+	listeners []io.Closer
+	conns     map[net.Conn]struct{}
+	wg        sync.WaitGroup
+}
+
+func NewServer(cfg *Config, inserter ingestor.DBInserter) *Server {
+	return &Server{
+		cfg:      cfg,
+		inserter: inserter,
+		mapper:   NewMapper(cfg.Mappings),
+		conns:    make(map[net.Conn]struct{}),
+	}
+}
+
+// Run listens on the configured addresses and serves the connections until
+// ctx is done.
+func (s *Server) Run(ctx context.Context) error {
+	if s.cfg.ListenAddress != "" {
+		l, err := net.Listen("tcp", s.cfg.ListenAddress)
+		if err != nil {
+			return fmt.Errorf("error listening for Graphite plaintext over TCP: %w", err)
+		}
+		s.addListener(l)
+		s.serveTCP(ctx, l, s.serveLines)
+
+		conn, err := net.ListenPacket("udp", s.cfg.ListenAddress)
+		if err != nil {
+			s.close()
+			return fmt.Errorf("error listening for Graphite plaintext over UDP: %w", err)
+		}
+		s.addListener(conn)
+		s.serveUDP(ctx, conn)
+	}
+	if s.cfg.PickleListenAddress != "" {
+		l, err := net.Listen("tcp", s.cfg.PickleListenAddress)
+		if err != nil {
+			s.close()
+			return fmt.Errorf("error listening for Graphite pickle: %w", err)
+		}
+		s.addListener(l)
+		s.serveTCP(ctx, l, s.servePickle)
+	}
+
+	<-ctx.Done()
+	s.close()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Server) addListener(l io.Closer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, l)
+}
+
+// close closes the listeners and the open connections.
+func (s *Server) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, l := range s.listeners {
+		_ = l.Close()
+	}
+	s.listeners = nil
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+func (s *Server) trackConn(conn net.Conn, add bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		s.conns[conn] = struct{}{}
+	} else {
+		delete(s.conns, conn)
+	}
+}
+
+func (s *Server) serveTCP(ctx context.Context, l net.Listener, serve func(context.Context, io.Reader, string) error) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				if ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+					log.Error("msg", "error accepting Graphite connection", "err", err)
+				}
+				return
+			}
+			s.trackConn(conn, true)
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer s.trackConn(conn, false)
+				defer conn.Close()
+				defer func() {
+					// A payload crashing the parser only drops its connection.
+					if r := recover(); r != nil {
+						log.Error("msg", "panic serving Graphite connection, dropping it", "remote", conn.RemoteAddr().String(), "panic", r)
+					}
+				}()
+				if err := serve(ctx, conn, protocolTCP); err != nil && ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+					log.Warn("msg", "error reading Graphite connection", "remote", conn.RemoteAddr().String(), "err", err)
+				}
+			}()
+		}
+	}()
+}
+
+func (s *Server) serveUDP(ctx context.Context, conn net.PacketConn) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		buf := make([]byte, maxUDPPacket)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				if ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+					log.Error("msg", "error reading Graphite UDP packet", "err", err)
+				}
+				return
+			}
+			// Each packet is ingested as a batch.
+			_ = s.serveLines(ctx, bytes.NewReader(buf[:n]), protocolUDP)
+		}
+	}()
+}
+
+// serveLines reads plaintext lines from r, ingesting them in batches whenever
+// no more data is buffered or the batch is full.
+func (s *Server) serveLines(ctx context.Context, r io.Reader, protocol string) error {
+	reader := bufio.NewReader(r)
+	batch := make([]sample, 0, maxBatchSize)
+	for {
+		line, err := reader.ReadString('\n')
+		if line = strings.TrimSpace(line); line != "" {
+			linesReceived.WithLabelValues(protocol).Inc()
+			smpl, perr := parseLine(line, timeProvider())
+			if perr != nil {
+				invalidLines.WithLabelValues(protocol).Inc()
+				log.Debug("msg", "invalid Graphite line", "protocol", protocol, "err", perr)
+			} else {
+				batch = append(batch, smpl)
+			}
+		}
+		if len(batch) > 0 && (err != nil || reader.Buffered() == 0 || len(batch) >= maxBatchSize) {
+			s.ingest(ctx, batch)
+			batch = batch[:0]
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// servePickle reads pickle payloads from r, each prefixed with its length as a
+// 4 bytes big endian integer, and ingests each payload as a batch.
+func (s *Server) servePickle(ctx context.Context, r io.Reader, _ string) error {
+	var header [4]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		size := binary.BigEndian.Uint32(header[:])
+		if size > maxPickleSize {
+			invalidLines.WithLabelValues(protocolPickle).Inc()
+			return fmt.Errorf("pickle payload of %d bytes exceeds the limit of %d bytes", size, maxPickleSize)
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return err
+		}
+		samples, err := parsePickle(payload, timeProvider())
+		if err != nil {
+			invalidLines.WithLabelValues(protocolPickle).Inc()
+			log.Debug("msg", "invalid Graphite pickle payload", "err", err)
+			continue
+		}
+		linesReceived.WithLabelValues(protocolPickle).Add(float64(len(samples)))
+		if len(samples) > 0 {
+			s.ingest(ctx, samples)
+		}
+	}
+}
+
+// ingest maps the samples to series and ingests them as one write request.
+func (s *Server) ingest(ctx context.Context, samples []sample) {
+	ts := s.toTimeseries(samples)
+	if len(ts) == 0 {
+		return
+	}
+	wr := ingestor.NewWriteRequest()
+	wr.Timeseries = ts
+	if _, _, err := s.inserter.IngestMetrics(ctx, wr); err != nil {
+		ingestErrors.Inc()
+		log.Error("msg", "error ingesting Graphite metrics", "err", err)
+	}
+}
+
+func (s *Server) toTimeseries(samples []sample) []prompb.TimeSeries {
+	ts := make([]prompb.TimeSeries, 0, len(samples))
+	for _, smpl := range samples {
+		name, labels, mapping, keep := s.mapper.Map(smpl.path)
+		switch {
+		case mapping == nil:
+			unmatchedLines.Inc()
+			if s.cfg.StrictMatch {
+				droppedLines.WithLabelValues("unmatched").Inc()
+				continue
+			}
+		case !keep:
+			mappedLines.WithLabelValues(mapping.Match).Inc()
+			droppedLines.WithLabelValues("mapping").Inc()
+			continue
+		default:
+			mappedLines.WithLabelValues(mapping.Match).Inc()
+		}
+		ts = append(ts, prompb.TimeSeries{
+			Labels:  toLabels(name, smpl.tags, labels),
+			Samples: []prompb.Sample{{Timestamp: smpl.timestamp, Value: smpl.value}},
+		})
+	}
+	return ts
+}
+
+// toLabels returns the sorted labels of a series. The labels of the mapping
+// take precedence over the Graphite tags.
+func toLabels(name string, tags, labels map[string]string) []prompb.Label {
+	merged := make(map[string]string, len(tags)+len(labels)+1)
+	for k, v := range tags {
+		merged[sanitizeName(k)] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	merged[model.MetricNameLabel] = name
+	result := make([]prompb.Label, 0, len(merged))
+	for k, v := range merged {
+		if v == "" {
+			continue
+		}
+		result = append(result, prompb.Label{Name: k, Value: v})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
@@ -0,0 +1,185 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package graphite
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+type mockInserter struct {
+	mu     sync.Mutex
+	series []prompb.TimeSeries
+}
+
+func (m *mockInserter) IngestMetrics(_ context.Context, r *prompb.WriteRequest) (uint64, uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.series = append(m.series, r.Timeseries...)
+	return uint64(len(r.Timeseries)), 0, nil
+}
+
+func (m *mockInserter) IngestTraces(context.Context, ptrace.Traces) error { return nil }
+
+func (m *mockInserter) IngestLogs(context.Context, plog.Logs) error { return nil }
+
+func (m *mockInserter) Close() {}
+
+func (m *mockInserter) len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.series)
+}
+
+func series(t int64, v float64, lbls ...string) prompb.TimeSeries {
+	ts := prompb.TimeSeries{Samples: []prompb.Sample{{Timestamp: t, Value: v}}}
+	for i := 0; i < len(lbls); i += 2 {
+		ts.Labels = append(ts.Labels, prompb.Label{Name: lbls[i], Value: lbls[i+1]})
+	}
+	return ts
+}
+
+func TestServeLines(t *testing.T) {
+	now := time.Unix(2000, 0)
+	timeProvider = func() time.Time { return now }
+	defer func() { timeProvider = time.Now }()
+
+	mappings, err := ParseMappings([]byte(`
+mappings:
+- match: servers.*.cpu
+  name: cpu
+  labels:
+    host: $1
+- match: debug.*
+  action: drop
+`))
+	require.NoError(t, err)
+
+	input := `servers.a.cpu 1.5 1000
+servers.b.cpu;env=prod 2 1000.5
+other.metric 3
+debug.x 4 1000
+invalid
+not.a.number abc 1000
+`
+	testCases := []struct {
+		name        string
+		strictMatch bool
+		result      []prompb.TimeSeries
+	}{
+		{
+			name: "default",
+			result: []prompb.TimeSeries{
+				series(1000000, 1.5, "__name__", "cpu", "host", "a"),
+				series(1000500, 2, "__name__", "cpu", "env", "prod", "host", "b"),
+				series(2000000, 3, "__name__", "other_metric"),
+			},
+		},
+		{
+			name:        "strict match",
+			strictMatch: true,
+			result: []prompb.TimeSeries{
+				series(1000000, 1.5, "__name__", "cpu", "host", "a"),
+				series(1000500, 2, "__name__", "cpu", "env", "prod", "host", "b"),
+			},
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			inserter := &mockInserter{}
+			s := NewServer(&Config{StrictMatch: c.strictMatch, Mappings: mappings}, inserter)
+			require.NoError(t, s.serveLines(context.Background(), strings.NewReader(input), protocolTCP))
+			require.Equal(t, c.result, inserter.series)
+		})
+	}
+}
+
+func TestServePickle(t *testing.T) {
+	payload, err := hex.DecodeString("80025d7100285805000000612e622e6371014de803473ff8000000000000867102867103580a000000783b656e763d70726f64710447408f4400000000004b0286710586710658010000007971074affffffff580100000033710886710986710a652e")
+	require.NoError(t, err)
+	var framed []byte
+	framed = binary.BigEndian.AppendUint32(framed, uint32(len(payload)))
+	framed = append(framed, payload...)
+
+	inserter := &mockInserter{}
+	s := NewServer(&Config{}, inserter)
+	require.NoError(t, s.servePickle(context.Background(), strings.NewReader(string(framed)), protocolPickle))
+	require.Len(t, inserter.series, 3)
+	require.Equal(t, series(1000000, 1.5, "__name__", "a_b_c"), inserter.series[0])
+
+	framed = binary.BigEndian.AppendUint32(nil, maxPickleSize+1)
+	require.Error(t, s.servePickle(context.Background(), strings.NewReader(string(framed)), protocolPickle))
+}
+
+func TestServerRun(t *testing.T) {
+	inserter := &mockInserter{}
+	s := NewServer(&Config{ListenAddress: "127.0.0.1:0"}, inserter)
+	// Listening on a random port, the actual address is found via the listener.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	var addr string
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if len(s.listeners) < 2 {
+			return false
+		}
+		addr = s.listeners[0].(net.Listener).Addr().String()
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("tcp.metric 1 1000\n"))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	require.Eventually(t, func() bool { return inserter.len() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestServeTCPRecoversPanics(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := NewServer(&Config{}, &mockInserter{})
+	s.addListener(l)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan struct{}, 2)
+	s.serveTCP(ctx, l, func(context.Context, io.Reader, string) error {
+		served <- struct{}{}
+		panic("boom")
+	})
+
+	// The connection is dropped, the server keeps accepting others.
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		require.NoError(t, err)
+		<-served
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		_, err = conn.Read(make([]byte, 1))
+		require.ErrorIs(t, err, io.EOF)
+		require.NoError(t, conn.Close())
+	}
+
+	cancel()
+	s.close()
+	s.wg.Wait()
+}
@@ -0,0 +1,201 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/storage/spanstore"
+	"github.com/timescale/promscale/pkg/pgmodel/metrics"
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+// ArchiveTables are the tables archived traces are copied to, created by the
+// connector migrations (pkg/migrations/sql/connector). Unlike the span, event and link tables they aren't
+// hypertables, so the trace retention (ps_trace.set_trace_retention_period)
+// never drops archived traces.
+var ArchiveTables = []string{"_ps_trace.archive_span", "_ps_trace.archive_event", "_ps_trace.archive_link"}
+
+const (
+	// Generated columns can't be inserted, hence duration_ms is left out.
+	archiveSpanSQL = `
+	INSERT INTO _ps_trace.archive_span (trace_id, span_id, parent_span_id, operation_id, start_time, end_time,
+		trace_state, span_tags, dropped_tags_count, event_time, dropped_events_count, dropped_link_count,
+		status_code, status_message, instrumentation_lib_id, resource_tags, resource_dropped_tags_count,
+		resource_schema_url_id)
+	SELECT trace_id, span_id, parent_span_id, operation_id, start_time, end_time,
+		trace_state, span_tags, dropped_tags_count, event_time, dropped_events_count, dropped_link_count,
+		status_code, status_message, instrumentation_lib_id, resource_tags, resource_dropped_tags_count,
+		resource_schema_url_id
+	FROM _ps_trace.span
+	WHERE trace_id = $1 AND span_id = $2 AND start_time = $3::timestamptz
+	ON CONFLICT DO NOTHING`
+	archiveEventSQL = `
+	INSERT INTO _ps_trace.archive_event
+	SELECT * FROM _ps_trace.event
+	WHERE trace_id = $1 AND span_id = $2
+		AND time >= $3::timestamptz - $4::interval AND time <= $3::timestamptz + $4::interval
+	ON CONFLICT DO NOTHING`
+	archiveLinkSQL = `
+	INSERT INTO _ps_trace.archive_link
+	SELECT * FROM _ps_trace.link
+	WHERE trace_id = $1 AND span_id = $2 AND span_start_time = $3::timestamptz
+	ON CONFLICT DO NOTHING`
+)
+
+var errArchiveNotSupported = errors.New("not supported by the archive storage")
+
+// Archive is the Jaeger archive storage. Archiving a span copies it, along
+// with its events and links, from the trace tables into the archive tables.
+type Archive struct {
+	readerConn pgxconn.PgxConn
+	writerConn pgxconn.PgxConn
+	builder    *Builder
+}
+
+// NewArchive returns the archive storage. The writer connection is nil for
+// read-only connectors, in which case only reading archived traces is supported.
+func NewArchive(readerConn, writerConn pgxconn.PgxConn, cfg *Config) *Archive {
+	return &Archive{readerConn, writerConn, NewBuilder(cfg)}
+}
+
+func (a *Archive) ArchiveSpanReader() spanstore.Reader {
+	return (*archiveReader)(a)
+}
+
+func (a *Archive) ArchiveSpanWriter() spanstore.Writer {
+	if a.writerConn == nil {
+		return nil
+	}
+	return (*archiveWriter)(a)
+}
+
+type archiveReader Archive
+
+func (r *archiveReader) GetTrace(ctx context.Context, traceID model.TraceID) (*model.Trace, error) {
+	code := "5xx"
+	start := time.Now()
+	defer func() {
+		labels := prometheus.Labels{"type": "trace", "handler": "Get_Archive_Trace", "code": code, "reason": ""}
+		metrics.Query.With(labels).Inc()
+		delete(labels, "reason")
+		metrics.QueryDuration.With(labels).Observe(time.Since(start).Seconds())
+	}()
+	res, err := getArchiveTrace(ctx, r.builder, r.readerConn, traceID)
+	if err != nil {
+		if !errors.Is(err, spanstore.ErrTraceNotFound) {
+			err = logError(err)
+		}
+		return nil, err
+	}
+	code = "2xx"
+	return res, nil
+}
+
+// The Jaeger query service only reads traces by ID from the archive storage.
+
+func (r *archiveReader) GetServices(context.Context) ([]string, error) {
+	return nil, errArchiveNotSupported
+}
+
+func (r *archiveReader) GetOperations(context.Context, spanstore.OperationQueryParameters) ([]spanstore.Operation, error) {
+	return nil, errArchiveNotSupported
+}
+
+func (r *archiveReader) FindTraces(context.Context, *spanstore.TraceQueryParameters) ([]*model.Trace, error) {
+	return nil, errArchiveNotSupported
+}
+
+func (r *archiveReader) FindTraceIDs(context.Context, *spanstore.TraceQueryParameters) ([]model.TraceID, error) {
+	return nil, errArchiveNotSupported
+}
+
+type archiveWriter Archive
+
+// WriteSpan copies the span from the trace tables into the archive tables.
+// The Jaeger query service archives the spans of a trace it has just read
+// from the same storage, so the span is expected to be in the trace tables
+// with the exact same start time.
+func (w *archiveWriter) WriteSpan(ctx context.Context, span *model.Span) error {
+	code := "5xx"
+	start := time.Now()
+	defer func() {
+		labels := prometheus.Labels{"type": "trace", "handler": "Write_Archive_Span", "code": code, "reason": ""}
+		metrics.Query.With(labels).Inc()
+		delete(labels, "reason")
+		metrics.QueryDuration.With(labels).Observe(time.Since(start).Seconds())
+	}()
+	if err := archiveSpan(ctx, w.writerConn, w.builder.cfg.MaxTraceDuration, span); err != nil {
+		return logError(err)
+	}
+	code = "2xx"
+	return nil
+}
+
+func archiveSpan(ctx context.Context, conn pgxconn.PgxConn, maxTraceDuration time.Duration, span *model.Span) error {
+	traceUUID, err := getUUIDFromTraceID(span.TraceID)
+	if err != nil {
+		return fmt.Errorf("TraceID to UUID conversion: %w", err)
+	}
+	spanID := int64(span.SpanID)
+
+	batch := conn.NewBatch()
+	batch.Queue(archiveSpanSQL, traceUUID, spanID, span.StartTime)
+	batch.Queue(archiveEventSQL, traceUUID, spanID, span.StartTime, maxTraceDuration)
+	batch.Queue(archiveLinkSQL, traceUUID, spanID, span.StartTime)
+	results, err := conn.SendBatch(ctx, batch)
+	if err != nil {
+		return fmt.Errorf("archiving span: %w", err)
+	}
+	defer results.Close()
+
+	tag, err := results.Exec()
+	if err != nil {
+		return fmt.Errorf("archiving span: %w", err)
+	}
+	for i := 1; i < batch.Len(); i++ {
+		if _, err = results.Exec(); err != nil {
+			return fmt.Errorf("archiving span: %w", err)
+		}
+	}
+	if tag.RowsAffected() == 0 {
+		// Either already archived or missing from the trace tables.
+		var archived bool
+		err = conn.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM _ps_trace.archive_span WHERE trace_id = $1 AND span_id = $2)", traceUUID, spanID).Scan(&archived)
+		if err != nil {
+			return fmt.Errorf("checking archived span: %w", err)
+		}
+		if !archived {
+			return fmt.Errorf("span %s of trace %s not found in the trace storage", span.SpanID, span.TraceID)
+		}
+	}
+	return nil
+}
+
+func getArchiveTrace(ctx context.Context, builder *Builder, conn pgxconn.PgxConn, traceID model.TraceID) (*model.Trace, error) {
+	query, params, err := builder.getArchiveTraceQuery(traceID)
+	if err != nil {
+		return nil, fmt.Errorf("get archive trace query: %w", err)
+	}
+	rows, err := conn.Query(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("querying archived traces: %w", err)
+	}
+	defer rows.Close()
+	traces, err := scanTraces(rows)
+	if err != nil {
+		return nil, fmt.Errorf("scanning archived traces: %w", err)
+	}
+	if len(traces) == 0 {
+		return nil, spanstore.ErrTraceNotFound
+	}
+	return traces[0], nil
+}
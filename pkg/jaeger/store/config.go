@@ -12,16 +12,19 @@ const (
 type Config struct {
 	MaxTraceDuration    time.Duration
 	StreamingSpanWriter bool
+	ArchiveStorage      bool
 }
 
 var DefaultConfig = Config{
 	MaxTraceDuration:    DefaultMaxTraceDuration,
 	StreamingSpanWriter: true,
+	ArchiveStorage:      true,
 }
 
 func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
 	fs.DurationVar(&cfg.MaxTraceDuration, "tracing.max-trace-duration", DefaultMaxTraceDuration, "Maximum duration of any trace in the system. This parameter is used to optimize queries.")
 	fs.BoolVar(&cfg.StreamingSpanWriter, "tracing.streaming-span-writer", true, "StreamingSpanWriter for remote Jaeger grpc store.")
+	fs.BoolVar(&cfg.ArchiveStorage, "tracing.archive-storage", true, "Archive storage for remote Jaeger grpc store. Archived traces are kept in separate tables which are exempt from the trace retention.")
 	return cfg
 }
 
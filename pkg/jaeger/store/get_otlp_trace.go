@@ -0,0 +1,42 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jaegertracing/jaeger/storage/spanstore"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+// getOTLPTrace returns the trace without translating it to the Jaeger model.
+// Every span is returned in its own resource spans.
+func getOTLPTrace(ctx context.Context, builder *Builder, conn pgxconn.PgxConn, traceID pcommon.TraceID) (ptrace.Traces, error) {
+	subquery, params := builder.BuildTraceTimeRangeSubqueryForTraceID(pgtype.UUID{Bytes: traceID, Valid: true})
+	rows, err := conn.Query(ctx, completeTraceQuery(subquery, liveTraceTables), params...)
+	if err != nil {
+		return ptrace.Traces{}, fmt.Errorf("querying trace: %w", err)
+	}
+	defer rows.Close()
+
+	traces := ptrace.NewTraces()
+	for rows.Next() {
+		if err = ScanRow(rows, &traces); err != nil {
+			return ptrace.Traces{}, fmt.Errorf("error scanning trace: %w", err)
+		}
+	}
+	if rows.Err() != nil {
+		return ptrace.Traces{}, fmt.Errorf("trace row iterator: %w", rows.Err())
+	}
+	if traces.SpanCount() == 0 {
+		return ptrace.Traces{}, spanstore.ErrTraceNotFound
+	}
+	return traces, nil
+}
@@ -0,0 +1,146 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/traceql"
+)
+
+// Only a sample of the values of a key is looked at to find its scopes, keys
+// with many values would be expensive to look up otherwise.
+const searchTagNamesSQL = `
+	SELECT
+		k.key,
+		t.tag_type OPERATOR(pg_catalog.&) ps_trace.span_tag_type() != 0,
+		t.tag_type OPERATOR(pg_catalog.&) ps_trace.resource_tag_type() != 0
+	FROM _ps_trace.tag_key k
+	INNER JOIN LATERAL (
+		SELECT bit_or(v.tag_type) tag_type
+		FROM (
+			SELECT tag.tag_type FROM _ps_trace.tag tag
+			WHERE tag.key OPERATOR(pg_catalog.=) k.key
+			LIMIT 1000
+		) v
+	) t ON (t.tag_type IS NOT NULL)
+	ORDER BY k.key`
+
+const searchTagValuesSQLFormat = `
+	SELECT value#>>'{}', jsonb_typeof(value)
+	FROM _ps_trace.tag
+	WHERE key OPERATOR(pg_catalog.=) $1
+		AND tag_type OPERATOR(pg_catalog.&) (%s) != 0
+	ORDER BY value
+	LIMIT $2`
+
+const searchSpanNamesSQL = `
+	SELECT DISTINCT span_name
+	FROM _ps_trace.operation
+	ORDER BY span_name
+	LIMIT $1`
+
+// TagNames are the names of the span and resource attributes.
+type TagNames struct {
+	Span     []string
+	Resource []string
+}
+
+// TagValue is an attribute value formatted as text. Type is the JSON type of
+// the value, or keyword for the values of the status and kind intrinsics.
+type TagValue struct {
+	Type  string
+	Value string
+}
+
+func searchTagNames(ctx context.Context, conn pgxconn.PgxConn) (TagNames, error) {
+	names := TagNames{Span: []string{}, Resource: []string{}}
+	rows, err := conn.Query(ctx, searchTagNamesSQL)
+	if err != nil {
+		return names, fmt.Errorf("querying tag names: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			key                string
+			isSpan, isResource bool
+		)
+		if err = rows.Scan(&key, &isSpan, &isResource); err != nil {
+			return names, fmt.Errorf("scanning tag names: %w", err)
+		}
+		if isSpan {
+			names.Span = append(names.Span, key)
+		}
+		if isResource {
+			names.Resource = append(names.Resource, key)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return names, fmt.Errorf("tag names: %w", err)
+	}
+	return names, nil
+}
+
+func searchTagValues(ctx context.Context, conn pgxconn.PgxConn, field traceql.Field, limit int) ([]TagValue, error) {
+	values := []TagValue{}
+	var tagTypes string
+	switch field.Scope {
+	case traceql.ScopeIntrinsic:
+		if field.Name == traceql.IntrinsicName {
+			return searchSpanNames(ctx, conn, limit)
+		}
+		for _, v := range traceql.IntrinsicValues(field.Name) {
+			values = append(values, TagValue{Type: "keyword", Value: v})
+		}
+		return values, nil
+	case traceql.ScopeSpan:
+		tagTypes = "ps_trace.span_tag_type()"
+	case traceql.ScopeResource:
+		tagTypes = "ps_trace.resource_tag_type()"
+	case traceql.ScopeEvent:
+		tagTypes = "ps_trace.event_tag_type()"
+	default:
+		tagTypes = "ps_trace.span_tag_type() OPERATOR(pg_catalog.|) ps_trace.resource_tag_type()"
+	}
+
+	rows, err := conn.Query(ctx, fmt.Sprintf(searchTagValuesSQLFormat, tagTypes), field.Name, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying tag values: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v TagValue
+		if err = rows.Scan(&v.Value, &v.Type); err != nil {
+			return nil, fmt.Errorf("scanning tag values: %w", err)
+		}
+		values = append(values, v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("tag values: %w", err)
+	}
+	return values, nil
+}
+
+func searchSpanNames(ctx context.Context, conn pgxconn.PgxConn, limit int) ([]TagValue, error) {
+	rows, err := conn.Query(ctx, searchSpanNamesSQL, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying span names: %w", err)
+	}
+	defer rows.Close()
+	values := []TagValue{}
+	for rows.Next() {
+		v := TagValue{Type: "string"}
+		if err = rows.Scan(&v.Value); err != nil {
+			return nil, fmt.Errorf("scanning span names: %w", err)
+		}
+		values = append(values, v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("span names: %w", err)
+	}
+	return values, nil
+}
@@ -0,0 +1,189 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+
+	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/traceql"
+)
+
+// The whole trace is looked up within the max trace duration around the
+// matching spans.
+const traceSummarySQL = `
+	SELECT
+		t.trace_id,
+		root.service_name,
+		root.span_name,
+		stats.start_time,
+		stats.end_time,
+		stats.span_count
+	FROM unnest($1::uuid[], $2::timestamptz[]) WITH ORDINALITY AS t(trace_id, start_time_max, nr)
+	LEFT JOIN LATERAL (
+		SELECT min(s.start_time) start_time, max(s.end_time) end_time, count(*) span_count
+		FROM _ps_trace.span s
+		WHERE s.trace_id = t.trace_id
+			AND s.start_time >= t.start_time_max - $3::interval AND s.start_time <= t.start_time_max + $3::interval
+	) stats ON (TRUE)
+	LEFT JOIN LATERAL (
+		SELECT o.span_name, tag.value#>>'{}' service_name
+		FROM _ps_trace.span s
+		INNER JOIN _ps_trace.operation o ON (s.operation_id = o.id)
+		LEFT JOIN _ps_trace.tag tag ON (tag.key = 'service.name' AND tag.id = o.service_name_id)
+		WHERE s.trace_id = t.trace_id AND s.parent_span_id IS NULL
+			AND s.start_time >= t.start_time_max - $3::interval AND s.start_time <= t.start_time_max + $3::interval
+		LIMIT 1
+	) root ON (TRUE)
+	ORDER BY t.nr`
+
+// SpanMatch is a span matching a trace search.
+type SpanMatch struct {
+	SpanID    pcommon.SpanID
+	StartTime time.Time
+	Duration  time.Duration
+}
+
+// TraceRef references a trace with a time it was active at, like the time of
+// an exemplar linking to it.
+type TraceRef struct {
+	TraceID pcommon.TraceID
+	Time    time.Time
+}
+
+// TraceSummary summarizes a stored trace. The trace wasn't found if it has no
+// spans, and the root span fields are empty if the root span wasn't found.
+type TraceSummary struct {
+	TraceID         pcommon.TraceID
+	RootServiceName string
+	RootSpanName    string
+	StartTime       time.Time
+	Duration        time.Duration
+	SpanCount       int
+}
+
+// TraceMatch summarizes a trace matching a trace search. The root span fields
+// are empty if the root span wasn't found.
+type TraceMatch struct {
+	TraceID         pcommon.TraceID
+	RootServiceName string
+	RootSpanName    string
+	StartTime       time.Time
+	Duration        time.Duration
+	SpanCount       int
+	Spans           []SpanMatch
+}
+
+func searchTraces(ctx context.Context, builder *Builder, conn pgxconn.PgxConn, q *traceql.Query, start, end time.Time, limit int) ([]TraceMatch, error) {
+	query, params := q.ToSQL(start, end, builder.cfg.MaxTraceDuration, limit)
+	rows, err := conn.Query(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("searching traces: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		matches        []TraceMatch
+		traceIDs       []pgtype.UUID
+		startTimesMax  []time.Time
+		spanIDs        []int64
+		spanStartTimes []time.Time
+		spanEndTimes   []time.Time
+	)
+	for rows.Next() {
+		var (
+			traceID      pgtype.UUID
+			startTimeMax time.Time
+		)
+		if err = rows.Scan(&traceID, &spanIDs, &spanStartTimes, &spanEndTimes, &startTimeMax); err != nil {
+			return nil, fmt.Errorf("scanning trace search results: %w", err)
+		}
+		m := TraceMatch{TraceID: makeTraceId(traceID), Spans: make([]SpanMatch, len(spanIDs))}
+		for i := range spanIDs {
+			m.Spans[i] = SpanMatch{
+				SpanID:    makeSpanId(&spanIDs[i]),
+				StartTime: spanStartTimes[i],
+				Duration:  spanEndTimes[i].Sub(spanStartTimes[i]),
+			}
+		}
+		matches = append(matches, m)
+		traceIDs = append(traceIDs, traceID)
+		startTimesMax = append(startTimesMax, startTimeMax)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("trace search results: %w", err)
+	}
+	rows.Close()
+	if len(matches) == 0 {
+		return matches, nil
+	}
+
+	summaries, err := getTraceSummaries(ctx, builder, conn, traceIDs, startTimesMax)
+	if err != nil {
+		return nil, err
+	}
+	for i, summary := range summaries {
+		m := &matches[i]
+		m.RootServiceName = summary.RootServiceName
+		m.RootSpanName = summary.RootSpanName
+		m.StartTime = summary.StartTime
+		m.Duration = summary.Duration
+		m.SpanCount = summary.SpanCount
+	}
+	return matches, nil
+}
+
+// getTraceSummaries returns the summaries of the traces in the order of the
+// trace IDs, each trace being looked up around its time.
+func getTraceSummaries(ctx context.Context, builder *Builder, conn pgxconn.PgxConn, traceIDs []pgtype.UUID, times []time.Time) ([]TraceSummary, error) {
+	rows, err := conn.Query(ctx, traceSummarySQL, traceIDs, times, builder.cfg.MaxTraceDuration)
+	if err != nil {
+		return nil, fmt.Errorf("querying trace summaries: %w", err)
+	}
+	defer rows.Close()
+	summaries := make([]TraceSummary, 0, len(traceIDs))
+	for rows.Next() {
+		var (
+			traceID               pgtype.UUID
+			serviceName, spanName *string
+			startTime, endTime    *time.Time
+			spanCount             int64
+		)
+		if err = rows.Scan(&traceID, &serviceName, &spanName, &startTime, &endTime, &spanCount); err != nil {
+			return nil, fmt.Errorf("scanning trace summaries: %w", err)
+		}
+		s := TraceSummary{TraceID: makeTraceId(traceID), SpanCount: int(spanCount)}
+		if serviceName != nil {
+			s.RootServiceName = *serviceName
+		}
+		if spanName != nil {
+			s.RootSpanName = *spanName
+		}
+		if startTime != nil && endTime != nil {
+			s.StartTime = *startTime
+			s.Duration = endTime.Sub(*startTime)
+		}
+		summaries = append(summaries, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("trace summaries: %w", err)
+	}
+	return summaries, nil
+}
+
+func getTraceSummariesByRef(ctx context.Context, builder *Builder, conn pgxconn.PgxConn, refs []TraceRef) ([]TraceSummary, error) {
+	traceIDs := make([]pgtype.UUID, len(refs))
+	times := make([]time.Time, len(refs))
+	for i, ref := range refs {
+		traceIDs[i] = pgtype.UUID{Bytes: ref.TraceID, Valid: true}
+		times[i] = ref.Time
+	}
+	return getTraceSummaries(ctx, builder, conn, traceIDs, times)
+}
@@ -10,6 +10,8 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
 
 	"github.com/jaegertracing/jaeger/model"
 	"github.com/jaegertracing/jaeger/storage/dependencystore"
@@ -18,6 +20,7 @@ import (
 	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
 	"github.com/timescale/promscale/pkg/pgmodel/metrics"
 	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/traceql"
 )
 
 type Store struct {
@@ -173,6 +176,107 @@ func (p *Store) GetDependencies(ctx context.Context, endTs time.Time, lookback t
 	return res, nil
 }
 
+// SearchTraces returns the most recent traces matching the query with spans
+// starting in the time range.
+func (p *Store) SearchTraces(ctx context.Context, query *traceql.Query, start, end time.Time, limit int) ([]TraceMatch, error) {
+	code := "5xx"
+	begin := time.Now()
+	defer func() {
+		labels := prometheus.Labels{"type": "trace", "handler": "Search_Traces", "code": code, "reason": ""}
+		metrics.Query.With(labels).Inc()
+		delete(labels, "reason")
+		metrics.QueryDuration.With(labels).Observe(time.Since(begin).Seconds())
+	}()
+	res, err := searchTraces(ctx, p.builder, p.conn, query, start, end, limit)
+	if err != nil {
+		return nil, logError(err)
+	}
+	code = "2xx"
+	traceRequestsExec.Add(1)
+	return res, nil
+}
+
+// GetTraceSummaries returns the summaries of the referenced traces, in the
+// order of the references. A trace that wasn't found has no spans.
+func (p *Store) GetTraceSummaries(ctx context.Context, refs []TraceRef) ([]TraceSummary, error) {
+	code := "5xx"
+	start := time.Now()
+	defer func() {
+		labels := prometheus.Labels{"type": "trace", "handler": "Get_Trace_Summaries", "code": code, "reason": ""}
+		metrics.Query.With(labels).Inc()
+		delete(labels, "reason")
+		metrics.QueryDuration.With(labels).Observe(time.Since(start).Seconds())
+	}()
+	if len(refs) == 0 {
+		code = "2xx"
+		return []TraceSummary{}, nil
+	}
+	res, err := getTraceSummariesByRef(ctx, p.builder, p.conn, refs)
+	if err != nil {
+		return nil, logError(err)
+	}
+	code = "2xx"
+	return res, nil
+}
+
+// GetOTLPTrace returns the trace in the OpenTelemetry data model.
+func (p *Store) GetOTLPTrace(ctx context.Context, traceID pcommon.TraceID) (ptrace.Traces, error) {
+	code := "5xx"
+	start := time.Now()
+	defer func() {
+		labels := prometheus.Labels{"type": "trace", "handler": "Get_OTLP_Trace", "code": code, "reason": ""}
+		metrics.Query.With(labels).Inc()
+		delete(labels, "reason")
+		metrics.QueryDuration.With(labels).Observe(time.Since(start).Seconds())
+	}()
+	res, err := getOTLPTrace(ctx, p.builder, p.conn, traceID)
+	if err != nil {
+		if !errors.Is(err, spanstore.ErrTraceNotFound) {
+			err = logError(err)
+		}
+		return ptrace.Traces{}, err
+	}
+	code = "2xx"
+	traceRequestsExec.Add(1)
+	return res, nil
+}
+
+// SearchTagNames returns the names of the span and resource attributes.
+func (p *Store) SearchTagNames(ctx context.Context) (TagNames, error) {
+	code := "5xx"
+	start := time.Now()
+	defer func() {
+		labels := prometheus.Labels{"type": "trace", "handler": "Search_Tag_Names", "code": code, "reason": ""}
+		metrics.Query.With(labels).Inc()
+		delete(labels, "reason")
+		metrics.QueryDuration.With(labels).Observe(time.Since(start).Seconds())
+	}()
+	res, err := searchTagNames(ctx, p.conn)
+	if err != nil {
+		return res, logError(err)
+	}
+	code = "2xx"
+	return res, nil
+}
+
+// SearchTagValues returns up to limit values of the field.
+func (p *Store) SearchTagValues(ctx context.Context, field traceql.Field, limit int) ([]TagValue, error) {
+	code := "5xx"
+	start := time.Now()
+	defer func() {
+		labels := prometheus.Labels{"type": "trace", "handler": "Search_Tag_Values", "code": code, "reason": ""}
+		metrics.Query.With(labels).Inc()
+		delete(labels, "reason")
+		metrics.QueryDuration.With(labels).Observe(time.Since(start).Seconds())
+	}()
+	res, err := searchTagValues(ctx, p.conn, field, limit)
+	if err != nil {
+		return nil, logError(err)
+	}
+	code = "2xx"
+	return res, nil
+}
+
 func (p *Store) GetBuilder() *Builder {
 	return p.builder
 }
@@ -40,6 +40,13 @@ const (
 		LIMIT 1
 	`
 
+	subqueryArchiveTraceID = `
+		SELECT
+			$1::ps_trace.trace_id as trace_id,
+			'-infinity'::timestamptz as time_low,
+			'infinity'::timestamptz as time_high
+	`
+
 	// PostgreSQL badly overestimates the number of rows returned if the complete trace query
 	// uses an IN clause on trace_id, but gives good estimates for equality conditions. So, leverage an INNER
 	// JOIN LATERAL to provide an equality condition on the complete trace.
@@ -62,7 +69,7 @@ const (
 	// - Without GROUP BY https://explain.dalibo.com/plan/f09259cd21g57dh3
 	findTraceSQLFormat = `
 	WITH trace_ids AS (
-		%s
+		%[1]s
 	)
 	SELECT
 		complete_trace.*
@@ -99,7 +106,7 @@ const (
 			links_dropped_tags_count,
 			links_tags
 		FROM
-			_ps_trace.span s
+			%[2]s s
 		INNER JOIN
 			_ps_trace.operation o ON (s.operation_id = o.id)
 		LEFT JOIN
@@ -114,7 +121,7 @@ const (
 				array_agg(e.time ORDER BY e.event_nbr) event_times,
 				array_agg(e.dropped_tags_count ORDER BY e.event_nbr) event_dropped_tags_count,
 				array_agg(_ps_trace.tag_map_denormalize(e.tags) ORDER BY e.event_nbr) event_tags
-			FROM _ps_trace.event as e
+			FROM %[3]s as e
 			WHERE e.trace_id = s.trace_id AND e.span_id = s.span_id
 				AND e.time > trace_ids.time_low AND e.time < trace_ids.time_high
 		) as event ON (TRUE)
@@ -125,7 +132,7 @@ const (
 				array_agg(lk.trace_state ORDER BY lk.link_nbr) links_trace_states,
 				array_agg(lk.dropped_tags_count ORDER BY lk.link_nbr) links_dropped_tags_count,
 				array_agg(_ps_trace.tag_map_denormalize(lk.tags) ORDER BY lk.link_nbr) links_tags
-			FROM _ps_trace.link as lk
+			FROM %[4]s as lk
 			WHERE lk.trace_id = s.trace_id AND lk.span_id = s.span_id
 				AND lk.span_start_time > trace_ids.time_low AND lk.span_start_time < trace_ids.time_high
 		) as link ON (TRUE)
@@ -175,9 +182,23 @@ func NewBuilder(cfg *Config) *Builder {
 	return &Builder{cfg}
 }
 
+// traceTables are the tables a complete trace is read from.
+type traceTables struct {
+	span, event, link string
+}
+
+var (
+	liveTraceTables    = traceTables{span: "_ps_trace.span", event: "_ps_trace.event", link: "_ps_trace.link"}
+	archiveTraceTables = traceTables{span: "_ps_trace.archive_span", event: "_ps_trace.archive_event", link: "_ps_trace.archive_link"}
+)
+
+func completeTraceQuery(subquery string, tables traceTables) string {
+	return fmt.Sprintf(findTraceSQLFormat, subquery, tables.span, tables.event, tables.link)
+}
+
 func (b *Builder) findTracesQuery(q *spanstore.TraceQueryParameters, tInfo *tagsInfo) (string, []interface{}) {
 	subquery, params := b.BuildTraceIDSubquery(q, tInfo)
-	return fmt.Sprintf(findTraceSQLFormat, subquery), params
+	return completeTraceQuery(subquery, liveTraceTables), params
 }
 
 func (b *Builder) findTraceIDsQuery(q *spanstore.TraceQueryParameters, tInfo *tagsInfo) (string, []interface{}) {
@@ -204,7 +225,18 @@ func (b *Builder) getTraceQuery(traceID model.TraceID) (string, []interface{}, e
 	//it may seem silly to build a traceID subquery when we know the traceID
 	//but, this allows us to get the time range of the trace for the rest of the query.
 	subquery, params := b.BuildTraceTimeRangeSubqueryForTraceID(traceUUID)
-	return fmt.Sprintf(findTraceSQLFormat, subquery), params, nil
+	return completeTraceQuery(subquery, liveTraceTables), params, nil
+}
+
+// getArchiveTraceQuery returns the query of a trace from the archive tables.
+// Those aren't hypertables, so the time range of the trace isn't needed to
+// limit the scan.
+func (b *Builder) getArchiveTraceQuery(traceID model.TraceID) (string, []interface{}, error) {
+	traceUUID, err := getUUIDFromTraceID(traceID)
+	if err != nil {
+		return "", nil, fmt.Errorf("TraceID to UUID conversion: %w", err)
+	}
+	return completeTraceQuery(subqueryArchiveTraceID, archiveTraceTables), []interface{}{traceUUID}, nil
 }
 
 func (b *Builder) buildOperationSubquery(q *spanstore.TraceQueryParameters, tInfo *tagsInfo, params []interface{}) (string, []interface{}) {
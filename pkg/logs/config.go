@@ -0,0 +1,31 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package logs
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+const defaultRetentionPeriod = 30 * 24 * time.Hour
+
+type Config struct {
+	Enabled         bool
+	RetentionPeriod time.Duration
+}
+
+func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
+	fs.BoolVar(&cfg.Enabled, "logs.enable", false, "Store OTLP logs in the ps_log schema. Logs are received on the tracing gRPC server and on the /v1/logs endpoint, and queried with /api/v1/logs.")
+	fs.DurationVar(&cfg.RetentionPeriod, "logs.retention-period", defaultRetentionPeriod, "Duration logs are kept for. Setting it to `0` keeps logs forever.")
+	return cfg
+}
+
+func Validate(cfg *Config) error {
+	if cfg.Enabled && cfg.RetentionPeriod < 0 {
+		return fmt.Errorf("logs.retention-period must not be negative: %s", cfg.RetentionPeriod)
+	}
+	return nil
+}
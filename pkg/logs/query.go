@@ -0,0 +1,181 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package logs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+const queryFormat = `
+	SELECT l.time, l.observed_time, l.trace_id, l.span_id, l.trace_flags, l.severity_number, l.severity_text, l.body,
+		_ps_trace.tag_map_denormalize(l.attributes), _ps_trace.tag_map_denormalize(l.resource_tags),
+		il.name, il.version
+	FROM ps_log.log l
+	LEFT JOIN _ps_trace.instrumentation_lib il ON (l.instrumentation_lib_id = il.id)
+	WHERE %s
+	ORDER BY l.time DESC
+	LIMIT %d`
+
+type MatchType int
+
+const (
+	MatchEqual MatchType = iota
+	MatchNotEqual
+	MatchRegexp
+	MatchNotRegexp
+)
+
+// matchOperators are the ps_tag operators of the match types. The regular
+// expressions are anchored like in PromQL.
+var matchOperators = map[MatchType]string{
+	MatchEqual:     "==",
+	MatchNotEqual:  "!==",
+	MatchRegexp:    "==~",
+	MatchNotRegexp: "!=~",
+}
+
+// Matcher matches the value of a resource or log attribute.
+type Matcher struct {
+	Key   string
+	Type  MatchType
+	Value string
+}
+
+// matchesMissing reports whether the matcher matches a missing key, whose
+// value is empty.
+func (m Matcher) matchesMissing() bool {
+	switch m.Type {
+	case MatchNotEqual:
+		return m.Value != ""
+	case MatchNotRegexp:
+		re, err := regexp.Compile("^(?:" + m.Value + ")$")
+		return err == nil && !re.MatchString("")
+	}
+	return false
+}
+
+// ParseMatcher parses a matcher written key=value, key!=value, key=~regexp or
+// key!~regexp. The value may be a double quoted string.
+func ParseMatcher(s string) (Matcher, error) {
+	i := strings.IndexAny(s, "=!")
+	if i < 1 {
+		return Matcher{}, fmt.Errorf("invalid matcher %q: expected key=value, key!=value, key=~regexp or key!~regexp", s)
+	}
+	m := Matcher{Key: s[:i]}
+	op := s[i:]
+	switch {
+	case strings.HasPrefix(op, "=~"):
+		m.Type, m.Value = MatchRegexp, op[2:]
+	case strings.HasPrefix(op, "!~"):
+		m.Type, m.Value = MatchNotRegexp, op[2:]
+	case strings.HasPrefix(op, "!="):
+		m.Type, m.Value = MatchNotEqual, op[2:]
+	case strings.HasPrefix(op, "="):
+		m.Type, m.Value = MatchEqual, op[1:]
+	default:
+		return Matcher{}, fmt.Errorf("invalid matcher %q: expected key=value, key!=value, key=~regexp or key!~regexp", s)
+	}
+	if strings.HasPrefix(m.Value, `"`) {
+		v, err := strconv.Unquote(m.Value)
+		if err != nil {
+			return Matcher{}, fmt.Errorf("invalid matcher %q: %w", s, err)
+		}
+		m.Value = v
+	}
+	return m, nil
+}
+
+var severityNames = map[string]int{
+	"TRACE": 1,
+	"DEBUG": 5,
+	"INFO":  9,
+	"WARN":  13,
+	"ERROR": 17,
+	"FATAL": 21,
+}
+
+// ParseSeverity parses a severity number, from 1 to 24, or the name of a
+// severity range as defined by OpenTelemetry, like WARN, which stands for the
+// lowest number of the range.
+func ParseSeverity(s string) (int, error) {
+	if n, ok := severityNames[strings.ToUpper(s)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > 24 {
+		return 0, fmt.Errorf("invalid severity %q: expected a number between 1 and 24 or one of TRACE, DEBUG, INFO, WARN, ERROR and FATAL", s)
+	}
+	return n, nil
+}
+
+// Query selects the logs in a time range, the most recent ones first.
+type Query struct {
+	Start, End time.Time
+	// MinSeverity is the lowest severity number of the logs, 0 for any.
+	MinSeverity       int
+	ResourceMatchers  []Matcher
+	AttributeMatchers []Matcher
+	TraceID           pcommon.TraceID
+	SpanID            pcommon.SpanID
+	// Body is a full text search of the body, in the web search syntax of
+	// PostgreSQL: words, "quoted phrases", OR and -excluded words.
+	Body  string
+	Limit int
+}
+
+// ToSQL returns the query and its parameters.
+func (q *Query) ToSQL() (string, []interface{}) {
+	var (
+		params []interface{}
+		conds  []string
+	)
+	param := func(v interface{}) string {
+		params = append(params, v)
+		return fmt.Sprintf("$%d", len(params))
+	}
+	conds = append(conds, "l.time >= "+param(q.Start), "l.time <= "+param(q.End))
+	if q.MinSeverity > 0 {
+		conds = append(conds, "l.severity_number >= "+param(q.MinSeverity))
+	}
+	match := func(tagMap string, m Matcher) string {
+		value := m.Value
+		if m.Type == MatchRegexp || m.Type == MatchNotRegexp {
+			value = "^(?:" + value + ")$"
+		}
+		key := param(m.Key)
+		cond := fmt.Sprintf("%s OPERATOR(ps_trace.?) (%s::text OPERATOR(ps_tag.%s) %s::text)", tagMap, key, matchOperators[m.Type], param(value))
+		if !m.matchesMissing() {
+			return cond
+		}
+		// Like a missing label in PromQL, a missing key has an empty value,
+		// which the ps_tag operators only compare with existing keys.
+		return fmt.Sprintf("(%s OR NOT EXISTS (SELECT 1 FROM _ps_trace.tag_key k WHERE k.key OPERATOR(pg_catalog.=) %s::text AND %s::jsonb OPERATOR(pg_catalog.?) k.id::text))", cond, key, tagMap)
+	}
+	for _, m := range q.ResourceMatchers {
+		conds = append(conds, match("l.resource_tags", m))
+	}
+	for _, m := range q.AttributeMatchers {
+		conds = append(conds, match("l.attributes", m))
+	}
+	if !q.TraceID.IsEmpty() {
+		conds = append(conds, "l.trace_id = "+param(pgtype.UUID{Bytes: q.TraceID, Valid: true}))
+	}
+	if !q.SpanID.IsEmpty() {
+		conds = append(conds, "l.span_id = "+param(int64(binary.BigEndian.Uint64(q.SpanID[:]))))
+	}
+	if q.Body != "" {
+		// The expression matches the one of the log_body_idx index.
+		conds = append(conds, "to_tsvector('simple', l.body) @@ websearch_to_tsquery('simple', "+param(q.Body)+")")
+	}
+	return fmt.Sprintf(queryFormat, strings.Join(conds, " AND "), q.Limit), params
+}
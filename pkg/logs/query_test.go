@@ -0,0 +1,111 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package logs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+func TestParseMatcher(t *testing.T) {
+	testCases := []struct {
+		in  string
+		out Matcher
+		err bool
+	}{
+		{in: "service.name=api", out: Matcher{Key: "service.name", Type: MatchEqual, Value: "api"}},
+		{in: `service.name!="a b"`, out: Matcher{Key: "service.name", Type: MatchNotEqual, Value: "a b"}},
+		{in: "http.route=~/api/.*", out: Matcher{Key: "http.route", Type: MatchRegexp, Value: "/api/.*"}},
+		{in: "http.route!~/health", out: Matcher{Key: "http.route", Type: MatchNotRegexp, Value: "/health"}},
+		{in: "empty=", out: Matcher{Key: "empty", Type: MatchEqual}},
+		{in: "=api", err: true},
+		{in: "service.name", err: true},
+		{in: "a!b", err: true},
+		{in: `a="unterminated`, err: true},
+	}
+	for _, c := range testCases {
+		t.Run(c.in, func(t *testing.T) {
+			m, err := ParseMatcher(c.in)
+			if c.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.out, m)
+		})
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for in, expected := range map[string]int{"warn": 13, "ERROR": 17, "1": 1, "24": 24} {
+		n, err := ParseSeverity(in)
+		require.NoError(t, err, in)
+		require.Equal(t, expected, n, in)
+	}
+	for _, in := range []string{"0", "25", "warning", ""} {
+		_, err := ParseSeverity(in)
+		require.Error(t, err, in)
+	}
+}
+
+func TestToSQL(t *testing.T) {
+	start := time.Unix(1000, 0)
+	end := time.Unix(2000, 0)
+	q := &Query{
+		Start:             start,
+		End:               end,
+		MinSeverity:       17,
+		ResourceMatchers:  []Matcher{{Key: "service.name", Type: MatchEqual, Value: "api"}},
+		AttributeMatchers: []Matcher{{Key: "http.route", Type: MatchNotRegexp, Value: "/health"}},
+		TraceID:           pcommon.TraceID([16]byte{1}),
+		SpanID:            pcommon.SpanID([8]byte{0, 0, 0, 0, 0, 0, 0, 2}),
+		Body:              `timeout -retry`,
+		Limit:             10,
+	}
+	sql, params := q.ToSQL()
+	for _, fragment := range []string{
+		"FROM ps_log.log l",
+		"l.time >= $1 AND l.time <= $2 AND l.severity_number >= $3",
+		"l.resource_tags OPERATOR(ps_trace.?) ($4::text OPERATOR(ps_tag.==) $5::text)",
+		"(l.attributes OPERATOR(ps_trace.?) ($6::text OPERATOR(ps_tag.!=~) $7::text) OR NOT EXISTS (SELECT 1 FROM _ps_trace.tag_key k WHERE k.key OPERATOR(pg_catalog.=) $6::text AND l.attributes::jsonb OPERATOR(pg_catalog.?) k.id::text))",
+		"l.trace_id = $8 AND l.span_id = $9",
+		"to_tsvector('simple', l.body) @@ websearch_to_tsquery('simple', $10)",
+		"ORDER BY l.time DESC\n\tLIMIT 10",
+	} {
+		require.Contains(t, sql, fragment)
+	}
+	require.Equal(t, []interface{}{
+		start, end, 17,
+		"service.name", "api",
+		"http.route", "^(?:/health)$",
+		pgtype.UUID{Bytes: [16]byte{1}, Valid: true}, int64(2),
+		"timeout -retry",
+	}, params)
+
+	sql, params = (&Query{Start: start, End: end, Limit: 1}).ToSQL()
+	require.Contains(t, sql, "WHERE l.time >= $1 AND l.time <= $2\n")
+	require.Len(t, params, 2)
+}
+
+func TestMatcherMatchesMissing(t *testing.T) {
+	for _, tc := range []struct {
+		m    Matcher
+		want bool
+	}{
+		{m: Matcher{Key: "a", Type: MatchEqual, Value: "x"}},
+		{m: Matcher{Key: "a", Type: MatchEqual, Value: ""}},
+		{m: Matcher{Key: "a", Type: MatchNotEqual, Value: "x"}, want: true},
+		{m: Matcher{Key: "a", Type: MatchNotEqual, Value: ""}},
+		{m: Matcher{Key: "a", Type: MatchRegexp, Value: ".*"}},
+		{m: Matcher{Key: "a", Type: MatchNotRegexp, Value: "x+"}, want: true},
+		{m: Matcher{Key: "a", Type: MatchNotRegexp, Value: "x*"}},
+	} {
+		require.Equal(t, tc.want, tc.m.matchesMissing(), "%+v", tc.m)
+	}
+}
@@ -0,0 +1,64 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+// Package logs stores OTLP logs in the ps_log schema and queries them.
+package logs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+// LogTable is created by the connector migrations (pkg/migrations/sql/connector).
+// Like the spans, its resource and attributes are tag maps referencing the
+// _ps_trace.tag table, so they can be filtered with the ps_tag operators, and
+// its trace_id and span_id columns join the spans.
+const LogTable = "ps_log.log"
+
+const (
+	dropExpiredLogsSQL = `SELECT ps_log.drop_expired_logs($1)`
+	retentionEvery     = time.Hour
+)
+
+// Retention drops the logs older than the retention period. Every connector
+// runs it, ps_log.drop_expired_logs takes an advisory lock so only one of them
+// drops the logs at a time.
+type Retention struct {
+	conn   pgxconn.PgxConn
+	period time.Duration
+}
+
+func NewRetention(conn pgxconn.PgxConn, period time.Duration) *Retention {
+	return &Retention{conn: conn, period: period}
+}
+
+// Run drops the expired logs every hour until ctx is done.
+func (r *Retention) Run(ctx context.Context) error {
+	ticker := time.NewTicker(retentionEvery)
+	defer ticker.Stop()
+	for {
+		if _, err := r.DropExpired(ctx); err != nil && ctx.Err() == nil {
+			log.Error("msg", "error dropping expired logs", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// DropExpired drops the expired logs. It reports false if another connector
+// was dropping them.
+func (r *Retention) DropExpired(ctx context.Context) (bool, error) {
+	var dropped bool
+	if err := r.conn.QueryRow(ctx, dropExpiredLogsSQL, time.Now().Add(-r.period)).Scan(&dropped); err != nil {
+		return false, fmt.Errorf("dropping logs older than %s: %w", r.period, err)
+	}
+	return dropped, nil
+}
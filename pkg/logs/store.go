@@ -0,0 +1,81 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package logs
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+// Record is a log record returned by a query.
+type Record struct {
+	Time           time.Time
+	ObservedTime   time.Time
+	TraceID        pcommon.TraceID
+	SpanID         pcommon.SpanID
+	Flags          uint32
+	SeverityNumber int
+	SeverityText   string
+	Body           string
+	Attributes     map[string]interface{}
+	Resource       map[string]interface{}
+	ScopeName      string
+	ScopeVersion   string
+}
+
+// Store queries the logs of the ps_log schema.
+type Store struct {
+	conn pgxconn.PgxConn
+}
+
+func NewStore(conn pgxconn.PgxConn) *Store {
+	return &Store{conn: conn}
+}
+
+func (s *Store) SearchLogs(ctx context.Context, q *Query) ([]Record, error) {
+	sql, params := q.ToSQL()
+	rows, err := s.conn.Query(ctx, sql, params...)
+	if err != nil {
+		return nil, fmt.Errorf("querying logs: %w", err)
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var (
+			r                                    Record
+			traceID                              pgtype.UUID
+			spanID                               pgtype.Int8
+			flags                                int32
+			severity                             int16
+			severityText, body, scope, scopeVers pgtype.Text
+		)
+		if err = rows.Scan(&r.Time, &r.ObservedTime, &traceID, &spanID, &flags, &severity, &severityText, &body,
+			&r.Attributes, &r.Resource, &scope, &scopeVers); err != nil {
+			return nil, fmt.Errorf("scanning logs: %w", err)
+		}
+		if traceID.Valid {
+			r.TraceID = traceID.Bytes
+		}
+		if spanID.Valid {
+			binary.BigEndian.PutUint64(r.SpanID[:], uint64(spanID.Int64))
+		}
+		r.Flags = uint32(flags)
+		r.SeverityNumber = int(severity)
+		r.SeverityText = severityText.String
+		r.Body = body.String
+		r.ScopeName = scope.String
+		r.ScopeVersion = scopeVers.String
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,14 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package migrations
+
+import "embed"
+
+// ConnectorFiles holds the idempotent scripts of the database objects owned
+// by the connector rather than by the Promscale extension. They are applied
+// in file name order after the extension is installed or upgraded.
+//
+//go:embed sql/connector/*.sql
+var ConnectorFiles embed.FS
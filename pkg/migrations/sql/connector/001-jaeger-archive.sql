@@ -0,0 +1,20 @@
+-- Tables archived Jaeger traces are copied to. Unlike the span, event and link
+-- tables they aren't hypertables, so the trace retention
+-- (ps_trace.set_trace_retention_period) never drops archived traces.
+CREATE TABLE IF NOT EXISTS _ps_trace.archive_span (
+    LIKE _ps_trace.span INCLUDING DEFAULTS INCLUDING CONSTRAINTS INCLUDING GENERATED,
+    PRIMARY KEY (trace_id, span_id, start_time)
+);
+
+CREATE TABLE IF NOT EXISTS _ps_trace.archive_event (
+    LIKE _ps_trace.event INCLUDING DEFAULTS INCLUDING CONSTRAINTS,
+    UNIQUE (trace_id, span_id, event_nbr)
+);
+
+CREATE TABLE IF NOT EXISTS _ps_trace.archive_link (
+    LIKE _ps_trace.link INCLUDING DEFAULTS INCLUDING CONSTRAINTS,
+    UNIQUE (trace_id, span_id, link_nbr)
+);
+
+GRANT SELECT ON TABLE _ps_trace.archive_span, _ps_trace.archive_event, _ps_trace.archive_link TO prom_reader;
+GRANT SELECT, INSERT, UPDATE, DELETE ON TABLE _ps_trace.archive_span, _ps_trace.archive_event, _ps_trace.archive_link TO prom_writer;
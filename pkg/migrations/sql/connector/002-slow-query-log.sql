@@ -0,0 +1,23 @@
+-- Table slow PromQL queries are recorded in when
+-- metrics.promql.slow-query-log.table is set. Connectors insert and delete
+-- slow queries with the prom_writer role.
+CREATE TABLE IF NOT EXISTS _ps_catalog.slow_query_log (
+    time                 TIMESTAMPTZ NOT NULL,
+    query                TEXT NOT NULL,
+    query_start          TIMESTAMPTZ NOT NULL,
+    query_end            TIMESTAMPTZ NOT NULL,
+    step_seconds         DOUBLE PRECISION NOT NULL,
+    tenant               TEXT,
+    caller               JSONB NOT NULL,
+    duration_seconds     DOUBLE PRECISION NOT NULL,
+    samples_touched      BIGINT NOT NULL,
+    series_fetched       BIGINT NOT NULL,
+    sql_round_trips      INTEGER NOT NULL,
+    sql_duration_seconds DOUBLE PRECISION NOT NULL,
+    error                TEXT
+);
+
+CREATE INDEX IF NOT EXISTS slow_query_log_time_idx ON _ps_catalog.slow_query_log (time DESC);
+
+GRANT SELECT ON TABLE _ps_catalog.slow_query_log TO prom_reader;
+GRANT SELECT, INSERT, DELETE ON TABLE _ps_catalog.slow_query_log TO prom_writer;
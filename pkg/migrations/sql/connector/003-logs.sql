@@ -0,0 +1,74 @@
+-- Table OTLP log records are stored in when logs.enable is set. Like the spans,
+-- its resource and attributes are tag maps referencing the _ps_trace.tag
+-- table, so they can be filtered with the ps_tag operators, and its trace_id
+-- and span_id columns join the spans.
+CREATE SCHEMA IF NOT EXISTS ps_log;
+GRANT USAGE ON SCHEMA ps_log TO prom_reader;
+
+CREATE TABLE IF NOT EXISTS ps_log.log (
+    time                        TIMESTAMPTZ NOT NULL,
+    observed_time               TIMESTAMPTZ NOT NULL,
+    trace_id                    UUID CHECK (trace_id != '00000000-0000-0000-0000-000000000000'),
+    span_id                     BIGINT CHECK (span_id != 0),
+    trace_flags                 INTEGER NOT NULL DEFAULT 0,
+    severity_number             SMALLINT NOT NULL DEFAULT 0,
+    severity_text               TEXT,
+    body                        TEXT,
+    attributes                  ps_trace.tag_map NOT NULL,
+    dropped_attributes_count    INTEGER NOT NULL DEFAULT 0,
+    resource_tags               ps_trace.tag_map NOT NULL,
+    resource_dropped_tags_count INTEGER NOT NULL DEFAULT 0,
+    resource_schema_url_id      BIGINT,
+    instrumentation_lib_id      BIGINT
+);
+
+DO $block$
+BEGIN
+    IF _prom_catalog.is_timescaledb_installed() THEN
+        PERFORM public.create_hypertable(
+            'ps_log.log'::regclass,
+            'time'::name,
+            chunk_time_interval=>'8 hours'::interval,
+            create_default_indexes=>false,
+            if_not_exists=>true
+        );
+    END IF;
+END
+$block$;
+
+CREATE INDEX IF NOT EXISTS log_time_idx ON ps_log.log (time DESC);
+CREATE INDEX IF NOT EXISTS log_trace_id_idx ON ps_log.log (trace_id, span_id) WHERE trace_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS log_attributes_idx ON ps_log.log USING GIN (attributes jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS log_resource_tags_idx ON ps_log.log USING GIN (resource_tags jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS log_body_idx ON ps_log.log USING GIN (to_tsvector('simple', body));
+
+-- Drops the logs older than _older_than. Every connector writing logs calls it
+-- periodically; the transaction advisory lock lets a single one drop the logs
+-- at a time, the others return right away. It runs as the owner of the table
+-- since dropping chunks requires ownership.
+CREATE OR REPLACE FUNCTION ps_log.drop_expired_logs(_older_than TIMESTAMPTZ)
+RETURNS BOOLEAN
+AS $func$
+BEGIN
+    -- Chosen randomly.
+    IF NOT pg_catalog.pg_try_advisory_xact_lock(7329521870263440917) THEN
+        RETURN false;
+    END IF;
+    IF _prom_catalog.is_timescaledb_installed() THEN
+        IF EXISTS (SELECT 1 FROM _timescaledb_catalog.hypertable WHERE schema_name = 'ps_log' AND table_name = 'log') THEN
+            PERFORM public.drop_chunks('ps_log.log'::regclass, older_than=>_older_than);
+        END IF;
+    END IF;
+    -- Chunks only partially expired are left by drop_chunks.
+    DELETE FROM ps_log.log WHERE time < _older_than;
+    RETURN true;
+END
+$func$
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, pg_temp;
+
+GRANT SELECT ON TABLE ps_log.log TO prom_reader;
+GRANT SELECT, INSERT, DELETE ON TABLE ps_log.log TO prom_writer;
+REVOKE ALL ON FUNCTION ps_log.drop_expired_logs(TIMESTAMPTZ) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION ps_log.drop_expired_logs(TIMESTAMPTZ) TO prom_writer;
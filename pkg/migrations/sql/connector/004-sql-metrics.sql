@@ -0,0 +1,95 @@
+-- Registry of the SQL metrics, tables or views queried as PromQL metrics when
+-- metrics.sql-metrics.enable is set, and the functions registering them.
+CREATE TABLE IF NOT EXISTS _ps_catalog.sql_metric (
+    metric_name   TEXT PRIMARY KEY,
+    table_schema  NAME NOT NULL,
+    table_name    NAME NOT NULL,
+    time_column   NAME NOT NULL,
+    value_columns NAME[] NOT NULL,
+    label_columns NAME[] NOT NULL
+);
+
+CREATE OR REPLACE FUNCTION prom_api.register_sql_metric(
+    metric_name TEXT, relation REGCLASS, time_column NAME, value_columns NAME[], label_columns NAME[] DEFAULT '{}'
+) RETURNS VOID
+LANGUAGE plpgsql
+SET search_path = pg_catalog, pg_temp
+AS $func$
+DECLARE
+    _schema NAME;
+    _table  NAME;
+    _column NAME;
+    _type   REGTYPE;
+BEGIN
+    IF metric_name IS NULL OR metric_name !~ '^[a-zA-Z_:][a-zA-Z0-9_:]*$' THEN
+        RAISE EXCEPTION 'invalid metric name %', metric_name;
+    END IF;
+    IF EXISTS (SELECT 1 FROM _prom_catalog.metric m WHERE m.metric_name = register_sql_metric.metric_name) THEN
+        RAISE EXCEPTION 'metric % already exists', metric_name;
+    END IF;
+    SELECT n.nspname, c.relname INTO _schema, _table
+    FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+    WHERE c.oid = relation;
+
+    SELECT a.atttypid::regtype INTO _type
+    FROM pg_attribute a
+    WHERE a.attrelid = relation AND a.attname = time_column AND a.attnum > 0 AND NOT a.attisdropped;
+    IF _type IS NULL OR _type NOT IN ('timestamptz'::regtype, 'timestamp'::regtype) THEN
+        RAISE EXCEPTION 'time column % of % must be a timestamptz or timestamp column', time_column, relation;
+    END IF;
+
+    IF coalesce(cardinality(value_columns), 0) = 0 THEN
+        RAISE EXCEPTION 'at least one value column is required';
+    END IF;
+    FOREACH _column IN ARRAY value_columns LOOP
+        SELECT a.atttypid::regtype INTO _type
+        FROM pg_attribute a
+        WHERE a.attrelid = relation AND a.attname = _column AND a.attnum > 0 AND NOT a.attisdropped;
+        IF _type IS NULL OR _type NOT IN ('smallint'::regtype, 'integer'::regtype, 'bigint'::regtype,
+            'real'::regtype, 'double precision'::regtype, 'numeric'::regtype) THEN
+            RAISE EXCEPTION 'value column % of % must be a numeric column', _column, relation;
+        END IF;
+    END LOOP;
+
+    FOREACH _column IN ARRAY coalesce(label_columns, '{}') LOOP
+        IF _column !~ '^[a-zA-Z_][a-zA-Z0-9_]*$' OR _column LIKE '\_\_%' THEN
+            RAISE EXCEPTION 'label column % is not a valid label name', _column;
+        END IF;
+        IF _column = time_column OR _column = ANY(value_columns) THEN
+            RAISE EXCEPTION 'label column % is also the time or a value column', _column;
+        END IF;
+        IF NOT EXISTS (
+            SELECT 1 FROM pg_attribute a
+            WHERE a.attrelid = relation AND a.attname = _column AND a.attnum > 0 AND NOT a.attisdropped
+        ) THEN
+            RAISE EXCEPTION 'label column % of % does not exist', _column, relation;
+        END IF;
+    END LOOP;
+
+    INSERT INTO _ps_catalog.sql_metric AS m (metric_name, table_schema, table_name, time_column, value_columns, label_columns)
+    VALUES (metric_name, _schema, _table, time_column, value_columns, coalesce(label_columns, '{}'))
+    ON CONFLICT (metric_name) DO UPDATE SET
+        table_schema = excluded.table_schema, table_name = excluded.table_name, time_column = excluded.time_column,
+        value_columns = excluded.value_columns, label_columns = excluded.label_columns;
+END
+$func$;
+
+CREATE OR REPLACE FUNCTION prom_api.unregister_sql_metric(metric_name TEXT) RETURNS BOOLEAN
+LANGUAGE sql
+SET search_path = pg_catalog, pg_temp
+AS $func$
+    WITH deleted AS (
+        DELETE FROM _ps_catalog.sql_metric m WHERE m.metric_name = unregister_sql_metric.metric_name RETURNING 1
+    )
+    SELECT EXISTS (SELECT 1 FROM deleted)
+$func$;
+
+COMMENT ON FUNCTION prom_api.register_sql_metric(TEXT, REGCLASS, NAME, NAME[], NAME[])
+    IS 'registers a table or view as a PromQL metric, with a series per value column and distinct label columns values';
+
+COMMENT ON FUNCTION prom_api.unregister_sql_metric(TEXT) IS 'unregisters a SQL metric, returning whether it was registered';
+
+REVOKE ALL ON FUNCTION prom_api.register_sql_metric(TEXT, REGCLASS, NAME, NAME[], NAME[]), prom_api.unregister_sql_metric(TEXT) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION prom_api.register_sql_metric(TEXT, REGCLASS, NAME, NAME[], NAME[]), prom_api.unregister_sql_metric(TEXT) TO prom_admin;
+GRANT SELECT ON TABLE _ps_catalog.sql_metric TO prom_reader;
+GRANT SELECT, INSERT, UPDATE, DELETE ON TABLE _ps_catalog.sql_metric TO prom_admin;
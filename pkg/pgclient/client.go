@@ -8,14 +8,17 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"strings"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 
 	"github.com/timescale/promscale/pkg/ha"
 	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel"
 	"github.com/timescale/promscale/pkg/pgmodel/cache"
 	"github.com/timescale/promscale/pkg/pgmodel/health"
 	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
@@ -51,6 +54,8 @@ type Client struct {
 	ingestor     ingestor.DBInserter
 	querier      querier.Querier
 	promqlEngine *promql.Engine
+	frontend     *query.Frontend
+	scheduler    *query.Scheduler
 	healthCheck  health.HealthCheckerFn
 	queryable    promql.Queryable
 	metricCache  cache.MetricCache
@@ -231,6 +236,9 @@ func NewClientWithPool(r prometheus.Registerer, cfg *Config, numCopiers int, wri
 		TracesBatchTimeout:      cfg.TracesBatchTimeout,
 		TracesMaxBatchSize:      cfg.TracesMaxBatchSize,
 		TracesBatchWorkers:      cfg.TracesBatchWorkers,
+		ServiceGraph:            cfg.ServiceGraphConfig,
+		TraceSampling:           cfg.TraceSamplingConfig,
+		Spool:                   cfg.SpoolConfig,
 	}
 
 	var (
@@ -243,8 +251,25 @@ func NewClientWithPool(r prometheus.Registerer, cfg *Config, numCopiers int, wri
 	exemplarKeyPosCache := cache.NewExemplarLabelsPosCache(cfg.CacheConfig)
 
 	labelsReader := lreader.NewLabelsReader(readerConn, labelsCache, mt.ReadAuthorizer())
-	dbQuerier := querier.NewQuerier(readerConn, metricsCache, labelsReader, exemplarKeyPosCache, mt.ReadAuthorizer())
-	queryable := query.NewQueryable(dbQuerier, labelsReader)
+	var (
+		querierOpts       []querier.Option
+		queryLabelsReader = labelsReader
+	)
+	if cfg.SQLMetricsConfig.Enabled {
+		missing, err := pgmodel.MissingRelations(context.Background(), readerConn, querier.SQLMetricTable)
+		if err != nil {
+			return nil, fmt.Errorf("sql metrics: %w", err)
+		}
+		if len(missing) > 0 {
+			log.Warn("msg", "SQL metrics are disabled since their registry doesn't exist, it is created when a connector migrates the database", "missing", strings.Join(missing, ","))
+		} else {
+			sqlMetrics := querier.NewSQLMetricRegistry(readerConn, cfg.SQLMetricsConfig.RefreshInterval)
+			querierOpts = append(querierOpts, querier.WithSQLMetrics(sqlMetrics))
+			queryLabelsReader = querier.NewSQLMetricLabelsReader(labelsReader, sqlMetrics, mt.ReadAuthorizer())
+		}
+	}
+	dbQuerier := querier.NewQuerier(readerConn, metricsCache, labelsReader, exemplarKeyPosCache, mt.ReadAuthorizer(), querierOpts...)
+	queryable := query.NewQueryable(dbQuerier, queryLabelsReader)
 
 	dbIngestor := ingestor.DBInserter(ingestor.ReadOnlyIngestor{})
 	if !readOnly {
@@ -282,6 +307,11 @@ func (c *Client) ReadOnlyConnection() pgxconn.PgxConn {
 	return c.readerPool
 }
 
+// WriterConnection returns the connection for writes, which is nil for read-only clients.
+func (c *Client) WriterConnection() pgxconn.PgxConn {
+	return c.writerPool
+}
+
 func (c *Client) MaintenanceConnection() pgxconn.PgxConn {
 	return c.maintPool
 }
@@ -292,6 +322,8 @@ func (c *Client) InitPromQLEngine(cfg *query.Config) error {
 		return fmt.Errorf("error creating PromQL engine: %w", err)
 	}
 	c.promqlEngine = engine
+	c.scheduler = query.NewScheduler(cfg)
+	c.frontend = query.NewFrontend(engine, cfg, c.scheduler)
 	return nil
 }
 
@@ -299,6 +331,18 @@ func (c *Client) QueryEngine() *promql.Engine {
 	return c.promqlEngine
 }
 
+// QueryFrontend returns the frontend splitting and sharding range queries
+// evaluated by the PromQL engine.
+func (c *Client) QueryFrontend() *query.Frontend {
+	return c.frontend
+}
+
+// QueryScheduler returns the scheduler admitting queries for evaluation, nil
+// if the number of concurrent queries is unlimited.
+func (c *Client) QueryScheduler() *query.Scheduler {
+	return c.scheduler
+}
+
 // Close closes the client and performs cleanup
 func (c *Client) Close() {
 	log.Info("msg", "Shutting down Client")
@@ -336,6 +380,11 @@ func (c *Client) IngestTraces(ctx context.Context, tr ptrace.Traces) error {
 	return c.ingestor.IngestTraces(ctx, tr)
 }
 
+// IngestLogs writes the logs object into the DB.
+func (c *Client) IngestLogs(ctx context.Context, l plog.Logs) error {
+	return c.ingestor.IngestLogs(ctx, l)
+}
+
 // Read returns the promQL query results
 func (c *Client) Read(ctx context.Context, req *prompb.ReadRequest) (*prompb.ReadResponse, error) {
 	if req == nil {
@@ -11,10 +11,10 @@ import (
 	"testing"
 
 	"github.com/prometheus/prometheus/model/labels"
-	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/timescale/promscale/pkg/pgmodel/querier"
 	"github.com/timescale/promscale/pkg/prompb"
+	"github.com/timescale/promscale/pkg/promql/parser"
 )
 
 type mockQuerier struct {
@@ -56,6 +56,10 @@ func (q *mockQuerier) ExemplarsQuerier(_ context.Context) querier.ExemplarQuerie
 	return nil
 }
 
+func (q *mockQuerier) LabelsQuerier(_ context.Context) querier.LabelsQuerier {
+	return nil
+}
+
 func (q *mockQuerier) LabelNames() ([]string, error) {
 	return q.labelNames, q.labelNamesErr
 }
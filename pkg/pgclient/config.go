@@ -17,7 +17,11 @@ import (
 	"github.com/timescale/promscale/pkg/limits"
 	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/pgmodel/cache"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/spool"
 	"github.com/timescale/promscale/pkg/pgmodel/ingestor/trace"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/trace/sampling"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/trace/servicegraph"
+	"github.com/timescale/promscale/pkg/pgmodel/querier"
 	"github.com/timescale/promscale/pkg/version"
 )
 
@@ -47,6 +51,10 @@ type Config struct {
 	TracesBatchTimeout      time.Duration
 	TracesMaxBatchSize      int
 	TracesBatchWorkers      int
+	ServiceGraphConfig      servicegraph.Config
+	TraceSamplingConfig     sampling.Config
+	SpoolConfig             spool.Config
+	SQLMetricsConfig        querier.SQLMetricsConfig
 }
 
 const (
@@ -75,6 +83,10 @@ var (
 // ParseFlags parses the configuration flags specific to PostgreSQL and TimescaleDB
 func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
 	cache.ParseFlags(fs, &cfg.CacheConfig)
+	servicegraph.ParseFlags(fs, &cfg.ServiceGraphConfig)
+	sampling.ParseFlags(fs, &cfg.TraceSamplingConfig)
+	spool.ParseFlags(fs, &cfg.SpoolConfig)
+	querier.ParseSQLMetricsFlags(fs, &cfg.SQLMetricsConfig)
 
 	fs.StringVar(&cfg.AppName, "db.app", DefaultApp, "This sets the application_name in database connection string. "+
 		"This is helpful during debugging when looking at pg_stat_activity.")
@@ -114,6 +126,22 @@ func Validate(cfg *Config, lcfg limits.Config) error {
 	if err := cfg.validateConnectionSettings(); err != nil {
 		return err
 	}
+	if err := servicegraph.Validate(&cfg.ServiceGraphConfig); err != nil {
+		return err
+	}
+	if err := sampling.Validate(&cfg.TraceSamplingConfig); err != nil {
+		return err
+	}
+	if err := spool.Validate(&cfg.SpoolConfig); err != nil {
+		return err
+	}
+	if err := querier.ValidateSQLMetrics(&cfg.SQLMetricsConfig); err != nil {
+		return err
+	}
+	if cfg.SpoolConfig.Enabled() && cfg.MetricsAsyncAcks {
+		// Failed inserts aren't reported with asynchronous acks, so they can't be spooled.
+		return fmt.Errorf("metrics.spool.dir can't be used with metrics.async-acks")
+	}
 	return cache.Validate(&cfg.CacheConfig, lcfg)
 }
 
@@ -0,0 +1,33 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package errors
+
+import (
+	stderrors "errors"
+	"io"
+	"net"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// IsUnavailable tells if the error is caused by the database being unreachable,
+// shutting down, failing over or out of resources, in which case the request
+// is worth retrying later.
+func IsUnavailable(err error) bool {
+	var pgErr *pgconn.PgError
+	if stderrors.As(err, &pgErr) {
+		return pgerrcode.IsConnectionException(pgErr.Code) ||
+			pgerrcode.IsOperatorIntervention(pgErr.Code) ||
+			pgerrcode.IsInsufficientResources(pgErr.Code) ||
+			pgErr.Code == pgerrcode.ReadOnlySQLTransaction
+	}
+	var netErr net.Error
+	return stderrors.As(err, &netErr) ||
+		pgconn.Timeout(err) ||
+		pgconn.SafeToRetry(err) ||
+		stderrors.Is(err, io.EOF) ||
+		stderrors.Is(err, io.ErrUnexpectedEOF)
+}
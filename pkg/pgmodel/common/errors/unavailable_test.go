@@ -0,0 +1,24 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package errors
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsUnavailable(t *testing.T) {
+	require.True(t, IsUnavailable(fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: pgerrcode.ConnectionFailure})))
+	require.True(t, IsUnavailable(&pgconn.PgError{Code: pgerrcode.CannotConnectNow}))
+	require.True(t, IsUnavailable(&pgconn.PgError{Code: pgerrcode.ReadOnlySQLTransaction}))
+	require.True(t, IsUnavailable(context.DeadlineExceeded))
+	require.False(t, IsUnavailable(&pgconn.PgError{Code: pgerrcode.UniqueViolation}))
+	require.False(t, IsUnavailable(fmt.Errorf("invalid sample")))
+}
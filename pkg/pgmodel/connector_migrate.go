@@ -0,0 +1,51 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/migrations"
+)
+
+const connectorFilesPattern = "sql/connector/*.sql"
+
+// installConnectorObjects applies the scripts of the database objects owned by
+// the connector in a single transaction. It runs after the extension is
+// installed or upgraded since the objects depend on its schemas and roles.
+func installConnectorObjects(conn *pgx.Conn) error {
+	// Glob returns the files in name order.
+	files, err := fs.Glob(migrations.ConnectorFiles, connectorFilesPattern)
+	if err != nil {
+		return fmt.Errorf("listing connector scripts: %w", err)
+	}
+
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+	for _, file := range files {
+		contents, err := fs.ReadFile(migrations.ConnectorFiles, file)
+		if err != nil {
+			return fmt.Errorf("unable to read connector script: name %s, err %w", file, err)
+		}
+		if _, err = tx.Exec(ctx, string(contents)); err != nil {
+			return fmt.Errorf("error executing connector script: name %s, err %w", file, err)
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("unable to commit connector scripts: %w", err)
+	}
+	log.Info("msg", "Installed the connector database objects", "scripts", len(files))
+	return nil
+}
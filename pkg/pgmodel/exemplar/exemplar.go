@@ -9,9 +9,9 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/promql/parser"
 )
 
 // QueryExemplar fetches the exemplars from the database using the queryable.
@@ -354,7 +354,7 @@ func insertSeries(ctx context.Context, conn pgxconn.PgxConn, onConflict bool, re
 	lowestMinTime := int64(math.MaxInt64)
 	tx, err := conn.BeginTx(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to start transaction for inserting metrics: %v", err), lowestMinTime
+		return fmt.Errorf("failed to start transaction for inserting metrics: %w", err), lowestMinTime
 	}
 	defer func() {
 		if tx != nil {
@@ -10,13 +10,17 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.uber.org/atomic"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/timescale/promscale/pkg/pgmodel/cache"
 	"github.com/timescale/promscale/pkg/pgmodel/common/errors"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/spool"
 	"github.com/timescale/promscale/pkg/pgmodel/ingestor/trace"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/trace/sampling"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/trace/servicegraph"
 	"github.com/timescale/promscale/pkg/pgmodel/metrics"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/pgxconn"
@@ -34,6 +38,9 @@ type Cfg struct {
 	TracesBatchTimeout      time.Duration
 	TracesMaxBatchSize      int
 	TracesBatchWorkers      int
+	ServiceGraph            servicegraph.Config
+	TraceSampling           sampling.Config
+	Spool                   spool.Config
 }
 
 // DBIngestor ingest the TimeSeries data into Timescale database.
@@ -41,7 +48,11 @@ type DBIngestor struct {
 	sCache     cache.SeriesCache
 	dispatcher model.Dispatcher
 	tWriter    trace.Writer
+	lWriter    trace.LogWriter
 	closed     *atomic.Bool
+
+	serviceGraph *servicegraph.Processor
+	spool        *spool.Spool
 }
 
 // NewPgxIngestor returns a new Ingestor that uses connection pool and a metrics cache
@@ -58,12 +69,32 @@ func NewPgxIngestor(conn pgxconn.PgxConn, cache cache.MetricCache, sCache cache.
 		Writers:      cfg.NumCopiers,
 	}
 	traceWriter := trace.NewWriter(conn)
-	return &DBIngestor{
+	var tWriter trace.Writer = trace.NewDispatcher(traceWriter, cfg.TracesAsyncAcks, batcherConfg)
+	if cfg.TraceSampling.Enabled {
+		sampler := sampling.NewSampler(cfg.TraceSampling, tWriter)
+		sampler.Run()
+		tWriter = sampler
+	}
+	ingestor := &DBIngestor{
 		sCache:     sCache,
 		dispatcher: dispatcher,
-		tWriter:    trace.NewDispatcher(traceWriter, cfg.TracesAsyncAcks, batcherConfg),
+		tWriter:    tWriter,
+		lWriter:    traceWriter,
 		closed:     atomic.NewBool(false),
-	}, nil
+	}
+	if cfg.ServiceGraph.Enabled {
+		ingestor.serviceGraph = servicegraph.NewProcessor(cfg.ServiceGraph, ingestor.ingestServiceGraph)
+		ingestor.serviceGraph.Run()
+	}
+	if cfg.Spool.Enabled() {
+		ingestor.spool, err = spool.New(cfg.Spool, ingestor.insertMetrics)
+		if err != nil {
+			ingestor.Close()
+			return nil, fmt.Errorf("creating ingest spool: %w", err)
+		}
+		ingestor.spool.Run()
+	}
+	return ingestor, nil
 }
 
 // NewPgxIngestorForTests returns a new Ingestor that write to PostgreSQL using PGX
@@ -86,9 +117,34 @@ func (ingestor *DBIngestor) IngestTraces(ctx context.Context, traces ptrace.Trac
 	}
 	_, span := tracer.Default().Start(ctx, "ingest-traces")
 	defer span.End()
+	if ingestor.serviceGraph != nil {
+		// The service graph is generated from all the spans, before the
+		// sampler drops any trace, so that its request counts and latencies
+		// aren't skewed by the sampling policies. The trace writer takes
+		// ownership of the spans, so the service graph has to look at them
+		// first.
+		ingestor.serviceGraph.Consume(traces)
+	}
 	return ingestor.tWriter.InsertTraces(ctx, traces)
 }
 
+func (ingestor *DBIngestor) IngestLogs(ctx context.Context, logs plog.Logs) error {
+	if ingestor.closed.Load() {
+		return fmt.Errorf("ingestor is closed and can't ingest logs")
+	}
+	_, span := tracer.Default().Start(ctx, "ingest-logs")
+	defer span.End()
+	return ingestor.lWriter.InsertLogs(ctx, logs)
+}
+
+// ingestServiceGraph writes the series generated by the service graph processor.
+func (ingestor *DBIngestor) ingestServiceGraph(ctx context.Context, ts []prompb.TimeSeries) error {
+	wr := NewWriteRequest()
+	wr.Timeseries = ts
+	_, _, err := ingestor.IngestMetrics(ctx, wr)
+	return err
+}
+
 // IngestMetrics transforms and ingests the timeseries data into Timescale database.
 // input:
 //
@@ -98,6 +154,23 @@ func (ingestor *DBIngestor) IngestMetrics(ctx context.Context, r *prompb.WriteRe
 	if ingestor.closed.Load() {
 		return 0, 0, fmt.Errorf("ingestor is closed and can't ingest metrics")
 	}
+	// WriteRequests can contain pointers into the original buffer we deserialized
+	// them out of, and can be quite large in and of themselves. In order to prevent
+	// memory blowup, and to allow faster deserializing, we recycle the WriteRequest
+	// here, allowing it to be either garbage collected or reused for a new request.
+	// In order for this to work correctly, any data we wish to keep using (e.g.
+	// samples) must no longer be reachable from req. The spool serializes the
+	// request before inserting it, so it is only recycled once the spool is done.
+	defer FinishWriteRequest(r)
+	if ingestor.spool != nil {
+		return ingestor.spool.Ingest(ctx, r)
+	}
+	return ingestor.insertMetrics(ctx, r)
+}
+
+// insertMetrics inserts the timeseries and metadata of the write request. It
+// doesn't recycle the request.
+func (ingestor *DBIngestor) insertMetrics(ctx context.Context, r *prompb.WriteRequest) (numInsertablesIngested uint64, numMetadataIngested uint64, err error) {
 	ctx, span := tracer.Default().Start(ctx, "db-ingest")
 	defer span.End()
 	metrics.IngestorActiveWriteRequests.With(prometheus.Labels{"type": "metric", "kind": "sample_or_metadata"}).Inc()
@@ -107,13 +180,6 @@ func (ingestor *DBIngestor) IngestMetrics(ctx context.Context, r *prompb.WriteRe
 		metadata   = r.Metadata
 		size       = r.Size()
 	)
-	// WriteRequests can contain pointers into the original buffer we deserialized
-	// them out of, and can be quite large in and of themselves. In order to prevent
-	// memory blowup, and to allow faster deserializing, we recycle the WriteRequest
-	// here, allowing it to be either garbage collected or reused for a new request.
-	// In order for this to work correctly, any data we wish to keep using (e.g.
-	// samples) must no longer be reachable from req.
-	defer FinishWriteRequest(r)
 
 	defer func(size int) {
 		if err == nil {
@@ -259,6 +325,14 @@ func (ingestor *DBIngestor) Close() {
 	if ingestor.closed.Load() {
 		return
 	}
+	if ingestor.serviceGraph != nil {
+		// Stopping flushes the final state, so the metric dispatcher must still be open.
+		ingestor.serviceGraph.Stop()
+	}
+	if ingestor.spool != nil {
+		// Spooled write requests left are ingested after the next start.
+		ingestor.spool.Stop()
+	}
 	ingestor.tWriter.Close()
 	ingestor.closed.Store(true)
 	ingestor.dispatcher.Close()
@@ -272,4 +346,8 @@ func (ReadOnlyIngestor) IngestMetrics(context.Context, *prompb.WriteRequest) (nu
 func (ReadOnlyIngestor) IngestTraces(context.Context, ptrace.Traces) error {
 	return fmt.Errorf("ingesting traces not allowed in read-only mode")
 }
+
+func (ReadOnlyIngestor) IngestLogs(context.Context, plog.Logs) error {
+	return fmt.Errorf("ingesting logs not allowed in read-only mode")
+}
 func (ReadOnlyIngestor) Close() {}
@@ -8,6 +8,7 @@ import (
 	"context"
 
 	"github.com/timescale/promscale/pkg/prompb"
+	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 )
 
@@ -18,5 +19,8 @@ type DBInserter interface {
 	// Returns the number of metrics ingested and any error encountered before finishing.
 	IngestMetrics(context.Context, *prompb.WriteRequest) (uint64, uint64, error)
 	IngestTraces(context.Context, ptrace.Traces) error
+	// IngestLogs stores OTLP logs in the ps_log schema, which must have been
+	// installed beforehand.
+	IngestLogs(context.Context, plog.Logs) error
 	Close()
 }
@@ -7,12 +7,18 @@ package ingestor
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
 
 	"github.com/timescale/promscale/pkg/pgmodel/cache"
 	"github.com/timescale/promscale/pkg/pgmodel/common/errors"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/spool"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/prompb"
 )
@@ -316,3 +322,83 @@ func TestDBIngestorIngest(t *testing.T) {
 		})
 	}
 }
+
+// unavailableDispatcher fails the inserts as if the database were down until
+// it is brought up.
+type unavailableDispatcher struct {
+	model.MockInserter
+	mu   sync.Mutex
+	down bool
+}
+
+func (d *unavailableDispatcher) setDown(down bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.down = down
+}
+
+func (d *unavailableDispatcher) InsertTs(ctx context.Context, data model.Data) (uint64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.down {
+		return 0, &pgconn.PgError{Code: pgerrcode.ConnectionFailure}
+	}
+	return d.MockInserter.InsertTs(ctx, data)
+}
+
+// samples returns the timestamps and values of the inserted samples.
+func (d *unavailableDispatcher) samples() []prompb.Sample {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var res []prompb.Sample
+	for _, rows := range d.InsertedData {
+		for _, insertables := range rows {
+			for _, ins := range insertables {
+				it, ok := ins.Iterator().(model.SamplesIterator)
+				if !ok {
+					continue
+				}
+				for it.HasNext() {
+					ts, v := it.Value()
+					res = append(res, prompb.Sample{Timestamp: ts, Value: v})
+				}
+			}
+		}
+	}
+	return res
+}
+
+func TestDBIngestorSpoolReplaysSamples(t *testing.T) {
+	dispatcher := &unavailableDispatcher{
+		MockInserter: model.MockInserter{InsertedSeries: make(map[string]model.SeriesID)},
+		down:         true,
+	}
+	i := &DBIngestor{
+		dispatcher: dispatcher,
+		sCache:     cache.NewSeriesCache(cache.DefaultConfig, nil),
+		closed:     atomic.NewBool(false),
+	}
+	cfg := spool.DefaultConfig
+	cfg.Dir = t.TempDir()
+	cfg.ReplayInterval = 10 * time.Millisecond
+	var err error
+	i.spool, err = spool.New(cfg, i.insertMetrics)
+	require.NoError(t, err)
+	i.spool.Run()
+	defer i.spool.Stop()
+
+	wr := NewWriteRequest()
+	wr.Timeseries = []prompb.TimeSeries{{
+		Labels:    []prompb.Label{{Name: model.MetricNameLabelName, Value: "m"}},
+		Samples:   []prompb.Sample{{Timestamp: 1, Value: 0.1}, {Timestamp: 2, Value: 0.2}},
+		Exemplars: []prompb.Exemplar{{Labels: []prompb.Label{{Name: "trace_id", Value: "abc"}}, Timestamp: 2, Value: 0.2}},
+	}}
+	// The insert fails, the request is spooled and acknowledged.
+	_, _, err = i.IngestMetrics(context.Background(), wr)
+	require.NoError(t, err)
+	require.Empty(t, dispatcher.samples())
+
+	dispatcher.setDown(false)
+	require.Eventually(t, func() bool { return len(dispatcher.samples()) > 0 }, 10*time.Second, 10*time.Millisecond)
+	require.Equal(t, []prompb.Sample{{Timestamp: 1, Value: 0.1}, {Timestamp: 2, Value: 0.2}}, dispatcher.samples())
+}
@@ -0,0 +1,78 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package spool
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+const (
+	// DropPolicyReject rejects new write requests once the spool is full, so
+	// the client keeps them and retries.
+	DropPolicyReject = "reject"
+	// DropPolicyDropOldest drops the oldest spooled write requests to make
+	// room for new ones.
+	DropPolicyDropOldest = "drop-oldest"
+
+	defaultMaxBytes          = 1 << 30
+	defaultReplayInterval    = 5 * time.Second
+	defaultReplayConcurrency = 1
+)
+
+type Config struct {
+	Dir               string
+	MaxBytes          int64
+	DropPolicy        string
+	ReplayInterval    time.Duration
+	ReplayConcurrency int
+}
+
+var DefaultConfig = Config{
+	Dir:               "",
+	MaxBytes:          defaultMaxBytes,
+	DropPolicy:        DropPolicyReject,
+	ReplayInterval:    defaultReplayInterval,
+	ReplayConcurrency: defaultReplayConcurrency,
+}
+
+// Enabled tells if write requests are spooled to disk.
+func (cfg *Config) Enabled() bool {
+	return cfg.Dir != ""
+}
+
+func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
+	fs.StringVar(&cfg.Dir, "metrics.spool.dir", "", "Directory where metric write requests are persisted while the database is unavailable, to be ingested once it is back. "+
+		"Setting it enables the spool. It requires synchronous metric inserts, i.e. metrics.async-acks disabled.")
+	fs.Int64Var(&cfg.MaxBytes, "metrics.spool.max-bytes", defaultMaxBytes, "Maximum size in bytes of the write requests kept in the spool. Once reached, metrics.spool.drop-policy applies.")
+	fs.StringVar(&cfg.DropPolicy, "metrics.spool.drop-policy", DropPolicyReject, "What to do with write requests once the spool is full. "+
+		"'reject' fails new write requests so the client retries them, 'drop-oldest' drops the oldest spooled write requests to make room.")
+	fs.DurationVar(&cfg.ReplayInterval, "metrics.spool.replay-interval", defaultReplayInterval, "Interval between attempts to ingest the spooled write requests while the database is unavailable.")
+	fs.IntVar(&cfg.ReplayConcurrency, "metrics.spool.replay-concurrency", defaultReplayConcurrency, "Number of spooled write requests ingested concurrently once the database is back. "+
+		"With more than 1, the spooled samples of a series may be ingested out of order.")
+	return cfg
+}
+
+func Validate(cfg *Config) error {
+	if !cfg.Enabled() {
+		return nil
+	}
+	if cfg.MaxBytes <= 0 {
+		return fmt.Errorf("metrics.spool.max-bytes must be positive: %d", cfg.MaxBytes)
+	}
+	switch cfg.DropPolicy {
+	case DropPolicyReject, DropPolicyDropOldest:
+	default:
+		return fmt.Errorf("metrics.spool.drop-policy must be one of '%s' or '%s': %s", DropPolicyReject, DropPolicyDropOldest, cfg.DropPolicy)
+	}
+	if cfg.ReplayInterval <= 0 {
+		return fmt.Errorf("metrics.spool.replay-interval must be positive: %s", cfg.ReplayInterval)
+	}
+	if cfg.ReplayConcurrency <= 0 {
+		return fmt.Errorf("metrics.spool.replay-concurrency must be positive: %d", cfg.ReplayConcurrency)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package spool
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/timescale/promscale/pkg/util"
+)
+
+var (
+	spoolBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "ingest",
+			Name:      "spool_bytes",
+			Help:      "Size in bytes of the write requests waiting in the spool.",
+		},
+	)
+	spoolRequests = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "ingest",
+			Name:      "spool_requests",
+			Help:      "Number of write requests waiting in the spool.",
+		},
+	)
+	spooledRequests = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "ingest",
+			Name:      "spool_spooled_requests_total",
+			Help:      "Total number of write requests persisted to the spool.",
+		},
+	)
+	replayedRequests = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "ingest",
+			Name:      "spool_replayed_requests_total",
+			Help:      "Total number of spooled write requests ingested into the database.",
+		},
+	)
+	droppedRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "ingest",
+			Name:      "spool_dropped_requests_total",
+			Help:      "Total number of write requests dropped or rejected by the spool, by reason.",
+		},
+		[]string{"reason"},
+	)
+	replayErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "ingest",
+			Name:      "spool_replay_errors_total",
+			Help:      "Total number of failed attempts to ingest a spooled write request because the database is unavailable.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		spoolBytes,
+		spoolRequests,
+		spooledRequests,
+		replayedRequests,
+		droppedRequests,
+		replayErrors,
+	)
+}
@@ -0,0 +1,381 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+// Package spool persists metric write requests to disk while the database is
+// unavailable and ingests them, oldest first, once it is back.
+package spool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/timescale/promscale/pkg/log"
+	pgmodelErrs "github.com/timescale/promscale/pkg/pgmodel/common/errors"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+const fileExt = ".spool"
+
+// ErrFull is returned when a write request doesn't fit in the spool.
+var ErrFull = errors.New("ingest spool is full")
+
+// IngestFunc ingests a write request into the database. It must not recycle
+// the request, but may consume it, e.g. by moving the samples out of it.
+type IngestFunc func(context.Context, *prompb.WriteRequest) (uint64, uint64, error)
+
+type entry struct {
+	seq  uint64
+	size int64
+}
+
+// Spool wraps the ingestion of metric write requests. Requests failing because
+// the database is unavailable are persisted to disk, as are all requests until
+// the database is healthy again, so they don't wait for it to time out. The
+// spooled requests are ingested concurrently in the background, oldest first,
+// once the database is back, while new requests go straight to the database.
+type Spool struct {
+	cfg    Config
+	ingest IngestFunc
+
+	mu        sync.Mutex
+	entries   []entry
+	bytes     int64
+	nextSeq   uint64
+	unhealthy bool
+
+	wake   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New returns a spool ingesting with ingest. The requests spooled before a
+// restart are loaded from the directory.
+func New(cfg Config, ingest IngestFunc) (*Spool, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("creating spool directory: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Spool{
+		cfg:    cfg,
+		ingest: ingest,
+		wake:   make(chan struct{}, 1),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	if err := s.load(); err != nil {
+		cancel()
+		return nil, err
+	}
+	if len(s.entries) > 0 {
+		log.Info("msg", "Found spooled write requests, ingesting them once the database is available", "requests", len(s.entries), "bytes", s.bytes)
+	}
+	return s, nil
+}
+
+func (s *Spool) load() error {
+	files, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("reading spool directory: %w", err)
+	}
+	for _, f := range files {
+		name := f.Name()
+		if strings.HasSuffix(name, ".tmp") {
+			// Leftover of an interrupted write, the request wasn't acknowledged.
+			_ = os.Remove(filepath.Join(s.cfg.Dir, name))
+			continue
+		}
+		if !strings.HasSuffix(name, fileExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, fileExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			return fmt.Errorf("reading spool file info: %w", err)
+		}
+		s.entries = append(s.entries, entry{seq: seq, size: info.Size()})
+		s.bytes += info.Size()
+		if seq >= s.nextSeq {
+			s.nextSeq = seq + 1
+		}
+	}
+	sort.Slice(s.entries, func(i, j int) bool { return s.entries[i].seq < s.entries[j].seq })
+	s.updateMetrics()
+	return nil
+}
+
+// Run starts ingesting the spooled requests in the background.
+func (s *Spool) Run() {
+	go s.replayLoop()
+	s.notify()
+}
+
+// Stop stops ingesting the spooled requests. The remaining ones are ingested
+// on the next start.
+func (s *Spool) Stop() {
+	s.cancel()
+	<-s.done
+}
+
+// Ingest ingests the write request, spooling it if the database is
+// unavailable. Once the database was found unavailable, requests are spooled
+// without trying it until a spooled request is ingested again. The request is
+// serialized before it is ingested, since ingesting consumes it, and the
+// caller recycles it afterwards.
+func (s *Spool) Ingest(ctx context.Context, r *prompb.WriteRequest) (uint64, uint64, error) {
+	data, err := r.Marshal()
+	if err != nil {
+		return 0, 0, fmt.Errorf("serializing write request for the spool: %w", err)
+	}
+	var ingestErr error
+	if s.healthy() {
+		numInsertables, numMetadata, err := s.ingest(ctx, r)
+		if err == nil || !pgmodelErrs.IsUnavailable(err) {
+			return numInsertables, numMetadata, err
+		}
+		log.Warn("msg", "Database unavailable, spooling write request", "err", err)
+		s.setHealthy(false)
+		ingestErr = err
+	}
+	if err = s.append(data); err != nil {
+		if ingestErr != nil {
+			return 0, 0, fmt.Errorf("%w: %s", err, ingestErr.Error())
+		}
+		return 0, 0, err
+	}
+	return 0, 0, nil
+}
+
+func (s *Spool) healthy() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.unhealthy
+}
+
+func (s *Spool) setHealthy(healthy bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unhealthy = !healthy
+}
+
+func (s *Spool) empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries) == 0
+}
+
+// append persists the serialized request as the newest spool file.
+func (s *Spool) append(data []byte) error {
+	size := int64(len(data))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if size > s.cfg.MaxBytes {
+		droppedRequests.WithLabelValues("full").Inc()
+		return ErrFull
+	}
+	for s.bytes+size > s.cfg.MaxBytes {
+		if s.cfg.DropPolicy != DropPolicyDropOldest || len(s.entries) == 0 {
+			droppedRequests.WithLabelValues("full").Inc()
+			return ErrFull
+		}
+		oldest := s.entries[0]
+		if err := os.Remove(s.path(oldest.seq)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("dropping oldest spooled write request: %w", err)
+		}
+		s.removeLocked(oldest.seq)
+		droppedRequests.WithLabelValues("dropped_oldest").Inc()
+	}
+
+	seq := s.nextSeq
+	if err := writeFile(s.cfg.Dir, s.path(seq), data); err != nil {
+		return fmt.Errorf("spooling write request: %w", err)
+	}
+	s.nextSeq++
+	s.entries = append(s.entries, entry{seq: seq, size: size})
+	s.bytes += size
+	spooledRequests.Inc()
+	s.updateMetrics()
+	s.notify()
+	return nil
+}
+
+// writeFile writes and syncs the file, then renames it into place, so a
+// spooled request is either complete or absent after a crash.
+func writeFile(dir, path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return err
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (s *Spool) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Spool) replayLoop() {
+	defer close(s.done)
+	for {
+		entries := s.oldest(s.cfg.ReplayConcurrency)
+		if len(entries) == 0 {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-s.wake:
+				continue
+			}
+		}
+		if err := s.replayAll(entries); err != nil {
+			replayErrors.Inc()
+			log.Debug("msg", "Database still unavailable, retrying spooled write requests later", "err", err)
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-time.After(s.cfg.ReplayInterval):
+			}
+		}
+	}
+}
+
+// oldest returns up to n of the oldest spooled requests.
+func (s *Spool) oldest(n int) []entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n > len(s.entries) {
+		n = len(s.entries)
+	}
+	return append([]entry(nil), s.entries[:n]...)
+}
+
+// replayAll replays the spooled requests concurrently, so the samples of a
+// series spread over several requests may be ingested out of order unless
+// they are replayed one at a time. It returns the error of one of the
+// requests which couldn't be replayed.
+func (s *Spool) replayAll(entries []entry) error {
+	var (
+		wg    sync.WaitGroup
+		errMu sync.Mutex
+		err   error
+	)
+	for _, e := range entries {
+		wg.Add(1)
+		go func(e entry) {
+			defer wg.Done()
+			if replayErr := s.replay(e); replayErr != nil {
+				errMu.Lock()
+				err = replayErr
+				errMu.Unlock()
+			}
+		}(e)
+	}
+	wg.Wait()
+	return err
+}
+
+// replay ingests the spooled request, removing it unless the database is
+// unavailable. Requests failing for other reasons would never succeed, so
+// they are dropped.
+func (s *Spool) replay(e entry) error {
+	data, err := os.ReadFile(s.path(e.seq))
+	if os.IsNotExist(err) {
+		// Dropped to make room in the meantime.
+		s.remove(e.seq)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	r := &prompb.WriteRequest{}
+	if err = r.Unmarshal(data); err != nil {
+		log.Error("msg", "Dropping corrupt spooled write request", "file", s.path(e.seq), "err", err)
+		droppedRequests.WithLabelValues("invalid").Inc()
+		return s.delete(e.seq)
+	}
+	if _, _, err = s.ingest(s.ctx, r); err != nil {
+		if pgmodelErrs.IsUnavailable(err) {
+			s.setHealthy(false)
+			return err
+		}
+		if s.ctx.Err() != nil {
+			return err
+		}
+		log.Error("msg", "Dropping spooled write request failing to be ingested", "file", s.path(e.seq), "err", err)
+		droppedRequests.WithLabelValues("invalid").Inc()
+		return s.delete(e.seq)
+	}
+	replayedRequests.Inc()
+	s.setHealthy(true)
+	return s.delete(e.seq)
+}
+
+func (s *Spool) delete(seq uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path(seq)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing spooled write request: %w", err)
+	}
+	s.removeLocked(seq)
+	return nil
+}
+
+func (s *Spool) remove(seq uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeLocked(seq)
+}
+
+func (s *Spool) removeLocked(seq uint64) {
+	// Entries are replayed oldest first, so they are usually at the front.
+	for i := range s.entries {
+		if s.entries[i].seq == seq {
+			s.bytes -= s.entries[i].size
+			s.entries = append(s.entries[:i:i], s.entries[i+1:]...)
+			s.updateMetrics()
+			return
+		}
+	}
+}
+
+func (s *Spool) updateMetrics() {
+	spoolBytes.Set(float64(s.bytes))
+	spoolRequests.Set(float64(len(s.entries)))
+}
+
+func (s *Spool) path(seq uint64) string {
+	return filepath.Join(s.cfg.Dir, fmt.Sprintf("%020d%s", seq, fileExt))
+}
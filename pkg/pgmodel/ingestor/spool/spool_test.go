@@ -0,0 +1,218 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package spool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+// mockDB ingests write requests unless it is down.
+type mockDB struct {
+	mu       sync.Mutex
+	down     bool
+	ingested []int64
+	attempts int
+	// delay is how long ingesting a request takes.
+	delay       time.Duration
+	inFlight    int
+	maxInFlight int
+}
+
+func (m *mockDB) setDown(down bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.down = down
+}
+
+func (m *mockDB) timestamps() []int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]int64(nil), m.ingested...)
+}
+
+func (m *mockDB) ingest(_ context.Context, r *prompb.WriteRequest) (uint64, uint64, error) {
+	m.mu.Lock()
+	m.inFlight++
+	if m.inFlight > m.maxInFlight {
+		m.maxInFlight = m.inFlight
+	}
+	m.mu.Unlock()
+	time.Sleep(m.delay)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight--
+	m.attempts++
+	if m.down {
+		return 0, 0, fmt.Errorf("copier: %w", &pgconn.PgError{Code: pgerrcode.AdminShutdown})
+	}
+	if r.Timeseries[0].Samples[0].Value < 0 {
+		return 0, 0, fmt.Errorf("invalid sample")
+	}
+	m.ingested = append(m.ingested, r.Timeseries[0].Samples[0].Timestamp)
+	return 1, 0, nil
+}
+
+func request(ts int64, v float64) *prompb.WriteRequest {
+	return &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "m"}},
+		Samples: []prompb.Sample{{Timestamp: ts, Value: v}},
+	}}}
+}
+
+func newSpool(t *testing.T, cfg Config, db *mockDB) *Spool {
+	s, err := New(cfg, db.ingest)
+	require.NoError(t, err)
+	s.Run()
+	return s
+}
+
+func TestSpoolReplays(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.Dir = t.TempDir()
+	cfg.ReplayInterval = 10 * time.Millisecond
+	db := &mockDB{}
+	s := newSpool(t, cfg, db)
+
+	ctx := context.Background()
+	_, _, err := s.Ingest(ctx, request(1, 1))
+	require.NoError(t, err)
+
+	db.setDown(true)
+	for ts := int64(2); ts <= 4; ts++ {
+		_, _, err = s.Ingest(ctx, request(ts, 1))
+		require.NoError(t, err)
+	}
+	// Invalid requests are dropped on replay instead of blocking the spool.
+	_, _, err = s.Ingest(ctx, request(5, -1))
+	require.NoError(t, err)
+	_, _, err = s.Ingest(ctx, request(6, 1))
+	require.NoError(t, err)
+	require.Equal(t, []int64{1}, db.timestamps())
+
+	// Requests spooled before a restart are kept.
+	s.Stop()
+	s = newSpool(t, cfg, db)
+	defer s.Stop()
+	require.Len(t, s.entries, 5)
+
+	db.setDown(false)
+	// New requests go straight to the healthy database.
+	_, _, err = s.Ingest(ctx, request(7, 1))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return s.empty() }, 5*time.Second, 10*time.Millisecond)
+	require.ElementsMatch(t, []int64{1, 2, 3, 4, 6, 7}, db.timestamps())
+
+	// Errors other than the database being unavailable aren't spooled.
+	_, _, err = s.Ingest(ctx, request(8, -1))
+	require.Error(t, err)
+	require.True(t, s.empty())
+}
+
+func TestSpoolUnhealthyDatabase(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.Dir = t.TempDir()
+	db := &mockDB{down: true}
+	s, err := New(cfg, db.ingest)
+	require.NoError(t, err)
+
+	// Once the database failed, requests are spooled without trying it.
+	ctx := context.Background()
+	for ts := int64(1); ts <= 3; ts++ {
+		_, _, err = s.Ingest(ctx, request(ts, 1))
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, db.attempts)
+	require.Len(t, s.entries, 3)
+
+	// A replayed request marks the database healthy again.
+	db.setDown(false)
+	require.NoError(t, s.replay(s.entries[0]))
+	require.Len(t, s.entries, 2)
+	_, _, err = s.Ingest(ctx, request(4, 1))
+	require.NoError(t, err)
+	require.Len(t, s.entries, 2)
+	require.Equal(t, []int64{1, 4}, db.timestamps())
+}
+
+func TestSpoolReplaysInOrder(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.Dir = t.TempDir()
+	db := &mockDB{down: true}
+	s, err := New(cfg, db.ingest)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for ts := int64(1); ts <= 5; ts++ {
+		_, _, err = s.Ingest(ctx, request(ts, 1))
+		require.NoError(t, err)
+	}
+	db.setDown(false)
+	s.Run()
+	defer s.Stop()
+	require.Eventually(t, func() bool { return s.empty() }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []int64{1, 2, 3, 4, 5}, db.timestamps())
+	require.Equal(t, 1, db.maxInFlight)
+}
+
+func TestSpoolReplaysConcurrently(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.Dir = t.TempDir()
+	cfg.ReplayConcurrency = 3
+	db := &mockDB{down: true}
+	s, err := New(cfg, db.ingest)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for ts := int64(1); ts <= 7; ts++ {
+		_, _, err = s.Ingest(ctx, request(ts, 1))
+		require.NoError(t, err)
+	}
+	db.setDown(false)
+	db.delay = 20 * time.Millisecond
+	s.Run()
+	defer s.Stop()
+	require.Eventually(t, func() bool { return s.empty() }, 5*time.Second, 10*time.Millisecond)
+	require.ElementsMatch(t, []int64{1, 2, 3, 4, 5, 6, 7}, db.timestamps())
+	require.Equal(t, 3, db.maxInFlight)
+	require.Zero(t, s.bytes)
+}
+
+func TestSpoolFull(t *testing.T) {
+	data, err := request(1, 1).Marshal()
+	require.NoError(t, err)
+
+	cfg := DefaultConfig
+	cfg.Dir = t.TempDir()
+	cfg.MaxBytes = int64(len(data) * 2)
+	db := &mockDB{down: true}
+	s, err := New(cfg, db.ingest)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for ts := int64(1); ts <= 2; ts++ {
+		_, _, err = s.Ingest(ctx, request(ts, 1))
+		require.NoError(t, err)
+	}
+	_, _, err = s.Ingest(ctx, request(3, 1))
+	require.ErrorIs(t, err, ErrFull)
+	require.Equal(t, []entry{{seq: 0, size: int64(len(data))}, {seq: 1, size: int64(len(data))}}, s.entries)
+
+	s.cfg.DropPolicy = DropPolicyDropOldest
+	_, _, err = s.Ingest(ctx, request(3, 1))
+	require.NoError(t, err)
+	require.Equal(t, []entry{{seq: 1, size: int64(len(data))}, {seq: 2, size: int64(len(data))}}, s.entries)
+	require.Equal(t, int64(len(data)*2), s.bytes)
+}
@@ -0,0 +1,195 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package trace
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+
+	"github.com/timescale/promscale/pkg/pgmodel/metrics"
+)
+
+var (
+	logTable        = pgx.Identifier{"ps_log", "log"}
+	logTableColumns = []string{"time", "observed_time", "trace_id", "span_id", "trace_flags", "severity_number", "severity_text", "body",
+		"attributes", "dropped_attributes_count", "resource_tags", "resource_dropped_tags_count", "resource_schema_url_id", "instrumentation_lib_id"}
+
+	logLabel       = prometheus.Labels{"type": "log"}
+	logRecordLabel = prometheus.Labels{"type": "log", "kind": "record"}
+)
+
+// LogWriter writes OTLP logs to the ps_log.log table.
+type LogWriter interface {
+	InsertLogs(ctx context.Context, logs plog.Logs) error
+}
+
+// InsertLogs writes the log records. Their resource and attributes are stored
+// as tag maps, like the ones of the spans, sharing the tag, schema URL and
+// instrumentation library caches of the trace writer.
+func (t *traceWriterImpl) InsertLogs(ctx context.Context, logs plog.Logs) error {
+	startIngest := time.Now()
+	code := "500"
+	metrics.IngestorActiveWriteRequests.With(logRecordLabel).Inc()
+	metrics.IngestorItemsReceived.With(logRecordLabel).Observe(float64(logs.LogRecordCount()))
+	defer func() {
+		metrics.IngestorDuration.With(prometheus.Labels{"type": "log", "code": code}).Observe(time.Since(startIngest).Seconds())
+		metrics.IngestorActiveWriteRequests.With(logRecordLabel).Dec()
+	}()
+
+	rLogs := logs.ResourceLogs()
+	sURLBatch := newSchemaUrlBatch(t.schemaCache)
+	for i := 0; i < rLogs.Len(); i++ {
+		rLog := rLogs.At(i)
+		sURLBatch.Queue(rLog.SchemaUrl())
+		scopeLogs := rLog.ScopeLogs()
+		for j := 0; j < scopeLogs.Len(); j++ {
+			sURLBatch.Queue(scopeLogs.At(j).SchemaUrl())
+		}
+	}
+	if err := sURLBatch.SendBatch(ctx, t.conn); err != nil {
+		return err
+	}
+
+	instrLibBatch := newInstrumentationLibraryBatch(t.instLibCache)
+	tagsBatch := newTagBatch(t.tagCache)
+	for i := 0; i < rLogs.Len(); i++ {
+		rLog := rLogs.At(i)
+		if err := tagsBatch.Queue(rLog.Resource().Attributes().AsRaw(), ResourceTagType); err != nil {
+			return err
+		}
+		scopeLogs := rLog.ScopeLogs()
+		for j := 0; j < scopeLogs.Len(); j++ {
+			scopeLog := scopeLogs.At(j)
+			sURLID, err := sURLBatch.GetID(scopeLog.SchemaUrl())
+			if err != nil {
+				return err
+			}
+			instrLibBatch.Queue(scopeLog.Scope().Name(), scopeLog.Scope().Version(), sURLID)
+			records := scopeLog.LogRecords()
+			for k := 0; k < records.Len(); k++ {
+				// The extension defines no tag type for logs; record
+				// attributes are stored as span tags.
+				if err := tagsBatch.Queue(records.At(k).Attributes().AsRaw(), SpanTagType); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err := instrLibBatch.SendBatch(ctx, t.conn); err != nil {
+		return err
+	}
+	if err := tagsBatch.SendBatch(ctx, t.conn); err != nil {
+		return err
+	}
+
+	var (
+		rows    [][]interface{}
+		maxTime time.Time
+		now     = time.Now()
+	)
+	for i := 0; i < rLogs.Len(); i++ {
+		rLog := rLogs.At(i)
+		rSchemaURLID, err := sURLBatch.GetID(rLog.SchemaUrl())
+		if err != nil {
+			return err
+		}
+		jsonResourceTags, err := tagsBatch.GetTagMapJSON(rLog.Resource().Attributes().AsRaw(), ResourceTagType)
+		if err != nil {
+			return err
+		}
+		scopeLogs := rLog.ScopeLogs()
+		for j := 0; j < scopeLogs.Len(); j++ {
+			scopeLog := scopeLogs.At(j)
+			sURLID, err := sURLBatch.GetID(scopeLog.SchemaUrl())
+			if err != nil {
+				return err
+			}
+			instLibID, err := instrLibBatch.GetID(scopeLog.Scope().Name(), scopeLog.Scope().Version(), sURLID)
+			if err != nil {
+				return err
+			}
+			records := scopeLog.LogRecords()
+			for k := 0; k < records.Len(); k++ {
+				record := records.At(k)
+				jsonTags, err := tagsBatch.GetTagMapJSON(record.Attributes().AsRaw(), SpanTagType)
+				if err != nil {
+					return err
+				}
+				logTime, observedTime := getLogTimes(record, now)
+				if maxTime.Before(logTime) {
+					maxTime = logTime
+				}
+				var traceID pgtype.UUID
+				if !record.TraceID().IsEmpty() {
+					traceID = TraceIDToUUID(record.TraceID())
+				}
+				rows = append(rows, []interface{}{logTime, observedTime, traceID, getSpanID(record.SpanID()), int32(record.Flags()),
+					int16(record.SeverityNumber()), getNullableText(record.SeverityText()), getLogBody(record.Body()),
+					jsonTags, record.DroppedAttributesCount(), jsonResourceTags, 0, rSchemaURLID, instLibID})
+			}
+		}
+	}
+	metrics.InsertBatchSize.With(logRecordLabel).Observe(float64(len(rows)))
+
+	start := time.Now()
+	if err := t.copyLogs(ctx, rows); err != nil {
+		return fmt.Errorf("error inserting logs: %w", err)
+	}
+	metrics.IngestorInsertDuration.With(prometheus.Labels{"type": "log", "subsystem": "", "kind": "record"}).Observe(time.Since(start).Seconds())
+	metrics.IngestorItems.With(prometheus.Labels{"type": "log", "kind": "record", "subsystem": ""}).Add(float64(len(rows)))
+	metrics.IngestorMaxSentTimestamp.With(logLabel).Set(float64(maxTime.UnixNano() / 1e6))
+	code = "2xx"
+	return nil
+}
+
+// copyLogs copies the rows straight into the log table, which has no unique
+// constraint to resolve conflicts on.
+func (t *traceWriterImpl) copyLogs(ctx context.Context, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	conn, err := t.conn.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	_, err = conn.CopyFrom(ctx, logTable, logTableColumns, pgx.CopyFromRows(rows))
+	return err
+}
+
+// getLogTimes returns the time of a log record and the time it was observed
+// at. Records without time are given their observed time, or else the time of
+// reception, as recommended by the OpenTelemetry log data model.
+func getLogTimes(record plog.LogRecord, now time.Time) (time.Time, time.Time) {
+	observed := now
+	if record.ObservedTimestamp() != 0 {
+		observed = record.ObservedTimestamp().AsTime()
+	}
+	logTime := observed
+	if record.Timestamp() != 0 {
+		logTime = record.Timestamp().AsTime()
+	}
+	// postgresql timestamptz only has microsecond precision
+	return logTime.Truncate(time.Microsecond), observed.Truncate(time.Microsecond)
+}
+
+// getLogBody returns the body as text, maps and slices being encoded in JSON.
+func getLogBody(body pcommon.Value) pgtype.Text {
+	if body.Type() == pcommon.ValueTypeEmpty {
+		return pgtype.Text{}
+	}
+	return pgtype.Text{String: body.AsString(), Valid: true}
+}
+
+func getNullableText(s string) pgtype.Text {
+	return pgtype.Text{String: s, Valid: s != ""}
+}
@@ -0,0 +1,242 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package sampling
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/grafana/regexp"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	defaultDecisionWait = 10 * time.Second
+	defaultMaxTraces    = 50000
+)
+
+// PolicyType is the kind of check a sampling policy performs.
+type PolicyType string
+
+const (
+	// StatusCodePolicy keeps traces containing at least one span with error status.
+	StatusCodePolicy PolicyType = "status_code"
+	// LatencyPolicy keeps traces whose duration is at least the threshold.
+	LatencyPolicy PolicyType = "latency"
+	// AttributePolicy keeps traces containing a span or resource attribute matching the policy.
+	AttributePolicy PolicyType = "attribute"
+	// ProbabilisticPolicy keeps a fraction of traces based on the trace ID, optionally per root service.
+	ProbabilisticPolicy PolicyType = "probabilistic"
+)
+
+// PolicyConfig is a single sampling policy. A trace is stored if any policy keeps it.
+type PolicyConfig struct {
+	Name string     `yaml:"name"`
+	Type PolicyType `yaml:"type"`
+
+	// Latency policy.
+	Threshold model.Duration `yaml:"threshold,omitempty"`
+
+	// Attribute policy. The attribute value must be one of Values or match Regex.
+	Key    string   `yaml:"key,omitempty"`
+	Values []string `yaml:"values,omitempty"`
+	Regex  string   `yaml:"regex,omitempty"`
+
+	// Probabilistic policy. Rate is the default fraction of traces to keep,
+	// ServiceRates overrides it for traces whose root span belongs to a service.
+	Rate         float64            `yaml:"rate,omitempty"`
+	ServiceRates map[string]float64 `yaml:"service_rates,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+// SpanFilterConfig drops spans of the kept traces. A span is dropped if it
+// matches all the conditions set in the filter.
+type SpanFilterConfig struct {
+	Name string `yaml:"name"`
+
+	// SpanName is a regex the span name must fully match.
+	SpanName string `yaml:"span_name,omitempty"`
+
+	// The value of the span or resource attribute Key must be one of Values
+	// or match Regex.
+	Key    string   `yaml:"key,omitempty"`
+	Values []string `yaml:"values,omitempty"`
+	Regex  string   `yaml:"regex,omitempty"`
+
+	// MaxDuration matches the spans shorter than it.
+	MaxDuration model.Duration `yaml:"max_duration,omitempty"`
+
+	spanName *regexp.Regexp
+	regex    *regexp.Regexp
+}
+
+// PoliciesConfig is the content of the sampling policy file.
+type PoliciesConfig struct {
+	Policies    []PolicyConfig     `yaml:"policies"`
+	SpanFilters []SpanFilterConfig `yaml:"span_filters,omitempty"`
+}
+
+type Config struct {
+	Enabled      bool
+	DecisionWait time.Duration
+	MaxTraces    int
+	PolicyFile   string
+	Policies     []PolicyConfig
+	SpanFilters  []SpanFilterConfig
+}
+
+var DefaultConfig = Config{
+	Enabled:      false,
+	DecisionWait: defaultDecisionWait,
+	MaxTraces:    defaultMaxTraces,
+}
+
+func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
+	fs.BoolVar(&cfg.Enabled, "tracing.sampling.enable", false, "Enable tail-based sampling of traces before they are written to the database. Spans are acknowledged before they are written, regardless of `tracing.async-acks`. Requires `tracing.sampling.policy-file`.")
+	fs.DurationVar(&cfg.DecisionWait, "tracing.sampling.decision-wait", defaultDecisionWait, "Time to buffer the spans of a trace, counting from its first span, before deciding whether to keep it.")
+	fs.IntVar(&cfg.MaxTraces, "tracing.sampling.max-traces", defaultMaxTraces, "Maximum number of traces buffered while waiting for a sampling decision. When exceeded, the oldest trace is decided early.")
+	fs.StringVar(&cfg.PolicyFile, "tracing.sampling.policy-file", "", "Path to the YAML file with the sampling policies. A trace is kept if any policy matches it, and its spans matching a span filter are dropped.")
+	return cfg
+}
+
+func Validate(cfg *Config) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.DecisionWait <= 0 {
+		return fmt.Errorf("tracing.sampling.decision-wait must be positive: %s", cfg.DecisionWait)
+	}
+	if cfg.MaxTraces < 1 {
+		return fmt.Errorf("tracing.sampling.max-traces must be at least 1: %d", cfg.MaxTraces)
+	}
+	if cfg.PolicyFile == "" {
+		return fmt.Errorf("tracing.sampling.policy-file is required when tracing.sampling.enable is set")
+	}
+	content, err := os.ReadFile(cfg.PolicyFile)
+	if err != nil {
+		return fmt.Errorf("error reading sampling policy file: %w", err)
+	}
+	pc, err := ParsePolicies(content)
+	if err != nil {
+		return fmt.Errorf("error parsing sampling policy file: %w", err)
+	}
+	cfg.Policies = pc.Policies
+	cfg.SpanFilters = pc.SpanFilters
+	return nil
+}
+
+// ParsePolicies parses and validates the YAML content of a policy file.
+func ParsePolicies(content []byte) (*PoliciesConfig, error) {
+	var pc PoliciesConfig
+	if err := yaml.UnmarshalStrict(content, &pc); err != nil {
+		return nil, err
+	}
+	if len(pc.Policies) == 0 {
+		return nil, fmt.Errorf("no sampling policies defined")
+	}
+	names := make(map[string]struct{}, len(pc.Policies))
+	for i := range pc.Policies {
+		p := &pc.Policies[i]
+		if p.Name == "" {
+			p.Name = fmt.Sprintf("%s-%d", p.Type, i)
+		}
+		if _, dup := names[p.Name]; dup {
+			return nil, fmt.Errorf("duplicate policy name %q", p.Name)
+		}
+		names[p.Name] = struct{}{}
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("policy %q: %w", p.Name, err)
+		}
+	}
+	filterNames := make(map[string]struct{}, len(pc.SpanFilters))
+	for i := range pc.SpanFilters {
+		f := &pc.SpanFilters[i]
+		if f.Name == "" {
+			f.Name = fmt.Sprintf("span-filter-%d", i)
+		}
+		if _, dup := filterNames[f.Name]; dup {
+			return nil, fmt.Errorf("duplicate span filter name %q", f.Name)
+		}
+		filterNames[f.Name] = struct{}{}
+		if err := f.validate(); err != nil {
+			return nil, fmt.Errorf("span filter %q: %w", f.Name, err)
+		}
+	}
+	return &pc, nil
+}
+
+func (f *SpanFilterConfig) validate() error {
+	if f.SpanName == "" && f.Key == "" && f.MaxDuration <= 0 {
+		return fmt.Errorf("at least one of span_name, key and max_duration is required")
+	}
+	if f.SpanName != "" {
+		r, err := regexp.Compile("^(?:" + f.SpanName + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid span_name: %w", err)
+		}
+		f.spanName = r
+	}
+	if f.Key == "" {
+		if len(f.Values) > 0 || f.Regex != "" {
+			return fmt.Errorf("key is required with values or regex")
+		}
+		return nil
+	}
+	if len(f.Values) == 0 && f.Regex == "" {
+		return fmt.Errorf("either values or regex is required with key")
+	}
+	if f.Regex != "" {
+		r, err := regexp.Compile("^(?:" + f.Regex + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+		f.regex = r
+	}
+	return nil
+}
+
+func (p *PolicyConfig) validate() error {
+	switch p.Type {
+	case StatusCodePolicy:
+	case LatencyPolicy:
+		if p.Threshold <= 0 {
+			return fmt.Errorf("threshold must be positive")
+		}
+	case AttributePolicy:
+		if p.Key == "" {
+			return fmt.Errorf("key is required")
+		}
+		if len(p.Values) == 0 && p.Regex == "" {
+			return fmt.Errorf("either values or regex is required")
+		}
+		if p.Regex != "" {
+			r, err := regexp.Compile("^(?:" + p.Regex + ")$")
+			if err != nil {
+				return fmt.Errorf("invalid regex: %w", err)
+			}
+			p.regex = r
+		}
+	case ProbabilisticPolicy:
+		if !validRate(p.Rate) {
+			return fmt.Errorf("rate must be between 0 and 1: %v", p.Rate)
+		}
+		for service, rate := range p.ServiceRates {
+			if !validRate(rate) {
+				return fmt.Errorf("rate of service %q must be between 0 and 1: %v", service, rate)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown policy type %q", p.Type)
+	}
+	return nil
+}
+
+func validRate(r float64) bool {
+	return r >= 0 && r <= 1
+}
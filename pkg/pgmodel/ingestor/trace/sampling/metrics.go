@@ -0,0 +1,72 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package sampling
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/timescale/promscale/pkg/util"
+)
+
+var (
+	bufferedTraces = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "trace",
+			Name:      "sampling_buffered_traces",
+			Help:      "Number of traces waiting for a sampling decision.",
+		},
+	)
+	sampledSpans = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "trace",
+			Name:      "sampling_spans_total",
+			Help:      "Total number of spans kept or dropped by tail-based sampling.",
+		}, []string{"decision"},
+	)
+	sampledTraces = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "trace",
+			Name:      "sampling_traces_total",
+			Help:      "Total number of traces kept or dropped by tail-based sampling, by the first policy that kept them.",
+		}, []string{"decision", "policy"},
+	)
+	filteredSpans = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "trace",
+			Name:      "sampling_filtered_spans_total",
+			Help:      "Total number of spans of kept traces dropped by span filters.",
+		}, []string{"filter"},
+	)
+	writeFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "trace",
+			Name:      "sampling_write_failures_total",
+			Help:      "Total number of failed writes of kept traces after their sampling decision. The spans of a failed write are lost.",
+		},
+	)
+	earlyDecisions = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "trace",
+			Name:      "sampling_early_decisions_total",
+			Help:      "Total number of traces decided before the end of the decision wait because the buffer was full.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		bufferedTraces,
+		sampledSpans,
+		sampledTraces,
+		filteredSpans,
+		writeFailures,
+		earlyDecisions,
+	)
+}
@@ -0,0 +1,186 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package sampling
+
+import (
+	"math"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/grafana/regexp"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const serviceNameTagKey = "service.name"
+
+// traceData holds the spans of a trace buffered until the sampling decision.
+type traceData struct {
+	id        pcommon.TraceID
+	spans     ptrace.Traces
+	spanCount int
+	arrival   time.Time
+}
+
+func newTraceData(id pcommon.TraceID, arrival time.Time) *traceData {
+	return &traceData{id: id, spans: ptrace.NewTraces(), arrival: arrival}
+}
+
+// forEachSpan calls fn for every buffered span of the trace together with the
+// attributes of the resource it belongs to. Iteration stops when fn returns true.
+func (t *traceData) forEachSpan(fn func(resource pcommon.Map, span ptrace.Span) bool) {
+	rSpans := t.spans.ResourceSpans()
+	for i := 0; i < rSpans.Len(); i++ {
+		rSpan := rSpans.At(i)
+		scopeSpans := rSpan.ScopeSpans()
+		for j := 0; j < scopeSpans.Len(); j++ {
+			spans := scopeSpans.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				if fn(rSpan.Resource().Attributes(), spans.At(k)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// rootService returns the service of the root span, or of the first span if
+// the root span hasn't been received.
+func (t *traceData) rootService() string {
+	var service, first string
+	t.forEachSpan(func(resource pcommon.Map, span ptrace.Span) bool {
+		name := ""
+		if av, found := resource.Get(serviceNameTagKey); found {
+			name = av.AsString()
+		}
+		if first == "" {
+			first = name
+		}
+		if span.ParentSpanID().IsEmpty() {
+			service = name
+			return true
+		}
+		return false
+	})
+	if service == "" {
+		return first
+	}
+	return service
+}
+
+func (t *traceData) duration() time.Duration {
+	var start, end time.Time
+	t.forEachSpan(func(_ pcommon.Map, span ptrace.Span) bool {
+		s, e := span.StartTimestamp().AsTime(), span.EndTimestamp().AsTime()
+		if start.IsZero() || s.Before(start) {
+			start = s
+		}
+		if end.IsZero() || e.After(end) {
+			end = e
+		}
+		return false
+	})
+	return end.Sub(start)
+}
+
+// keep returns whether the policy keeps the trace.
+func (p *PolicyConfig) keep(t *traceData) bool {
+	switch p.Type {
+	case StatusCodePolicy:
+		found := false
+		t.forEachSpan(func(_ pcommon.Map, span ptrace.Span) bool {
+			found = span.Status().Code() == ptrace.StatusCodeError
+			return found
+		})
+		return found
+	case LatencyPolicy:
+		return t.duration() >= time.Duration(p.Threshold)
+	case AttributePolicy:
+		found := false
+		t.forEachSpan(func(resource pcommon.Map, span ptrace.Span) bool {
+			found = p.matchAttribute(span.Attributes()) || p.matchAttribute(resource)
+			return found
+		})
+		return found
+	case ProbabilisticPolicy:
+		rate := p.Rate
+		if serviceRate, ok := p.ServiceRates[t.rootService()]; ok {
+			rate = serviceRate
+		}
+		return sampledByTraceID(t.id, rate)
+	}
+	return false
+}
+
+func (p *PolicyConfig) matchAttribute(attrs pcommon.Map) bool {
+	return matchAttribute(attrs, p.Key, p.Values, p.regex)
+}
+
+func matchAttribute(attrs pcommon.Map, key string, values []string, regex *regexp.Regexp) bool {
+	av, found := attrs.Get(key)
+	if !found {
+		return false
+	}
+	value := av.AsString()
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return regex != nil && regex.MatchString(value)
+}
+
+// drop returns whether the filter drops the span.
+func (f *SpanFilterConfig) drop(resource pcommon.Map, span ptrace.Span) bool {
+	if f.spanName != nil && !f.spanName.MatchString(span.Name()) {
+		return false
+	}
+	if f.Key != "" && !matchAttribute(span.Attributes(), f.Key, f.Values, f.regex) && !matchAttribute(resource, f.Key, f.Values, f.regex) {
+		return false
+	}
+	if f.MaxDuration > 0 {
+		d := span.EndTimestamp().AsTime().Sub(span.StartTimestamp().AsTime())
+		if d >= time.Duration(f.MaxDuration) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterSpans removes the spans dropped by the filters from the traces, and
+// the resource and scope spans left empty.
+func filterSpans(filters []SpanFilterConfig, traces ptrace.Traces) {
+	if len(filters) == 0 {
+		return
+	}
+	traces.ResourceSpans().RemoveIf(func(rSpan ptrace.ResourceSpans) bool {
+		resource := rSpan.Resource().Attributes()
+		rSpan.ScopeSpans().RemoveIf(func(scopeSpans ptrace.ScopeSpans) bool {
+			scopeSpans.Spans().RemoveIf(func(span ptrace.Span) bool {
+				for i := range filters {
+					if filters[i].drop(resource, span) {
+						filteredSpans.WithLabelValues(filters[i].Name).Inc()
+						return true
+					}
+				}
+				return false
+			})
+			return scopeSpans.Spans().Len() == 0
+		})
+		return rSpan.ScopeSpans().Len() == 0
+	})
+}
+
+// sampledByTraceID decides based on a hash of the trace ID, so that every
+// connector reaches the same decision for the same trace.
+func sampledByTraceID(id pcommon.TraceID, rate float64) bool {
+	switch {
+	case rate <= 0:
+		return false
+	case rate >= 1:
+		return true
+	}
+	return xxhash.Sum64(id[:]) < uint64(rate*math.MaxUint64)
+}
@@ -0,0 +1,282 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+// Package sampling implements tail-based sampling of traces before they are
+// written to the database.
+//
+// Spans are buffered per trace ID for the decision wait, counting from the
+// first span of the trace. The trace is then evaluated against the configured
+// policies and is either forwarded to the trace writer or dropped. Spans of
+// a trace arriving after its decision follow the same decision for as long
+// as the decision is remembered (another decision wait). The spans of the kept
+// traces are finally filtered by the span filters.
+package sampling
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/trace"
+)
+
+const maxTickInterval = time.Second
+
+var (
+	keptLabel    = prometheus.Labels{"decision": "kept"}
+	droppedLabel = prometheus.Labels{"decision": "dropped"}
+)
+
+type decision struct {
+	keep       bool
+	expiration time.Time
+}
+
+// Sampler is a trace.Writer that forwards only the sampled traces to the next writer.
+type Sampler struct {
+	cfg  Config
+	next trace.Writer
+
+	mu        sync.Mutex
+	traces    map[pcommon.TraceID]*list.Element
+	order     *list.List // *traceData in arrival order
+	decisions map[pcommon.TraceID]decision
+
+	now  func() time.Time
+	stop chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+func NewSampler(cfg Config, next trace.Writer) *Sampler {
+	return &Sampler{
+		cfg:       cfg,
+		next:      next,
+		traces:    make(map[pcommon.TraceID]*list.Element),
+		order:     list.New(),
+		decisions: make(map[pcommon.TraceID]decision),
+		now:       time.Now,
+		stop:      make(chan struct{}),
+	}
+}
+
+// spanSlices caches the destination span slice per trace, resource and scope
+// so that spans sharing them aren't split into separate resource spans.
+type spanSlices map[spanSliceKey]ptrace.SpanSlice
+
+type spanSliceKey struct {
+	traceID  pcommon.TraceID
+	resource int
+	scope    int
+}
+
+func (s spanSlices) appendSpan(dst ptrace.Traces, key spanSliceKey, rSpan ptrace.ResourceSpans, scopeSpans ptrace.ScopeSpans, span ptrace.Span) {
+	slice, ok := s[key]
+	if !ok {
+		rs := dst.ResourceSpans().AppendEmpty()
+		rs.SetSchemaUrl(rSpan.SchemaUrl())
+		rSpan.Resource().CopyTo(rs.Resource())
+		ss := rs.ScopeSpans().AppendEmpty()
+		ss.SetSchemaUrl(scopeSpans.SchemaUrl())
+		scopeSpans.Scope().CopyTo(ss.Scope())
+		slice = ss.Spans()
+		s[key] = slice
+	}
+	span.CopyTo(slice.AppendEmpty())
+}
+
+// InsertTraces buffers the spans of traces awaiting a decision. Spans of
+// already decided traces are forwarded or dropped right away.
+//
+// The buffered spans are written once their trace is decided, after
+// InsertTraces returned, so a nil error doesn't mean they are stored. Only
+// the write of the late spans is reported to the caller, the failed writes of
+// decided traces are logged and counted by writeFailures.
+func (s *Sampler) InsertTraces(ctx context.Context, traces ptrace.Traces) error {
+	var (
+		now      = s.now()
+		late     = ptrace.NewTraces()
+		lateDst  = make(spanSlices)
+		bufDst   = make(spanSlices)
+		decided  []*traceData
+		dropped  int
+		buffered int
+	)
+
+	s.mu.Lock()
+	rSpans := traces.ResourceSpans()
+	for i := 0; i < rSpans.Len(); i++ {
+		rSpan := rSpans.At(i)
+		scopeSpans := rSpan.ScopeSpans()
+		for j := 0; j < scopeSpans.Len(); j++ {
+			ss := scopeSpans.At(j)
+			spans := ss.Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				id := span.TraceID()
+				key := spanSliceKey{traceID: id, resource: i, scope: j}
+				if d, ok := s.decisions[id]; ok {
+					if d.keep {
+						lateDst.appendSpan(late, key, rSpan, ss, span)
+					} else {
+						dropped++
+					}
+					continue
+				}
+				elem, ok := s.traces[id]
+				if !ok {
+					if s.order.Len() >= s.cfg.MaxTraces {
+						earlyDecisions.Inc()
+						if t := s.decideOldest(now); t != nil {
+							decided = append(decided, t)
+						}
+					}
+					elem = s.order.PushBack(newTraceData(id, now))
+					s.traces[id] = elem
+				}
+				t := elem.Value.(*traceData)
+				bufDst.appendSpan(t.spans, key, rSpan, ss, span)
+				t.spanCount++
+				buffered++
+			}
+		}
+	}
+	bufferedTraces.Set(float64(s.order.Len()))
+	s.mu.Unlock()
+
+	sampledSpans.With(droppedLabel).Add(float64(dropped))
+	if err := s.forward(context.Background(), decided); err != nil {
+		log.Error("msg", "error writing sampled traces", "err", err)
+	}
+	filterSpans(s.cfg.SpanFilters, late)
+	if late.SpanCount() == 0 {
+		return nil
+	}
+	sampledSpans.With(keptLabel).Add(float64(late.SpanCount()))
+	return s.next.InsertTraces(ctx, late)
+}
+
+// decideOldest removes the oldest buffered trace and records the decision
+// for it. It returns the trace if it must be kept. s.mu must be held.
+func (s *Sampler) decideOldest(now time.Time) *traceData {
+	elem := s.order.Front()
+	if elem == nil {
+		return nil
+	}
+	s.order.Remove(elem)
+	t := elem.Value.(*traceData)
+	delete(s.traces, t.id)
+
+	keep, policy := s.evaluate(t)
+	s.decisions[t.id] = decision{keep: keep, expiration: now.Add(s.cfg.DecisionWait)}
+	if !keep {
+		sampledTraces.With(prometheus.Labels{"decision": "dropped", "policy": ""}).Inc()
+		sampledSpans.With(droppedLabel).Add(float64(t.spanCount))
+		return nil
+	}
+	sampledTraces.With(prometheus.Labels{"decision": "kept", "policy": policy}).Inc()
+	return t
+}
+
+// evaluate returns whether the trace is kept and by which policy.
+func (s *Sampler) evaluate(t *traceData) (bool, string) {
+	for i := range s.cfg.Policies {
+		p := &s.cfg.Policies[i]
+		if p.keep(t) {
+			return true, p.Name
+		}
+	}
+	return false, ""
+}
+
+// decide makes a decision for every trace whose decision wait has passed,
+// or for all buffered traces if flushAll is set.
+func (s *Sampler) decide(now time.Time, flushAll bool) []*traceData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var kept []*traceData
+	for elem := s.order.Front(); elem != nil; elem = s.order.Front() {
+		t := elem.Value.(*traceData)
+		if !flushAll && now.Sub(t.arrival) < s.cfg.DecisionWait {
+			// The list is in arrival order so the rest isn't due either.
+			break
+		}
+		if t := s.decideOldest(now); t != nil {
+			kept = append(kept, t)
+		}
+	}
+	for id, d := range s.decisions {
+		if now.After(d.expiration) {
+			delete(s.decisions, id)
+		}
+	}
+	bufferedTraces.Set(float64(s.order.Len()))
+	return kept
+}
+
+// forward writes the kept traces, without the filtered spans, to the next
+// writer as a single request. Failed writes are counted, since no ingest
+// request waits for them.
+func (s *Sampler) forward(ctx context.Context, kept []*traceData) error {
+	if len(kept) == 0 {
+		return nil
+	}
+	traces := ptrace.NewTraces()
+	for _, t := range kept {
+		t.spans.ResourceSpans().MoveAndAppendTo(traces.ResourceSpans())
+	}
+	filterSpans(s.cfg.SpanFilters, traces)
+	if traces.SpanCount() == 0 {
+		return nil
+	}
+	sampledSpans.With(keptLabel).Add(float64(traces.SpanCount()))
+	if err := s.next.InsertTraces(ctx, traces); err != nil {
+		writeFailures.Inc()
+		return err
+	}
+	return nil
+}
+
+// Run starts the routine making the sampling decisions.
+func (s *Sampler) Run() {
+	interval := s.cfg.DecisionWait
+	if interval > maxTickInterval {
+		interval = maxTickInterval
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.forward(context.Background(), s.decide(s.now(), false)); err != nil {
+					log.Error("msg", "error writing sampled traces", "err", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close decides all buffered traces, forwards the kept ones and closes the next writer.
+func (s *Sampler) Close() {
+	s.once.Do(func() {
+		close(s.stop)
+		s.wg.Wait()
+		if err := s.forward(context.Background(), s.decide(s.now(), true)); err != nil {
+			log.Error("msg", "error writing sampled traces on shutdown", "err", err)
+		}
+		s.next.Close()
+	})
+}
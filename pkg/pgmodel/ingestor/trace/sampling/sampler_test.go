@@ -0,0 +1,265 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package sampling
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+type recordingWriter struct {
+	mu     sync.Mutex
+	traces map[pcommon.TraceID]int
+	closed bool
+	err    error
+}
+
+func newRecordingWriter() *recordingWriter {
+	return &recordingWriter{traces: make(map[pcommon.TraceID]int)}
+}
+
+func (w *recordingWriter) InsertTraces(_ context.Context, traces ptrace.Traces) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	rSpans := traces.ResourceSpans()
+	for i := 0; i < rSpans.Len(); i++ {
+		scopeSpans := rSpans.At(i).ScopeSpans()
+		for j := 0; j < scopeSpans.Len(); j++ {
+			spans := scopeSpans.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				w.traces[spans.At(k).TraceID()]++
+			}
+		}
+	}
+	return nil
+}
+
+func (w *recordingWriter) Close() {
+	w.closed = true
+}
+
+type testSpan struct {
+	traceID byte
+	spanID  byte
+	parent  byte
+	name    string
+	service string
+	latency time.Duration
+	status  ptrace.StatusCode
+	attrs   map[string]string
+}
+
+func newTestTraces(spans ...testSpan) ptrace.Traces {
+	traces := ptrace.NewTraces()
+	for _, s := range spans {
+		rSpan := traces.ResourceSpans().AppendEmpty()
+		rSpan.Resource().Attributes().PutString(serviceNameTagKey, s.service)
+		span := rSpan.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		span.SetTraceID(pcommon.TraceID([16]byte{s.traceID}))
+		span.SetSpanID(pcommon.SpanID([8]byte{s.spanID}))
+		span.SetName(s.name)
+		if s.parent != 0 {
+			span.SetParentSpanID(pcommon.SpanID([8]byte{s.parent}))
+		}
+		start := time.Unix(100, 0)
+		span.SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+		span.SetEndTimestamp(pcommon.NewTimestampFromTime(start.Add(s.latency)))
+		span.Status().SetCode(s.status)
+		for k, v := range s.attrs {
+			span.Attributes().PutString(k, v)
+		}
+	}
+	return traces
+}
+
+func newTestSampler(t *testing.T, policies string) (*Sampler, *recordingWriter) {
+	p, err := ParsePolicies([]byte(policies))
+	require.NoError(t, err)
+	cfg := DefaultConfig
+	cfg.Enabled = true
+	cfg.DecisionWait = time.Second
+	cfg.Policies = p.Policies
+	cfg.SpanFilters = p.SpanFilters
+	w := newRecordingWriter()
+	return NewSampler(cfg, w), w
+}
+
+func traceID(b byte) pcommon.TraceID {
+	return pcommon.TraceID([16]byte{b})
+}
+
+func TestSamplerPolicies(t *testing.T) {
+	s, w := newTestSampler(t, `
+policies:
+  - name: errors
+    type: status_code
+  - name: slow
+    type: latency
+    threshold: 500ms
+  - name: gold
+    type: attribute
+    key: customer.tier
+    values: [gold]
+`)
+	now := time.Unix(1000, 0)
+	s.now = func() time.Time { return now }
+
+	require.NoError(t, s.InsertTraces(context.Background(), newTestTraces(
+		testSpan{traceID: 1, spanID: 1, service: "a", latency: time.Millisecond, status: ptrace.StatusCodeError},
+		testSpan{traceID: 2, spanID: 1, service: "a", latency: time.Second},
+		testSpan{traceID: 3, spanID: 1, service: "a", latency: time.Millisecond, attrs: map[string]string{"customer.tier": "gold"}},
+		testSpan{traceID: 4, spanID: 1, service: "a", latency: time.Millisecond, attrs: map[string]string{"customer.tier": "silver"}},
+	)))
+	// Only the child span of trace 5 has an error.
+	require.NoError(t, s.InsertTraces(context.Background(), newTestTraces(
+		testSpan{traceID: 5, spanID: 1, service: "a", latency: time.Millisecond},
+		testSpan{traceID: 5, spanID: 2, parent: 1, service: "b", latency: time.Millisecond, status: ptrace.StatusCodeError},
+	)))
+
+	// Nothing is decided before the decision wait.
+	require.NoError(t, s.forward(context.Background(), s.decide(now.Add(time.Millisecond), false)))
+	require.Empty(t, w.traces)
+
+	require.NoError(t, s.forward(context.Background(), s.decide(now.Add(2*time.Second), false)))
+	require.Equal(t, map[pcommon.TraceID]int{traceID(1): 1, traceID(2): 1, traceID(3): 1, traceID(5): 2}, w.traces)
+
+	// Late spans follow the decision of their trace.
+	require.NoError(t, s.InsertTraces(context.Background(), newTestTraces(
+		testSpan{traceID: 1, spanID: 2, parent: 1, service: "a"},
+		testSpan{traceID: 4, spanID: 2, parent: 1, service: "a", status: ptrace.StatusCodeError},
+	)))
+	require.Equal(t, 2, w.traces[traceID(1)])
+	require.Equal(t, 0, w.traces[traceID(4)])
+}
+
+func TestSamplerProbabilisticPerService(t *testing.T) {
+	s, w := newTestSampler(t, `
+policies:
+  - type: probabilistic
+    rate: 0
+    service_rates:
+      checkout: 1
+`)
+	require.NoError(t, s.InsertTraces(context.Background(), newTestTraces(
+		testSpan{traceID: 1, spanID: 1, service: "checkout"},
+		testSpan{traceID: 1, spanID: 2, parent: 1, service: "db"},
+		testSpan{traceID: 2, spanID: 1, service: "search"},
+	)))
+	s.Close()
+	require.True(t, w.closed)
+	require.Equal(t, map[pcommon.TraceID]int{traceID(1): 2}, w.traces)
+}
+
+func TestSamplerMaxTraces(t *testing.T) {
+	s, w := newTestSampler(t, `
+policies:
+  - type: status_code
+`)
+	s.cfg.MaxTraces = 1
+	require.NoError(t, s.InsertTraces(context.Background(), newTestTraces(
+		testSpan{traceID: 1, spanID: 1, service: "a", status: ptrace.StatusCodeError},
+		testSpan{traceID: 2, spanID: 1, service: "a"},
+	)))
+	// The first trace was decided early to make room for the second.
+	require.Equal(t, map[pcommon.TraceID]int{traceID(1): 1}, w.traces)
+	require.Equal(t, 1, s.order.Len())
+}
+
+func TestSamplerWriteFailures(t *testing.T) {
+	s, w := newTestSampler(t, `
+policies:
+  - type: status_code
+`)
+	w.err = fmt.Errorf("database unavailable")
+	// The spans are buffered, the request doesn't see the failure.
+	require.NoError(t, s.InsertTraces(context.Background(), newTestTraces(
+		testSpan{traceID: 1, spanID: 1, service: "a", status: ptrace.StatusCodeError},
+	)))
+	before := testutil.ToFloat64(writeFailures)
+	s.Close()
+	require.Equal(t, before+1, testutil.ToFloat64(writeFailures))
+	require.Empty(t, w.traces)
+}
+
+func TestSamplerSpanFilters(t *testing.T) {
+	s, w := newTestSampler(t, `
+policies:
+  - type: probabilistic
+    rate: 1
+span_filters:
+  - name: health-checks
+    span_name: GET /health.*
+  - name: fast-cache
+    key: db.system
+    values: [redis]
+    max_duration: 1ms
+`)
+	require.NoError(t, s.InsertTraces(context.Background(), newTestTraces(
+		testSpan{traceID: 1, spanID: 1, name: "GET /", service: "a", latency: time.Second},
+		testSpan{traceID: 1, spanID: 2, parent: 1, name: "GET /healthz", service: "a"},
+		testSpan{traceID: 1, spanID: 3, parent: 1, name: "get", service: "a", latency: time.Microsecond, attrs: map[string]string{"db.system": "redis"}},
+		testSpan{traceID: 1, spanID: 4, parent: 1, name: "get", service: "a", latency: time.Second, attrs: map[string]string{"db.system": "redis"}},
+		testSpan{traceID: 2, spanID: 1, name: "GET /health", service: "a"},
+	)))
+	s.Close()
+	// Trace 2 has no span left after filtering.
+	require.Equal(t, map[pcommon.TraceID]int{traceID(1): 2}, w.traces)
+}
+
+func TestSampledByTraceID(t *testing.T) {
+	kept := 0
+	for i := 0; i < 1000; i++ {
+		var id pcommon.TraceID
+		id[0], id[1] = byte(i), byte(i>>8)
+		if sampledByTraceID(id, 0.25) {
+			kept++
+		}
+	}
+	require.InDelta(t, 250, kept, 50)
+}
+
+func TestParsePolicies(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "empty", content: "policies: []", wantErr: true},
+		{name: "unknown type", content: "policies: [{type: foo}]", wantErr: true},
+		{name: "latency without threshold", content: "policies: [{type: latency}]", wantErr: true},
+		{name: "attribute without values", content: "policies: [{type: attribute, key: a}]", wantErr: true},
+		{name: "invalid regex", content: "policies: [{type: attribute, key: a, regex: '('}]", wantErr: true},
+		{name: "invalid rate", content: "policies: [{type: probabilistic, rate: 2}]", wantErr: true},
+		{name: "duplicate names", content: "policies: [{name: a, type: status_code}, {name: a, type: status_code}]", wantErr: true},
+		{name: "unknown field", content: "policies: [{type: status_code, foo: bar}]", wantErr: true},
+		{name: "empty span filter", content: "policies: [{type: status_code}]\nspan_filters: [{name: a}]", wantErr: true},
+		{name: "span filter values without key", content: "policies: [{type: status_code}]\nspan_filters: [{values: [a]}]", wantErr: true},
+		{name: "span filter key without values", content: "policies: [{type: status_code}]\nspan_filters: [{key: a}]", wantErr: true},
+		{name: "span filter invalid span name", content: "policies: [{type: status_code}]\nspan_filters: [{span_name: '('}]", wantErr: true},
+		{name: "valid", content: "policies: [{type: latency, threshold: 1s}, {type: attribute, key: a, regex: 'b.*'}]"},
+		{name: "valid span filters", content: "policies: [{type: status_code}]\nspan_filters: [{span_name: 'GET /health'}, {key: a, values: [b], max_duration: 1ms}]"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParsePolicies([]byte(tc.content))
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package servicegraph
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	defaultWaitTime      = 10 * time.Second
+	defaultMaxItems      = 10000
+	defaultFlushInterval = 15 * time.Second
+)
+
+// DefaultLatencyBuckets are the histogram buckets (in seconds) used for
+// client and server request latencies.
+var DefaultLatencyBuckets = []float64{0.1, 0.2, 0.4, 0.8, 1.6, 3.2, 6.4, 12.8}
+
+type Config struct {
+	Enabled        bool
+	WaitTime       time.Duration
+	MaxItems       int
+	FlushInterval  time.Duration
+	LatencyBuckets []float64
+	// Instance is the value of the instance label of the series, telling
+	// apart the series of the connectors generating them.
+	Instance string
+}
+
+var DefaultConfig = Config{
+	Enabled:        false,
+	WaitTime:       defaultWaitTime,
+	MaxItems:       defaultMaxItems,
+	FlushInterval:  defaultFlushInterval,
+	LatencyBuckets: DefaultLatencyBuckets,
+}
+
+func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
+	cfg.LatencyBuckets = DefaultLatencyBuckets
+	hostname, _ := os.Hostname()
+	fs.BoolVar(&cfg.Enabled, "tracing.service-graph.enable", false, "Generate service graph metrics (traces_service_graph_*) from client/server span pairs during trace ingestion.")
+	fs.DurationVar(&cfg.WaitTime, "tracing.service-graph.wait", defaultWaitTime, "Maximum time to wait for the partner span of a client/server pair before the edge is discarded as unpaired.")
+	fs.IntVar(&cfg.MaxItems, "tracing.service-graph.max-items", defaultMaxItems, "Maximum number of unpaired edges kept in memory. Spans that would start a new edge beyond this limit are dropped.")
+	fs.DurationVar(&cfg.FlushInterval, "tracing.service-graph.flush-interval", defaultFlushInterval, "Interval at which service graph metrics are written to the database.")
+	fs.StringVar(&cfg.Instance, "tracing.service-graph.instance", hostname, "Value of the instance label of the service graph metrics. "+
+		"Every connector generating them must have a distinct one, since each writes the counts of the spans it ingested. Defaults to the hostname.")
+	return cfg
+}
+
+func Validate(cfg *Config) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.WaitTime <= 0 {
+		return fmt.Errorf("tracing.service-graph.wait must be positive: %s", cfg.WaitTime)
+	}
+	if cfg.MaxItems < 1 {
+		return fmt.Errorf("tracing.service-graph.max-items must be at least 1: %d", cfg.MaxItems)
+	}
+	if cfg.FlushInterval <= 0 {
+		return fmt.Errorf("tracing.service-graph.flush-interval must be positive: %s", cfg.FlushInterval)
+	}
+	if cfg.Instance == "" {
+		return fmt.Errorf("tracing.service-graph.instance must not be empty")
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package servicegraph
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/timescale/promscale/pkg/util"
+)
+
+var (
+	pendingEdges = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "trace",
+			Name:      "service_graph_pending_edges",
+			Help:      "Number of service graph edges waiting for their partner span.",
+		},
+	)
+	completedEdges = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "trace",
+			Name:      "service_graph_completed_edges_total",
+			Help:      "Total number of service graph edges paired from a client and a server span.",
+		},
+	)
+	expiredEdges = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "trace",
+			Name:      "service_graph_expired_edges_total",
+			Help:      "Total number of service graph edges discarded because the partner span did not arrive in time.",
+		},
+	)
+	droppedSpans = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "trace",
+			Name:      "service_graph_dropped_spans_total",
+			Help:      "Total number of spans not considered for the service graph because the pending edges limit was reached.",
+		},
+	)
+	flushErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "trace",
+			Name:      "service_graph_flush_errors_total",
+			Help:      "Total number of failed attempts to write service graph metrics to the database.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		pendingEdges,
+		completedEdges,
+		expiredEdges,
+		droppedSpans,
+		flushErrors,
+	)
+}
@@ -0,0 +1,352 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+// Package servicegraph derives service graph metrics from ingested spans.
+//
+// A request between two services is recorded by a pair of spans: a client
+// (or producer) span emitted by the caller and a server (or consumer) span,
+// whose parent is the client span, emitted by the callee. The spans of a pair
+// usually arrive in different requests, so the processor keeps the first half
+// of every pair in memory for a bounded amount of time waiting for its
+// partner. Once an edge is complete it is accounted for in per client/server
+// counters and latency histograms, which are periodically written as regular
+// Prometheus series through the metric ingest path:
+//
+//	traces_service_graph_request_total{client, server, instance}
+//	traces_service_graph_request_failed_total{client, server, instance}
+//	traces_service_graph_request_server_seconds_{bucket,sum,count}{client, server, instance}
+//	traces_service_graph_request_client_seconds_{bucket,sum,count}{client, server, instance}
+//	traces_service_graph_unpaired_spans_total{client, instance} or {server, instance}
+//
+// The series are cumulative for the lifetime of the connector and are meant to
+// be queried with rate/increase, just like counters scraped from a service.
+// Every connector only counts the spans it ingested, so the instance label
+// tells its series apart from the ones of the other connectors, and the rates
+// are summed over it.
+package servicegraph
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+const (
+	requestTotalMetric  = "traces_service_graph_request_total"
+	requestFailedMetric = "traces_service_graph_request_failed_total"
+	serverLatencyMetric = "traces_service_graph_request_server_seconds"
+	clientLatencyMetric = "traces_service_graph_request_client_seconds"
+	unpairedMetric      = "traces_service_graph_unpaired_spans_total"
+
+	clientLabel   = "client"
+	serverLabel   = "server"
+	instanceLabel = "instance"
+
+	serviceNameTagKey  = "service.name"
+	missingServiceName = "OTLPResourceNoServiceName"
+)
+
+// Sink persists the service graph series.
+type Sink func(ctx context.Context, ts []prompb.TimeSeries) error
+
+// edgeKey identifies an edge by the trace ID and the span ID of the client
+// span, which is the parent span ID of the server span.
+type edgeKey struct {
+	traceID pcommon.TraceID
+	spanID  pcommon.SpanID
+}
+
+// edge is one half or both halves of a client/server span pair.
+type edge struct {
+	clientService string
+	serverService string
+	clientLatency float64
+	serverLatency float64
+	failed        bool
+	hasClient     bool
+	hasServer     bool
+	expiration    time.Time
+}
+
+func (e *edge) isComplete() bool {
+	return e.hasClient && e.hasServer
+}
+
+// serviceKey identifies the client/server pair of services an edge connects.
+type serviceKey struct {
+	client string
+	server string
+}
+
+type histogram struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+func (h *histogram) observe(bounds []float64, v float64) {
+	for i, b := range bounds {
+		if v <= b {
+			h.buckets[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+type edgeStats struct {
+	total         uint64
+	failed        uint64
+	serverLatency histogram
+	clientLatency histogram
+}
+
+// Processor pairs client and server spans and accumulates service graph metrics.
+type Processor struct {
+	cfg  Config
+	sink Sink
+
+	mu       sync.Mutex
+	pending  map[edgeKey]*edge
+	stats    map[serviceKey]*edgeStats
+	unpaired map[serviceKey]uint64
+
+	now  func() time.Time
+	stop chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+func NewProcessor(cfg Config, sink Sink) *Processor {
+	if len(cfg.LatencyBuckets) == 0 {
+		cfg.LatencyBuckets = DefaultLatencyBuckets
+	}
+	return &Processor{
+		cfg:      cfg,
+		sink:     sink,
+		pending:  make(map[edgeKey]*edge),
+		stats:    make(map[serviceKey]*edgeStats),
+		unpaired: make(map[serviceKey]uint64),
+		now:      time.Now,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Consume inspects the client and server spans of traces. It must be called
+// before traces are handed over to the trace writer, as the writer takes
+// ownership of the spans.
+func (p *Processor) Consume(traces ptrace.Traces) {
+	now := p.now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rSpans := traces.ResourceSpans()
+	for i := 0; i < rSpans.Len(); i++ {
+		rSpan := rSpans.At(i)
+		serviceName := missingServiceName
+		if av, found := rSpan.Resource().Attributes().Get(serviceNameTagKey); found {
+			serviceName = av.AsString()
+		}
+		scopeSpans := rSpan.ScopeSpans()
+		for j := 0; j < scopeSpans.Len(); j++ {
+			spans := scopeSpans.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				p.consumeSpan(now, serviceName, spans.At(k))
+			}
+		}
+	}
+	pendingEdges.Set(float64(len(p.pending)))
+}
+
+func (p *Processor) consumeSpan(now time.Time, serviceName string, span ptrace.Span) {
+	var (
+		key      edgeKey
+		isClient bool
+	)
+	switch span.Kind() {
+	case ptrace.SpanKindClient, ptrace.SpanKindProducer:
+		key = edgeKey{traceID: span.TraceID(), spanID: span.SpanID()}
+		isClient = true
+	case ptrace.SpanKindServer, ptrace.SpanKindConsumer:
+		if span.ParentSpanID().IsEmpty() {
+			return
+		}
+		key = edgeKey{traceID: span.TraceID(), spanID: span.ParentSpanID()}
+	default:
+		return
+	}
+
+	e, ok := p.pending[key]
+	if !ok {
+		if len(p.pending) >= p.cfg.MaxItems {
+			droppedSpans.Inc()
+			return
+		}
+		e = &edge{expiration: now.Add(p.cfg.WaitTime)}
+		p.pending[key] = e
+	}
+
+	latency := span.EndTimestamp().AsTime().Sub(span.StartTimestamp().AsTime()).Seconds()
+	if latency < 0 {
+		latency = -latency
+	}
+	if span.Status().Code() == ptrace.StatusCodeError {
+		e.failed = true
+	}
+	if isClient {
+		e.clientService = serviceName
+		e.clientLatency = latency
+		e.hasClient = true
+	} else {
+		e.serverService = serviceName
+		e.serverLatency = latency
+		e.hasServer = true
+	}
+
+	if e.isComplete() {
+		p.record(e)
+		delete(p.pending, key)
+	}
+}
+
+func (p *Processor) record(e *edge) {
+	completedEdges.Inc()
+	key := serviceKey{client: e.clientService, server: e.serverService}
+	s, ok := p.stats[key]
+	if !ok {
+		s = &edgeStats{
+			serverLatency: histogram{buckets: make([]uint64, len(p.cfg.LatencyBuckets))},
+			clientLatency: histogram{buckets: make([]uint64, len(p.cfg.LatencyBuckets))},
+		}
+		p.stats[key] = s
+	}
+	s.total++
+	if e.failed {
+		s.failed++
+	}
+	s.serverLatency.observe(p.cfg.LatencyBuckets, e.serverLatency)
+	s.clientLatency.observe(p.cfg.LatencyBuckets, e.clientLatency)
+}
+
+// expire discards the edges whose partner span did not arrive within the wait time.
+func (p *Processor) expire(now time.Time) {
+	for key, e := range p.pending {
+		if now.Before(e.expiration) {
+			continue
+		}
+		expiredEdges.Inc()
+		if e.hasClient {
+			p.unpaired[serviceKey{client: e.clientService}]++
+		} else {
+			p.unpaired[serviceKey{server: e.serverService}]++
+		}
+		delete(p.pending, key)
+	}
+	pendingEdges.Set(float64(len(p.pending)))
+}
+
+// collect expires outdated edges and returns the current state of the service
+// graph as series with a single sample taken at now.
+func (p *Processor) collect(now time.Time) []prompb.TimeSeries {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.expire(now)
+
+	ts := now.UnixNano() / int64(time.Millisecond)
+	series := make([]prompb.TimeSeries, 0, len(p.stats)*(2*len(p.cfg.LatencyBuckets)+8)+len(p.unpaired))
+	for key, s := range p.stats {
+		series = append(series,
+			p.newSeries(requestTotalMetric, key, ts, float64(s.total)),
+			p.newSeries(requestFailedMetric, key, ts, float64(s.failed)),
+		)
+		series = p.appendHistogram(series, serverLatencyMetric, key, ts, &s.serverLatency)
+		series = p.appendHistogram(series, clientLatencyMetric, key, ts, &s.clientLatency)
+	}
+	for key, count := range p.unpaired {
+		series = append(series, p.newSeries(unpairedMetric, key, ts, float64(count)))
+	}
+	return series
+}
+
+func (p *Processor) appendHistogram(series []prompb.TimeSeries, name string, key serviceKey, ts int64, h *histogram) []prompb.TimeSeries {
+	for i, bound := range p.cfg.LatencyBuckets {
+		series = append(series, p.newBucketSeries(name, key, strconv.FormatFloat(bound, 'f', -1, 64), ts, float64(h.buckets[i])))
+	}
+	return append(series,
+		p.newBucketSeries(name, key, "+Inf", ts, float64(h.count)),
+		p.newSeries(name+"_sum", key, ts, h.sum),
+		p.newSeries(name+"_count", key, ts, float64(h.count)),
+	)
+}
+
+func (p *Processor) newSeries(name string, key serviceKey, ts int64, value float64, extra ...prompb.Label) prompb.TimeSeries {
+	labels := append([]prompb.Label{{Name: "__name__", Value: name}}, extra...)
+	if key.client != "" {
+		labels = append(labels, prompb.Label{Name: clientLabel, Value: key.client})
+	}
+	if key.server != "" {
+		labels = append(labels, prompb.Label{Name: serverLabel, Value: key.server})
+	}
+	if p.cfg.Instance != "" {
+		labels = append(labels, prompb.Label{Name: instanceLabel, Value: p.cfg.Instance})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	return prompb.TimeSeries{
+		Labels:  labels,
+		Samples: []prompb.Sample{{Timestamp: ts, Value: value}},
+	}
+}
+
+func (p *Processor) newBucketSeries(name string, key serviceKey, le string, ts int64, value float64) prompb.TimeSeries {
+	return p.newSeries(name+"_bucket", key, ts, value, prompb.Label{Name: "le", Value: le})
+}
+
+func (p *Processor) flush(now time.Time) {
+	series := p.collect(now)
+	if len(series) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.FlushInterval)
+	defer cancel()
+	if err := p.sink(ctx, series); err != nil {
+		flushErrors.Inc()
+		log.Error("msg", "error writing service graph metrics", "err", err)
+	}
+}
+
+// Run starts flushing the service graph metrics every flush interval.
+func (p *Processor) Run() {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.cfg.FlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.flush(p.now())
+			case <-p.stop:
+				// Write the final state so that the last interval isn't lost.
+				p.flush(p.now())
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the flushing routine after a final flush.
+func (p *Processor) Stop() {
+	p.once.Do(func() {
+		close(p.stop)
+		p.wg.Wait()
+	})
+}
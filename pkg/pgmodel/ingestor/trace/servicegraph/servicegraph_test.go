@@ -0,0 +1,149 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package servicegraph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+var (
+	testTraceID      = pcommon.TraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	testClientSpanID = pcommon.SpanID([8]byte{1, 1, 1, 1, 1, 1, 1, 1})
+	testServerSpanID = pcommon.SpanID([8]byte{2, 2, 2, 2, 2, 2, 2, 2})
+)
+
+func newTestSpan(traces ptrace.Traces, service string, kind ptrace.SpanKind, spanID, parentSpanID pcommon.SpanID, latency time.Duration, status ptrace.StatusCode) {
+	rSpan := traces.ResourceSpans().AppendEmpty()
+	rSpan.Resource().Attributes().PutString(serviceNameTagKey, service)
+	span := rSpan.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(testTraceID)
+	span.SetSpanID(spanID)
+	span.SetParentSpanID(parentSpanID)
+	span.SetKind(kind)
+	start := time.Unix(100, 0)
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+	span.SetEndTimestamp(pcommon.NewTimestampFromTime(start.Add(latency)))
+	span.Status().SetCode(status)
+}
+
+func findSample(t *testing.T, series []prompb.TimeSeries, labels map[string]string) float64 {
+	t.Helper()
+	for _, s := range series {
+		if len(s.Labels) != len(labels) {
+			continue
+		}
+		matches := true
+		for _, l := range s.Labels {
+			if labels[l.Name] != l.Value {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			require.Len(t, s.Samples, 1)
+			return s.Samples[0].Value
+		}
+	}
+	t.Fatalf("series %v not found", labels)
+	return 0
+}
+
+func TestProcessorPairsClientAndServerSpans(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.Enabled = true
+	cfg.Instance = "promscale-0"
+	p := NewProcessor(cfg, nil)
+
+	// The server span arrives before the client span in a separate request.
+	server := ptrace.NewTraces()
+	newTestSpan(server, "backend", ptrace.SpanKindServer, testServerSpanID, testClientSpanID, 300*time.Millisecond, ptrace.StatusCodeError)
+	p.Consume(server)
+	require.Len(t, p.pending, 1)
+
+	client := ptrace.NewTraces()
+	newTestSpan(client, "frontend", ptrace.SpanKindClient, testClientSpanID, pcommon.SpanID{}, 500*time.Millisecond, ptrace.StatusCodeUnset)
+	p.Consume(client)
+	require.Len(t, p.pending, 0)
+
+	series := p.collect(time.Unix(200, 0))
+	edge := map[string]string{"client": "frontend", "server": "backend", "instance": "promscale-0"}
+	with := func(name string, extra ...string) map[string]string {
+		l := map[string]string{"__name__": name}
+		for k, v := range edge {
+			l[k] = v
+		}
+		for i := 0; i < len(extra); i += 2 {
+			l[extra[i]] = extra[i+1]
+		}
+		return l
+	}
+	require.Equal(t, 1.0, findSample(t, series, with(requestTotalMetric)))
+	require.Equal(t, 1.0, findSample(t, series, with(requestFailedMetric)))
+	require.Equal(t, 0.0, findSample(t, series, with(serverLatencyMetric+"_bucket", "le", "0.2")))
+	require.Equal(t, 1.0, findSample(t, series, with(serverLatencyMetric+"_bucket", "le", "0.4")))
+	require.Equal(t, 1.0, findSample(t, series, with(clientLatencyMetric+"_bucket", "le", "+Inf")))
+	require.InDelta(t, 0.5, findSample(t, series, with(clientLatencyMetric+"_sum")), 1e-9)
+	require.Equal(t, 1.0, findSample(t, series, with(clientLatencyMetric+"_count")))
+}
+
+func TestProcessorExpiresUnpairedSpans(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.Enabled = true
+	cfg.WaitTime = time.Second
+	p := NewProcessor(cfg, nil)
+	p.now = func() time.Time { return time.Unix(100, 0) }
+
+	traces := ptrace.NewTraces()
+	newTestSpan(traces, "frontend", ptrace.SpanKindClient, testClientSpanID, pcommon.SpanID{}, time.Millisecond, ptrace.StatusCodeOk)
+	p.Consume(traces)
+
+	// Not expired yet.
+	require.Empty(t, p.collect(time.Unix(100, 500)))
+	require.Len(t, p.pending, 1)
+
+	series := p.collect(time.Unix(102, 0))
+	require.Len(t, p.pending, 0)
+	require.Equal(t, 1.0, findSample(t, series, map[string]string{"__name__": unpairedMetric, "client": "frontend"}))
+}
+
+func TestProcessorMaxItems(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.Enabled = true
+	cfg.MaxItems = 1
+	p := NewProcessor(cfg, nil)
+
+	traces := ptrace.NewTraces()
+	newTestSpan(traces, "frontend", ptrace.SpanKindClient, testClientSpanID, pcommon.SpanID{}, time.Millisecond, ptrace.StatusCodeOk)
+	newTestSpan(traces, "frontend", ptrace.SpanKindClient, testServerSpanID, pcommon.SpanID{}, time.Millisecond, ptrace.StatusCodeOk)
+	p.Consume(traces)
+	require.Len(t, p.pending, 1)
+}
+
+func TestProcessorFlushesOnStop(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.Enabled = true
+	var flushed []prompb.TimeSeries
+	p := NewProcessor(cfg, func(_ context.Context, ts []prompb.TimeSeries) error {
+		flushed = ts
+		return nil
+	})
+	p.Run()
+
+	traces := ptrace.NewTraces()
+	newTestSpan(traces, "frontend", ptrace.SpanKindClient, testClientSpanID, pcommon.SpanID{}, time.Millisecond, ptrace.StatusCodeOk)
+	newTestSpan(traces, "backend", ptrace.SpanKindServer, testServerSpanID, testClientSpanID, time.Millisecond, ptrace.StatusCodeOk)
+	p.Consume(traces)
+	p.Stop()
+
+	require.Equal(t, 1.0, findSample(t, flushed, map[string]string{"__name__": requestTotalMetric, "client": "frontend", "server": "backend"}))
+}
@@ -149,7 +149,7 @@ func Migrate(conn *pgx.Conn, appVersion VersionInfo, leaseLock *util.PgAdvisoryL
 			return err
 		}
 	}
-	return nil
+	return installConnectorObjects(conn)
 }
 
 func upgradeThroughAllBalls(conn *pgx.Conn, appSemver semver.Version, extOptions extension.ExtensionMigrateOptions) error {
@@ -0,0 +1,141 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package querier
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/promql/parser"
+)
+
+type explainCtxKey struct{}
+
+// Explain collects the SQL statements generated for the selectors of a PromQL
+// query. It is passed to the querier in the context of the query, see
+// WithExplain.
+type Explain struct {
+	// Analyze runs EXPLAIN (ANALYZE, BUFFERS) on the statements instead of
+	// EXPLAIN, which executes every statement a second time.
+	Analyze bool
+
+	mu        sync.Mutex
+	selectors []*SelectorExplain
+}
+
+// SelectorExplain describes how the samples of a vector selector were
+// fetched.
+type SelectorExplain struct {
+	// Node is the vector selector of the PromQL expression.
+	Node     parser.Node
+	Matchers []*labels.Matcher
+	// Start and End are the fetched time range in milliseconds.
+	Start, End int64
+	// Pushdown is the expression evaluated in the database instead of the
+	// PromQL engine, nil if nothing was pushed down.
+	Pushdown   parser.Node
+	Statements []ExplainStatement
+
+	explain *Explain
+}
+
+// ExplainStatement is a SQL statement and its query plan.
+type ExplainStatement struct {
+	// Metric is the metric the statement selects samples from. It is empty
+	// for the series lookup of selectors matching multiple metrics.
+	Metric string
+	SQL    string
+	Params []interface{}
+	// SeriesIDs are the series the samples are selected from, they are only
+	// known in advance for selectors matching multiple metrics.
+	SeriesIDs []model.SeriesID
+	Plan      []string
+	// PlanErr is the error explaining the statement. The statement is still
+	// executed, as some errors result in an empty result.
+	PlanErr error
+}
+
+// WithExplain returns a context which makes the querier record the SQL
+// statements it runs in e.
+func WithExplain(ctx context.Context, e *Explain) context.Context {
+	return context.WithValue(ctx, explainCtxKey{}, e)
+}
+
+// Selectors returns the explanations of the selectors in the order they were
+// evaluated.
+func (e *Explain) Selectors() []*SelectorExplain {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*SelectorExplain{}, e.selectors...)
+}
+
+// explainSelector returns the explanation of the selector evaluated with the
+// metadata, or nil if the query is not explained.
+func explainSelector(ctx context.Context, metadata *evalMetadata, mint, maxt int64) *SelectorExplain {
+	e, ok := ctx.Value(explainCtxKey{}).(*Explain)
+	if !ok || e == nil {
+		return nil
+	}
+	s := &SelectorExplain{
+		Matchers: metadata.matchers,
+		Start:    mint,
+		End:      maxt,
+		explain:  e,
+	}
+	if metadata.queryHints != nil {
+		s.Node = metadata.queryHints.CurrentNode
+	}
+	if sh := metadata.selectHints; sh != nil {
+		s.Start, s.End = sh.Start, sh.End
+	}
+	e.mu.Lock()
+	e.selectors = append(e.selectors, s)
+	e.mu.Unlock()
+	return s
+}
+
+// addStatement explains the statement and adds it to the selector. It is a
+// no-op on a nil selector.
+func (s *SelectorExplain) addStatement(ctx context.Context, conn pgxconn.PgxConn, stmt ExplainStatement) {
+	if s == nil {
+		return
+	}
+	stmt.Plan, stmt.PlanErr = s.explain.plan(ctx, conn, stmt.SQL, stmt.Params)
+	s.Statements = append(s.Statements, stmt)
+}
+
+func (e *Explain) plan(ctx context.Context, conn pgxconn.PgxConn, sql string, params []interface{}) ([]string, error) {
+	prefix := "EXPLAIN "
+	if e.Analyze {
+		prefix = "EXPLAIN (ANALYZE, BUFFERS) "
+	}
+	rows, err := conn.Query(ctx, prefix+sql, params...)
+	if err != nil {
+		return nil, fmt.Errorf("explaining statement: %w", err)
+	}
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err = rows.Scan(&line); err != nil {
+			return nil, fmt.Errorf("scanning query plan: %w", err)
+		}
+		plan = append(plan, line)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("query plan: %w", err)
+	}
+	return plan, nil
+}
+
+func (s *SelectorExplain) setPushdown(node parser.Node) {
+	if s != nil {
+		s.Pushdown = node
+	}
+}
@@ -5,10 +5,10 @@ import (
 	"time"
 
 	"github.com/prometheus/prometheus/model/labels"
-	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/prompb"
+	"github.com/timescale/promscale/pkg/promql/parser"
 )
 
 // Reader reads the data based on the provided read request.
@@ -31,6 +31,8 @@ type Querier interface {
 	SamplesQuerier(ctx context.Context) SamplesQuerier
 	// ExemplarsQuerier returns an exemplar querier.
 	ExemplarsQuerier(ctx context.Context) ExemplarQuerier
+	// LabelsQuerier returns a labels querier.
+	LabelsQuerier(ctx context.Context) LabelsQuerier
 }
 
 // RemoteReadQuerier queries the data using the provided query data and returns
@@ -53,3 +55,12 @@ type ExemplarQuerier interface {
 	// Select returns a series set containing the exemplar that matches the supplied query parameters.
 	Select(start, end time.Time, ms ...[]*labels.Matcher) ([]model.ExemplarQueryResult, error)
 }
+
+// LabelsQuerier queries the label names and values of the series matching
+// the matchers with samples in the time range.
+type LabelsQuerier interface {
+	// LabelNames returns the sorted label names.
+	LabelNames(mint, maxt int64, ms ...*labels.Matcher) ([]string, error)
+	// LabelValues returns the sorted values of the label.
+	LabelValues(name string, mint, maxt int64, ms ...*labels.Matcher) ([]string, error)
+}
@@ -4,8 +4,8 @@ import (
 	"fmt"
 
 	"github.com/prometheus/prometheus/model/labels"
-	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/storage"
+	"github.com/timescale/promscale/pkg/promql/parser"
 )
 
 // promqlMetadata is metadata received directly from our native PromQL engine.
@@ -29,6 +29,7 @@ func NewQuerier(
 	labelsReader lreader.LabelsReader,
 	exemplarCache cache.PositionCache,
 	rAuth tenancy.ReadAuthorizer,
+	opts ...Option,
 ) Querier {
 	querier := &pgxQuerier{
 		tools: &queryTools{
@@ -39,6 +40,9 @@ func NewQuerier(
 			rAuth:            rAuth,
 		},
 	}
+	for _, opt := range opts {
+		opt(querier.tools)
+	}
 	return querier
 }
 
@@ -54,6 +58,10 @@ func (q *pgxQuerier) ExemplarsQuerier(ctx context.Context) ExemplarQuerier {
 	return newQueryExemplars(ctx, q)
 }
 
+func (q *pgxQuerier) LabelsQuerier(ctx context.Context) LabelsQuerier {
+	return newQueryLabels(ctx, q)
+}
+
 // errorSeriesSet represents an error result in a form of a series set.
 // This behavior is inherited from Prometheus codebase.
 type errorSeriesSet struct {
@@ -5,9 +5,9 @@ import (
 	"strings"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
 	pgmodel "github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/promql/parser"
 )
 
 const (
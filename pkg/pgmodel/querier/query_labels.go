@@ -0,0 +1,210 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package querier
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/timescale/promscale/pkg/pgmodel/common/errors"
+	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+)
+
+const (
+	// The labels are looked up in the live series matching the clauses, in
+	// a single statement whatever the number of metrics.
+	labelsSQLFormat = `SELECT DISTINCT l.%[1]s
+	FROM (%[2]s) series
+	INNER JOIN _prom_catalog.label l ON (l.id = ANY(series.labels))
+	WHERE %[3]s`
+
+	// Without a time range, the series of every metric are looked up at once.
+	allSeriesSQLFormat = `SELECT labels FROM _prom_catalog.series WHERE delete_epoch IS NULL AND %s`
+
+	// With a time range, only the series with samples in it count. The
+	// series of each metric are checked against its data table, which only
+	// touches the chunks overlapping the range.
+	seriesInRangeSQLFormat = `SELECT series.labels FROM %[1]s series
+	WHERE series.delete_epoch IS NULL AND %[2]s AND EXISTS (
+		SELECT 1 FROM %[3]s metric
+		WHERE metric.series_id = series.id AND metric.time >= $%[4]d::timestamptz AND metric.time <= $%[5]d::timestamptz
+	)`
+
+	matchingMetricTablesSQLFormat = `SELECT m.id, m.table_schema, m.table_name, m.series_table
+	FROM _prom_catalog.metric m
+	WHERE m.creation_completed AND NOT m.is_view AND EXISTS (
+		SELECT 1 FROM _prom_catalog.series
+		WHERE metric_id = m.id AND delete_epoch IS NULL AND %s
+	)`
+)
+
+type queryLabels struct {
+	*pgxQuerier
+	ctx context.Context
+}
+
+func newQueryLabels(ctx context.Context, qr *pgxQuerier) *queryLabels {
+	return &queryLabels{qr, ctx}
+}
+
+// seriesSelection is the series matching the clauses, among those of the
+// metrics if set or else of every metric.
+type seriesSelection struct {
+	metrics []model.MetricInfo
+	clauses []string
+	values  []interface{}
+}
+
+// LabelNames implements the LabelsQuerier interface.
+func (q *queryLabels) LabelNames(mint, maxt int64, ms ...*labels.Matcher) ([]string, error) {
+	return q.query("key", "", mint, maxt, ms)
+}
+
+// LabelValues implements the LabelsQuerier interface.
+func (q *queryLabels) LabelValues(name string, mint, maxt int64, ms ...*labels.Matcher) ([]string, error) {
+	return q.query("value", name, mint, maxt, ms)
+}
+
+func (q *queryLabels) query(column, key string, mint, maxt int64, ms []*labels.Matcher) ([]string, error) {
+	if q.tools.rAuth != nil {
+		ms = q.tools.rAuth.AppendTenantMatcher(ms)
+	}
+	res, err := q.seriesLabels(column, key, mint, maxt, ms)
+	if err != nil || q.tools.sqlMetrics == nil {
+		return res, err
+	}
+	return addSQLMetricLabels(q.ctx, q.tools.sqlMetrics, res, key, mint, maxt, ms)
+}
+
+// seriesLabels returns the labels of the series of the metrics.
+func (q *queryLabels) seriesLabels(column, key string, mint, maxt int64, ms []*labels.Matcher) ([]string, error) {
+	sel, err := q.selection(ms)
+	if err != nil {
+		return nil, err
+	}
+	if sel == nil {
+		return []string{}, nil
+	}
+	inRange := mint > minTime || maxt < maxTime
+	if inRange && sel.metrics == nil {
+		// The data tables to check are those of the metrics with matching
+		// series.
+		if sel.metrics, err = q.metricTables(sel.clauses, sel.values); err != nil {
+			return nil, err
+		}
+	}
+	if inRange && len(sel.metrics) == 0 {
+		return []string{}, nil
+	}
+
+	sql, values := buildLabelsQuery(column, key, sel, inRange, mint, maxt)
+	rows, err := q.tools.conn.Query(q.ctx, sql, values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []string{}
+	for rows.Next() {
+		var s string
+		if err = rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// selection returns the series matching the matchers, or nil if there are
+// none. Without matchers, every series counts.
+func (q *queryLabels) selection(ms []*labels.Matcher) (*seriesSelection, error) {
+	if len(ms) == 0 {
+		return &seriesSelection{clauses: []string{"TRUE"}}, nil
+	}
+
+	builder, err := BuildSubQueries(ms)
+	if err != nil {
+		return nil, fmt.Errorf("build subQueries: %w", err)
+	}
+	if metric := builder.GetMetricName(); metric != "" {
+		mInfo, err := q.tools.getMetricTableName(q.ctx, builder.GetSchemaName(), metric, false)
+		if err != nil {
+			if err == errors.ErrMissingTableName {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("get metric table name: %w", err)
+		}
+		clauses, values, err := builder.Build(false)
+		if err != nil {
+			return nil, fmt.Errorf("building single metric clauses: %w", err)
+		}
+		return &seriesSelection{metrics: []model.MetricInfo{mInfo}, clauses: clauses, values: values}, nil
+	}
+
+	clauses, values, err := builder.Build(true)
+	if err != nil {
+		return nil, fmt.Errorf("building multiple metric clauses: %w", err)
+	}
+	return &seriesSelection{clauses: clauses, values: values}, nil
+}
+
+// metricTables returns the tables of the metrics with live series matching
+// the clauses.
+func (q *queryLabels) metricTables(clauses []string, values []interface{}) ([]model.MetricInfo, error) {
+	rows, err := q.tools.conn.Query(q.ctx, fmt.Sprintf(matchingMetricTablesSQLFormat, strings.Join(clauses, " AND ")), values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	metrics := []model.MetricInfo{}
+	for rows.Next() {
+		var m model.MetricInfo
+		if err = rows.Scan(&m.MetricID, &m.TableSchema, &m.TableName, &m.SeriesTable); err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, rows.Err()
+}
+
+func buildLabelsQuery(column, key string, sel *seriesSelection, inRange bool, mint, maxt int64) (string, []interface{}) {
+	values := append([]interface{}{}, sel.values...)
+	clauses := strings.Join(sel.clauses, " AND ")
+	var series string
+	switch {
+	case inRange:
+		values = append(values, toRFC3339Nano(mint), toRFC3339Nano(maxt))
+		branches := make([]string, 0, len(sel.metrics))
+		for _, m := range sel.metrics {
+			branches = append(branches, fmt.Sprintf(seriesInRangeSQLFormat,
+				pgx.Identifier{schema.PromDataSeries, m.SeriesTable}.Sanitize(),
+				clauses,
+				pgx.Identifier{m.TableSchema, m.TableName}.Sanitize(),
+				len(values)-1,
+				len(values),
+			))
+		}
+		series = strings.Join(branches, "\n\tUNION ALL\n\t")
+	case len(sel.metrics) == 1:
+		series = fmt.Sprintf("SELECT series.labels FROM %s series WHERE series.delete_epoch IS NULL AND %s",
+			pgx.Identifier{schema.PromDataSeries, sel.metrics[0].SeriesTable}.Sanitize(), clauses)
+	default:
+		series = fmt.Sprintf(allSeriesSQLFormat, clauses)
+	}
+	keyClause := "TRUE"
+	if key != "" {
+		values = append(values, key)
+		keyClause = fmt.Sprintf("l.key = $%d", len(values))
+	}
+	return fmt.Sprintf(labelsSQLFormat, column, series, keyClause), values
+}
@@ -0,0 +1,98 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package querier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+)
+
+func TestBuildLabelsQuery(t *testing.T) {
+	foo := model.MetricInfo{TableSchema: "prom_data", TableName: "foo", SeriesTable: "foo"}
+	bar := model.MetricInfo{TableSchema: "prom_data", TableName: "bar", SeriesTable: "bar_series"}
+	testCases := []struct {
+		name          string
+		column, key   string
+		sel           seriesSelection
+		mint, maxt    int64
+		expectClauses []string
+		expectValues  []interface{}
+	}{
+		{
+			name:   "unbounded label names of a metric",
+			column: "key",
+			sel:    seriesSelection{metrics: []model.MetricInfo{foo}, clauses: []string{"labels && $1"}, values: []interface{}{1}},
+			mint:   minTime,
+			maxt:   maxTime,
+			expectClauses: []string{
+				`SELECT DISTINCT l.key`,
+				`FROM "prom_data_series"."foo" series WHERE series.delete_epoch IS NULL AND labels && $1`,
+				`WHERE TRUE`,
+			},
+			expectValues: []interface{}{1},
+		},
+		{
+			name:   "unbounded label values of every metric",
+			column: "value",
+			key:    "job",
+			sel:    seriesSelection{clauses: []string{"TRUE"}},
+			mint:   minTime,
+			maxt:   maxTime,
+			expectClauses: []string{
+				`SELECT DISTINCT l.value`,
+				`FROM _prom_catalog.series WHERE delete_epoch IS NULL AND TRUE`,
+				`WHERE l.key = $1`,
+			},
+			expectValues: []interface{}{"job"},
+		},
+		{
+			name:   "label values in range",
+			column: "value",
+			key:    "job",
+			sel:    seriesSelection{metrics: []model.MetricInfo{foo, bar}, clauses: []string{"labels && $1"}, values: []interface{}{1}},
+			mint:   1000,
+			maxt:   2000,
+			expectClauses: []string{
+				`SELECT DISTINCT l.value`,
+				`FROM "prom_data_series"."foo" series
+	WHERE series.delete_epoch IS NULL AND labels && $1 AND EXISTS (`,
+				`SELECT 1 FROM "prom_data"."foo" metric`,
+				"\n\tUNION ALL\n\tSELECT series.labels FROM \"prom_data_series\".\"bar_series\" series",
+				`SELECT 1 FROM "prom_data"."bar" metric`,
+				`WHERE metric.series_id = series.id AND metric.time >= $2::timestamptz AND metric.time <= $3::timestamptz`,
+				`WHERE l.key = $4`,
+			},
+			expectValues: []interface{}{1, "1970-01-01T00:00:01Z", "1970-01-01T00:00:02Z", "job"},
+		},
+		{
+			name:   "label names from a time",
+			column: "key",
+			sel:    seriesSelection{metrics: []model.MetricInfo{foo}, clauses: []string{"TRUE"}},
+			mint:   1000,
+			maxt:   maxTime,
+			expectClauses: []string{
+				`WHERE series.delete_epoch IS NULL AND TRUE AND EXISTS (`,
+				`WHERE metric.series_id = series.id AND metric.time >= $1::timestamptz AND metric.time <= $2::timestamptz`,
+			},
+			expectValues: []interface{}{"1970-01-01T00:00:01Z", "Infinity"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			inRange := tc.mint > minTime || tc.maxt < maxTime
+			sql, values := buildLabelsQuery(tc.column, tc.key, &tc.sel, inRange, tc.mint, tc.maxt)
+			for _, c := range tc.expectClauses {
+				require.True(t, strings.Contains(sql, c), "expected %q in %q", c, sql)
+			}
+			require.Equal(t, tc.expectValues, values)
+			if !inRange {
+				require.False(t, strings.Contains(sql, "EXISTS"))
+			}
+		})
+	}
+}
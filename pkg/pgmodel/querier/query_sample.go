@@ -3,14 +3,15 @@ package querier
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/prometheus/prometheus/model/labels"
-	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/timescale/promscale/pkg/pgmodel/common/errors"
 	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
+	"github.com/timescale/promscale/pkg/promql/parser"
 )
 
 type querySamples struct {
@@ -29,6 +30,7 @@ func (q *querySamples) Select(mint, maxt int64, _ bool, hints *storage.SelectHin
 	if err != nil {
 		return errorSeriesSet{err: err}, nil
 	}
+	queryStatsFromContext(q.ctx).addSeries(len(sampleRows))
 	responseSeriesSet := buildSeriesSet(sampleRows, q.tools.labelsReader)
 	return responseSeriesSet, topNode
 }
@@ -38,14 +40,26 @@ func (q *querySamples) fetchSamplesRows(mint, maxt int64, hints *storage.SelectH
 	if err != nil {
 		return nil, nil, fmt.Errorf("get evaluation metadata: %w", err)
 	}
+	if err = addShardClause(q.ctx, metadata); err != nil {
+		return nil, nil, err
+	}
 
+	explain := explainSelector(q.ctx, metadata, mint, maxt)
 	filter := metadata.timeFilter
 	if metadata.isSingleMetric {
 		// Single vector selector case.
 		mInfo, err := q.tools.getMetricTableName(q.ctx, filter.schema, filter.metric, false)
 		if err != nil {
 			if err == errors.ErrMissingTableName {
-				return nil, nil, nil
+				if q.tools.sqlMetrics == nil || filter.schema != "" {
+					return nil, nil, nil
+				}
+				m, ok, err := q.tools.sqlMetrics.get(q.ctx, filter.metric)
+				if err != nil || !ok {
+					return nil, nil, err
+				}
+				sampleRows, err := fetchSQLMetricSamples(q.ctx, q.tools, m, metadata, explain)
+				return sampleRows, nil, err
 			}
 			return nil, nil, fmt.Errorf("get metric table name: %w", err)
 		}
@@ -53,7 +67,7 @@ func (q *querySamples) fetchSamplesRows(mint, maxt int64, hints *storage.SelectH
 		metadata.timeFilter.schema = mInfo.TableSchema
 		metadata.timeFilter.seriesTable = mInfo.SeriesTable
 
-		sampleRows, topNode, err := fetchSingleMetricSamples(q.ctx, q.tools, metadata)
+		sampleRows, topNode, err := fetchSingleMetricSamples(q.ctx, q.tools, metadata, explain)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -61,10 +75,23 @@ func (q *querySamples) fetchSamplesRows(mint, maxt int64, hints *storage.SelectH
 		return sampleRows, topNode, nil
 	}
 	// Multiple vector selector case.
-	sampleRows, err := fetchMultipleMetricsSamples(q.ctx, q.tools, metadata)
+	sampleRows, err := fetchMultipleMetricsSamples(q.ctx, q.tools, metadata, explain)
 	if err != nil {
 		return nil, nil, err
 	}
+	if q.tools.sqlMetrics != nil {
+		metrics, err := q.tools.sqlMetrics.all(q.ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, m := range metrics {
+			rows, err := fetchSQLMetricSamples(q.ctx, q.tools, m, metadata, explain)
+			if err != nil {
+				return nil, nil, err
+			}
+			sampleRows = append(sampleRows, rows...)
+		}
+	}
 	return sampleRows, nil, nil
 }
 
@@ -73,12 +100,16 @@ func (q *querySamples) fetchSamplesRows(mint, maxt int64, hints *storage.SelectH
 // try to push down query functions where possible. When a pushdown is
 // successfully applied, the new top node is returned together with the metric
 // rows. For more information about top nodes, see `engine.populateSeries`.
-func fetchSingleMetricSamples(ctx context.Context, tools *queryTools, metadata *evalMetadata) ([]sampleRow, parser.Node, error) {
+// The statement is added to explain if it is not nil.
+func fetchSingleMetricSamples(ctx context.Context, tools *queryTools, metadata *evalMetadata, explain *SelectorExplain) ([]sampleRow, parser.Node, error) {
 	sqlQuery, values, topNode, tsSeries, err := buildSingleMetricSamplesQuery(metadata)
 	if err != nil {
 		return nil, nil, err
 	}
+	explain.setPushdown(topNode)
+	explain.addStatement(ctx, tools.conn, ExplainStatement{Metric: metadata.timeFilter.metric, SQL: sqlQuery, Params: values})
 
+	defer queryStatsFromContext(ctx).roundTrip(time.Now())
 	rows, err := tools.conn.Query(ctx, sqlQuery, values...)
 	if err != nil {
 		if e, ok := err.(*pgconn.PgError); ok {
@@ -114,10 +145,15 @@ func fetchSingleMetricSamples(ctx context.Context, tools *queryTools, metadata *
 }
 
 // fetchMultipleMetricsSamples returns all the result rows for across multiple
-// metrics using the supplied query parameters.
-func fetchMultipleMetricsSamples(ctx context.Context, tools *queryTools, metadata *evalMetadata) ([]sampleRow, error) {
+// metrics using the supplied query parameters. The statements are added to
+// explain if it is not nil.
+func fetchMultipleMetricsSamples(ctx context.Context, tools *queryTools, metadata *evalMetadata, explain *SelectorExplain) ([]sampleRow, error) {
 	// First fetch series IDs per metric.
+	explain.addStatement(ctx, tools.conn, ExplainStatement{SQL: buildMetricNameSeriesIDQuery(metadata.clauses), Params: metadata.values})
+	stats := queryStatsFromContext(ctx)
+	start := time.Now()
 	metrics, schemas, series, err := GetMetricNameSeriesIds(ctx, tools.conn, metadata)
+	stats.roundTrip(start)
 	if err != nil {
 		return nil, err
 	}
@@ -157,10 +193,16 @@ func fetchMultipleMetricsSamples(ctx context.Context, tools *queryTools, metadat
 		if err != nil {
 			return nil, fmt.Errorf("build timeseries by series-id: %w", err)
 		}
+		explain.addStatement(ctx, tools.conn, ExplainStatement{Metric: metricInfo.TableName, SQL: sqlQuery, SeriesIDs: series[i]})
 		batch.Queue(sqlQuery)
 		numQueries += 1
 	}
 
+	if numQueries == 0 {
+		return results, nil
+	}
+
+	defer stats.roundTrip(time.Now())
 	batchResults, err := tools.conn.SendBatch(ctx, batch)
 	if err != nil {
 		return nil, err
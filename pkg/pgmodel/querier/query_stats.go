@@ -0,0 +1,72 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package querier
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type queryStatsCtxKey struct{}
+
+// QueryStats counts the series fetched and the SQL round-trips made while
+// evaluating a PromQL query. It is passed to the querier in the context of the
+// query, see WithQueryStats.
+type QueryStats struct {
+	mu                sync.Mutex
+	seriesFetched     int
+	roundTrips        int
+	roundTripDuration time.Duration
+}
+
+// WithQueryStats returns a context which makes the querier record the
+// statistics of the samples it fetches in s.
+func WithQueryStats(ctx context.Context, s *QueryStats) context.Context {
+	return context.WithValue(ctx, queryStatsCtxKey{}, s)
+}
+
+// SeriesFetched returns the number of series fetched from the database.
+func (s *QueryStats) SeriesFetched() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seriesFetched
+}
+
+// RoundTrips returns the number of SQL round-trips and their total duration,
+// which includes reading the result rows.
+func (s *QueryStats) RoundTrips() (int, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.roundTrips, s.roundTripDuration
+}
+
+// queryStatsFromContext returns the statistics of the context, nil if they
+// aren't recorded. The methods recording statistics are no-ops on nil.
+func queryStatsFromContext(ctx context.Context) *QueryStats {
+	s, _ := ctx.Value(queryStatsCtxKey{}).(*QueryStats)
+	return s
+}
+
+func (s *QueryStats) addSeries(n int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.seriesFetched += n
+	s.mu.Unlock()
+}
+
+// roundTrip records a round-trip which started at start and ended now.
+func (s *QueryStats) roundTrip(start time.Time) {
+	if s == nil {
+		return
+	}
+	d := time.Since(start)
+	s.mu.Lock()
+	s.roundTrips++
+	s.roundTripDuration += d
+	s.mu.Unlock()
+}
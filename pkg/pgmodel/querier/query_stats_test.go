@@ -0,0 +1,33 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package querier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryStats(t *testing.T) {
+	// Recording statistics without a collector in the context is a no-op.
+	noStats := queryStatsFromContext(context.Background())
+	require.Nil(t, noStats)
+	noStats.addSeries(1)
+	noStats.roundTrip(time.Now())
+
+	stats := &QueryStats{}
+	ctx := WithQueryStats(context.Background(), stats)
+	queryStatsFromContext(ctx).addSeries(2)
+	queryStatsFromContext(ctx).addSeries(3)
+	queryStatsFromContext(ctx).roundTrip(time.Now().Add(-time.Second))
+	queryStatsFromContext(ctx).roundTrip(time.Now())
+
+	require.Equal(t, 5, stats.SeriesFetched())
+	n, d := stats.RoundTrips()
+	require.Equal(t, 2, n)
+	require.GreaterOrEqual(t, d, time.Second)
+}
@@ -20,6 +20,8 @@ type queryTools struct {
 	exemplarPosCache cache.PositionCache
 	labelsReader     lreader.LabelsReader
 	rAuth            tenancy.ReadAuthorizer
+	// sqlMetrics is nil if SQL metrics are disabled.
+	sqlMetrics *SQLMetricRegistry
 }
 
 // getMetricTableName gets the table name for a specific metric from internal
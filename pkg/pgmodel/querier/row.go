@@ -25,7 +25,10 @@ var tPool = sync.Pool{
 }
 
 type sampleRow struct {
-	labelIds       []*int64
+	labelIds []*int64
+	// labels are the labels of the rows which aren't series, like the rows of
+	// SQL metrics, labelIds is empty then.
+	labels         labels.Labels
 	times          TimestampSeries
 	values         *model.ReusableArray[pgtype.Float8]
 	err            error
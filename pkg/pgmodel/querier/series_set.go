@@ -90,6 +90,7 @@ func (p *pgxSamplesSeriesSet) At() storage.Series {
 	// this should pretty much always be non-empty due to __name__, but it
 	// costs little to check here
 	if len(row.labelIds) == 0 {
+		ps.labels = row.labels
 		return ps
 	}
 
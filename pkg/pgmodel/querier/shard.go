@@ -0,0 +1,59 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package querier
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// The single metric query selects from the series table as series, the
+	// multiple metrics series lookup from _prom_catalog.series as s.
+	singleMetricShardClause   = "series.id %% $%d = $%d"
+	multipleMetricShardClause = "s.id %% $%d = $%d"
+)
+
+type shardCtxKey struct{}
+
+// Shard is a subset of the series selected by a query, those whose series ID
+// modulo Count is Index. Series IDs are allocated sequentially, so the series
+// are spread evenly across shards.
+type Shard struct {
+	Index int
+	Count int
+}
+
+// WithShard returns a context which makes the querier only select the series
+// of the shard.
+func WithShard(ctx context.Context, s Shard) context.Context {
+	return context.WithValue(ctx, shardCtxKey{}, s)
+}
+
+// addShardClause restricts the metadata to the series of the shard of the
+// context, if any.
+func addShardClause(ctx context.Context, metadata *evalMetadata) error {
+	shard, ok := ctx.Value(shardCtxKey{}).(Shard)
+	if !ok || shard.Count <= 1 {
+		return nil
+	}
+	format := multipleMetricShardClause
+	if metadata.isSingleMetric {
+		format = singleMetricShardClause
+	}
+	clauses := metadata.clauses
+	if len(clauses) == 1 && clauses[0] == "TRUE" {
+		clauses = nil
+	}
+	values := make([]interface{}, len(metadata.values), len(metadata.values)+2)
+	copy(values, metadata.values)
+	clause, values, err := setParameterNumbers(format, values, int64(shard.Count), int64(shard.Index))
+	if err != nil {
+		return fmt.Errorf("shard clause: %w", err)
+	}
+	metadata.clauses = append(clauses[:len(clauses):len(clauses)], clause)
+	metadata.values = values
+	return nil
+}
@@ -0,0 +1,63 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package querier
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddShardClause(t *testing.T) {
+	testCases := []struct {
+		name          string
+		ctx           context.Context
+		metadata      evalMetadata
+		expectClauses []string
+		expectValues  []interface{}
+	}{
+		{
+			name:          "No shard",
+			ctx:           context.Background(),
+			metadata:      evalMetadata{isSingleMetric: true, clauses: []string{"TRUE"}},
+			expectClauses: []string{"TRUE"},
+		}, {
+			name:          "Single shard",
+			ctx:           WithShard(context.Background(), Shard{Index: 0, Count: 1}),
+			metadata:      evalMetadata{isSingleMetric: true, clauses: []string{"TRUE"}},
+			expectClauses: []string{"TRUE"},
+		}, {
+			name:          "Single metric without clauses",
+			ctx:           WithShard(context.Background(), Shard{Index: 1, Count: 4}),
+			metadata:      evalMetadata{isSingleMetric: true, clauses: []string{"TRUE"}},
+			expectClauses: []string{"series.id % $1 = $2"},
+			expectValues:  []interface{}{int64(4), int64(1)},
+		}, {
+			name:          "Single metric",
+			ctx:           WithShard(context.Background(), Shard{Index: 2, Count: 4}),
+			metadata:      evalMetadata{isSingleMetric: true, clauses: []string{"a = $1"}, values: []interface{}{"x"}},
+			expectClauses: []string{"a = $1", "series.id % $2 = $3"},
+			expectValues:  []interface{}{"x", int64(4), int64(2)},
+		}, {
+			name:          "Multiple metrics",
+			ctx:           WithShard(context.Background(), Shard{Index: 0, Count: 2}),
+			metadata:      evalMetadata{clauses: []string{"a = $1", "b = $2"}, values: []interface{}{"x", "y"}},
+			expectClauses: []string{"a = $1", "b = $2", "s.id % $3 = $4"},
+			expectValues:  []interface{}{"x", "y", int64(2), int64(0)},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clauses := append([]string{}, tc.metadata.clauses...)
+			metadata := tc.metadata
+			require.NoError(t, addShardClause(tc.ctx, &metadata))
+			require.Equal(t, tc.expectClauses, metadata.clauses)
+			require.Equal(t, tc.expectValues, metadata.values)
+			// The clauses of the original metadata are left untouched.
+			require.Equal(t, clauses, tc.metadata.clauses)
+		})
+	}
+}
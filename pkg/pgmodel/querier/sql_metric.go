@@ -0,0 +1,484 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package querier
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/lreader"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/tenancy"
+)
+
+// SQLMetricTable is created by the connector migrations
+// (pkg/migrations/sql/connector). It holds the tables and views registered
+// with prom_api.register_sql_metric.
+const SQLMetricTable = "_ps_catalog.sql_metric"
+
+const (
+	// The metrics ingested with the name of a SQL metric take precedence.
+	getSQLMetricsSQL = `SELECT s.metric_name, s.table_schema, s.table_name, s.time_column, s.value_columns, s.label_columns
+	FROM _ps_catalog.sql_metric s
+	WHERE NOT EXISTS (SELECT 1 FROM _prom_catalog.metric m WHERE m.metric_name = s.metric_name)`
+
+	sqlMetricLabelsSQLFormat = `SELECT DISTINCT %s AS label_values
+FROM %s t
+WHERE %s`
+
+	defaultSQLMetricsRefreshInterval = time.Minute
+)
+
+// SQLMetricsConfig configures the SQL metrics, tables or views registered
+// with prom_api.register_sql_metric and queried as PromQL metrics.
+type SQLMetricsConfig struct {
+	Enabled         bool
+	RefreshInterval time.Duration
+}
+
+func ParseSQLMetricsFlags(fs *flag.FlagSet, cfg *SQLMetricsConfig) *SQLMetricsConfig {
+	fs.BoolVar(&cfg.Enabled, "metrics.sql-metrics.enable", false, "Query the tables and views registered with prom_api.register_sql_metric as PromQL metrics.")
+	fs.DurationVar(&cfg.RefreshInterval, "metrics.sql-metrics.refresh-interval", defaultSQLMetricsRefreshInterval, "Interval at which the registered SQL metrics are reloaded from the database.")
+	return cfg
+}
+
+func ValidateSQLMetrics(cfg *SQLMetricsConfig) error {
+	if cfg.Enabled && cfg.RefreshInterval <= 0 {
+		return fmt.Errorf("metrics.sql-metrics.refresh-interval must be positive: %s", cfg.RefreshInterval)
+	}
+	return nil
+}
+
+// Option configures the querier.
+type Option func(*queryTools)
+
+// WithSQLMetrics makes the querier serve the SQL metrics of the registry.
+func WithSQLMetrics(registry *SQLMetricRegistry) Option {
+	return func(tools *queryTools) {
+		tools.sqlMetrics = registry
+	}
+}
+
+// sqlMetric is a table or view queried as a PromQL metric. Every value column
+// is a series of the metric per distinct label columns values, the first
+// value column is selected unless __column__ selects another one.
+type sqlMetric struct {
+	name         string
+	tableSchema  string
+	tableName    string
+	timeColumn   string
+	valueColumns []string
+	labelColumns []string
+}
+
+// SQLMetricRegistry caches the registered SQL metrics.
+type SQLMetricRegistry struct {
+	conn            pgxconn.PgxConn
+	refreshInterval time.Duration
+
+	mu        sync.Mutex
+	metrics   map[string]sqlMetric
+	refreshed time.Time
+}
+
+// NewSQLMetricRegistry returns a registry reloading the SQL metrics every
+// refresh interval.
+func NewSQLMetricRegistry(conn pgxconn.PgxConn, refreshInterval time.Duration) *SQLMetricRegistry {
+	return &SQLMetricRegistry{conn: conn, refreshInterval: refreshInterval}
+}
+
+// get returns the SQL metric registered with the name.
+func (r *SQLMetricRegistry) get(ctx context.Context, name string) (sqlMetric, bool, error) {
+	metrics, err := r.load(ctx)
+	if err != nil {
+		return sqlMetric{}, false, err
+	}
+	m, ok := metrics[name]
+	return m, ok, nil
+}
+
+// all returns the SQL metrics sorted by name.
+func (r *SQLMetricRegistry) all(ctx context.Context) ([]sqlMetric, error) {
+	metrics, err := r.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]sqlMetric, 0, len(metrics))
+	for _, m := range metrics {
+		res = append(res, m)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].name < res[j].name })
+	return res, nil
+}
+
+// load returns the registered SQL metrics, reloading them if they are older
+// than the refresh interval. The map is replaced on reload, never modified,
+// and the reload runs without the lock so it doesn't hold up the queries
+// while it waits for the database.
+func (r *SQLMetricRegistry) load(ctx context.Context) (map[string]sqlMetric, error) {
+	r.mu.Lock()
+	metrics, refreshed := r.metrics, r.refreshed
+	r.mu.Unlock()
+	if metrics != nil && time.Since(refreshed) < r.refreshInterval {
+		return metrics, nil
+	}
+
+	metrics, err := loadSQLMetrics(ctx, r.conn)
+	if err != nil {
+		return nil, fmt.Errorf("loading SQL metrics: %w", err)
+	}
+	r.mu.Lock()
+	r.metrics = metrics
+	r.refreshed = time.Now()
+	r.mu.Unlock()
+	return metrics, nil
+}
+
+func loadSQLMetrics(ctx context.Context, conn pgxconn.PgxConn) (map[string]sqlMetric, error) {
+	metrics := make(map[string]sqlMetric)
+	rows, err := conn.Query(ctx, getSQLMetricsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m sqlMetric
+		if err = rows.Scan(&m.name, &m.tableSchema, &m.tableName, &m.timeColumn, &m.valueColumns, &m.labelColumns); err != nil {
+			return nil, err
+		}
+		metrics[m.name] = m
+	}
+	return metrics, rows.Err()
+}
+
+// fetchSQLMetricSamples returns the series of the SQL metric matching the
+// matchers of the metadata. The statement is added to explain if it is not nil.
+func fetchSQLMetricSamples(ctx context.Context, tools *queryTools, m sqlMetric, metadata *evalMetadata, explain *SelectorExplain) ([]sampleRow, error) {
+	shard, _ := ctx.Value(shardCtxKey{}).(Shard)
+	matchers := metadata.matchers
+	if tools.rAuth != nil {
+		// SQL metrics have no tenant label, the tenant matcher hides
+		// them unless it matches the empty value.
+		matchers = tools.rAuth.AppendTenantMatcher(matchers)
+	}
+	sqlQuery, values, column, ok := buildSQLMetricQuery(m, metadata.timeFilter, matchers, shard)
+	if !ok {
+		return nil, nil
+	}
+	explain.addStatement(ctx, tools.conn, ExplainStatement{Metric: m.name, SQL: sqlQuery, Params: values})
+
+	defer queryStatsFromContext(ctx).roundTrip(time.Now())
+	rows, err := tools.conn.Query(ctx, sqlQuery, values...)
+	if err != nil {
+		return nil, fmt.Errorf("querying SQL metric %s: %w", m.name, err)
+	}
+	defer rows.Close()
+
+	additional := labels.Labels{{Name: model.MetricNameLabelName, Value: m.name}}
+	if column != m.valueColumns[0] {
+		additional = append(additional, labels.Label{Name: model.ColumnNameLabelName, Value: column})
+	}
+	return appendSQLMetricRows(nil, rows, m.labelColumns, additional)
+}
+
+// sqlMetricSelection is the rows of a SQL metric matching a selector.
+type sqlMetricSelection struct {
+	labelsExpr string
+	timeCol    string
+	valueCol   string
+	// column is the value column selected by __column__.
+	column  string
+	clauses []string
+	values  []interface{}
+}
+
+// buildSQLMetricQuery returns the statement selecting the series of the SQL
+// metric, one row per distinct label columns values with the arrays of times
+// and values. It returns the value column selected by __column__ and false if
+// the matchers can't match any series.
+func buildSQLMetricQuery(m sqlMetric, filter timeFilter, matchers []*labels.Matcher, shard Shard) (string, []interface{}, string, bool) {
+	sel, ok := selectSQLMetric(m, filter, matchers, shard)
+	if !ok {
+		return "", nil, "", false
+	}
+	sqlQuery := fmt.Sprintf(`SELECT %s AS label_values,
+	array_agg(%s::timestamptz ORDER BY %s) AS time_array,
+	array_agg(%s::double precision ORDER BY %s) AS value_array
+FROM %s t
+WHERE %s
+GROUP BY 1`,
+		sel.labelsExpr, sel.timeCol, sel.timeCol, sel.valueCol, sel.timeCol,
+		pgx.Identifier{m.tableSchema, m.tableName}.Sanitize(),
+		strings.Join(sel.clauses, " AND "))
+	return sqlQuery, sel.values, sel.column, true
+}
+
+// buildSQLMetricLabelsQuery returns the statement selecting the distinct
+// label columns values of the series of the SQL metric, and false if the
+// matchers can't match any series.
+func buildSQLMetricLabelsQuery(m sqlMetric, filter timeFilter, matchers []*labels.Matcher) (string, []interface{}, string, bool) {
+	sel, ok := selectSQLMetric(m, filter, matchers, Shard{})
+	if !ok {
+		return "", nil, "", false
+	}
+	sqlQuery := fmt.Sprintf(sqlMetricLabelsSQLFormat,
+		sel.labelsExpr,
+		pgx.Identifier{m.tableSchema, m.tableName}.Sanitize(),
+		strings.Join(sel.clauses, " AND "))
+	return sqlQuery, sel.values, sel.column, true
+}
+
+// selectSQLMetric returns the rows of the SQL metric matching the matchers in
+// the time range, and false if the matchers can't match any series.
+func selectSQLMetric(m sqlMetric, filter timeFilter, matchers []*labels.Matcher, shard Shard) (sqlMetricSelection, bool) {
+	// The column of the time filter defaults to the value column of the
+	// metric tables, the first value column is the default here.
+	column := m.valueColumns[0]
+	for _, matcher := range matchers {
+		if matcher.Name == model.ColumnNameLabelName && matcher.Type == labels.MatchEqual {
+			column = matcher.Value
+			break
+		}
+	}
+	found := false
+	for _, c := range m.valueColumns {
+		found = found || c == column
+	}
+	if !found {
+		return sqlMetricSelection{}, false
+	}
+
+	isLabelColumn := make(map[string]bool, len(m.labelColumns))
+	labelExprs := make([]string, 0, len(m.labelColumns))
+	for _, c := range m.labelColumns {
+		isLabelColumn[c] = true
+		labelExprs = append(labelExprs, fmt.Sprintf("t.%s::text", pgx.Identifier{c}.Sanitize()))
+	}
+	labelsExpr := "ARRAY[" + strings.Join(labelExprs, ", ") + "]::text[]"
+
+	var (
+		timeCol  = "t." + pgx.Identifier{m.timeColumn}.Sanitize()
+		valueCol = "t." + pgx.Identifier{column}.Sanitize()
+		values   = []interface{}{filter.start, filter.end}
+		clauses  = []string{
+			timeCol + " >= $1::timestamptz",
+			timeCol + " <= $2::timestamptz",
+			valueCol + " IS NOT NULL",
+		}
+	)
+	for _, matcher := range matchers {
+		if !isLabelColumn[matcher.Name] {
+			// The metric name, __column__ and the labels which aren't a
+			// column have the same value for all the series.
+			value := ""
+			switch matcher.Name {
+			case model.MetricNameLabelName:
+				value = m.name
+			case model.ColumnNameLabelName:
+				// Only equality matchers are supported, the column they
+				// select is the value of the label.
+				value = column
+			}
+			if !matcher.Matches(value) {
+				return sqlMetricSelection{}, false
+			}
+			continue
+		}
+		// Like a missing label, a NULL label column matches the empty value.
+		labelValue := fmt.Sprintf("COALESCE(t.%s::text, '')", pgx.Identifier{matcher.Name}.Sanitize())
+		switch matcher.Type {
+		case labels.MatchEqual:
+			values = append(values, matcher.Value)
+			clauses = append(clauses, fmt.Sprintf("%s = $%d", labelValue, len(values)))
+		case labels.MatchNotEqual:
+			values = append(values, matcher.Value)
+			clauses = append(clauses, fmt.Sprintf("%s <> $%d", labelValue, len(values)))
+		case labels.MatchRegexp, labels.MatchNotRegexp:
+			values = append(values, anchorValue(matcher.Value))
+			clause := fmt.Sprintf("%s ~ $%d", labelValue, len(values))
+			if re2Regex.MatchString(matcher.Value) {
+				clause = fmt.Sprintf("_prom_ext.re2_match(%s, $%d)", labelValue, len(values))
+			}
+			if matcher.Type == labels.MatchNotRegexp {
+				clause = "NOT " + clause
+			}
+			clauses = append(clauses, clause)
+		}
+	}
+	if shard.Count > 1 {
+		// There are no series IDs, the series are sharded by the hash of
+		// their labels instead.
+		values = append(values, int64(shard.Count), int64(shard.Index))
+		clauses = append(clauses, fmt.Sprintf("((hashtext(%s::text)::bigint %% $%d) + $%d) %% $%d = $%d",
+			labelsExpr, len(values)-1, len(values)-1, len(values)-1, len(values)))
+	}
+
+	return sqlMetricSelection{
+		labelsExpr: labelsExpr,
+		timeCol:    timeCol,
+		valueCol:   valueCol,
+		column:     column,
+		clauses:    clauses,
+		values:     values,
+	}, true
+}
+
+// appendSQLMetricRows adds the rows of a SQL metric query to the result rows,
+// with the labels of the label columns and the additional labels.
+func appendSQLMetricRows(out []sampleRow, in pgxconn.PgxRows, labelColumns []string, additional labels.Labels) ([]sampleRow, error) {
+	for in.Next() {
+		var (
+			row         sampleRow
+			labelValues []pgtype.Text
+		)
+		times := tPool.Get().(*model.ReusableArray[pgtype.Timestamptz])
+		if times.FlatArray != nil {
+			times.FlatArray = times.FlatArray[:0]
+		}
+		values := fPool.Get().(*model.ReusableArray[pgtype.Float8])
+		if values.FlatArray != nil {
+			values.FlatArray = values.FlatArray[:0]
+		}
+		row.err = in.Scan(&labelValues, times, values)
+		row.timeArrayOwnership = times
+		row.times = newRowTimestampSeries(times)
+		row.values = values
+
+		ll := make(labels.Labels, 0, len(labelValues)+len(additional))
+		ll = append(ll, additional...)
+		for i, v := range labelValues {
+			if v.Valid && v.String != "" {
+				ll = append(ll, labels.Label{Name: labelColumns[i], Value: v.String})
+			}
+		}
+		sort.Sort(ll)
+		row.labels = ll
+
+		out = append(out, row)
+		if row.err != nil {
+			log.Error("err", row.err)
+			return out, row.err
+		}
+	}
+	return out, in.Err()
+}
+
+// addSQLMetricLabels adds to the sorted label names, or the values of the
+// label if name is set, those of the series of the SQL metrics matching the
+// matchers with samples in the time range.
+func addSQLMetricLabels(ctx context.Context, registry *SQLMetricRegistry, res []string, name string, mint, maxt int64, ms []*labels.Matcher) ([]string, error) {
+	metrics, err := registry.all(ctx)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]struct{}, len(res))
+	for _, v := range res {
+		found[v] = struct{}{}
+	}
+	add := func(n, v string) {
+		switch {
+		case name == "":
+			found[n] = struct{}{}
+		case n == name:
+			found[v] = struct{}{}
+		}
+	}
+	filter := timeFilter{start: toRFC3339Nano(mint), end: toRFC3339Nano(maxt)}
+	for _, m := range metrics {
+		sqlQuery, values, column, ok := buildSQLMetricLabelsQuery(m, filter, ms)
+		if !ok {
+			continue
+		}
+		rows, err := querySQLMetricLabelValues(ctx, registry.conn, sqlQuery, values)
+		if err != nil {
+			return nil, fmt.Errorf("querying SQL metric %s: %w", m.name, err)
+		}
+		for _, labelValues := range rows {
+			add(model.MetricNameLabelName, m.name)
+			if column != m.valueColumns[0] {
+				add(model.ColumnNameLabelName, column)
+			}
+			for i, v := range labelValues {
+				if v.Valid && v.String != "" {
+					add(m.labelColumns[i], v.String)
+				}
+			}
+		}
+	}
+	if len(found) == len(res) {
+		return res, nil
+	}
+	res = make([]string, 0, len(found))
+	for v := range found {
+		res = append(res, v)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+func querySQLMetricLabelValues(ctx context.Context, conn pgxconn.PgxConn, sqlQuery string, values []interface{}) ([][]pgtype.Text, error) {
+	rows, err := conn.Query(ctx, sqlQuery, values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res [][]pgtype.Text
+	for rows.Next() {
+		var labelValues []pgtype.Text
+		if err = rows.Scan(&labelValues); err != nil {
+			return nil, err
+		}
+		res = append(res, labelValues)
+	}
+	return res, rows.Err()
+}
+
+// sqlMetricLabelsReader adds the labels of the SQL metrics to all the label
+// names and values of the series.
+type sqlMetricLabelsReader struct {
+	lreader.LabelsReader
+	registry *SQLMetricRegistry
+	rAuth    tenancy.ReadAuthorizer
+}
+
+// NewSQLMetricLabelsReader returns a labels reader adding the labels of the
+// SQL metrics of the registry to the label names and values of lr.
+func NewSQLMetricLabelsReader(lr lreader.LabelsReader, registry *SQLMetricRegistry, rAuth tenancy.ReadAuthorizer) lreader.LabelsReader {
+	return &sqlMetricLabelsReader{LabelsReader: lr, registry: registry, rAuth: rAuth}
+}
+
+func (r *sqlMetricLabelsReader) LabelNames() ([]string, error) {
+	names, err := r.LabelsReader.LabelNames()
+	if err != nil {
+		return nil, err
+	}
+	return r.addSQLMetricLabels(names, "")
+}
+
+func (r *sqlMetricLabelsReader) LabelValues(labelName string) ([]string, error) {
+	values, err := r.LabelsReader.LabelValues(labelName)
+	if err != nil {
+		return nil, err
+	}
+	return r.addSQLMetricLabels(values, labelName)
+}
+
+func (r *sqlMetricLabelsReader) addSQLMetricLabels(res []string, name string) ([]string, error) {
+	var ms []*labels.Matcher
+	if r.rAuth != nil {
+		ms = r.rAuth.AppendTenantMatcher(ms)
+	}
+	return addSQLMetricLabels(context.Background(), r.registry, res, name, minTime, maxTime, ms)
+}
@@ -0,0 +1,156 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package querier
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+)
+
+func TestBuildSQLMetricQuery(t *testing.T) {
+	m := sqlMetric{
+		name:         "orders",
+		tableSchema:  "shop",
+		tableName:    "orders",
+		timeColumn:   "created_at",
+		valueColumns: []string{"amount", "items"},
+		labelColumns: []string{"region", "channel"},
+	}
+	filter := timeFilter{start: "start", end: "end"}
+	nameMatcher := labels.MustNewMatcher(labels.MatchEqual, model.MetricNameLabelName, "orders")
+
+	testCases := []struct {
+		name         string
+		matchers     []*labels.Matcher
+		shard        Shard
+		expectSQL    string
+		expectValues []interface{}
+		expectColumn string
+		expectNone   bool
+	}{
+		{
+			name:     "default value column",
+			matchers: []*labels.Matcher{nameMatcher},
+			expectSQL: `SELECT ARRAY[t."region"::text, t."channel"::text]::text[] AS label_values,
+	array_agg(t."created_at"::timestamptz ORDER BY t."created_at") AS time_array,
+	array_agg(t."amount"::double precision ORDER BY t."created_at") AS value_array
+FROM "shop"."orders" t
+WHERE t."created_at" >= $1::timestamptz AND t."created_at" <= $2::timestamptz AND t."amount" IS NOT NULL
+GROUP BY 1`,
+			expectValues: []interface{}{"start", "end"},
+			expectColumn: "amount",
+		},
+		{
+			name: "label matchers",
+			matchers: []*labels.Matcher{
+				nameMatcher,
+				labels.MustNewMatcher(labels.MatchEqual, model.ColumnNameLabelName, "items"),
+				labels.MustNewMatcher(labels.MatchEqual, "region", "eu"),
+				labels.MustNewMatcher(labels.MatchNotEqual, "channel", ""),
+				labels.MustNewMatcher(labels.MatchRegexp, "region", "e.*"),
+				labels.MustNewMatcher(labels.MatchNotRegexp, "channel", "(?i)web"),
+				labels.MustNewMatcher(labels.MatchEqual, "job", ""),
+			},
+			expectSQL: `SELECT ARRAY[t."region"::text, t."channel"::text]::text[] AS label_values,
+	array_agg(t."created_at"::timestamptz ORDER BY t."created_at") AS time_array,
+	array_agg(t."items"::double precision ORDER BY t."created_at") AS value_array
+FROM "shop"."orders" t
+WHERE t."created_at" >= $1::timestamptz AND t."created_at" <= $2::timestamptz AND t."items" IS NOT NULL AND ` +
+				`COALESCE(t."region"::text, '') = $3 AND COALESCE(t."channel"::text, '') <> $4 AND ` +
+				`COALESCE(t."region"::text, '') ~ $5 AND NOT _prom_ext.re2_match(COALESCE(t."channel"::text, ''), $6)
+GROUP BY 1`,
+			expectValues: []interface{}{"start", "end", "eu", "", "^(?:e.*)$", "^(?:(?i)web)$"},
+			expectColumn: "items",
+		},
+		{
+			name: "sharded",
+			matchers: []*labels.Matcher{
+				nameMatcher,
+				labels.MustNewMatcher(labels.MatchEqual, "region", "eu"),
+			},
+			shard: Shard{Index: 1, Count: 3},
+			expectSQL: `SELECT ARRAY[t."region"::text, t."channel"::text]::text[] AS label_values,
+	array_agg(t."created_at"::timestamptz ORDER BY t."created_at") AS time_array,
+	array_agg(t."amount"::double precision ORDER BY t."created_at") AS value_array
+FROM "shop"."orders" t
+WHERE t."created_at" >= $1::timestamptz AND t."created_at" <= $2::timestamptz AND t."amount" IS NOT NULL AND ` +
+				`COALESCE(t."region"::text, '') = $3 AND ` +
+				`((hashtext(ARRAY[t."region"::text, t."channel"::text]::text[]::text)::bigint % $4) + $4) % $4 = $5
+GROUP BY 1`,
+			expectValues: []interface{}{"start", "end", "eu", int64(3), int64(1)},
+			expectColumn: "amount",
+		},
+		{
+			name: "unknown value column",
+			matchers: []*labels.Matcher{
+				nameMatcher,
+				labels.MustNewMatcher(labels.MatchEqual, model.ColumnNameLabelName, "region"),
+			},
+			expectNone: true,
+		},
+		{
+			name: "contradicting value columns",
+			matchers: []*labels.Matcher{
+				nameMatcher,
+				labels.MustNewMatcher(labels.MatchEqual, model.ColumnNameLabelName, "amount"),
+				labels.MustNewMatcher(labels.MatchEqual, model.ColumnNameLabelName, "items"),
+			},
+			expectNone: true,
+		},
+		{
+			name: "label without column",
+			matchers: []*labels.Matcher{
+				nameMatcher,
+				labels.MustNewMatcher(labels.MatchEqual, "job", "shop"),
+			},
+			expectNone: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sql, values, column, ok := buildSQLMetricQuery(m, filter, tc.matchers, tc.shard)
+			if tc.expectNone {
+				require.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			require.Equal(t, tc.expectSQL, sql)
+			require.Equal(t, tc.expectValues, values)
+			require.Equal(t, tc.expectColumn, column)
+		})
+	}
+}
+
+func TestBuildSQLMetricLabelsQuery(t *testing.T) {
+	m := sqlMetric{
+		name:         "orders",
+		tableSchema:  "shop",
+		tableName:    "orders",
+		timeColumn:   "created_at",
+		valueColumns: []string{"amount", "items"},
+		labelColumns: []string{"region", "channel"},
+	}
+	filter := timeFilter{start: "start", end: "end"}
+
+	sql, values, column, ok := buildSQLMetricLabelsQuery(m, filter, []*labels.Matcher{
+		labels.MustNewMatcher(labels.MatchRegexp, model.MetricNameLabelName, "ord.*"),
+		labels.MustNewMatcher(labels.MatchEqual, "region", "eu"),
+	})
+	require.True(t, ok)
+	require.Equal(t, `SELECT DISTINCT ARRAY[t."region"::text, t."channel"::text]::text[] AS label_values
+FROM "shop"."orders" t
+WHERE t."created_at" >= $1::timestamptz AND t."created_at" <= $2::timestamptz AND t."amount" IS NOT NULL AND `+
+		`COALESCE(t."region"::text, '') = $3`, sql)
+	require.Equal(t, []interface{}{"start", "end", "eu"}, values)
+	require.Equal(t, "amount", column)
+
+	_, _, _, ok = buildSQLMetricLabelsQuery(m, filter, []*labels.Matcher{
+		labels.MustNewMatcher(labels.MatchEqual, model.MetricNameLabelName, "payments"),
+	})
+	require.False(t, ok)
+}
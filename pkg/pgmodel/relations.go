@@ -0,0 +1,27 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package pgmodel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+// MissingRelations returns the relations, qualified with their schema, which
+// don't exist in the database. Features relying on database objects created
+// by the Promscale extension use it to check the installed extension provides
+// them before being enabled.
+func MissingRelations(ctx context.Context, conn pgxconn.PgxConn, relations ...string) ([]string, error) {
+	var missing []string
+	err := conn.QueryRow(ctx,
+		"SELECT coalesce(array_agg(r ORDER BY r), '{}') FROM unnest($1::text[]) r WHERE to_regclass(r) IS NULL",
+		relations).Scan(&missing)
+	if err != nil {
+		return nil, fmt.Errorf("checking relations: %w", err)
+	}
+	return missing, nil
+}
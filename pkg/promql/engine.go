@@ -40,11 +40,11 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/timestamp"
 	"github.com/prometheus/prometheus/model/value"
-	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/util/stats"
 
 	pgquerier "github.com/timescale/promscale/pkg/pgmodel/querier"
+	"github.com/timescale/promscale/pkg/promql/parser"
 	"github.com/timescale/promscale/pkg/util"
 )
 
@@ -61,10 +61,14 @@ type Queryable interface {
 // SamplesQuerier provides querying access over time series data of a fixed time range.
 type SamplesQuerier interface {
 	// LabelValues returns all potential values for a label name.
+	// If matchers are specified the returned result set is reduced
+	// to label values of metrics matching the matchers.
 	// It is not safe to use the strings beyond the lifefime of the querier.
-	LabelValues(name string) ([]string, storage.Warnings, error)
+	LabelValues(name string, matchers ...*labels.Matcher) ([]string, storage.Warnings, error)
 
 	// LabelNames returns all the unique label names present in the block in sorted order.
+	// If matchers are specified the returned result set is reduced
+	// to label names of metrics matching the matchers.
 	LabelNames(...*labels.Matcher) ([]string, storage.Warnings, error)
 
 	// Close releases the resources of the Querier.
@@ -462,6 +466,7 @@ func (ng *Engine) newQuery(q Queryable, opts *QueryOpts, expr parser.Expr, start
 	if err := ng.validateOpts(expr); err != nil {
 		return nil, err
 	}
+	expandInfoCalls(expr)
 
 	// Default to empty QueryOpts if not provided.
 	if opts == nil {
@@ -2363,8 +2368,11 @@ type groupedAggregation struct {
 func (ev *evaluator) aggregation(op parser.ItemType, grouping []string, without bool, param interface{}, vec Vector, seriesHelper []EvalSeriesHelper, enh *EvalNodeHelper) Vector {
 	result := map[uint64]*groupedAggregation{}
 	orderedResult := []*groupedAggregation{}
+	if op == parser.LIMIT_RATIO {
+		return limitRatio(param.(float64), vec, enh)
+	}
 	var k int64
-	if op == parser.TOPK || op == parser.BOTTOMK {
+	if op == parser.TOPK || op == parser.BOTTOMK || op == parser.LIMITK {
 		f := param.(float64)
 		if !convertibleToInt64(f) {
 			ev.errorf("Scalar value %v overflows int64", f)
@@ -2450,7 +2458,7 @@ func (ev *evaluator) aggregation(op parser.ItemType, grouping []string, without
 			switch op {
 			case parser.STDVAR, parser.STDDEV:
 				result[groupingKey].value = 0
-			case parser.TOPK, parser.QUANTILE:
+			case parser.TOPK, parser.QUANTILE, parser.LIMITK:
 				result[groupingKey].heap = make(vectorByValueHeap, 1, resultSize)
 				result[groupingKey].heap[0] = Sample{
 					Point:  Point{V: s.V},
@@ -2552,7 +2560,7 @@ func (ev *evaluator) aggregation(op parser.ItemType, grouping []string, without
 				})
 			}
 
-		case parser.QUANTILE:
+		case parser.QUANTILE, parser.LIMITK:
 			group.heap = append(group.heap, s)
 
 		default:
@@ -2601,6 +2609,23 @@ func (ev *evaluator) aggregation(op parser.ItemType, grouping []string, without
 			}
 			continue // Bypass default append.
 
+		case parser.LIMITK:
+			// The samples are picked by their label sets, so that the same
+			// series are returned at every step.
+			sort.Slice(aggr.heap, func(i, j int) bool {
+				return labels.Compare(aggr.heap[i].Metric, aggr.heap[j].Metric) < 0
+			})
+			if int64(len(aggr.heap)) > k {
+				aggr.heap = aggr.heap[:k]
+			}
+			for _, v := range aggr.heap {
+				enh.Out = append(enh.Out, Sample{
+					Metric: v.Metric,
+					Point:  Point{V: v.V},
+				})
+			}
+			continue // Bypass default append.
+
 		case parser.QUANTILE:
 			aggr.value = quantile(q, aggr.heap)
 
@@ -2616,6 +2641,25 @@ func (ev *evaluator) aggregation(op parser.ItemType, grouping []string, without
 	return enh.Out
 }
 
+// limitRatio keeps the samples whose series hash, as a fraction of the hash
+// space, is below the ratio, so that the same series are kept at every step.
+// The ratio is clamped to [-1, 1], a negative one keeps the complement of the
+// series kept by its absolute value. The grouping doesn't change the samples
+// kept.
+func limitRatio(r float64, vec Vector, enh *EvalNodeHelper) Vector {
+	if math.IsNaN(r) {
+		return enh.Out
+	}
+	r = math.Max(-1, math.Min(1, r))
+	for _, s := range vec {
+		offset := float64(s.Metric.Hash()) / float64(math.MaxUint64)
+		if (r >= 0 && offset < r) || (r < 0 && offset >= 1+r) {
+			enh.Out = append(enh.Out, s)
+		}
+	}
+	return enh.Out
+}
+
 // groupingKey builds and returns the grouping key for the given metric and
 // grouping labels.
 func generateGroupingKey(metric labels.Labels, grouping []string, without bool, buf []byte) (uint64, []byte) {
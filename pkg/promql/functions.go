@@ -25,7 +25,7 @@ import (
 	"github.com/prometheus/common/model"
 
 	"github.com/prometheus/prometheus/model/labels"
-	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/timescale/promscale/pkg/promql/parser"
 )
 
 // FunctionCall is the type of a PromQL function implementation
@@ -284,6 +284,82 @@ func funcSortDesc(vals []parser.Value, args parser.Expressions, enh *EvalNodeHel
 	return Vector(byValueSorter)
 }
 
+// === sort_by_label(vector parser.ValueTypeVector, label parser.ValueTypeString...) Vector ===
+func funcSortByLabel(vals []parser.Value, args parser.Expressions, enh *EvalNodeHelper) Vector {
+	return sortByLabel(vals[0].(Vector), args[1:], false)
+}
+
+// === sort_by_label_desc(vector parser.ValueTypeVector, label parser.ValueTypeString...) Vector ===
+func funcSortByLabelDesc(vals []parser.Value, args parser.Expressions, enh *EvalNodeHelper) Vector {
+	return sortByLabel(vals[0].(Vector), args[1:], true)
+}
+
+// sortByLabel sorts the vector by the values of the labels in natural sort
+// order. Samples with equal values are sorted by their label sets, which
+// keeps the order stable.
+func sortByLabel(vec Vector, args parser.Expressions, desc bool) Vector {
+	names := make([]string, len(args))
+	for i, arg := range args {
+		names[i] = stringFromArg(arg)
+	}
+	sort.SliceStable(vec, func(i, j int) bool {
+		for _, name := range names {
+			a, b := vec[i].Metric.Get(name), vec[j].Metric.Get(name)
+			if a == b {
+				continue
+			}
+			if desc {
+				return naturalLess(b, a)
+			}
+			return naturalLess(a, b)
+		}
+		if desc {
+			return labels.Compare(vec[j].Metric, vec[i].Metric) < 0
+		}
+		return labels.Compare(vec[i].Metric, vec[j].Metric) < 0
+	})
+	return vec
+}
+
+// naturalLess compares the strings treating runs of digits as numbers, so
+// that "a2" sorts before "a10".
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		ca, cb := chunk(a), chunk(b)
+		a, b = a[len(ca):], b[len(cb):]
+		if ca == cb {
+			continue
+		}
+		if isDigit(ca[0]) && isDigit(cb[0]) {
+			na, nb := strings.TrimLeft(ca, "0"), strings.TrimLeft(cb, "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			// Equal numbers, the one with fewer leading zeros sorts first.
+			return len(ca) < len(cb)
+		}
+		return ca < cb
+	}
+	return len(a) < len(b)
+}
+
+// chunk returns the leading run of digits or non-digits of s.
+func chunk(s string) string {
+	digits := isDigit(s[0])
+	i := 1
+	for i < len(s) && isDigit(s[i]) == digits {
+		i++
+	}
+	return s[:i]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
 // === clamp(Vector parser.ValueTypeVector, min, max Scalar) Vector ===
 func funcClamp(vals []parser.Value, args parser.Expressions, enh *EvalNodeHelper) Vector {
 	vec := vals[0].(Vector)
@@ -473,6 +549,22 @@ func funcQuantileOverTime(vals []parser.Value, args parser.Expressions, enh *Eva
 	})
 }
 
+// === mad_over_time(Matrix parser.ValueTypeMatrix) Vector ===
+func funcMadOverTime(vals []parser.Value, args parser.Expressions, enh *EvalNodeHelper) Vector {
+	return aggrOverTime(vals, enh, func(points []Point) float64 {
+		values := make(vectorByValueHeap, 0, len(points))
+		for _, v := range points {
+			values = append(values, Sample{Point: Point{V: v.V}})
+		}
+		median := quantile(0.5, values)
+		values = values[:0]
+		for _, v := range points {
+			values = append(values, Sample{Point: Point{V: math.Abs(v.V - median)}})
+		}
+		return quantile(0.5, values)
+	})
+}
+
 // === stddev_over_time(Matrix parser.ValueTypeMatrix) Vector ===
 func funcStddevOverTime(vals []parser.Value, args parser.Expressions, enh *EvalNodeHelper) Vector {
 	return aggrOverTime(vals, enh, func(values []Point) float64 {
@@ -997,6 +1089,138 @@ func funcLabelJoin(vals []parser.Value, args parser.Expressions, enh *EvalNodeHe
 	return enh.Out
 }
 
+// infoIdentifyingLabels are the labels joining a series with its info series.
+var infoIdentifyingLabels = []string{"instance", "job"}
+
+// === info(vector parser.ValueTypeVector, [info parser.ValueTypeVector]) Vector ===
+//
+// The info series are selected by the second argument, which is set to the
+// target_info metric by expandInfoCalls if it does not select a metric name.
+func funcInfo(vals []parser.Value, args parser.Expressions, enh *EvalNodeHelper) Vector {
+	vec := vals[0].(Vector)
+	infoVec := vals[1].(Vector)
+
+	// Only the labels of the matchers are added if data label matchers are
+	// given, and series without info series are only kept if the matchers
+	// match an empty label.
+	var dataMatchers []*labels.Matcher
+	if vs, ok := infoSelector(args[1]); ok {
+		for _, m := range vs.LabelMatchers {
+			if m.Name != labels.MetricName {
+				dataMatchers = append(dataMatchers, m)
+			}
+		}
+	}
+
+	infoByID := make(map[string][]labels.Labels, len(infoVec))
+	seen := make(map[string]struct{}, len(infoVec))
+	for _, el := range infoVec {
+		id := infoID(el.Metric)
+		key := el.Metric.Get(labels.MetricName) + "\xff" + id
+		if _, ok := seen[key]; ok {
+			panic(fmt.Errorf("found duplicate series for info metric %s with identifying labels %s", el.Metric.Get(labels.MetricName), id))
+		}
+		seen[key] = struct{}{}
+		infoByID[id] = append(infoByID[id], el.Metric)
+	}
+
+	for _, el := range vec {
+		infos, ok := infoByID[infoID(el.Metric)]
+		if !ok {
+			if matchesEmpty(dataMatchers) {
+				enh.Out = append(enh.Out, el)
+			}
+			continue
+		}
+		lb := labels.NewBuilder(el.Metric)
+		for _, info := range infos {
+			for _, l := range info {
+				if l.Name == labels.MetricName || isInfoIdentifyingLabel(l.Name) || el.Metric.Has(l.Name) {
+					continue
+				}
+				if len(dataMatchers) > 0 && !hasMatcherFor(dataMatchers, l.Name) {
+					continue
+				}
+				lb.Set(l.Name, l.Value)
+			}
+		}
+		enh.Out = append(enh.Out, Sample{
+			Metric: lb.Labels(nil),
+			Point:  el.Point,
+		})
+	}
+	return enh.Out
+}
+
+func infoSelector(e parser.Expr) (*parser.VectorSelector, bool) {
+	tmp := unwrapStepInvariantExpr(e)
+	unwrapParenExpr(&tmp)
+	vs, ok := tmp.(*parser.VectorSelector)
+	return vs, ok
+}
+
+func infoID(l labels.Labels) string {
+	var b strings.Builder
+	for _, name := range infoIdentifyingLabels {
+		b.WriteString(l.Get(name))
+		b.WriteByte('\xff')
+	}
+	return b.String()
+}
+
+func isInfoIdentifyingLabel(name string) bool {
+	for _, n := range infoIdentifyingLabels {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasMatcherFor(ms []*labels.Matcher, name string) bool {
+	for _, m := range ms {
+		if m.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesEmpty(ms []*labels.Matcher) bool {
+	for _, m := range ms {
+		if !m.Matches("") {
+			return false
+		}
+	}
+	return true
+}
+
+// expandInfoCalls sets the info metric selected by the second argument of the
+// info function. It defaults to target_info if no metric name is selected.
+func expandInfoCalls(expr parser.Expr) {
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		call, ok := node.(*parser.Call)
+		if !ok || call.Func.Name != "info" {
+			return nil
+		}
+		if len(call.Args) == 1 {
+			call.Args = append(call.Args, &parser.VectorSelector{PosRange: call.PosRange})
+		}
+		vs, ok := infoSelector(call.Args[1])
+		if !ok {
+			return nil
+		}
+		for _, m := range vs.LabelMatchers {
+			if m.Name == labels.MetricName {
+				return nil
+			}
+		}
+		vs.Name = "target_info"
+		vs.LabelMatchers = append(vs.LabelMatchers, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, vs.Name))
+		return nil
+	})
+}
+
 // Common code for date related functions.
 func dateWrapper(vals []parser.Value, enh *EvalNodeHelper, f func(time.Time) float64) Vector {
 	if len(vals) == 0 {
@@ -1075,73 +1299,78 @@ func funcYear(vals []parser.Value, args parser.Expressions, enh *EvalNodeHelper)
 
 // FunctionCalls is a list of all functions supported by PromQL, including their types.
 var FunctionCalls = map[string]FunctionCall{
-	"abs":                funcAbs,
-	"absent":             funcAbsent,
-	"absent_over_time":   funcAbsentOverTime,
-	"acos":               funcAcos,
-	"acosh":              funcAcosh,
-	"asin":               funcAsin,
-	"asinh":              funcAsinh,
-	"atan":               funcAtan,
-	"atanh":              funcAtanh,
-	"avg_over_time":      funcAvgOverTime,
-	"ceil":               funcCeil,
-	"changes":            funcChanges,
-	"clamp":              funcClamp,
-	"clamp_max":          funcClampMax,
-	"clamp_min":          funcClampMin,
-	"cos":                funcCos,
-	"cosh":               funcCosh,
-	"count_over_time":    funcCountOverTime,
-	"days_in_month":      funcDaysInMonth,
-	"day_of_month":       funcDayOfMonth,
-	"day_of_week":        funcDayOfWeek,
-	"day_of_year":        funcDayOfYear,
-	"deg":                funcDeg,
-	"delta":              funcDelta,
-	"deriv":              funcDeriv,
-	"exp":                funcExp,
-	"floor":              funcFloor,
-	"histogram_quantile": funcHistogramQuantile,
-	"holt_winters":       funcHoltWinters,
-	"hour":               funcHour,
-	"idelta":             funcIdelta,
-	"increase":           funcIncrease,
-	"irate":              funcIrate,
-	"label_replace":      funcLabelReplace,
-	"label_join":         funcLabelJoin,
-	"ln":                 funcLn,
-	"log10":              funcLog10,
-	"log2":               funcLog2,
-	"last_over_time":     funcLastOverTime,
-	"max_over_time":      funcMaxOverTime,
-	"min_over_time":      funcMinOverTime,
-	"minute":             funcMinute,
-	"month":              funcMonth,
-	"pi":                 funcPi,
-	"predict_linear":     funcPredictLinear,
-	"present_over_time":  funcPresentOverTime,
-	"quantile_over_time": funcQuantileOverTime,
-	"rad":                funcRad,
-	"rate":               funcRate,
-	"resets":             funcResets,
-	"round":              funcRound,
-	"scalar":             funcScalar,
-	"sgn":                funcSgn,
-	"sin":                funcSin,
-	"sinh":               funcSinh,
-	"sort":               funcSort,
-	"sort_desc":          funcSortDesc,
-	"sqrt":               funcSqrt,
-	"stddev_over_time":   funcStddevOverTime,
-	"stdvar_over_time":   funcStdvarOverTime,
-	"sum_over_time":      funcSumOverTime,
-	"tan":                funcTan,
-	"tanh":               funcTanh,
-	"time":               funcTime,
-	"timestamp":          funcTimestamp,
-	"vector":             funcVector,
-	"year":               funcYear,
+	"abs":                          funcAbs,
+	"absent":                       funcAbsent,
+	"absent_over_time":             funcAbsentOverTime,
+	"acos":                         funcAcos,
+	"acosh":                        funcAcosh,
+	"asin":                         funcAsin,
+	"asinh":                        funcAsinh,
+	"atan":                         funcAtan,
+	"atanh":                        funcAtanh,
+	"avg_over_time":                funcAvgOverTime,
+	"ceil":                         funcCeil,
+	"changes":                      funcChanges,
+	"clamp":                        funcClamp,
+	"clamp_max":                    funcClampMax,
+	"clamp_min":                    funcClampMin,
+	"cos":                          funcCos,
+	"cosh":                         funcCosh,
+	"count_over_time":              funcCountOverTime,
+	"days_in_month":                funcDaysInMonth,
+	"day_of_month":                 funcDayOfMonth,
+	"day_of_week":                  funcDayOfWeek,
+	"day_of_year":                  funcDayOfYear,
+	"deg":                          funcDeg,
+	"delta":                        funcDelta,
+	"deriv":                        funcDeriv,
+	"double_exponential_smoothing": funcHoltWinters,
+	"exp":                          funcExp,
+	"floor":                        funcFloor,
+	"histogram_quantile":           funcHistogramQuantile,
+	"holt_winters":                 funcHoltWinters,
+	"hour":                         funcHour,
+	"idelta":                       funcIdelta,
+	"increase":                     funcIncrease,
+	"info":                         funcInfo,
+	"irate":                        funcIrate,
+	"label_replace":                funcLabelReplace,
+	"label_join":                   funcLabelJoin,
+	"ln":                           funcLn,
+	"log10":                        funcLog10,
+	"log2":                         funcLog2,
+	"last_over_time":               funcLastOverTime,
+	"mad_over_time":                funcMadOverTime,
+	"max_over_time":                funcMaxOverTime,
+	"min_over_time":                funcMinOverTime,
+	"minute":                       funcMinute,
+	"month":                        funcMonth,
+	"pi":                           funcPi,
+	"predict_linear":               funcPredictLinear,
+	"present_over_time":            funcPresentOverTime,
+	"quantile_over_time":           funcQuantileOverTime,
+	"rad":                          funcRad,
+	"rate":                         funcRate,
+	"resets":                       funcResets,
+	"round":                        funcRound,
+	"scalar":                       funcScalar,
+	"sgn":                          funcSgn,
+	"sin":                          funcSin,
+	"sinh":                         funcSinh,
+	"sort":                         funcSort,
+	"sort_desc":                    funcSortDesc,
+	"sort_by_label":                funcSortByLabel,
+	"sort_by_label_desc":           funcSortByLabelDesc,
+	"sqrt":                         funcSqrt,
+	"stddev_over_time":             funcStddevOverTime,
+	"stdvar_over_time":             funcStdvarOverTime,
+	"sum_over_time":                funcSumOverTime,
+	"tan":                          funcTan,
+	"tanh":                         funcTanh,
+	"time":                         funcTime,
+	"timestamp":                    funcTimestamp,
+	"vector":                       funcVector,
+	"year":                         funcYear,
 }
 
 // AtModifierUnsafeFunctions are the functions whose result
@@ -1157,6 +1386,8 @@ var AtModifierUnsafeFunctions = map[string]struct{}{
 	// Uses timestamp of the argument for the result,
 	// hence unsafe to use with @ modifier.
 	"timestamp": {},
+	// Looks up the info series at the evaluation time.
+	"info": {},
 }
 
 type vectorByValueHeap Vector
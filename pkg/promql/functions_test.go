@@ -23,7 +23,7 @@ import (
 
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/timestamp"
-	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/timescale/promscale/pkg/promql/parser"
 )
 
 func TestDeriv(t *testing.T) {
@@ -84,3 +84,24 @@ func TestKahanSum(t *testing.T) {
 	expected := 2.0
 	require.Equal(t, expected, kahanSum(vals))
 }
+
+func TestNaturalLess(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		less bool
+	}{
+		{"a", "b", true},
+		{"a2", "a10", true},
+		{"a10", "a2", false},
+		{"1.2.3", "1.11.3", true},
+		{"4m600", "4m1000", true},
+		{"a01", "a1", false},
+		{"a1", "a01", true},
+		{"a", "a1", true},
+		{"", "a", true},
+		{"a", "a", false},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.less, naturalLess(tc.a, tc.b), "naturalLess(%q, %q)", tc.a, tc.b)
+	}
+}
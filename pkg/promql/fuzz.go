@@ -21,7 +21,7 @@ import (
 	"io"
 
 	"github.com/prometheus/prometheus/model/textparse"
-	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/timescale/promscale/pkg/promql/parser"
 )
 
 // PromQL parser fuzzing instrumentation for use with
@@ -0,0 +1,23 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+// Package parser is a copy of the PromQL parser of
+// github.com/prometheus/prometheus/promql/parser at the version in go.mod,
+// extended with the functions and aggregators of newer Prometheus releases:
+//
+//   - functions.go has the double_exponential_smoothing, info,
+//     mad_over_time and sort_by_label(_desc) functions.
+//   - generated_parser.y has the limitk and limit_ratio aggregators, lex.go
+//     their keywords and parse.go checks their parameter like topk's.
+//
+// The copy is kept in sync with upstream when the Prometheus dependency is
+// bumped: the files of the new version replace these ones, the changes above
+// that upstream doesn't have yet are applied again, and the parser is
+// regenerated from the pkg directory with the goyacc version in go.mod:
+//
+//	go run golang.org/x/tools/cmd/goyacc -o promql/parser/generated_parser.y.go promql/parser/generated_parser.y
+//
+// Once upstream has all of them, the copy is removed in favour of the
+// upstream package.
+package parser
@@ -0,0 +1,1750 @@
+// Code generated by goyacc -o promql/parser/generated_parser.y.go promql/parser/generated_parser.y. DO NOT EDIT.
+
+//line promql/parser/generated_parser.y:15
+package parser
+
+import __yyfmt__ "fmt"
+
+//line promql/parser/generated_parser.y:15
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+)
+
+//line promql/parser/generated_parser.y:28
+type yySymType struct {
+	yys      int
+	node     Node
+	item     Item
+	matchers []*labels.Matcher
+	matcher  *labels.Matcher
+	label    labels.Label
+	labels   labels.Labels
+	strings  []string
+	series   []SequenceValue
+	uint     uint64
+	float    float64
+	duration time.Duration
+}
+
+const EQL = 57346
+const BLANK = 57347
+const COLON = 57348
+const COMMA = 57349
+const COMMENT = 57350
+const DURATION = 57351
+const EOF = 57352
+const ERROR = 57353
+const IDENTIFIER = 57354
+const LEFT_BRACE = 57355
+const LEFT_BRACKET = 57356
+const LEFT_PAREN = 57357
+const METRIC_IDENTIFIER = 57358
+const NUMBER = 57359
+const RIGHT_BRACE = 57360
+const RIGHT_BRACKET = 57361
+const RIGHT_PAREN = 57362
+const SEMICOLON = 57363
+const SPACE = 57364
+const STRING = 57365
+const TIMES = 57366
+const operatorsStart = 57367
+const ADD = 57368
+const DIV = 57369
+const EQLC = 57370
+const EQL_REGEX = 57371
+const GTE = 57372
+const GTR = 57373
+const LAND = 57374
+const LOR = 57375
+const LSS = 57376
+const LTE = 57377
+const LUNLESS = 57378
+const MOD = 57379
+const MUL = 57380
+const NEQ = 57381
+const NEQ_REGEX = 57382
+const POW = 57383
+const SUB = 57384
+const AT = 57385
+const ATAN2 = 57386
+const operatorsEnd = 57387
+const aggregatorsStart = 57388
+const AVG = 57389
+const BOTTOMK = 57390
+const COUNT = 57391
+const COUNT_VALUES = 57392
+const GROUP = 57393
+const MAX = 57394
+const MIN = 57395
+const QUANTILE = 57396
+const STDDEV = 57397
+const STDVAR = 57398
+const SUM = 57399
+const TOPK = 57400
+const LIMITK = 57401
+const LIMIT_RATIO = 57402
+const aggregatorsEnd = 57403
+const keywordsStart = 57404
+const BOOL = 57405
+const BY = 57406
+const GROUP_LEFT = 57407
+const GROUP_RIGHT = 57408
+const IGNORING = 57409
+const OFFSET = 57410
+const ON = 57411
+const WITHOUT = 57412
+const keywordsEnd = 57413
+const preprocessorStart = 57414
+const START = 57415
+const END = 57416
+const preprocessorEnd = 57417
+const startSymbolsStart = 57418
+const START_METRIC = 57419
+const START_SERIES_DESCRIPTION = 57420
+const START_EXPRESSION = 57421
+const START_METRIC_SELECTOR = 57422
+const startSymbolsEnd = 57423
+
+var yyToknames = [...]string{
+	"$end",
+	"error",
+	"$unk",
+	"EQL",
+	"BLANK",
+	"COLON",
+	"COMMA",
+	"COMMENT",
+	"DURATION",
+	"EOF",
+	"ERROR",
+	"IDENTIFIER",
+	"LEFT_BRACE",
+	"LEFT_BRACKET",
+	"LEFT_PAREN",
+	"METRIC_IDENTIFIER",
+	"NUMBER",
+	"RIGHT_BRACE",
+	"RIGHT_BRACKET",
+	"RIGHT_PAREN",
+	"SEMICOLON",
+	"SPACE",
+	"STRING",
+	"TIMES",
+	"operatorsStart",
+	"ADD",
+	"DIV",
+	"EQLC",
+	"EQL_REGEX",
+	"GTE",
+	"GTR",
+	"LAND",
+	"LOR",
+	"LSS",
+	"LTE",
+	"LUNLESS",
+	"MOD",
+	"MUL",
+	"NEQ",
+	"NEQ_REGEX",
+	"POW",
+	"SUB",
+	"AT",
+	"ATAN2",
+	"operatorsEnd",
+	"aggregatorsStart",
+	"AVG",
+	"BOTTOMK",
+	"COUNT",
+	"COUNT_VALUES",
+	"GROUP",
+	"MAX",
+	"MIN",
+	"QUANTILE",
+	"STDDEV",
+	"STDVAR",
+	"SUM",
+	"TOPK",
+	"LIMITK",
+	"LIMIT_RATIO",
+	"aggregatorsEnd",
+	"keywordsStart",
+	"BOOL",
+	"BY",
+	"GROUP_LEFT",
+	"GROUP_RIGHT",
+	"IGNORING",
+	"OFFSET",
+	"ON",
+	"WITHOUT",
+	"keywordsEnd",
+	"preprocessorStart",
+	"START",
+	"END",
+	"preprocessorEnd",
+	"startSymbolsStart",
+	"START_METRIC",
+	"START_SERIES_DESCRIPTION",
+	"START_EXPRESSION",
+	"START_METRIC_SELECTOR",
+	"startSymbolsEnd",
+}
+
+var yyStatenames = [...]string{}
+
+const yyEofCode = 1
+const yyErrCode = 2
+const yyInitialStackSize = 16
+
+//line promql/parser/generated_parser.y:751
+
+//line yacctab:1
+var yyExca = [...]int16{
+	-1, 1,
+	1, -1,
+	-2, 0,
+	-1, 37,
+	1, 133,
+	10, 133,
+	22, 133,
+	-2, 0,
+	-1, 60,
+	2, 145,
+	15, 145,
+	64, 145,
+	70, 145,
+	-2, 97,
+	-1, 61,
+	2, 146,
+	15, 146,
+	64, 146,
+	70, 146,
+	-2, 98,
+	-1, 62,
+	2, 147,
+	15, 147,
+	64, 147,
+	70, 147,
+	-2, 100,
+	-1, 63,
+	2, 148,
+	15, 148,
+	64, 148,
+	70, 148,
+	-2, 101,
+	-1, 64,
+	2, 149,
+	15, 149,
+	64, 149,
+	70, 149,
+	-2, 102,
+	-1, 65,
+	2, 150,
+	15, 150,
+	64, 150,
+	70, 150,
+	-2, 107,
+	-1, 66,
+	2, 151,
+	15, 151,
+	64, 151,
+	70, 151,
+	-2, 109,
+	-1, 67,
+	2, 152,
+	15, 152,
+	64, 152,
+	70, 152,
+	-2, 111,
+	-1, 68,
+	2, 153,
+	15, 153,
+	64, 153,
+	70, 153,
+	-2, 112,
+	-1, 69,
+	2, 154,
+	15, 154,
+	64, 154,
+	70, 154,
+	-2, 113,
+	-1, 70,
+	2, 155,
+	15, 155,
+	64, 155,
+	70, 155,
+	-2, 114,
+	-1, 71,
+	2, 156,
+	15, 156,
+	64, 156,
+	70, 156,
+	-2, 115,
+	-1, 72,
+	2, 157,
+	15, 157,
+	64, 157,
+	70, 157,
+	-2, 119,
+	-1, 73,
+	2, 158,
+	15, 158,
+	64, 158,
+	70, 158,
+	-2, 120,
+	-1, 194,
+	12, 205,
+	13, 205,
+	16, 205,
+	17, 205,
+	23, 205,
+	26, 205,
+	32, 205,
+	33, 205,
+	36, 205,
+	42, 205,
+	47, 205,
+	48, 205,
+	49, 205,
+	50, 205,
+	51, 205,
+	52, 205,
+	53, 205,
+	54, 205,
+	55, 205,
+	56, 205,
+	57, 205,
+	58, 205,
+	59, 205,
+	60, 205,
+	64, 205,
+	68, 205,
+	70, 205,
+	73, 205,
+	74, 205,
+	-2, 0,
+	-1, 195,
+	12, 205,
+	13, 205,
+	16, 205,
+	17, 205,
+	23, 205,
+	26, 205,
+	32, 205,
+	33, 205,
+	36, 205,
+	42, 205,
+	47, 205,
+	48, 205,
+	49, 205,
+	50, 205,
+	51, 205,
+	52, 205,
+	53, 205,
+	54, 205,
+	55, 205,
+	56, 205,
+	57, 205,
+	58, 205,
+	59, 205,
+	60, 205,
+	64, 205,
+	68, 205,
+	70, 205,
+	73, 205,
+	74, 205,
+	-2, 0,
+	-1, 216,
+	19, 203,
+	-2, 0,
+	-1, 268,
+	19, 204,
+	-2, 0,
+}
+
+const yyPrivate = 57344
+
+const yyLast = 698
+
+var yyAct = [...]int16{
+	274, 39, 220, 146, 264, 263, 154, 117, 81, 106,
+	105, 108, 192, 277, 193, 194, 195, 109, 6, 130,
+	222, 258, 125, 153, 186, 259, 104, 158, 257, 59,
+	232, 188, 275, 266, 238, 107, 157, 184, 280, 148,
+	278, 157, 272, 159, 212, 158, 149, 271, 110, 256,
+	234, 235, 76, 100, 236, 103, 110, 112, 183, 113,
+	270, 159, 249, 111, 126, 223, 225, 227, 228, 229,
+	237, 239, 242, 243, 244, 245, 246, 250, 251, 147,
+	102, 224, 226, 230, 231, 233, 240, 241, 114, 253,
+	119, 247, 248, 2, 3, 4, 5, 108, 35, 7,
+	118, 217, 252, 109, 149, 216, 254, 279, 160, 83,
+	119, 174, 170, 164, 167, 162, 173, 163, 215, 82,
+	118, 124, 269, 123, 161, 181, 116, 172, 191, 149,
+	182, 149, 190, 196, 197, 198, 199, 200, 201, 202,
+	203, 204, 205, 206, 207, 208, 209, 210, 85, 189,
+	36, 211, 131, 132, 133, 134, 135, 136, 137, 138,
+	139, 140, 141, 142, 143, 144, 145, 166, 122, 83,
+	8, 1, 267, 121, 37, 152, 176, 222, 177, 82,
+	165, 255, 213, 214, 120, 80, 10, 232, 49, 58,
+	157, 238, 9, 9, 260, 219, 78, 261, 262, 158,
+	50, 265, 48, 179, 47, 46, 77, 234, 235, 129,
+	45, 236, 44, 178, 180, 159, 43, 127, 168, 249,
+	268, 42, 223, 225, 227, 228, 229, 237, 239, 242,
+	243, 244, 245, 246, 250, 251, 128, 41, 224, 226,
+	230, 231, 233, 240, 241, 40, 155, 156, 247, 248,
+	51, 150, 187, 84, 185, 273, 218, 79, 151, 57,
+	276, 53, 76, 221, 55, 22, 54, 175, 52, 169,
+	115, 0, 56, 0, 281, 74, 0, 0, 282, 0,
+	0, 18, 19, 0, 0, 20, 0, 0, 0, 0,
+	0, 75, 0, 0, 0, 0, 60, 61, 62, 63,
+	64, 65, 66, 67, 68, 69, 70, 71, 72, 73,
+	0, 0, 0, 13, 0, 0, 0, 24, 0, 30,
+	0, 0, 31, 32, 38, 104, 53, 76, 0, 55,
+	22, 54, 0, 0, 0, 0, 0, 56, 88, 0,
+	74, 0, 0, 0, 0, 0, 18, 19, 97, 98,
+	20, 0, 100, 0, 103, 87, 75, 0, 0, 0,
+	0, 60, 61, 62, 63, 64, 65, 66, 67, 68,
+	69, 70, 71, 72, 73, 0, 0, 0, 13, 102,
+	0, 0, 24, 0, 30, 0, 0, 31, 32, 53,
+	76, 0, 55, 22, 54, 0, 0, 0, 0, 0,
+	56, 0, 0, 74, 0, 0, 0, 0, 0, 18,
+	19, 0, 0, 20, 0, 0, 0, 0, 0, 75,
+	0, 0, 0, 0, 60, 61, 62, 63, 64, 65,
+	66, 67, 68, 69, 70, 71, 72, 73, 17, 76,
+	0, 13, 22, 0, 0, 24, 0, 30, 0, 0,
+	31, 32, 0, 0, 0, 0, 0, 0, 18, 19,
+	0, 0, 20, 0, 0, 0, 0, 17, 35, 0,
+	0, 22, 0, 11, 12, 14, 15, 16, 21, 23,
+	25, 26, 27, 28, 29, 33, 34, 18, 19, 0,
+	13, 20, 0, 0, 24, 0, 30, 0, 0, 31,
+	32, 0, 11, 12, 14, 15, 16, 21, 23, 25,
+	26, 27, 28, 29, 33, 34, 0, 0, 104, 13,
+	0, 0, 0, 24, 171, 30, 0, 0, 31, 32,
+	86, 88, 89, 0, 90, 91, 92, 93, 94, 95,
+	96, 97, 98, 99, 0, 100, 101, 103, 87, 0,
+	0, 0, 0, 0, 0, 0, 0, 104, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 86,
+	88, 89, 102, 90, 91, 92, 93, 94, 95, 96,
+	97, 98, 99, 0, 100, 101, 103, 87, 0, 0,
+	0, 0, 104, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 86, 88, 89, 0, 90, 91,
+	92, 102, 94, 95, 96, 97, 98, 99, 0, 100,
+	101, 103, 87, 0, 104, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 86, 88, 89, 0,
+	90, 91, 0, 104, 94, 95, 102, 97, 98, 99,
+	0, 100, 101, 103, 87, 86, 88, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 97, 98, 0, 0,
+	100, 101, 103, 87, 0, 0, 0, 0, 102, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 102,
+}
+
+var yyPact = [...]int16{
+	16, 89, 455, 455, 314, 426, -1000, -1000, -1000, 85,
+	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
+	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
+	-1000, -1000, -1000, -1000, -1000, 167, -1000, 146, -1000, 543,
+	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
+	-1000, -1000, 33, 41, -1000, 377, -1000, 377, 39, -1000,
+	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
+	-1000, -1000, -1000, -1000, -1000, -1000, 108, -1000, -1000, 166,
+	-1000, -1000, 119, -1000, 0, -1000, -44, -44, -44, -44,
+	-44, -44, -44, -44, -44, -44, -44, -44, -44, -44,
+	-44, -44, 37, 173, 122, 41, -53, -1000, 165, 165,
+	249, -1000, 504, 12, -1000, 109, -1000, -1000, 174, -1000,
+	-1000, 107, -1000, 35, -1000, 19, 377, -1000, -55, -50,
+	-1000, 377, 377, 377, 377, 377, 377, 377, 377, 377,
+	377, 377, 377, 377, 377, 377, -1000, 95, -1000, -1000,
+	-1000, 29, -1000, -1000, -1000, -1000, -1000, -1000, 24, 24,
+	99, -1000, -1000, -1000, -1000, 175, -1000, -1000, 82, -1000,
+	543, -1000, -1000, 88, -1000, 26, -1000, -1000, -1000, -1000,
+	-1000, -1000, -1000, -1000, -1000, -1000, -3, 1, -1000, -1000,
+	-1000, 311, 165, 165, 165, 165, 12, 12, 629, 629,
+	629, 610, 578, 629, 629, 610, 12, 12, 629, 12,
+	311, -1000, 13, -1000, -1000, -1000, 120, -1000, 40, -1000,
+	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
+	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
+	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
+	-1000, -1000, -1000, 377, -1000, -1000, -1000, -1000, 15, 15,
+	-11, -1000, -1000, -1000, -1000, -1000, -1000, 21, 105, -1000,
+	-1000, 18, -1000, 543, -1000, -1000, -1000, 15, -1000, -1000,
+	-1000, -1000, -1000,
+}
+
+var yyPgo = [...]int16{
+	0, 270, 7, 268, 2, 267, 263, 189, 259, 258,
+	186, 257, 170, 8, 256, 4, 5, 254, 253, 0,
+	23, 252, 6, 251, 250, 245, 10, 64, 237, 236,
+	1, 221, 218, 9, 217, 29, 216, 212, 210, 209,
+	205, 204, 202, 188, 200, 3, 172, 171, 150,
+}
+
+var yyR1 = [...]int8{
+	0, 47, 47, 47, 47, 47, 47, 47, 30, 30,
+	30, 30, 30, 30, 30, 30, 30, 30, 30, 30,
+	25, 25, 25, 25, 26, 26, 28, 28, 28, 28,
+	28, 28, 28, 28, 28, 28, 28, 28, 28, 28,
+	28, 28, 27, 29, 29, 39, 39, 34, 34, 34,
+	34, 15, 15, 15, 15, 14, 14, 14, 4, 4,
+	31, 33, 33, 32, 32, 32, 40, 38, 38, 38,
+	24, 24, 24, 9, 9, 36, 42, 42, 42, 42,
+	42, 43, 44, 44, 44, 35, 35, 35, 1, 1,
+	1, 2, 2, 2, 2, 12, 12, 7, 7, 7,
+	7, 7, 7, 7, 7, 7, 7, 7, 7, 7,
+	7, 7, 7, 7, 7, 7, 7, 7, 7, 7,
+	7, 10, 10, 10, 10, 11, 11, 11, 13, 13,
+	13, 13, 48, 18, 18, 18, 18, 17, 17, 17,
+	17, 17, 21, 21, 21, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 6,
+	6, 6, 6, 6, 6, 6, 6, 6, 6, 6,
+	6, 6, 6, 6, 6, 6, 6, 6, 6, 6,
+	6, 6, 6, 6, 6, 6, 6, 6, 8, 8,
+	5, 5, 5, 5, 37, 20, 22, 22, 23, 23,
+	19, 45, 41, 46, 46, 16, 16,
+}
+
+var yyR2 = [...]int8{
+	0, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	3, 3, 2, 2, 2, 2, 4, 4, 4, 4,
+	4, 4, 4, 4, 4, 4, 4, 4, 4, 4,
+	4, 4, 1, 0, 1, 3, 3, 1, 1, 3,
+	3, 3, 4, 2, 1, 3, 1, 2, 1, 1,
+	2, 3, 2, 3, 1, 2, 3, 3, 4, 3,
+	3, 5, 3, 1, 1, 4, 6, 6, 5, 4,
+	3, 2, 2, 1, 1, 3, 4, 2, 3, 1,
+	2, 3, 3, 2, 1, 2, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	1, 3, 4, 2, 0, 3, 1, 2, 3, 3,
+	2, 1, 2, 0, 3, 2, 1, 1, 3, 1,
+	3, 4, 1, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 2, 2, 1, 1,
+	1, 1, 1, 0, 1, 0, 1,
+}
+
+var yyChk = [...]int16{
+	-1000, -47, 77, 78, 79, 80, 2, 10, -12, -7,
+	-10, 47, 48, 64, 49, 50, 51, 12, 32, 33,
+	36, 52, 16, 53, 68, 54, 55, 56, 57, 58,
+	70, 73, 74, 59, 60, 13, -48, -12, 10, -30,
+	-25, -28, -31, -36, -37, -38, -40, -41, -42, -43,
+	-44, -24, -3, 12, 17, 15, 23, -8, -7, -35,
+	47, 48, 49, 50, 51, 52, 53, 54, 55, 56,
+	57, 58, 59, 60, 26, 42, 13, -44, -10, -11,
+	18, -13, 12, 2, -18, 2, 26, 44, 27, 28,
+	30, 31, 32, 33, 34, 35, 36, 37, 38, 39,
+	41, 42, 68, 43, 14, -26, -33, 2, 64, 70,
+	15, -33, -30, -30, -35, -1, 18, -2, 12, 2,
+	18, 7, 2, 4, 2, 22, -27, -34, -29, -39,
+	63, -27, -27, -27, -27, -27, -27, -27, -27, -27,
+	-27, -27, -27, -27, -27, -27, -45, 42, 2, 9,
+	-23, -9, 2, -20, -22, 73, 74, 17, 26, 42,
+	-45, 2, -33, -26, -15, 15, 2, -15, -32, 20,
+	-30, 20, 18, 7, 2, -5, 2, 4, 39, 29,
+	40, 18, -13, 23, 2, -17, 5, -21, 12, -20,
+	-22, -30, 67, 69, 65, 66, -30, -30, -30, -30,
+	-30, -30, -30, -30, -30, -30, -30, -30, -30, -30,
+	-30, -45, 15, -20, -20, 19, 6, 2, -14, 20,
+	-4, -6, 2, 47, 63, 48, 64, 49, 50, 51,
+	65, 66, 12, 67, 32, 33, 36, 52, 16, 53,
+	68, 69, 54, 55, 56, 57, 58, 73, 74, 44,
+	59, 60, 20, 7, 18, -2, 23, 2, 24, 24,
+	-22, -15, -15, -16, -15, -16, 20, -46, -45, 2,
+	20, 7, 2, -30, -19, 17, -19, 24, 19, 2,
+	20, -4, -19,
+}
+
+var yyDef = [...]int16{
+	0, -2, 124, 124, 0, 0, 7, 6, 1, 124,
+	96, 97, 98, 99, 100, 101, 102, 103, 104, 105,
+	106, 107, 108, 109, 110, 111, 112, 113, 114, 115,
+	116, 117, 118, 119, 120, 0, 2, -2, 3, 4,
+	8, 9, 10, 11, 12, 13, 14, 15, 16, 17,
+	18, 19, 0, 103, 194, 0, 202, 0, 83, 84,
+	-2, -2, -2, -2, -2, -2, -2, -2, -2, -2,
+	-2, -2, -2, -2, 188, 189, 0, 5, 95, 0,
+	123, 126, 0, 131, 132, 136, 43, 43, 43, 43,
+	43, 43, 43, 43, 43, 43, 43, 43, 43, 43,
+	43, 43, 0, 0, 0, 0, 22, 23, 0, 0,
+	0, 60, 0, 81, 82, 0, 87, 89, 0, 94,
+	121, 0, 127, 0, 130, 135, 0, 42, 47, 48,
+	44, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 67, 0, 69, 201,
+	70, 0, 72, 198, 199, 73, 74, 195, 0, 0,
+	0, 80, 20, 21, 24, 0, 54, 25, 0, 62,
+	64, 66, 85, 0, 90, 0, 93, 190, 191, 192,
+	193, 122, 125, 128, 129, 134, 137, 139, 142, 143,
+	144, 26, 0, 0, -2, -2, 27, 28, 29, 30,
+	31, 32, 33, 34, 35, 36, 37, 38, 39, 40,
+	41, 68, 0, 196, 197, 75, -2, 79, 0, 53,
+	56, 58, 59, 159, 160, 161, 162, 163, 164, 165,
+	166, 167, 168, 169, 170, 171, 172, 173, 174, 175,
+	176, 177, 178, 179, 180, 181, 182, 183, 184, 185,
+	186, 187, 61, 65, 86, 88, 91, 92, 0, 0,
+	0, 45, 46, 49, 206, 50, 71, 0, -2, 78,
+	51, 0, 57, 63, 138, 200, 140, 0, 76, 77,
+	52, 55, 141,
+}
+
+var yyTok1 = [...]int8{
+	1,
+}
+
+var yyTok2 = [...]int8{
+	2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
+	12, 13, 14, 15, 16, 17, 18, 19, 20, 21,
+	22, 23, 24, 25, 26, 27, 28, 29, 30, 31,
+	32, 33, 34, 35, 36, 37, 38, 39, 40, 41,
+	42, 43, 44, 45, 46, 47, 48, 49, 50, 51,
+	52, 53, 54, 55, 56, 57, 58, 59, 60, 61,
+	62, 63, 64, 65, 66, 67, 68, 69, 70, 71,
+	72, 73, 74, 75, 76, 77, 78, 79, 80, 81,
+}
+
+var yyTok3 = [...]int8{
+	0,
+}
+
+var yyErrorMessages = [...]struct {
+	state int
+	token int
+	msg   string
+}{}
+
+//line yaccpar:1
+
+/*	parser for yacc output	*/
+
+var (
+	yyDebug        = 0
+	yyErrorVerbose = false
+)
+
+type yyLexer interface {
+	Lex(lval *yySymType) int
+	Error(s string)
+}
+
+type yyParser interface {
+	Parse(yyLexer) int
+	Lookahead() int
+}
+
+type yyParserImpl struct {
+	lval  yySymType
+	stack [yyInitialStackSize]yySymType
+	char  int
+}
+
+func (p *yyParserImpl) Lookahead() int {
+	return p.char
+}
+
+func yyNewParser() yyParser {
+	return &yyParserImpl{}
+}
+
+const yyFlag = -1000
+
+func yyTokname(c int) string {
+	if c >= 1 && c-1 < len(yyToknames) {
+		if yyToknames[c-1] != "" {
+			return yyToknames[c-1]
+		}
+	}
+	return __yyfmt__.Sprintf("tok-%v", c)
+}
+
+func yyStatname(s int) string {
+	if s >= 0 && s < len(yyStatenames) {
+		if yyStatenames[s] != "" {
+			return yyStatenames[s]
+		}
+	}
+	return __yyfmt__.Sprintf("state-%v", s)
+}
+
+func yyErrorMessage(state, lookAhead int) string {
+	const TOKSTART = 4
+
+	if !yyErrorVerbose {
+		return "syntax error"
+	}
+
+	for _, e := range yyErrorMessages {
+		if e.state == state && e.token == lookAhead {
+			return "syntax error: " + e.msg
+		}
+	}
+
+	res := "syntax error: unexpected " + yyTokname(lookAhead)
+
+	// To match Bison, suggest at most four expected tokens.
+	expected := make([]int, 0, 4)
+
+	// Look for shiftable tokens.
+	base := int(yyPact[state])
+	for tok := TOKSTART; tok-1 < len(yyToknames); tok++ {
+		if n := base + tok; n >= 0 && n < yyLast && int(yyChk[int(yyAct[n])]) == tok {
+			if len(expected) == cap(expected) {
+				return res
+			}
+			expected = append(expected, tok)
+		}
+	}
+
+	if yyDef[state] == -2 {
+		i := 0
+		for yyExca[i] != -1 || int(yyExca[i+1]) != state {
+			i += 2
+		}
+
+		// Look for tokens that we accept or reduce.
+		for i += 2; yyExca[i] >= 0; i += 2 {
+			tok := int(yyExca[i])
+			if tok < TOKSTART || yyExca[i+1] == 0 {
+				continue
+			}
+			if len(expected) == cap(expected) {
+				return res
+			}
+			expected = append(expected, tok)
+		}
+
+		// If the default action is to accept or reduce, give up.
+		if yyExca[i+1] != 0 {
+			return res
+		}
+	}
+
+	for i, tok := range expected {
+		if i == 0 {
+			res += ", expecting "
+		} else {
+			res += " or "
+		}
+		res += yyTokname(tok)
+	}
+	return res
+}
+
+func yylex1(lex yyLexer, lval *yySymType) (char, token int) {
+	token = 0
+	char = lex.Lex(lval)
+	if char <= 0 {
+		token = int(yyTok1[0])
+		goto out
+	}
+	if char < len(yyTok1) {
+		token = int(yyTok1[char])
+		goto out
+	}
+	if char >= yyPrivate {
+		if char < yyPrivate+len(yyTok2) {
+			token = int(yyTok2[char-yyPrivate])
+			goto out
+		}
+	}
+	for i := 0; i < len(yyTok3); i += 2 {
+		token = int(yyTok3[i+0])
+		if token == char {
+			token = int(yyTok3[i+1])
+			goto out
+		}
+	}
+
+out:
+	if token == 0 {
+		token = int(yyTok2[1]) /* unknown char */
+	}
+	if yyDebug >= 3 {
+		__yyfmt__.Printf("lex %s(%d)\n", yyTokname(token), uint(char))
+	}
+	return char, token
+}
+
+func yyParse(yylex yyLexer) int {
+	return yyNewParser().Parse(yylex)
+}
+
+func (yyrcvr *yyParserImpl) Parse(yylex yyLexer) int {
+	var yyn int
+	var yyVAL yySymType
+	var yyDollar []yySymType
+	_ = yyDollar // silence set and not used
+	yyS := yyrcvr.stack[:]
+
+	Nerrs := 0   /* number of errors */
+	Errflag := 0 /* error recovery flag */
+	yystate := 0
+	yyrcvr.char = -1
+	yytoken := -1 // yyrcvr.char translated into internal numbering
+	defer func() {
+		// Make sure we report no lookahead when not parsing.
+		yystate = -1
+		yyrcvr.char = -1
+		yytoken = -1
+	}()
+	yyp := -1
+	goto yystack
+
+ret0:
+	return 0
+
+ret1:
+	return 1
+
+yystack:
+	/* put a state and value onto the stack */
+	if yyDebug >= 4 {
+		__yyfmt__.Printf("char %v in %v\n", yyTokname(yytoken), yyStatname(yystate))
+	}
+
+	yyp++
+	if yyp >= len(yyS) {
+		nyys := make([]yySymType, len(yyS)*2)
+		copy(nyys, yyS)
+		yyS = nyys
+	}
+	yyS[yyp] = yyVAL
+	yyS[yyp].yys = yystate
+
+yynewstate:
+	yyn = int(yyPact[yystate])
+	if yyn <= yyFlag {
+		goto yydefault /* simple state */
+	}
+	if yyrcvr.char < 0 {
+		yyrcvr.char, yytoken = yylex1(yylex, &yyrcvr.lval)
+	}
+	yyn += yytoken
+	if yyn < 0 || yyn >= yyLast {
+		goto yydefault
+	}
+	yyn = int(yyAct[yyn])
+	if int(yyChk[yyn]) == yytoken { /* valid shift */
+		yyrcvr.char = -1
+		yytoken = -1
+		yyVAL = yyrcvr.lval
+		yystate = yyn
+		if Errflag > 0 {
+			Errflag--
+		}
+		goto yystack
+	}
+
+yydefault:
+	/* default state action */
+	yyn = int(yyDef[yystate])
+	if yyn == -2 {
+		if yyrcvr.char < 0 {
+			yyrcvr.char, yytoken = yylex1(yylex, &yyrcvr.lval)
+		}
+
+		/* look through exception table */
+		xi := 0
+		for {
+			if yyExca[xi+0] == -1 && int(yyExca[xi+1]) == yystate {
+				break
+			}
+			xi += 2
+		}
+		for xi += 2; ; xi += 2 {
+			yyn = int(yyExca[xi+0])
+			if yyn < 0 || yyn == yytoken {
+				break
+			}
+		}
+		yyn = int(yyExca[xi+1])
+		if yyn < 0 {
+			goto ret0
+		}
+	}
+	if yyn == 0 {
+		/* error ... attempt to resume parsing */
+		switch Errflag {
+		case 0: /* brand new error */
+			yylex.Error(yyErrorMessage(yystate, yytoken))
+			Nerrs++
+			if yyDebug >= 1 {
+				__yyfmt__.Printf("%s", yyStatname(yystate))
+				__yyfmt__.Printf(" saw %s\n", yyTokname(yytoken))
+			}
+			fallthrough
+
+		case 1, 2: /* incompletely recovered error ... try again */
+			Errflag = 3
+
+			/* find a state where "error" is a legal shift action */
+			for yyp >= 0 {
+				yyn = int(yyPact[yyS[yyp].yys]) + yyErrCode
+				if yyn >= 0 && yyn < yyLast {
+					yystate = int(yyAct[yyn]) /* simulate a shift of "error" */
+					if int(yyChk[yystate]) == yyErrCode {
+						goto yystack
+					}
+				}
+
+				/* the current p has no shift on "error", pop stack */
+				if yyDebug >= 2 {
+					__yyfmt__.Printf("error recovery pops state %d\n", yyS[yyp].yys)
+				}
+				yyp--
+			}
+			/* there is no state on the stack with an error shift ... abort */
+			goto ret1
+
+		case 3: /* no shift yet; clobber input char */
+			if yyDebug >= 2 {
+				__yyfmt__.Printf("error recovery discards %s\n", yyTokname(yytoken))
+			}
+			if yytoken == yyEofCode {
+				goto ret1
+			}
+			yyrcvr.char = -1
+			yytoken = -1
+			goto yynewstate /* try again in the same state */
+		}
+	}
+
+	/* reduction by production yyn */
+	if yyDebug >= 2 {
+		__yyfmt__.Printf("reduce %v in:\n\t%v\n", yyn, yyStatname(yystate))
+	}
+
+	yynt := yyn
+	yypt := yyp
+	_ = yypt // guard against "declared and not used"
+
+	yyp -= int(yyR2[yyn])
+	// yyp is now the index of $0. Perform the default action. Iff the
+	// reduced production is ε, $1 is possibly out of range.
+	if yyp+1 >= len(yyS) {
+		nyys := make([]yySymType, len(yyS)*2)
+		copy(nyys, yyS)
+		yyS = nyys
+	}
+	yyVAL = yyS[yyp+1]
+
+	/* consult goto table to find next state */
+	yyn = int(yyR1[yyn])
+	yyg := int(yyPgo[yyn])
+	yyj := yyg + yyS[yyp].yys + 1
+
+	if yyj >= yyLast {
+		yystate = int(yyAct[yyg])
+	} else {
+		yystate = int(yyAct[yyj])
+		if int(yyChk[yystate]) != -yyn {
+			yystate = int(yyAct[yyg])
+		}
+	}
+	// dummy call; replaced with literal code
+	switch yynt {
+
+	case 1:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:176
+		{
+			yylex.(*parser).generatedParserResult = yyDollar[2].labels
+		}
+	case 3:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:179
+		{
+			yylex.(*parser).addParseErrf(PositionRange{}, "no expression found in input")
+		}
+	case 4:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:181
+		{
+			yylex.(*parser).generatedParserResult = yyDollar[2].node
+		}
+	case 5:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:183
+		{
+			yylex.(*parser).generatedParserResult = yyDollar[2].node
+		}
+	case 7:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:186
+		{
+			yylex.(*parser).unexpected("", "")
+		}
+	case 20:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:209
+		{
+			yyVAL.node = yylex.(*parser).newAggregateExpr(yyDollar[1].item, yyDollar[2].node, yyDollar[3].node)
+		}
+	case 21:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:211
+		{
+			yyVAL.node = yylex.(*parser).newAggregateExpr(yyDollar[1].item, yyDollar[3].node, yyDollar[2].node)
+		}
+	case 22:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:213
+		{
+			yyVAL.node = yylex.(*parser).newAggregateExpr(yyDollar[1].item, &AggregateExpr{}, yyDollar[2].node)
+		}
+	case 23:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:215
+		{
+			yylex.(*parser).unexpected("aggregation", "")
+			yyVAL.node = yylex.(*parser).newAggregateExpr(yyDollar[1].item, &AggregateExpr{}, Expressions{})
+		}
+	case 24:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:223
+		{
+			yyVAL.node = &AggregateExpr{
+				Grouping: yyDollar[2].strings,
+			}
+		}
+	case 25:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:229
+		{
+			yyVAL.node = &AggregateExpr{
+				Grouping: yyDollar[2].strings,
+				Without:  true,
+			}
+		}
+	case 26:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:242
+		{
+			yyVAL.node = yylex.(*parser).newBinaryExpression(yyDollar[1].node, yyDollar[2].item, yyDollar[3].node, yyDollar[4].node)
+		}
+	case 27:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:243
+		{
+			yyVAL.node = yylex.(*parser).newBinaryExpression(yyDollar[1].node, yyDollar[2].item, yyDollar[3].node, yyDollar[4].node)
+		}
+	case 28:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:244
+		{
+			yyVAL.node = yylex.(*parser).newBinaryExpression(yyDollar[1].node, yyDollar[2].item, yyDollar[3].node, yyDollar[4].node)
+		}
+	case 29:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:245
+		{
+			yyVAL.node = yylex.(*parser).newBinaryExpression(yyDollar[1].node, yyDollar[2].item, yyDollar[3].node, yyDollar[4].node)
+		}
+	case 30:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:246
+		{
+			yyVAL.node = yylex.(*parser).newBinaryExpression(yyDollar[1].node, yyDollar[2].item, yyDollar[3].node, yyDollar[4].node)
+		}
+	case 31:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:247
+		{
+			yyVAL.node = yylex.(*parser).newBinaryExpression(yyDollar[1].node, yyDollar[2].item, yyDollar[3].node, yyDollar[4].node)
+		}
+	case 32:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:248
+		{
+			yyVAL.node = yylex.(*parser).newBinaryExpression(yyDollar[1].node, yyDollar[2].item, yyDollar[3].node, yyDollar[4].node)
+		}
+	case 33:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:249
+		{
+			yyVAL.node = yylex.(*parser).newBinaryExpression(yyDollar[1].node, yyDollar[2].item, yyDollar[3].node, yyDollar[4].node)
+		}
+	case 34:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:250
+		{
+			yyVAL.node = yylex.(*parser).newBinaryExpression(yyDollar[1].node, yyDollar[2].item, yyDollar[3].node, yyDollar[4].node)
+		}
+	case 35:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:251
+		{
+			yyVAL.node = yylex.(*parser).newBinaryExpression(yyDollar[1].node, yyDollar[2].item, yyDollar[3].node, yyDollar[4].node)
+		}
+	case 36:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:252
+		{
+			yyVAL.node = yylex.(*parser).newBinaryExpression(yyDollar[1].node, yyDollar[2].item, yyDollar[3].node, yyDollar[4].node)
+		}
+	case 37:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:253
+		{
+			yyVAL.node = yylex.(*parser).newBinaryExpression(yyDollar[1].node, yyDollar[2].item, yyDollar[3].node, yyDollar[4].node)
+		}
+	case 38:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:254
+		{
+			yyVAL.node = yylex.(*parser).newBinaryExpression(yyDollar[1].node, yyDollar[2].item, yyDollar[3].node, yyDollar[4].node)
+		}
+	case 39:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:255
+		{
+			yyVAL.node = yylex.(*parser).newBinaryExpression(yyDollar[1].node, yyDollar[2].item, yyDollar[3].node, yyDollar[4].node)
+		}
+	case 40:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:256
+		{
+			yyVAL.node = yylex.(*parser).newBinaryExpression(yyDollar[1].node, yyDollar[2].item, yyDollar[3].node, yyDollar[4].node)
+		}
+	case 41:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:257
+		{
+			yyVAL.node = yylex.(*parser).newBinaryExpression(yyDollar[1].node, yyDollar[2].item, yyDollar[3].node, yyDollar[4].node)
+		}
+	case 43:
+		yyDollar = yyS[yypt-0 : yypt+1]
+//line promql/parser/generated_parser.y:265
+		{
+			yyVAL.node = &BinaryExpr{
+				VectorMatching: &VectorMatching{Card: CardOneToOne},
+			}
+		}
+	case 44:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:270
+		{
+			yyVAL.node = &BinaryExpr{
+				VectorMatching: &VectorMatching{Card: CardOneToOne},
+				ReturnBool:     true,
+			}
+		}
+	case 45:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:278
+		{
+			yyVAL.node = yyDollar[1].node
+			yyVAL.node.(*BinaryExpr).VectorMatching.MatchingLabels = yyDollar[3].strings
+		}
+	case 46:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:283
+		{
+			yyVAL.node = yyDollar[1].node
+			yyVAL.node.(*BinaryExpr).VectorMatching.MatchingLabels = yyDollar[3].strings
+			yyVAL.node.(*BinaryExpr).VectorMatching.On = true
+		}
+	case 49:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:293
+		{
+			yyVAL.node = yyDollar[1].node
+			yyVAL.node.(*BinaryExpr).VectorMatching.Card = CardManyToOne
+			yyVAL.node.(*BinaryExpr).VectorMatching.Include = yyDollar[3].strings
+		}
+	case 50:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:299
+		{
+			yyVAL.node = yyDollar[1].node
+			yyVAL.node.(*BinaryExpr).VectorMatching.Card = CardOneToMany
+			yyVAL.node.(*BinaryExpr).VectorMatching.Include = yyDollar[3].strings
+		}
+	case 51:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:308
+		{
+			yyVAL.strings = yyDollar[2].strings
+		}
+	case 52:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:310
+		{
+			yyVAL.strings = yyDollar[2].strings
+		}
+	case 53:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:312
+		{
+			yyVAL.strings = []string{}
+		}
+	case 54:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:314
+		{
+			yylex.(*parser).unexpected("grouping opts", "\"(\"")
+			yyVAL.strings = nil
+		}
+	case 55:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:320
+		{
+			yyVAL.strings = append(yyDollar[1].strings, yyDollar[3].item.Val)
+		}
+	case 56:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:322
+		{
+			yyVAL.strings = []string{yyDollar[1].item.Val}
+		}
+	case 57:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:324
+		{
+			yylex.(*parser).unexpected("grouping opts", "\",\" or \")\"")
+			yyVAL.strings = yyDollar[1].strings
+		}
+	case 58:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:328
+		{
+			if !isLabel(yyDollar[1].item.Val) {
+				yylex.(*parser).unexpected("grouping opts", "label")
+			}
+			yyVAL.item = yyDollar[1].item
+		}
+	case 59:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:335
+		{
+			yylex.(*parser).unexpected("grouping opts", "label")
+			yyVAL.item = Item{}
+		}
+	case 60:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:343
+		{
+			fn, exist := getFunction(yyDollar[1].item.Val)
+			if !exist {
+				yylex.(*parser).addParseErrf(yyDollar[1].item.PositionRange(), "unknown function with name %q", yyDollar[1].item.Val)
+			}
+			yyVAL.node = &Call{
+				Func: fn,
+				Args: yyDollar[2].node.(Expressions),
+				PosRange: PositionRange{
+					Start: yyDollar[1].item.Pos,
+					End:   yylex.(*parser).lastClosing,
+				},
+			}
+		}
+	case 61:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:360
+		{
+			yyVAL.node = yyDollar[2].node
+		}
+	case 62:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:362
+		{
+			yyVAL.node = Expressions{}
+		}
+	case 63:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:366
+		{
+			yyVAL.node = append(yyDollar[1].node.(Expressions), yyDollar[3].node.(Expr))
+		}
+	case 64:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:368
+		{
+			yyVAL.node = Expressions{yyDollar[1].node.(Expr)}
+		}
+	case 65:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:370
+		{
+			yylex.(*parser).addParseErrf(yyDollar[2].item.PositionRange(), "trailing commas not allowed in function call args")
+			yyVAL.node = yyDollar[1].node
+		}
+	case 66:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:381
+		{
+			yyVAL.node = &ParenExpr{Expr: yyDollar[2].node.(Expr), PosRange: mergeRanges(&yyDollar[1].item, &yyDollar[3].item)}
+		}
+	case 67:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:389
+		{
+			yylex.(*parser).addOffset(yyDollar[1].node, yyDollar[3].duration)
+			yyVAL.node = yyDollar[1].node
+		}
+	case 68:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:394
+		{
+			yylex.(*parser).addOffset(yyDollar[1].node, -yyDollar[4].duration)
+			yyVAL.node = yyDollar[1].node
+		}
+	case 69:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:399
+		{
+			yylex.(*parser).unexpected("offset", "duration")
+			yyVAL.node = yyDollar[1].node
+		}
+	case 70:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:406
+		{
+			yylex.(*parser).setTimestamp(yyDollar[1].node, yyDollar[3].float)
+			yyVAL.node = yyDollar[1].node
+		}
+	case 71:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line promql/parser/generated_parser.y:411
+		{
+			yylex.(*parser).setAtModifierPreprocessor(yyDollar[1].node, yyDollar[3].item)
+			yyVAL.node = yyDollar[1].node
+		}
+	case 72:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:416
+		{
+			yylex.(*parser).unexpected("@", "timestamp")
+			yyVAL.node = yyDollar[1].node
+		}
+	case 75:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:426
+		{
+			var errMsg string
+			vs, ok := yyDollar[1].node.(*VectorSelector)
+			if !ok {
+				errMsg = "ranges only allowed for vector selectors"
+			} else if vs.OriginalOffset != 0 {
+				errMsg = "no offset modifiers allowed before range"
+			} else if vs.Timestamp != nil {
+				errMsg = "no @ modifiers allowed before range"
+			}
+
+			if errMsg != "" {
+				errRange := mergeRanges(&yyDollar[2].item, &yyDollar[4].item)
+				yylex.(*parser).addParseErrf(errRange, errMsg)
+			}
+
+			yyVAL.node = &MatrixSelector{
+				VectorSelector: yyDollar[1].node.(Expr),
+				Range:          yyDollar[3].duration,
+				EndPos:         yylex.(*parser).lastClosing,
+			}
+		}
+	case 76:
+		yyDollar = yyS[yypt-6 : yypt+1]
+//line promql/parser/generated_parser.y:451
+		{
+			yyVAL.node = &SubqueryExpr{
+				Expr:  yyDollar[1].node.(Expr),
+				Range: yyDollar[3].duration,
+				Step:  yyDollar[5].duration,
+
+				EndPos: yyDollar[6].item.Pos + 1,
+			}
+		}
+	case 77:
+		yyDollar = yyS[yypt-6 : yypt+1]
+//line promql/parser/generated_parser.y:461
+		{
+			yylex.(*parser).unexpected("subquery selector", "\"]\"")
+			yyVAL.node = yyDollar[1].node
+		}
+	case 78:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line promql/parser/generated_parser.y:463
+		{
+			yylex.(*parser).unexpected("subquery selector", "duration or \"]\"")
+			yyVAL.node = yyDollar[1].node
+		}
+	case 79:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:465
+		{
+			yylex.(*parser).unexpected("subquery or range", "\":\" or \"]\"")
+			yyVAL.node = yyDollar[1].node
+		}
+	case 80:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:467
+		{
+			yylex.(*parser).unexpected("subquery selector", "duration")
+			yyVAL.node = yyDollar[1].node
+		}
+	case 81:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:477
+		{
+			if nl, ok := yyDollar[2].node.(*NumberLiteral); ok {
+				if yyDollar[1].item.Typ == SUB {
+					nl.Val *= -1
+				}
+				nl.PosRange.Start = yyDollar[1].item.Pos
+				yyVAL.node = nl
+			} else {
+				yyVAL.node = &UnaryExpr{Op: yyDollar[1].item.Typ, Expr: yyDollar[2].node.(Expr), StartPos: yyDollar[1].item.Pos}
+			}
+		}
+	case 82:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:495
+		{
+			vs := yyDollar[2].node.(*VectorSelector)
+			vs.PosRange = mergeRanges(&yyDollar[1].item, vs)
+			vs.Name = yyDollar[1].item.Val
+			yylex.(*parser).assembleVectorSelector(vs)
+			yyVAL.node = vs
+		}
+	case 83:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:503
+		{
+			vs := &VectorSelector{
+				Name:          yyDollar[1].item.Val,
+				LabelMatchers: []*labels.Matcher{},
+				PosRange:      yyDollar[1].item.PositionRange(),
+			}
+			yylex.(*parser).assembleVectorSelector(vs)
+			yyVAL.node = vs
+		}
+	case 84:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:513
+		{
+			vs := yyDollar[1].node.(*VectorSelector)
+			yylex.(*parser).assembleVectorSelector(vs)
+			yyVAL.node = vs
+		}
+	case 85:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:521
+		{
+			yyVAL.node = &VectorSelector{
+				LabelMatchers: yyDollar[2].matchers,
+				PosRange:      mergeRanges(&yyDollar[1].item, &yyDollar[3].item),
+			}
+		}
+	case 86:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:528
+		{
+			yyVAL.node = &VectorSelector{
+				LabelMatchers: yyDollar[2].matchers,
+				PosRange:      mergeRanges(&yyDollar[1].item, &yyDollar[4].item),
+			}
+		}
+	case 87:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:535
+		{
+			yyVAL.node = &VectorSelector{
+				LabelMatchers: []*labels.Matcher{},
+				PosRange:      mergeRanges(&yyDollar[1].item, &yyDollar[2].item),
+			}
+		}
+	case 88:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:544
+		{
+			if yyDollar[1].matchers != nil {
+				yyVAL.matchers = append(yyDollar[1].matchers, yyDollar[3].matcher)
+			} else {
+				yyVAL.matchers = yyDollar[1].matchers
+			}
+		}
+	case 89:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:552
+		{
+			yyVAL.matchers = []*labels.Matcher{yyDollar[1].matcher}
+		}
+	case 90:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:554
+		{
+			yylex.(*parser).unexpected("label matching", "\",\" or \"}\"")
+			yyVAL.matchers = yyDollar[1].matchers
+		}
+	case 91:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:558
+		{
+			yyVAL.matcher = yylex.(*parser).newLabelMatcher(yyDollar[1].item, yyDollar[2].item, yyDollar[3].item)
+		}
+	case 92:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:560
+		{
+			yylex.(*parser).unexpected("label matching", "string")
+			yyVAL.matcher = nil
+		}
+	case 93:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:562
+		{
+			yylex.(*parser).unexpected("label matching", "label matching operator")
+			yyVAL.matcher = nil
+		}
+	case 94:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:564
+		{
+			yylex.(*parser).unexpected("label matching", "identifier or \"}\"")
+			yyVAL.matcher = nil
+		}
+	case 95:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:572
+		{
+			yyVAL.labels = append(yyDollar[2].labels, labels.Label{Name: labels.MetricName, Value: yyDollar[1].item.Val})
+			sort.Sort(yyVAL.labels)
+		}
+	case 96:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:574
+		{
+			yyVAL.labels = yyDollar[1].labels
+		}
+	case 121:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:581
+		{
+			yyVAL.labels = labels.New(yyDollar[2].labels...)
+		}
+	case 122:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:583
+		{
+			yyVAL.labels = labels.New(yyDollar[2].labels...)
+		}
+	case 123:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:585
+		{
+			yyVAL.labels = labels.New()
+		}
+	case 124:
+		yyDollar = yyS[yypt-0 : yypt+1]
+//line promql/parser/generated_parser.y:587
+		{
+			yyVAL.labels = labels.New()
+		}
+	case 125:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:591
+		{
+			yyVAL.labels = append(yyDollar[1].labels, yyDollar[3].label)
+		}
+	case 126:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:593
+		{
+			yyVAL.labels = []labels.Label{yyDollar[1].label}
+		}
+	case 127:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:595
+		{
+			yylex.(*parser).unexpected("label set", "\",\" or \"}\"")
+			yyVAL.labels = yyDollar[1].labels
+		}
+	case 128:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:600
+		{
+			yyVAL.label = labels.Label{Name: yyDollar[1].item.Val, Value: yylex.(*parser).unquoteString(yyDollar[3].item.Val)}
+		}
+	case 129:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:602
+		{
+			yylex.(*parser).unexpected("label set", "string")
+			yyVAL.label = labels.Label{}
+		}
+	case 130:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:604
+		{
+			yylex.(*parser).unexpected("label set", "\"=\"")
+			yyVAL.label = labels.Label{}
+		}
+	case 131:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:606
+		{
+			yylex.(*parser).unexpected("label set", "identifier or \"}\"")
+			yyVAL.label = labels.Label{}
+		}
+	case 132:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:614
+		{
+			yylex.(*parser).generatedParserResult = &seriesDescription{
+				labels: yyDollar[1].labels,
+				values: yyDollar[2].series,
+			}
+		}
+	case 133:
+		yyDollar = yyS[yypt-0 : yypt+1]
+//line promql/parser/generated_parser.y:623
+		{
+			yyVAL.series = []SequenceValue{}
+		}
+	case 134:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:625
+		{
+			yyVAL.series = append(yyDollar[1].series, yyDollar[3].series...)
+		}
+	case 135:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:627
+		{
+			yyVAL.series = yyDollar[1].series
+		}
+	case 136:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:629
+		{
+			yylex.(*parser).unexpected("series values", "")
+			yyVAL.series = nil
+		}
+	case 137:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:633
+		{
+			yyVAL.series = []SequenceValue{{Omitted: true}}
+		}
+	case 138:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:635
+		{
+			yyVAL.series = []SequenceValue{}
+			for i := uint64(0); i < yyDollar[3].uint; i++ {
+				yyVAL.series = append(yyVAL.series, SequenceValue{Omitted: true})
+			}
+		}
+	case 139:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:642
+		{
+			yyVAL.series = []SequenceValue{{Value: yyDollar[1].float}}
+		}
+	case 140:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line promql/parser/generated_parser.y:644
+		{
+			yyVAL.series = []SequenceValue{}
+			for i := uint64(0); i <= yyDollar[3].uint; i++ {
+				yyVAL.series = append(yyVAL.series, SequenceValue{Value: yyDollar[1].float})
+			}
+		}
+	case 141:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line promql/parser/generated_parser.y:651
+		{
+			yyVAL.series = []SequenceValue{}
+			for i := uint64(0); i <= yyDollar[4].uint; i++ {
+				yyVAL.series = append(yyVAL.series, SequenceValue{Value: yyDollar[1].float})
+				yyDollar[1].float += yyDollar[2].float
+			}
+		}
+	case 142:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:661
+		{
+			if yyDollar[1].item.Val != "stale" {
+				yylex.(*parser).unexpected("series values", "number or \"stale\"")
+			}
+			yyVAL.float = math.Float64frombits(value.StaleNaN)
+		}
+	case 194:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:692
+		{
+			yyVAL.node = &NumberLiteral{
+				Val:      yylex.(*parser).number(yyDollar[1].item.Val),
+				PosRange: yyDollar[1].item.PositionRange(),
+			}
+		}
+	case 195:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:700
+		{
+			yyVAL.float = yylex.(*parser).number(yyDollar[1].item.Val)
+		}
+	case 196:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:702
+		{
+			yyVAL.float = yyDollar[2].float
+		}
+	case 197:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line promql/parser/generated_parser.y:703
+		{
+			yyVAL.float = -yyDollar[2].float
+		}
+	case 200:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:709
+		{
+			var err error
+			yyVAL.uint, err = strconv.ParseUint(yyDollar[1].item.Val, 10, 64)
+			if err != nil {
+				yylex.(*parser).addParseErrf(yyDollar[1].item.PositionRange(), "invalid repetition in series values: %s", err)
+			}
+		}
+	case 201:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:719
+		{
+			var err error
+			yyVAL.duration, err = parseDuration(yyDollar[1].item.Val)
+			if err != nil {
+				yylex.(*parser).addParseErr(yyDollar[1].item.PositionRange(), err)
+			}
+		}
+	case 202:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line promql/parser/generated_parser.y:730
+		{
+			yyVAL.node = &StringLiteral{
+				Val:      yylex.(*parser).unquoteString(yyDollar[1].item.Val),
+				PosRange: yyDollar[1].item.PositionRange(),
+			}
+		}
+	case 203:
+		yyDollar = yyS[yypt-0 : yypt+1]
+//line promql/parser/generated_parser.y:743
+		{
+			yyVAL.duration = 0
+		}
+	case 205:
+		yyDollar = yyS[yypt-0 : yypt+1]
+//line promql/parser/generated_parser.y:747
+		{
+			yyVAL.strings = nil
+		}
+	}
+	goto yystack /* stack new state and value */
+}
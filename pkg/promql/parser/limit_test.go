@@ -0,0 +1,55 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimitAggregators(t *testing.T) {
+	testCases := []struct {
+		input    string
+		op       ItemType
+		grouping []string
+		without  bool
+		output   string
+	}{
+		{input: "limitk(2, x)", op: LIMITK, output: "limitk(2, x)"},
+		{input: "limitk by (job) (2, x)", op: LIMITK, grouping: []string{"job"}, output: "limitk by (job) (2, x)"},
+		{input: "limitk(2, x) without (instance)", op: LIMITK, grouping: []string{"instance"}, without: true, output: "limitk without (instance) (2, x)"},
+		{input: "limit_ratio by (job, group) (0.5, x)", op: LIMIT_RATIO, grouping: []string{"job", "group"}, output: "limit_ratio by (job, group) (0.5, x)"},
+		{input: "limit_ratio(-0.1, x)", op: LIMIT_RATIO, output: "limit_ratio(-0.1, x)"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			expr, err := ParseExpr(tc.input)
+			require.NoError(t, err)
+			agg, ok := expr.(*AggregateExpr)
+			require.True(t, ok, "expected an aggregation, got %T", expr)
+			require.Equal(t, tc.op, agg.Op)
+			require.Equal(t, tc.grouping, agg.Grouping)
+			require.Equal(t, tc.without, agg.Without)
+			require.NotNil(t, agg.Param)
+			require.Equal(t, tc.output, expr.String())
+		})
+	}
+
+	for _, input := range []string{
+		`limitk(x)`,
+		`limitk("2", x)`,
+		`limit_ratio(0.5)`,
+		`limitk(2, x, y)`,
+	} {
+		_, err := ParseExpr(input)
+		require.Error(t, err, input)
+	}
+
+	// The names are still usable as metric and label names.
+	expr, err := ParseExpr(`sum by (limitk) (limit_ratio{limitk="1"})`)
+	require.NoError(t, err)
+	require.Equal(t, `sum by (limitk) (limit_ratio{limitk="1"})`, expr.String())
+}
@@ -28,13 +28,13 @@ import (
 	"github.com/prometheus/prometheus/model/exemplar"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/timestamp"
-	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/tsdb"
 	"github.com/prometheus/prometheus/util/teststorage"
 	"github.com/prometheus/prometheus/util/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/timescale/promscale/pkg/pgmodel/querier"
+	"github.com/timescale/promscale/pkg/promql/parser"
 )
 
 var (
@@ -114,7 +114,7 @@ func (t *QuerierWrapper) Select(b bool, sh *storage.SelectHints, _ *querier.Quer
 	return ss, nil
 }
 
-func (t *QuerierWrapper) LabelValues(n string) ([]string, storage.Warnings, error) {
+func (t *QuerierWrapper) LabelValues(n string, _ ...*labels.Matcher) ([]string, storage.Warnings, error) {
 	return nil, nil, nil
 }
 
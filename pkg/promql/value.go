@@ -21,9 +21,9 @@ import (
 	"strings"
 
 	"github.com/prometheus/prometheus/model/labels"
-	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/timescale/promscale/pkg/promql/parser"
 )
 
 func (Matrix) Type() parser.ValueType { return parser.ValueTypeMatrix }
@@ -14,6 +14,17 @@ const (
 	DefaultLookBackDelta        = time.Minute * 5
 	DefaultSubqueryStepInterval = time.Minute
 	DefaultMaxSamples           = 50000000
+
+	DefaultQueryParallelism = 8
+
+	DefaultMaxQueuedQueries    = 100
+	DefaultQueueTimeout        = time.Minute
+	DefaultQueueFairnessHeader = "TENANT"
+
+	DefaultSlowQueryLogMaxFileSize = 100
+	DefaultSlowQueryLogMaxFiles    = 5
+	DefaultSlowQueryLogRecent      = 100
+	DefaultSlowQueryTableRetention = 7 * 24 * time.Hour
 )
 
 type CommaSeparatedList []string
@@ -39,10 +50,27 @@ type Config struct {
 	LookBackDelta        time.Duration
 	MaxSamples           int
 	MaxPointsPerTs       int64
+
+	SplitInterval    time.Duration // Range queries are split at multiples of it, 0 disables splitting.
+	Shards           int
+	QueryParallelism int
+
+	MaxConcurrentQueries int // 0 disables the query scheduler.
+	MaxQueuedQueries     int
+	QueueTimeout         time.Duration
+	QueueFairnessHeader  string
+
+	SlowQueryThreshold      time.Duration // Queries taking longer are logged, 0 disables the slow query log.
+	SlowQueryLogFile        string
+	SlowQueryLogMaxFileSize int // In megabytes.
+	SlowQueryLogMaxFiles    int
+	SlowQueryLogRecent      int
+	SlowQueryTable          bool
+	SlowQueryTableRetention time.Duration
 }
 
 func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
-	fs.Var(&cfg.PromscaleEnabledFeatureList, "enable-feature", "Enable beta/experimental features as a comma-separated list. Currently the following values can be passed: promql-at-modifier, promql-negative-offset, promql-per-step-stats")
+	fs.Var(&cfg.PromscaleEnabledFeatureList, "enable-feature", "Enable beta/experimental features as a comma-separated list. Currently the following values can be passed: promql-per-step-stats")
 
 	fs.DurationVar(&cfg.MaxQueryTimeout, "metrics.promql.query-timeout", DefaultQueryTimeout, "Maximum time a query may take before being aborted. This option sets both the default and maximum value of the 'timeout' parameter in "+
 		"'/api/v1/query.*' endpoints.")
@@ -54,6 +82,28 @@ func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
 		"so this also limits the number of samples a query can return.")
 	fs.Int64Var(&cfg.MaxPointsPerTs, "metrics.promql.max-points-per-ts", 11000, "Maximum number of points per time-series in a query-range request. "+
 		"This calculation is an estimation, that happens as (start - end)/step where start and end are the 'start' and 'end' timestamps of the query_range.")
+
+	fs.DurationVar(&cfg.SplitInterval, "metrics.promql.split-interval", 0, "Range queries are split into parts evaluated concurrently at multiples of this interval, "+
+		"aligned to the Unix epoch. A value of 24h splits at midnight UTC. Splitting is disabled if 0.")
+	fs.IntVar(&cfg.Shards, "metrics.promql.shards", 1, "Number of shards by series ID that range queries aggregating series-local expressions with sum, count, min or max "+
+		"are split into and evaluated concurrently. Sharding is disabled if 1.")
+	fs.IntVar(&cfg.QueryParallelism, "metrics.promql.query-parallelism", DefaultQueryParallelism, "Maximum number of parts of a split or sharded range query evaluated concurrently.")
+
+	fs.IntVar(&cfg.MaxConcurrentQueries, "metrics.promql.max-concurrent-queries", 0, "Maximum number of PromQL queries evaluated concurrently. Further queries wait in a queue, "+
+		"where rule evaluations are admitted before API queries and API queries before remote read exports. Unlimited if 0.")
+	fs.IntVar(&cfg.MaxQueuedQueries, "metrics.promql.max-queued-queries", DefaultMaxQueuedQueries, "Maximum number of queries waiting for evaluation. Queries are rejected when the queue is full.")
+	fs.DurationVar(&cfg.QueueTimeout, "metrics.promql.queue-timeout", DefaultQueueTimeout, "Maximum time a query waits for evaluation before being rejected. Unlimited if 0.")
+	fs.StringVar(&cfg.QueueFairnessHeader, "metrics.promql.queue-fairness-header", DefaultQueueFairnessHeader, "HTTP header identifying the tenant or user of an API query. "+
+		"Queued queries are admitted round-robin across its values, so a single tenant or user can't starve the others.")
+
+	fs.DurationVar(&cfg.SlowQueryThreshold, "metrics.promql.slow-query-log.threshold", 0, "PromQL queries taking longer than this are recorded in the slow query log, "+
+		"along with their caller, tenant, samples touched, series fetched and SQL round-trips. The log is disabled if 0.")
+	fs.StringVar(&cfg.SlowQueryLogFile, "metrics.promql.slow-query-log.file", "", "File the slow query log is written to as JSON lines. No file is written if empty.")
+	fs.IntVar(&cfg.SlowQueryLogMaxFileSize, "metrics.promql.slow-query-log.max-file-size", DefaultSlowQueryLogMaxFileSize, "Size in megabytes at which the slow query log file is rotated.")
+	fs.IntVar(&cfg.SlowQueryLogMaxFiles, "metrics.promql.slow-query-log.max-files", DefaultSlowQueryLogMaxFiles, "Number of rotated slow query log files to keep.")
+	fs.IntVar(&cfg.SlowQueryLogRecent, "metrics.promql.slow-query-log.recent-entries", DefaultSlowQueryLogRecent, "Number of recent slow queries kept in memory for the '/api/v1/status/slow_queries' endpoint.")
+	fs.BoolVar(&cfg.SlowQueryTable, "metrics.promql.slow-query-log.table", false, "Also record slow queries in the _ps_catalog.slow_query_log table created by the connector migrations. Cannot be used in read-only mode.")
+	fs.DurationVar(&cfg.SlowQueryTableRetention, "metrics.promql.slow-query-log.table-retention", DefaultSlowQueryTableRetention, "Age after which slow queries are deleted from the _ps_catalog.slow_query_log table.")
 	return cfg
 }
 
@@ -61,13 +111,40 @@ func Validate(cfg *Config) error {
 	cfg.EnabledFeatureMap = make(map[string]struct{})
 	for _, f := range cfg.PromscaleEnabledFeatureList {
 		switch f {
-		case "promql-at-modifier", "promql-negative-offset", "promql-per-step-stats":
+		case "promql-per-step-stats":
 			cfg.EnabledFeatureMap[f] = struct{}{}
+		case "promql-at-modifier", "promql-negative-offset":
+			cfg.EnabledFeatureMap[f] = struct{}{}
+			log.Warn("msg", f+" feature is now on by default, no need to use it with --enable-feature flag")
 		case "tracing":
 			log.Error("msg", "tracing feature is now on by default, no need to use it with --enable-feature flag")
 		default:
 			return fmt.Errorf("invalid feature: %s", f)
 		}
 	}
+	if cfg.SplitInterval < 0 {
+		return fmt.Errorf("split interval must not be negative")
+	}
+	if cfg.Shards < 1 || cfg.QueryParallelism < 1 {
+		return fmt.Errorf("shards and query parallelism must be positive")
+	}
+	if cfg.MaxConcurrentQueries < 0 || cfg.MaxQueuedQueries < 0 || cfg.QueueTimeout < 0 {
+		return fmt.Errorf("max concurrent queries, max queued queries and queue timeout must not be negative")
+	}
+	if cfg.SlowQueryThreshold < 0 {
+		return fmt.Errorf("slow query log threshold must not be negative")
+	}
+	if cfg.SlowQueryThreshold == 0 {
+		return nil
+	}
+	if cfg.SlowQueryLogFile != "" && (cfg.SlowQueryLogMaxFileSize <= 0 || cfg.SlowQueryLogMaxFiles < 0) {
+		return fmt.Errorf("slow query log max file size must be positive and max files must not be negative")
+	}
+	if cfg.SlowQueryLogRecent < 0 {
+		return fmt.Errorf("slow query log recent entries must not be negative")
+	}
+	if cfg.SlowQueryTable && cfg.SlowQueryTableRetention <= 0 {
+		return fmt.Errorf("slow query table retention must be positive")
+	}
 	return nil
 }
@@ -0,0 +1,429 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package query
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/stats"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/timescale/promscale/pkg/pgmodel/querier"
+	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/promql/parser"
+)
+
+// Frontend splits range queries into parts which are evaluated concurrently
+// by the engine, each with their own SQL statements, and merges the results.
+// Long ranges are split at multiples of the split interval, aligned to the
+// Unix epoch so that a split interval of a day splits at midnight UTC.
+// Queries which are a sum, count, min or max aggregation of series-local
+// expressions are also sharded by series ID.
+//
+// The query itself holds one slot of the scheduler. Every further part
+// evaluated concurrently takes a slot of its own.
+type Frontend struct {
+	engine        *promql.Engine
+	scheduler     *Scheduler
+	splitInterval time.Duration
+	shards        int
+	parallelism   int
+}
+
+func NewFrontend(engine *promql.Engine, cfg *Config, scheduler *Scheduler) *Frontend {
+	return &Frontend{
+		engine:        engine,
+		scheduler:     scheduler,
+		splitInterval: cfg.SplitInterval,
+		shards:        cfg.Shards,
+		parallelism:   cfg.QueryParallelism,
+	}
+}
+
+// NewRangeQuery returns a range query like promql.Engine.NewRangeQuery. It
+// returns a query of the engine if the query isn't split nor sharded.
+func (f *Frontend) NewRangeQuery(q promql.Queryable, opts *promql.QueryOpts, qs string, start, end time.Time, interval time.Duration) (promql.Query, error) {
+	expr, err := parser.ParseExpr(qs)
+	if err != nil {
+		return nil, err
+	}
+	splits := f.splitRange(expr, start, end, interval)
+	shards, aggregation := f.shardCount(expr)
+	if len(splits) == 1 && shards == 1 {
+		return f.engine.NewRangeQuery(q, opts, qs, start, end, interval)
+	}
+
+	fq := &frontendQuery{
+		qs: qs,
+		stmt: &parser.EvalStmt{
+			Expr:     expr,
+			Start:    start,
+			End:      end,
+			Interval: interval,
+		},
+		aggregation: aggregation,
+		splits:      len(splits),
+		parallelism: f.parallelism,
+		scheduler:   f.scheduler,
+	}
+	for i, split := range splits {
+		for shard := 0; shard < shards; shard++ {
+			qry, err := f.engine.NewRangeQuery(q, opts, qs, split[0], split[1], interval)
+			if err != nil {
+				fq.Close()
+				return nil, err
+			}
+			fq.parts = append(fq.parts, queryPart{
+				query: qry,
+				split: i,
+				shard: querier.Shard{Index: shard, Count: shards},
+			})
+		}
+	}
+	return fq, nil
+}
+
+// splitRange returns the start and end of the parts of the range query. The
+// steps of each part are the steps of the query within a split interval.
+func (f *Frontend) splitRange(expr parser.Expr, start, end time.Time, interval time.Duration) [][2]time.Time {
+	if f.splitInterval <= 0 || interval <= 0 || usesStartOrEnd(expr) {
+		return [][2]time.Time{{start, end}}
+	}
+	var (
+		splits   [][2]time.Time
+		step     = interval.Milliseconds()
+		split    = f.splitInterval.Milliseconds()
+		endMs    = timestamp.FromTime(end)
+		splitsAt = func(t int64) int64 {
+			return (t/split + 1) * split
+		}
+	)
+	for t := timestamp.FromTime(start); t <= endMs; {
+		// The last step before the next split.
+		partEnd := t + (splitsAt(t)-1-t)/step*step
+		if partEnd > endMs {
+			partEnd = endMs
+		}
+		splits = append(splits, [2]time.Time{timestamp.Time(t), timestamp.Time(partEnd)})
+		t = partEnd + step
+	}
+	return splits
+}
+
+// usesStartOrEnd tells if the result of the expression at a step depends on
+// the range of the query, through the start() and end() @ modifiers.
+func usesStartOrEnd(expr parser.Expr) bool {
+	found := false
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		switch n := node.(type) {
+		case *parser.VectorSelector:
+			found = found || n.StartOrEnd != 0
+		case *parser.SubqueryExpr:
+			found = found || n.StartOrEnd != 0
+		}
+		return nil
+	})
+	return found
+}
+
+// shardCount returns the number of shards of the query and the aggregation
+// merging the results of the shards.
+func (f *Frontend) shardCount(expr parser.Expr) (int, parser.ItemType) {
+	if f.shards <= 1 {
+		return 1, 0
+	}
+	expr = unwrapParens(expr)
+	agg, ok := expr.(*parser.AggregateExpr)
+	if !ok {
+		return 1, 0
+	}
+	switch agg.Op {
+	case parser.SUM, parser.COUNT, parser.MIN, parser.MAX:
+	default:
+		return 1, 0
+	}
+	if !isSeriesLocal(agg.Expr) {
+		return 1, 0
+	}
+	return f.shards, agg.Op
+}
+
+func unwrapParens(expr parser.Expr) parser.Expr {
+	for {
+		p, ok := expr.(*parser.ParenExpr)
+		if !ok {
+			return expr
+		}
+		expr = p.Expr
+	}
+}
+
+// seriesLocalFunctions are the functions computing the result of a series
+// from this series only.
+var seriesLocalFunctions = map[string]bool{
+	"abs": true, "ceil": true, "floor": true, "exp": true, "ln": true, "log2": true, "log10": true, "sqrt": true,
+	"round": true, "sgn": true, "clamp": true, "clamp_min": true, "clamp_max": true,
+	"rate": true, "irate": true, "increase": true, "delta": true, "idelta": true, "deriv": true,
+	"resets": true, "changes": true, "predict_linear": true,
+	"avg_over_time": true, "min_over_time": true, "max_over_time": true, "sum_over_time": true,
+	"count_over_time": true, "last_over_time": true, "present_over_time": true, "quantile_over_time": true,
+	"stddev_over_time": true, "stdvar_over_time": true, "mad_over_time": true,
+}
+
+// isSeriesLocal tells if every series of the result of the expression is
+// computed from a single series of a single metric, so evaluating it on
+// disjoint sets of series gives disjoint results. The metric must be fixed,
+// since functions dropping the metric name would otherwise merge series of
+// different metrics, which is an error only if they are in the same shard.
+func isSeriesLocal(expr parser.Expr) bool {
+	switch e := expr.(type) {
+	case *parser.NumberLiteral, *parser.StringLiteral:
+		return true
+	case *parser.ParenExpr:
+		return isSeriesLocal(e.Expr)
+	case *parser.UnaryExpr:
+		return isSeriesLocal(e.Expr)
+	case *parser.VectorSelector:
+		return hasMetricName(e.LabelMatchers)
+	case *parser.MatrixSelector:
+		return isSeriesLocal(e.VectorSelector)
+	case *parser.SubqueryExpr:
+		return isSeriesLocal(e.Expr)
+	case *parser.Call:
+		if !seriesLocalFunctions[e.Func.Name] {
+			return false
+		}
+		for _, arg := range e.Args {
+			if !isSeriesLocal(arg) {
+				return false
+			}
+		}
+		return true
+	case *parser.BinaryExpr:
+		// Only operations between a vector and a literal, vector to vector
+		// matching is across series.
+		_, lhsLiteral := unwrapParens(e.LHS).(*parser.NumberLiteral)
+		_, rhsLiteral := unwrapParens(e.RHS).(*parser.NumberLiteral)
+		return (lhsLiteral || rhsLiteral) && isSeriesLocal(e.LHS) && isSeriesLocal(e.RHS)
+	}
+	return false
+}
+
+func hasMetricName(ms []*labels.Matcher) bool {
+	for _, m := range ms {
+		if m.Name == labels.MetricName && m.Type == labels.MatchEqual {
+			return true
+		}
+	}
+	return false
+}
+
+type queryPart struct {
+	query promql.Query
+	split int
+	shard querier.Shard
+}
+
+// frontendQuery is a range query evaluated in parts.
+type frontendQuery struct {
+	qs          string
+	stmt        *parser.EvalStmt
+	aggregation parser.ItemType
+	splits      int
+	parallelism int
+	scheduler   *Scheduler
+	parts       []queryPart
+}
+
+// Exec evaluates the parts of the query concurrently and merges their results.
+// The parts are taken by workers: the first one runs in the scheduler slot of
+// the query, the others only once the scheduler admitted them. Workers still
+// queued when no part is left give up, so the query completes even if the
+// scheduler admits nothing more.
+func (q *frontendQuery) Exec(ctx context.Context) *promql.Result {
+	results := make([]*promql.Result, len(q.parts))
+	workers := len(q.parts)
+	if q.parallelism > 0 && q.parallelism < workers {
+		workers = q.parallelism
+	}
+	g, gctx := errgroup.WithContext(ctx)
+	acquireCtx, cancelAcquire := context.WithCancel(gctx)
+	defer cancelAcquire()
+
+	var next int64 = -1
+	work := func() error {
+		defer cancelAcquire()
+		for {
+			i := int(atomic.AddInt64(&next, 1))
+			if i >= len(q.parts) {
+				return nil
+			}
+			part := q.parts[i]
+			partCtx := gctx
+			if part.shard.Count > 1 {
+				partCtx = querier.WithShard(gctx, part.shard)
+			}
+			results[i] = part.query.Exec(partCtx)
+			if results[i].Err != nil {
+				return results[i].Err
+			}
+		}
+	}
+	g.Go(work)
+	p, key := queryClassFrom(ctx)
+	for w := 1; w < workers; w++ {
+		g.Go(func() error {
+			release, err := q.scheduler.Acquire(acquireCtx, p, key)
+			if err != nil {
+				// The admitted workers evaluate the remaining parts.
+				return nil
+			}
+			defer release()
+			return work()
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return &promql.Result{Err: err}
+	}
+
+	var warnings storage.Warnings
+	splits := make([]promql.Matrix, q.splits)
+	shards := make([][]promql.Matrix, q.splits)
+	for i, res := range results {
+		warnings = append(warnings, res.Warnings...)
+		m, err := res.Matrix()
+		if err != nil {
+			return &promql.Result{Err: err, Warnings: warnings}
+		}
+		split := q.parts[i].split
+		shards[split] = append(shards[split], m)
+	}
+	for i := range shards {
+		splits[i] = mergeShards(shards[i], q.aggregation)
+	}
+	return &promql.Result{Value: concatSplits(splits), Warnings: warnings}
+}
+
+// mergeShards merges the results of the shards of an aggregation.
+func mergeShards(shards []promql.Matrix, aggregation parser.ItemType) promql.Matrix {
+	if len(shards) == 1 {
+		return shards[0]
+	}
+	type group struct {
+		metric labels.Labels
+		points map[int64]float64
+	}
+	groups := make(map[uint64]*group)
+	for _, m := range shards {
+		for _, s := range m {
+			h := s.Metric.Hash()
+			g, ok := groups[h]
+			if !ok {
+				g = &group{metric: s.Metric, points: make(map[int64]float64, len(s.Points))}
+				groups[h] = g
+			}
+			for _, p := range s.Points {
+				cur, ok := g.points[p.T]
+				if !ok {
+					g.points[p.T] = p.V
+					continue
+				}
+				switch aggregation {
+				case parser.SUM, parser.COUNT:
+					g.points[p.T] = cur + p.V
+				case parser.MIN:
+					if p.V < cur || math.IsNaN(cur) {
+						g.points[p.T] = p.V
+					}
+				case parser.MAX:
+					if p.V > cur || math.IsNaN(cur) {
+						g.points[p.T] = p.V
+					}
+				}
+			}
+		}
+	}
+	res := make(promql.Matrix, 0, len(groups))
+	for _, g := range groups {
+		s := promql.Series{Metric: g.metric, Points: make([]promql.Point, 0, len(g.points))}
+		for t, v := range g.points {
+			s.Points = append(s.Points, promql.Point{T: t, V: v})
+		}
+		sort.Slice(s.Points, func(i, j int) bool { return s.Points[i].T < s.Points[j].T })
+		res = append(res, s)
+	}
+	return res
+}
+
+// concatSplits concatenates the results of consecutive time ranges.
+func concatSplits(splits []promql.Matrix) promql.Matrix {
+	if len(splits) == 1 {
+		sort.Sort(splits[0])
+		return splits[0]
+	}
+	index := make(map[uint64]int)
+	var res promql.Matrix
+	for _, m := range splits {
+		for _, s := range m {
+			h := s.Metric.Hash()
+			i, ok := index[h]
+			if !ok {
+				index[h] = len(res)
+				res = append(res, promql.Series{Metric: s.Metric, Points: s.Points})
+				continue
+			}
+			res[i].Points = append(res[i].Points, s.Points...)
+		}
+	}
+	sort.Sort(res)
+	return res
+}
+
+// Close closes the parts of the query.
+func (q *frontendQuery) Close() {
+	for _, p := range q.parts {
+		p.query.Close()
+	}
+}
+
+// Statement returns the statement of the whole query.
+func (q *frontendQuery) Statement() parser.Statement {
+	return q.stmt
+}
+
+// Stats returns the total and peak samples of the parts. Timers and per step
+// statistics aren't merged.
+func (q *frontendQuery) Stats() *stats.Statistics {
+	samples := stats.NewQuerySamples(false)
+	for _, p := range q.parts {
+		s := p.query.Stats()
+		if s == nil || s.Samples == nil {
+			continue
+		}
+		samples.TotalSamples += s.Samples.TotalSamples
+		if s.Samples.PeakSamples > samples.PeakSamples {
+			samples.PeakSamples = s.Samples.PeakSamples
+		}
+	}
+	return &stats.Statistics{Timers: stats.NewQueryTimers(), Samples: samples}
+}
+
+// Cancel cancels the parts of the query.
+func (q *frontendQuery) Cancel() {
+	for _, p := range q.parts {
+		p.query.Cancel()
+	}
+}
+
+func (q *frontendQuery) String() string {
+	return q.qs
+}
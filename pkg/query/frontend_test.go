@@ -0,0 +1,210 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package query
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/promql/parser"
+)
+
+func TestFrontendSplitRange(t *testing.T) {
+	f := &Frontend{splitInterval: 24 * time.Hour}
+	day := 24 * time.Hour
+	at := func(d time.Duration) time.Time { return time.Unix(0, 0).Add(d).UTC() }
+	expr, err := parser.ParseExpr("m")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		expr       parser.Expr
+		start, end time.Time
+		step       time.Duration
+		expect     [][2]time.Time
+	}{
+		{
+			name:   "Within a day",
+			expr:   expr,
+			start:  at(day + time.Hour),
+			end:    at(day + 2*time.Hour),
+			step:   time.Minute,
+			expect: [][2]time.Time{{at(day + time.Hour), at(day + 2*time.Hour)}},
+		}, {
+			name:  "Across days",
+			expr:  expr,
+			start: at(day - time.Hour),
+			end:   at(2*day + time.Hour),
+			step:  25 * time.Minute,
+			expect: [][2]time.Time{
+				{at(day - time.Hour), at(day - 10*time.Minute)},
+				{at(day + 15*time.Minute), at(2*day - 25*time.Minute)},
+				{at(2 * day), at(2*day + time.Hour)},
+			},
+		}, {
+			name:  "Step on the split",
+			expr:  expr,
+			start: at(day - time.Hour),
+			end:   at(day + time.Hour),
+			step:  time.Hour,
+			expect: [][2]time.Time{
+				{at(day - time.Hour), at(day - time.Hour)},
+				{at(day), at(day + time.Hour)},
+			},
+		}, {
+			name:   "Uses end()",
+			expr:   &parser.VectorSelector{Name: "m", StartOrEnd: parser.END},
+			start:  at(day - time.Hour),
+			end:    at(day + time.Hour),
+			step:   time.Minute,
+			expect: [][2]time.Time{{at(day - time.Hour), at(day + time.Hour)}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expect, f.splitRange(tc.expr, tc.start, tc.end, tc.step))
+		})
+	}
+}
+
+func TestFrontendShardCount(t *testing.T) {
+	f := &Frontend{shards: 4}
+	testCases := []struct {
+		query  string
+		expect int
+	}{
+		{query: "m", expect: 1},
+		{query: "sum(m)", expect: 4},
+		{query: "(sum by (job) (rate(m[5m])))", expect: 4},
+		{query: "count without (i) (m > 2)", expect: 4},
+		{query: "max(clamp_max(m, 10) * 2)", expect: 4},
+		{query: "min(max_over_time(m[1h:5m]))", expect: 4},
+		{query: "sum(quantile_over_time(0.9, m[5m]))", expect: 4},
+		{query: "avg(m)", expect: 1},
+		{query: "topk(2, m)", expect: 1},
+		{query: "sum(m + n)", expect: 1},
+		{query: "sum(sum(m))", expect: 1},
+		{query: "sum(label_replace(m, \"a\", \"b\", \"c\", \"d\"))", expect: 1},
+		{query: "sum(rate({__name__=~\"m|n\"}[5m]))", expect: 1},
+		{query: "sum(m) + 1", expect: 1},
+	}
+	for _, tc := range testCases {
+		expr, err := parser.ParseExpr(tc.query)
+		require.NoError(t, err)
+		shards, _ := f.shardCount(expr)
+		require.Equal(t, tc.expect, shards, tc.query)
+	}
+}
+
+func TestMergeShards(t *testing.T) {
+	a := labels.FromStrings("job", "a")
+	b := labels.FromStrings("job", "b")
+	shards := []promql.Matrix{
+		{
+			{Metric: a, Points: []promql.Point{{T: 1, V: 1}, {T: 2, V: 5}}},
+			{Metric: b, Points: []promql.Point{{T: 2, V: math.NaN()}}},
+		},
+		{
+			{Metric: a, Points: []promql.Point{{T: 2, V: 3}, {T: 3, V: 4}}},
+			{Metric: b, Points: []promql.Point{{T: 2, V: 7}}},
+		},
+	}
+	testCases := []struct {
+		aggregation parser.ItemType
+		expectA     []promql.Point
+		expectB     []promql.Point
+	}{
+		{aggregation: parser.SUM, expectA: []promql.Point{{T: 1, V: 1}, {T: 2, V: 8}, {T: 3, V: 4}}},
+		{aggregation: parser.MIN, expectA: []promql.Point{{T: 1, V: 1}, {T: 2, V: 3}, {T: 3, V: 4}}, expectB: []promql.Point{{T: 2, V: 7}}},
+		{aggregation: parser.MAX, expectA: []promql.Point{{T: 1, V: 1}, {T: 2, V: 5}, {T: 3, V: 4}}, expectB: []promql.Point{{T: 2, V: 7}}},
+	}
+	for _, tc := range testCases {
+		res := concatSplits([]promql.Matrix{mergeShards(shards, tc.aggregation)})
+		require.Len(t, res, 2)
+		require.Equal(t, a, res[0].Metric)
+		require.Equal(t, tc.expectA, res[0].Points, tc.aggregation.String())
+		if tc.expectB != nil {
+			require.Equal(t, tc.expectB, res[1].Points, tc.aggregation.String())
+		}
+	}
+}
+
+func TestFrontendSplitMatchesEngine(t *testing.T) {
+	test, err := promql.NewTest(t, `
+load 10m
+	m{job="a", i="1"} 0+1x300
+	m{job="a", i="2"} 0+2x300
+	m{job="b", i="1"} 0+3x150 _x50 0+1x100
+`)
+	require.NoError(t, err)
+	defer test.Close()
+	require.NoError(t, test.Run())
+
+	engine := test.QueryEngine()
+	f := NewFrontend(engine, &Config{SplitInterval: 3 * time.Hour, QueryParallelism: 2}, nil)
+	start, end := time.Unix(0, 0).Add(7*time.Minute), time.Unix(0, 0).Add(40*time.Hour)
+	for _, qs := range []string{
+		"m",
+		"sum by (job) (rate(m[30m]))",
+		"max_over_time(m[2h:15m])",
+		"m @ end()",
+		"count(m offset 1h)",
+		"time()",
+	} {
+		t.Run(qs, func(t *testing.T) {
+			expected, err := engine.NewRangeQuery(test.Queryable(), nil, qs, start, end, 13*time.Minute)
+			require.NoError(t, err)
+			defer expected.Close()
+			expectedRes := expected.Exec(context.Background())
+			require.NoError(t, expectedRes.Err)
+
+			qry, err := f.NewRangeQuery(test.Queryable(), nil, qs, start, end, 13*time.Minute)
+			require.NoError(t, err)
+			defer qry.Close()
+			res := qry.Exec(context.Background())
+			require.NoError(t, res.Err)
+			require.Equal(t, expectedRes.Value, res.Value)
+		})
+	}
+}
+
+func TestFrontendSchedulerSlots(t *testing.T) {
+	test, err := promql.NewTest(t, `
+load 10m
+	m{job="a"} 0+1x300
+	m{job="b"} 0+2x300
+`)
+	require.NoError(t, err)
+	defer test.Close()
+	require.NoError(t, test.Run())
+
+	start, end := time.Unix(0, 0), time.Unix(0, 0).Add(40*time.Hour)
+	for _, slots := range []int{1, 2, 8} {
+		s := NewScheduler(&Config{MaxConcurrentQueries: slots, MaxQueuedQueries: 10})
+		f := NewFrontend(test.QueryEngine(), &Config{SplitInterval: 3 * time.Hour, QueryParallelism: 4}, s)
+		qry, err := f.NewRangeQuery(test.Queryable(), nil, "m", start, end, 10*time.Minute)
+		require.NoError(t, err)
+
+		// The query holds a slot while it runs, like in the API.
+		release, err := s.Acquire(context.Background(), PriorityAPI, "")
+		require.NoError(t, err)
+		res := qry.Exec(WithQueryClass(context.Background(), PriorityAPI, ""))
+		require.NoError(t, res.Err, "slots: %d", slots)
+		qry.Close()
+
+		// The slots taken by the parts are released.
+		s.mu.Lock()
+		require.Equal(t, 1, s.running, "slots: %d", slots)
+		require.Equal(t, 0, s.queued, "slots: %d", slots)
+		s.mu.Unlock()
+		release()
+	}
+}
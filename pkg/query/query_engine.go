@@ -20,10 +20,11 @@ func NewEngine(logger log.Logger, queryTimeout, lookBackDelta, subqueryDefaultSt
 		Timeout:                  queryTimeout,
 		LookbackDelta:            lookBackDelta,
 		NoStepSubqueryIntervalFn: func(int64) int64 { return durationMilliseconds(subqueryDefaultStepInterval) },
+		// The @ modifier and negative offsets are stable since Prometheus 2.33.
+		EnableAtModifier:     true,
+		EnableNegativeOffset: true,
 	}
 
-	_, engineOpts.EnableAtModifier = enabledFeaturesMap["promql-at-modifier"]
-	_, engineOpts.EnableNegativeOffset = enabledFeaturesMap["promql-negative-offset"]
 	_, engineOpts.EnablePerStepStats = enabledFeaturesMap["promql-per-step-stats"]
 	return promql.NewEngine(engineOpts), nil
 }
@@ -8,11 +8,13 @@ import (
 	"context"
 
 	"github.com/prometheus/prometheus/model/labels"
-	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/model/timestamp"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/timescale/promscale/pkg/pgmodel/lreader"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
 	pgQuerier "github.com/timescale/promscale/pkg/pgmodel/querier"
 	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/promql/parser"
 )
 
 func NewQueryable(q pgQuerier.Querier, labelsReader lreader.LabelsReader) promql.Queryable {
@@ -48,17 +50,32 @@ func (q queryable) newSamplesQuerier(ctx context.Context, mint, maxt int64) *sam
 	}
 }
 
-func (q samplesQuerier) LabelValues(name string) ([]string, storage.Warnings, error) {
-	lVals, err := q.labelsReader.LabelValues(name)
+func (q samplesQuerier) LabelValues(name string, matchers ...*labels.Matcher) ([]string, storage.Warnings, error) {
+	if q.unrestricted(matchers) {
+		lVals, err := q.labelsReader.LabelValues(name)
+		return lVals, nil, err
+	}
+	lVals, err := q.metricsReader.LabelsQuerier(q.ctx).LabelValues(name, q.mint, q.maxt, matchers...)
 	return lVals, nil, err
 }
 
-func (q samplesQuerier) LabelNames(_ ...*labels.Matcher) ([]string, storage.Warnings, error) {
-	// todo: implement labels matcher
-	lNames, err := q.labelsReader.LabelNames()
+func (q samplesQuerier) LabelNames(matchers ...*labels.Matcher) ([]string, storage.Warnings, error) {
+	if q.unrestricted(matchers) {
+		lNames, err := q.labelsReader.LabelNames()
+		return lNames, nil, err
+	}
+	lNames, err := q.metricsReader.LabelsQuerier(q.ctx).LabelNames(q.mint, q.maxt, matchers...)
 	return lNames, nil, err
 }
 
+// unrestricted returns true if all labels in the database are requested, in
+// which case the label tables are read without looking at any series.
+func (q samplesQuerier) unrestricted(matchers []*labels.Matcher) bool {
+	return len(matchers) == 0 &&
+		q.mint <= timestamp.FromTime(model.MinTime) &&
+		q.maxt >= timestamp.FromTime(model.MaxTime)
+}
+
 func (q *samplesQuerier) Close() {
 	for _, ss := range q.seriesSets {
 		ss.Close()
@@ -0,0 +1,314 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package query
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/timescale/promscale/pkg/util"
+)
+
+// Priority is the priority class of a query. Queued queries of a higher class
+// are always admitted before those of a lower class.
+type Priority int
+
+const (
+	// PriorityBackground is for bulk reads such as remote read exports.
+	PriorityBackground Priority = iota
+	// PriorityAPI is for queries of the PromQL HTTP API.
+	PriorityAPI
+	// PriorityRules is for the evaluation of recording and alerting rules.
+	PriorityRules
+
+	numPriorities = int(PriorityRules) + 1
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityBackground:
+		return "background"
+	case PriorityAPI:
+		return "api"
+	case PriorityRules:
+		return "rules"
+	}
+	return "unknown"
+}
+
+var (
+	// ErrQueueFull is returned when a query can't be queued because the
+	// queue is full.
+	ErrQueueFull = errors.New("too many queued queries")
+	// ErrQueueTimeout is returned when a query waited in the queue longer
+	// than the queue timeout.
+	ErrQueueTimeout = errors.New("query timed out in queue")
+)
+
+var (
+	schedulerRunning = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "query",
+			Name:      "scheduler_running_queries",
+			Help:      "Number of queries admitted by the query scheduler and running.",
+		},
+	)
+	schedulerQueueLength = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "query",
+			Name:      "scheduler_queue_length",
+			Help:      "Number of queries waiting in the query scheduler queue.",
+		}, []string{"priority"},
+	)
+	schedulerQueueWait = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "query",
+			Name:      "scheduler_queue_wait_duration_seconds",
+			Help:      "Time queries waited in the query scheduler queue before being admitted.",
+			Buckets:   []float64{0.001, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		}, []string{"priority"},
+	)
+	schedulerRejected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "query",
+			Name:      "scheduler_rejected_total",
+			Help:      "Total number of queries rejected by the query scheduler.",
+		}, []string{"priority", "reason"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		schedulerRunning,
+		schedulerQueueLength,
+		schedulerQueueWait,
+		schedulerRejected,
+	)
+}
+
+// Scheduler limits the number of concurrently evaluated queries. Queries
+// exceeding the limit wait in a bounded queue, ordered by priority class.
+// Within a class queries are admitted round-robin across queue keys, usually
+// tenants or users, so a single key can't starve the others. A nil Scheduler
+// admits every query immediately.
+type Scheduler struct {
+	maxConcurrency int
+	maxQueued      int
+	queueTimeout   time.Duration
+	fairnessHeader string
+
+	mu      sync.Mutex
+	running int
+	queued  int
+	classes [numPriorities]fairQueue
+}
+
+// NewScheduler returns the scheduler described by cfg, nil if the number of
+// concurrent queries is unlimited.
+func NewScheduler(cfg *Config) *Scheduler {
+	if cfg.MaxConcurrentQueries <= 0 {
+		return nil
+	}
+	return &Scheduler{
+		maxConcurrency: cfg.MaxConcurrentQueries,
+		maxQueued:      cfg.MaxQueuedQueries,
+		queueTimeout:   cfg.QueueTimeout,
+		fairnessHeader: cfg.QueueFairnessHeader,
+	}
+}
+
+// FairnessHeader returns the HTTP header whose value is the queue key of API
+// queries.
+func (s *Scheduler) FairnessHeader() string {
+	if s == nil {
+		return ""
+	}
+	return s.fairnessHeader
+}
+
+// Acquire blocks until a query of priority p and queue key can run. The
+// returned function must be called once the query finished. It returns
+// ErrQueueFull, ErrQueueTimeout or the error of ctx if the query can't run.
+func (s *Scheduler) Acquire(ctx context.Context, p Priority, key string) (release func(), err error) {
+	if s == nil {
+		return func() {}, nil
+	}
+	s.mu.Lock()
+	// Queries are queued only when all slots are taken, so a free slot
+	// means the queue is empty.
+	if s.running < s.maxConcurrency {
+		s.running++
+		s.mu.Unlock()
+		schedulerRunning.Inc()
+		schedulerQueueWait.WithLabelValues(p.String()).Observe(0)
+		return s.release, nil
+	}
+	if s.queued >= s.maxQueued {
+		s.mu.Unlock()
+		schedulerRejected.WithLabelValues(p.String(), "queue_full").Inc()
+		return nil, ErrQueueFull
+	}
+	w := &waiter{admitted: make(chan struct{}), key: key}
+	s.classes[p].push(w)
+	s.queued++
+	s.mu.Unlock()
+	schedulerQueueLength.WithLabelValues(p.String()).Inc()
+
+	start := time.Now()
+	var timeout <-chan time.Time
+	if s.queueTimeout > 0 {
+		timer := time.NewTimer(s.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-w.admitted:
+		schedulerQueueWait.WithLabelValues(p.String()).Observe(time.Since(start).Seconds())
+		return s.release, nil
+	case <-timeout:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.mu.Lock()
+	if w.isAdmitted {
+		// Admitted concurrently with the timeout or cancellation, the slot
+		// is handed over to the next query.
+		s.mu.Unlock()
+		s.release()
+	} else {
+		s.classes[p].remove(w)
+		s.queued--
+		s.mu.Unlock()
+		schedulerQueueLength.WithLabelValues(p.String()).Dec()
+	}
+	reason := "timeout"
+	if err != ErrQueueTimeout {
+		reason = "canceled"
+	}
+	schedulerRejected.WithLabelValues(p.String(), reason).Inc()
+	return nil, err
+}
+
+type queryClassKey struct{}
+
+type queryClass struct {
+	priority Priority
+	key      string
+}
+
+// WithQueryClass returns a context carrying the priority and queue key of a
+// query admitted by the scheduler. The frontend queues the parts it evaluates
+// concurrently with them.
+func WithQueryClass(ctx context.Context, p Priority, key string) context.Context {
+	return context.WithValue(ctx, queryClassKey{}, queryClass{priority: p, key: key})
+}
+
+// queryClassFrom returns the priority and queue key of the query of ctx,
+// PriorityAPI and no key if ctx carries none.
+func queryClassFrom(ctx context.Context) (Priority, string) {
+	if c, ok := ctx.Value(queryClassKey{}).(queryClass); ok {
+		return c.priority, c.key
+	}
+	return PriorityAPI, ""
+}
+
+// release frees the slot of a query, admitting the next queued query of the
+// highest priority class.
+func (s *Scheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for p := numPriorities - 1; p >= 0; p-- {
+		if w := s.classes[p].pop(); w != nil {
+			s.queued--
+			w.isAdmitted = true
+			close(w.admitted)
+			schedulerQueueLength.WithLabelValues(Priority(p).String()).Dec()
+			return
+		}
+	}
+	s.running--
+	schedulerRunning.Dec()
+}
+
+type waiter struct {
+	key        string
+	admitted   chan struct{}
+	isAdmitted bool
+}
+
+// fairQueue is a queue per key, popped round-robin across keys.
+type fairQueue struct {
+	queues map[string][]*waiter
+	// keys are the keys with queued waiters, in round-robin order.
+	keys []string
+	next int
+}
+
+func (q *fairQueue) push(w *waiter) {
+	if q.queues == nil {
+		q.queues = make(map[string][]*waiter)
+	}
+	if len(q.queues[w.key]) == 0 {
+		q.keys = append(q.keys, w.key)
+	}
+	q.queues[w.key] = append(q.queues[w.key], w)
+}
+
+func (q *fairQueue) pop() *waiter {
+	if len(q.keys) == 0 {
+		return nil
+	}
+	if q.next >= len(q.keys) {
+		q.next = 0
+	}
+	key := q.keys[q.next]
+	queue := q.queues[key]
+	w := queue[0]
+	if len(queue) == 1 {
+		q.removeKey(q.next)
+	} else {
+		q.queues[key] = queue[1:]
+		q.next++
+	}
+	return w
+}
+
+func (q *fairQueue) remove(w *waiter) {
+	queue := q.queues[w.key]
+	for i := range queue {
+		if queue[i] != w {
+			continue
+		}
+		if len(queue) > 1 {
+			q.queues[w.key] = append(queue[:i:i], queue[i+1:]...)
+			return
+		}
+		for k, key := range q.keys {
+			if key == w.key {
+				q.removeKey(k)
+				return
+			}
+		}
+	}
+}
+
+// removeKey removes the key at index i, which has no more queued waiters.
+func (q *fairQueue) removeKey(i int) {
+	delete(q.queues, q.keys[i])
+	q.keys = append(q.keys[:i], q.keys[i+1:]...)
+	if i < q.next {
+		q.next--
+	}
+}
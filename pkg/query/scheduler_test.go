@@ -0,0 +1,94 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package query
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerDisabled(t *testing.T) {
+	s := NewScheduler(&Config{})
+	require.Nil(t, s)
+	release, err := s.Acquire(context.Background(), PriorityAPI, "")
+	require.NoError(t, err)
+	release()
+}
+
+// enqueue starts acquiring a slot in the background. The slot is released as
+// soon as it is admitted and the queue key is sent to admitted.
+func enqueue(t *testing.T, s *Scheduler, p Priority, key string, admitted chan<- string) {
+	queued := s.queuedCount()
+	go func() {
+		release, err := s.Acquire(context.Background(), p, key)
+		if err != nil {
+			admitted <- err.Error()
+			return
+		}
+		admitted <- key
+		release()
+	}()
+	require.Eventually(t, func() bool { return s.queuedCount() == queued+1 }, time.Second, time.Millisecond)
+}
+
+func (s *Scheduler) queuedCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queued
+}
+
+func TestSchedulerPriorityAndFairness(t *testing.T) {
+	s := NewScheduler(&Config{MaxConcurrentQueries: 1, MaxQueuedQueries: 10})
+	release, err := s.Acquire(context.Background(), PriorityAPI, "")
+	require.NoError(t, err)
+
+	// Admitted one at a time since each releases its slot before the next
+	// one is admitted.
+	admitted := make(chan string)
+	enqueue(t, s, PriorityBackground, "export", admitted)
+	enqueue(t, s, PriorityAPI, "a", admitted)
+	enqueue(t, s, PriorityAPI, "a", admitted)
+	enqueue(t, s, PriorityAPI, "a", admitted)
+	enqueue(t, s, PriorityAPI, "b", admitted)
+	enqueue(t, s, PriorityRules, "rules", admitted)
+	release()
+
+	var order []string
+	for i := 0; i < 6; i++ {
+		order = append(order, <-admitted)
+	}
+	require.Equal(t, []string{"rules", "a", "b", "a", "a", "export"}, order)
+
+	// All slots are free again.
+	release, err = s.Acquire(context.Background(), PriorityAPI, "")
+	require.NoError(t, err)
+	release()
+}
+
+func TestSchedulerRejects(t *testing.T) {
+	s := NewScheduler(&Config{MaxConcurrentQueries: 1, MaxQueuedQueries: 1, QueueTimeout: 50 * time.Millisecond})
+	release, err := s.Acquire(context.Background(), PriorityAPI, "")
+	require.NoError(t, err)
+
+	admitted := make(chan string)
+	enqueue(t, s, PriorityAPI, "a", admitted)
+	_, err = s.Acquire(context.Background(), PriorityAPI, "b")
+	require.ErrorIs(t, err, ErrQueueFull)
+	require.Equal(t, ErrQueueTimeout.Error(), <-admitted)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.Acquire(ctx, PriorityAPI, "c")
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 0, s.queuedCount())
+
+	release()
+	release, err = s.Acquire(context.Background(), PriorityAPI, "")
+	require.NoError(t, err)
+	release()
+}
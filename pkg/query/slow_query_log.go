@@ -0,0 +1,275 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/util"
+)
+
+// SlowQueryLogTable is the table slow queries are recorded in when
+// metrics.promql.slow-query-log.table is set. It is created by the connector
+// migrations (pkg/migrations/sql/connector), the connector inserts and deletes
+// slow queries with the prom_writer role.
+const SlowQueryLogTable = "_ps_catalog.slow_query_log"
+
+const (
+	insertSlowQuerySQL = `INSERT INTO _ps_catalog.slow_query_log (time, query, query_start, query_end, step_seconds, tenant, caller,
+		duration_seconds, samples_touched, series_fetched, sql_round_trips, sql_duration_seconds, error)
+	VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, $11, $12, NULLIF($13, ''))`
+	deleteSlowQueriesSQL = `DELETE FROM _ps_catalog.slow_query_log WHERE time < $1`
+
+	// slowQueryTableQueueSize is the number of slow queries waiting to be
+	// inserted into the table. Slow queries are dropped from the table when
+	// the queue is full, so a struggling database doesn't slow queries down
+	// further.
+	slowQueryTableQueueSize = 1000
+	slowQueryTableTimeout   = 10 * time.Second
+	slowQueryRetentionEvery = time.Hour
+)
+
+var (
+	slowQueries = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "query",
+			Name:      "slow_queries_total",
+			Help:      "Total number of PromQL queries recorded in the slow query log.",
+		},
+	)
+	slowQueryLogFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "query",
+			Name:      "slow_query_log_failures_total",
+			Help:      "Total number of slow queries which could not be written to the log file or table.",
+		}, []string{"output"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		slowQueries,
+		slowQueryLogFailures,
+	)
+}
+
+// SlowQuery is an entry of the slow query log.
+type SlowQuery struct {
+	Time  time.Time `json:"time"`
+	Query string    `json:"query"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// StepSeconds is 0 for instant queries.
+	StepSeconds float64 `json:"stepSeconds"`
+	Tenant      string  `json:"tenant,omitempty"`
+	Caller      Caller  `json:"caller"`
+
+	DurationSeconds    float64 `json:"durationSeconds"`
+	SamplesTouched     int64   `json:"samplesTouched"`
+	SeriesFetched      int     `json:"seriesFetched"`
+	SQLRoundTrips      int     `json:"sqlRoundTrips"`
+	SQLDurationSeconds float64 `json:"sqlDurationSeconds"`
+	Error              string  `json:"error,omitempty"`
+}
+
+// Caller identifies the client which sent a query. The dashboard and panel
+// are taken from the headers Grafana sets on its data source requests.
+type Caller struct {
+	Endpoint     string `json:"endpoint"`
+	RemoteAddr   string `json:"remoteAddr"`
+	UserAgent    string `json:"userAgent,omitempty"`
+	Referer      string `json:"referer,omitempty"`
+	DashboardUID string `json:"dashboardUid,omitempty"`
+	PanelID      string `json:"panelId,omitempty"`
+}
+
+// SlowQueryLog records the queries taking longer than a threshold. They are
+// written to a rotating file, to the _ps_catalog.slow_query_log table and
+// the most recent ones are kept in memory, depending on the configuration.
+// A nil SlowQueryLog is disabled.
+type SlowQueryLog struct {
+	threshold time.Duration
+
+	mu     sync.Mutex
+	file   *rotatingFile
+	recent []SlowQuery
+	next   int
+
+	conn      pgxconn.PgxConn
+	retention time.Duration
+	queue     chan SlowQuery
+	done      chan struct{}
+}
+
+// NewSlowQueryLog creates the slow query log described by cfg. The table is
+// written with conn, it must not be nil if the table is enabled. It returns
+// nil if the log is disabled.
+func NewSlowQueryLog(cfg *Config, conn pgxconn.PgxConn) (*SlowQueryLog, error) {
+	if cfg.SlowQueryThreshold == 0 {
+		return nil, nil
+	}
+	l := &SlowQueryLog{
+		threshold: cfg.SlowQueryThreshold,
+		recent:    make([]SlowQuery, 0, cfg.SlowQueryLogRecent),
+	}
+	if cfg.SlowQueryLogFile != "" {
+		f, err := openRotatingFile(cfg.SlowQueryLogFile, int64(cfg.SlowQueryLogMaxFileSize)<<20, cfg.SlowQueryLogMaxFiles)
+		if err != nil {
+			return nil, fmt.Errorf("opening slow query log file: %w", err)
+		}
+		l.file = f
+	}
+	if cfg.SlowQueryTable {
+		if conn == nil {
+			return nil, fmt.Errorf("the slow query table needs a connection with write permissions")
+		}
+		l.conn = conn
+		l.retention = cfg.SlowQueryTableRetention
+		l.queue = make(chan SlowQuery, slowQueryTableQueueSize)
+		l.done = make(chan struct{})
+		go l.writeTable(l.queue)
+	}
+	return l, nil
+}
+
+// IsSlow tells if a query taking d is recorded in the log.
+func (l *SlowQueryLog) IsSlow(d time.Duration) bool {
+	return l != nil && d >= l.threshold
+}
+
+// Log records the slow query q. Failures are logged and counted rather than
+// returned, since they must not fail the query.
+func (l *SlowQueryLog) Log(q SlowQuery) {
+	if l == nil {
+		return
+	}
+	slowQueries.Inc()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if cap(l.recent) > 0 {
+		if len(l.recent) < cap(l.recent) {
+			l.recent = append(l.recent, q)
+		} else {
+			l.recent[l.next] = q
+		}
+		l.next = (l.next + 1) % cap(l.recent)
+	}
+	if l.file != nil {
+		if err := l.writeFile(q); err != nil {
+			slowQueryLogFailures.WithLabelValues("file").Inc()
+			log.Warn("msg", "error writing slow query log file", "err", err)
+		}
+	}
+	if l.queue != nil {
+		select {
+		case l.queue <- q:
+		default:
+			slowQueryLogFailures.WithLabelValues("table").Inc()
+		}
+	}
+}
+
+func (l *SlowQueryLog) writeFile(q SlowQuery) error {
+	b, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	_, err = l.file.Write(append(b, '\n'))
+	return err
+}
+
+// Recent returns up to limit of the most recent slow queries, the most recent
+// first. A limit of 0 returns all the slow queries kept in memory.
+func (l *SlowQueryLog) Recent(limit int) []SlowQuery {
+	if l == nil {
+		return []SlowQuery{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := len(l.recent)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	res := make([]SlowQuery, 0, n)
+	for i := 1; i <= n; i++ {
+		res = append(res, l.recent[(l.next-i+len(l.recent))%len(l.recent)])
+	}
+	return res
+}
+
+func (l *SlowQueryLog) writeTable(queue <-chan SlowQuery) {
+	defer close(l.done)
+	retention := time.NewTicker(slowQueryRetentionEvery)
+	defer retention.Stop()
+	for {
+		select {
+		case q, ok := <-queue:
+			if !ok {
+				return
+			}
+			if err := l.insert(q); err != nil {
+				slowQueryLogFailures.WithLabelValues("table").Inc()
+				log.Warn("msg", "error inserting slow query", "err", err)
+			}
+		case <-retention.C:
+			if err := l.deleteExpired(); err != nil {
+				log.Warn("msg", "error deleting expired slow queries", "err", err)
+			}
+		}
+	}
+}
+
+func (l *SlowQueryLog) insert(q SlowQuery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), slowQueryTableTimeout)
+	defer cancel()
+	caller, err := json.Marshal(q.Caller)
+	if err != nil {
+		return err
+	}
+	_, err = l.conn.Exec(ctx, insertSlowQuerySQL, q.Time, q.Query, q.Start, q.End, q.StepSeconds, q.Tenant, string(caller),
+		q.DurationSeconds, q.SamplesTouched, q.SeriesFetched, q.SQLRoundTrips, q.SQLDurationSeconds, q.Error)
+	return err
+}
+
+func (l *SlowQueryLog) deleteExpired() error {
+	ctx, cancel := context.WithTimeout(context.Background(), slowQueryTableTimeout)
+	defer cancel()
+	_, err := l.conn.Exec(ctx, deleteSlowQueriesSQL, time.Now().Add(-l.retention))
+	return err
+}
+
+// Close flushes the slow queries waiting to be inserted into the table and
+// closes the log file.
+func (l *SlowQueryLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	queue := l.queue
+	l.queue = nil
+	var err error
+	if l.file != nil {
+		err = l.file.Close()
+		l.file = nil
+	}
+	l.mu.Unlock()
+
+	if queue != nil {
+		close(queue)
+		<-l.done
+	}
+	return err
+}
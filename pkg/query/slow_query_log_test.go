@@ -0,0 +1,114 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package query
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowQueryLogDisabled(t *testing.T) {
+	l, err := NewSlowQueryLog(&Config{}, nil)
+	require.NoError(t, err)
+	require.Nil(t, l)
+	require.False(t, l.IsSlow(time.Hour))
+	l.Log(SlowQuery{Query: "up"})
+	require.Empty(t, l.Recent(0))
+	require.NoError(t, l.Close())
+}
+
+func TestSlowQueryLogRecent(t *testing.T) {
+	l, err := NewSlowQueryLog(&Config{SlowQueryThreshold: time.Second, SlowQueryLogRecent: 3}, nil)
+	require.NoError(t, err)
+	defer l.Close()
+
+	require.False(t, l.IsSlow(time.Millisecond))
+	require.True(t, l.IsSlow(time.Second))
+
+	queries := func(qs []SlowQuery) []string {
+		res := make([]string, len(qs))
+		for i, q := range qs {
+			res[i] = q.Query
+		}
+		return res
+	}
+	l.Log(SlowQuery{Query: "a"})
+	l.Log(SlowQuery{Query: "b"})
+	require.Equal(t, []string{"b", "a"}, queries(l.Recent(0)))
+
+	l.Log(SlowQuery{Query: "c"})
+	l.Log(SlowQuery{Query: "d"})
+	require.Equal(t, []string{"d", "c", "b"}, queries(l.Recent(0)))
+	require.Equal(t, []string{"d", "c"}, queries(l.Recent(2)))
+	require.Equal(t, []string{"d", "c", "b"}, queries(l.Recent(10)))
+}
+
+func TestSlowQueryLogTableNeedsConnection(t *testing.T) {
+	_, err := NewSlowQueryLog(&Config{SlowQueryThreshold: time.Second, SlowQueryTable: true}, nil)
+	require.Error(t, err)
+}
+
+func TestSlowQueryLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "slow.log")
+	l, err := NewSlowQueryLog(&Config{SlowQueryThreshold: time.Second, SlowQueryLogFile: path, SlowQueryLogMaxFileSize: 1}, nil)
+	require.NoError(t, err)
+	l.Log(SlowQuery{Query: "a", Tenant: "t1", DurationSeconds: 2, Caller: Caller{Endpoint: "/api/v1/query"}})
+	l.Log(SlowQuery{Query: "b", SQLRoundTrips: 3, Error: "timeout"})
+	require.NoError(t, l.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var logged []SlowQuery
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var q SlowQuery
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &q))
+		logged = append(logged, q)
+	}
+	require.NoError(t, scanner.Err())
+	require.Equal(t, []SlowQuery{
+		{Query: "a", Tenant: "t1", DurationSeconds: 2, Caller: Caller{Endpoint: "/api/v1/query"}},
+		{Query: "b", SQLRoundTrips: 3, Error: "timeout"},
+	}, logged)
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "slow.log")
+	r, err := openRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+
+	for _, line := range []string{"aaaaaa\n", "bbbbbb\n", "cc\n", "dddddd\n", "eeeeeeeeeeee\n"} {
+		_, err = r.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, r.Close())
+
+	read := func(p string) string {
+		b, err := os.ReadFile(p)
+		require.NoError(t, err)
+		return string(b)
+	}
+	// An entry larger than the maximum size is written to an empty file
+	// rather than split.
+	require.Equal(t, "eeeeeeeeeeee\n", read(path))
+	require.Equal(t, "dddddd\n", read(path+".1"))
+	require.Equal(t, "bbbbbb\ncc\n", read(path+".2"))
+	require.NoFileExists(t, path+".3")
+
+	// Reopening appends to the existing file.
+	r, err = openRotatingFile(path, 100, 2)
+	require.NoError(t, err)
+	_, err = r.Write([]byte("f\n"))
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, "eeeeeeeeeeee\nf\n", read(path))
+}
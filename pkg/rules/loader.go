@@ -0,0 +1,76 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package rules
+
+import (
+	"errors"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	prom_parser "github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/timescale/promscale/pkg/promql/parser"
+)
+
+// groupLoader loads rule files with the Prometheus loader, but checks and
+// parses the rule expressions with the Promscale PromQL parser, which knows
+// about the functions and aggregators of newer Prometheus releases.
+type groupLoader struct{}
+
+func (groupLoader) Load(identifier string) (*rulefmt.RuleGroups, []error) {
+	rgs, errs := rulefmt.ParseFile(identifier)
+	if rgs == nil {
+		return nil, errs
+	}
+	var res []error
+	for _, err := range errs {
+		// Expressions the Prometheus parser rejected are checked again.
+		var ruleErr *rulefmt.Error
+		var parseErrs prom_parser.ParseErrors
+		if errors.As(err, &ruleErr) && errors.As(err, &parseErrs) {
+			if r, ok := findRule(rgs, ruleErr.Group, ruleErr.Rule); ok {
+				if _, err := parser.ParseExpr(r.Expr.Value); err == nil {
+					continue
+				}
+			}
+		}
+		res = append(res, err)
+	}
+	return rgs, res
+}
+
+func (groupLoader) Parse(query string) (prom_parser.Expr, error) {
+	e, err := parser.ParseExpr(query)
+	if err != nil {
+		return nil, err
+	}
+	return ruleExpr{e}, nil
+}
+
+// findRule returns the rule of a group by its position, starting at 1.
+func findRule(rgs *rulefmt.RuleGroups, group string, rule int) (rulefmt.RuleNode, bool) {
+	for _, g := range rgs.Groups {
+		if g.Name == group && rule >= 1 && rule <= len(g.Rules) {
+			return g.Rules[rule-1], true
+		}
+	}
+	return rulefmt.RuleNode{}, false
+}
+
+// ruleExpr is an expression of the Promscale parser given to the Prometheus
+// rules manager, which only uses its string form to evaluate and show it.
+type ruleExpr struct {
+	parser.Expr
+}
+
+func (e ruleExpr) PositionRange() prom_parser.PositionRange {
+	r := e.Expr.PositionRange()
+	return prom_parser.PositionRange{Start: prom_parser.Pos(r.Start), End: prom_parser.Pos(r.End)}
+}
+
+func (e ruleExpr) Type() prom_parser.ValueType {
+	return prom_parser.ValueType(e.Expr.Type())
+}
+
+func (ruleExpr) PromQLExpr() {}
@@ -0,0 +1,29 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupLoader(t *testing.T) {
+	loader := groupLoader{}
+	rgs, errs := loader.Load("./testdata/newer_promql.rules.yaml")
+	require.Empty(t, errs)
+	require.Len(t, rgs.Groups, 1)
+	require.Len(t, rgs.Groups[0].Rules, 2)
+
+	expr, err := loader.Parse(rgs.Groups[0].Rules[0].Expr.Value)
+	require.NoError(t, err)
+	require.Equal(t, "limitk by (job) (2, up)", expr.String())
+	require.Equal(t, "vector", string(expr.Type()))
+
+	_, errs = loader.Load("./testdata/invalid_promql.rules.yaml")
+	require.Len(t, errs, 1)
+	_, err = loader.Parse("limitk by (job) (up)")
+	require.Error(t, err)
+}
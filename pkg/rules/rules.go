@@ -82,11 +82,12 @@ func NewManager(ctx context.Context, r prometheus.Registerer, client *pgclient.C
 		ExternalURL:     parsedUrl,
 		Logger:          log.GetLogger(),
 		NotifyFunc:      sendAlerts(notifierManager, parsedUrl.String()),
-		QueryFunc:       engineQueryFunc(client.QueryEngine(), client.Queryable()),
+		QueryFunc:       engineQueryFunc(client.QueryEngine(), client.Queryable(), client.QueryScheduler()),
 		Registerer:      r,
 		OutageTolerance: cfg.OutageTolerance,
 		ForGracePeriod:  cfg.ForGracePeriod,
 		ResendDelay:     cfg.ResendDelay,
+		GroupLoader:     groupLoader{},
 	})
 
 	manager := &Manager{
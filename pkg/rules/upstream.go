@@ -17,19 +17,25 @@ import (
 	"github.com/prometheus/prometheus/util/strutil"
 
 	promscale_promql "github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/query"
 )
 
 // engineQueryFunc returns a new query function that executes instant queries against
 // the given engine.
 // It converts scalar into vector results.
 // Note: This function is copied from the link given in the starting of the file and modified
-// to adapt to Promscale's PromQL engine.
-func engineQueryFunc(engine *promscale_promql.Engine, q promscale_promql.Queryable) rules.QueryFunc {
+// to adapt to Promscale's PromQL engine and to queue queries in the query scheduler.
+func engineQueryFunc(engine *promscale_promql.Engine, q promscale_promql.Queryable, scheduler *query.Scheduler) rules.QueryFunc {
 	return func(ctx context.Context, qs string, t time.Time) (prometheus_promql.Vector, error) {
 		q, err := engine.NewInstantQuery(q, nil, qs, t)
 		if err != nil {
 			return nil, err
 		}
+		release, err := scheduler.Acquire(ctx, query.PriorityRules, "")
+		if err != nil {
+			return nil, err
+		}
+		defer release()
 		res := q.Exec(ctx)
 		if res.Err != nil {
 			return nil, res.Err
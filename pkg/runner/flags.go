@@ -14,12 +14,15 @@ import (
 	"github.com/timescale/promscale/pkg/api"
 	"github.com/timescale/promscale/pkg/auth"
 	"github.com/timescale/promscale/pkg/dataset"
+	"github.com/timescale/promscale/pkg/graphite"
 	jaegerStore "github.com/timescale/promscale/pkg/jaeger/store"
 	"github.com/timescale/promscale/pkg/limits"
 	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/logs"
 	"github.com/timescale/promscale/pkg/pgclient"
 	"github.com/timescale/promscale/pkg/query"
 	"github.com/timescale/promscale/pkg/rules"
+	"github.com/timescale/promscale/pkg/scrape"
 	"github.com/timescale/promscale/pkg/tenancy"
 	"github.com/timescale/promscale/pkg/tracer"
 	"github.com/timescale/promscale/pkg/util"
@@ -39,7 +42,10 @@ type Config struct {
 	TenancyCfg                  tenancy.Config
 	PromQLCfg                   query.Config
 	RulesCfg                    rules.Config
+	ScrapeCfg                   scrape.Config
+	GraphiteCfg                 graphite.Config
 	TracingCfg                  jaegerStore.Config
+	LogsCfg                     logs.Config
 	VacuumCfg                   vacuum.Config
 	ConfigFile                  string
 	DatasetConfig               string
@@ -142,6 +148,9 @@ func ParseFlags(cfg *Config, args []string) (*Config, error) {
 	query.ParseFlags(fs, &cfg.PromQLCfg)
 	jaegerStore.ParseFlags(fs, &cfg.TracingCfg)
 	rules.ParseFlags(fs, &cfg.RulesCfg)
+	scrape.ParseFlags(fs, &cfg.ScrapeCfg)
+	graphite.ParseFlags(fs, &cfg.GraphiteCfg)
+	logs.ParseFlags(fs, &cfg.LogsCfg)
 	vacuum.ParseFlags(fs, &cfg.VacuumCfg)
 
 	fs.StringVar(&cfg.ConfigFile, configFileFlagName, "config.yml", "YAML configuration file path for Promscale.")
@@ -228,6 +237,18 @@ func ParseFlags(cfg *Config, args []string) (*Config, error) {
 		if flagset["metrics.high-availability"] && cfg.APICfg.HighAvailability {
 			return nil, fmt.Errorf("cannot run Promscale in both HA and read-only mode")
 		}
+		if cfg.PromQLCfg.SlowQueryThreshold > 0 && cfg.PromQLCfg.SlowQueryTable {
+			return nil, fmt.Errorf("cannot record slow queries in the database in read-only mode")
+		}
+		if cfg.ScrapeCfg.Enabled {
+			return nil, fmt.Errorf("cannot scrape targets in read-only mode")
+		}
+		if cfg.GraphiteCfg.Enabled() {
+			return nil, fmt.Errorf("cannot ingest Graphite metrics in read-only mode")
+		}
+		if cfg.PgmodelCfg.SpoolConfig.Enabled() {
+			return nil, fmt.Errorf("cannot spool write requests in read-only mode")
+		}
 		cfg.Migrate = false
 		cfg.StopAfterMigrate = false
 		cfg.UseVersionLease = false
@@ -266,6 +287,15 @@ func validate(cfg *Config) error {
 	if err := rules.Validate(&cfg.RulesCfg); err != nil {
 		return fmt.Errorf("error validating rules configuration: %w", err)
 	}
+	if err := scrape.Validate(&cfg.ScrapeCfg); err != nil {
+		return fmt.Errorf("error validating scrape configuration: %w", err)
+	}
+	if err := graphite.Validate(&cfg.GraphiteCfg); err != nil {
+		return fmt.Errorf("error validating Graphite configuration: %w", err)
+	}
+	if err := logs.Validate(&cfg.LogsCfg); err != nil {
+		return fmt.Errorf("error validating logs configuration: %w", err)
+	}
 	if err := vacuum.Validate(&cfg.VacuumCfg); err != nil {
 		return fmt.Errorf("error validating vacuum configuration: %w", err)
 	}
@@ -123,6 +123,32 @@ func TestParseFlags(t *testing.T) {
 			},
 			shouldError: true,
 		},
+		{
+			name: "Recording slow queries in the table and read-only error",
+			args: []string{
+				"-metrics.promql.slow-query-log.threshold", "1s",
+				"-metrics.promql.slow-query-log.table",
+				"-db.read-only",
+			},
+			shouldError: true,
+		},
+		{
+			name: "Slow query table without threshold in read-only mode",
+			args: []string{
+				"-metrics.promql.slow-query-log.table",
+				"-db.read-only",
+			},
+			result: func(c Config) Config {
+				c.APICfg.ReadOnly = true
+				c.PromQLCfg.SlowQueryTable = true
+				c.Migrate = false
+				c.StopAfterMigrate = false
+				c.UseVersionLease = false
+				c.InstallExtensions = false
+				c.UpgradeExtensions = false
+				return c
+			},
+		},
 		{
 			name: "invalid TLS setup, missing key file",
 			args: []string{
@@ -15,12 +15,14 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/oklog/run"
 	"github.com/timescale/promscale/pkg/vacuum"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
 	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
 	"go.opentelemetry.io/otel"
 	"google.golang.org/grpc"
@@ -35,12 +37,17 @@ import (
 	"github.com/thanos-io/thanos/pkg/store/storepb"
 
 	"github.com/timescale/promscale/pkg/api"
+	"github.com/timescale/promscale/pkg/graphite"
 	jaegerStore "github.com/timescale/promscale/pkg/jaeger/store"
 	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/logs"
 	"github.com/timescale/promscale/pkg/pgclient"
+	"github.com/timescale/promscale/pkg/pgmodel"
 	"github.com/timescale/promscale/pkg/pgmodel/ingestor/trace"
 	dbMetrics "github.com/timescale/promscale/pkg/pgmodel/metrics/database"
+	"github.com/timescale/promscale/pkg/query"
 	"github.com/timescale/promscale/pkg/rules"
+	"github.com/timescale/promscale/pkg/scrape"
 	"github.com/timescale/promscale/pkg/telemetry"
 	"github.com/timescale/promscale/pkg/thanos"
 	"github.com/timescale/promscale/pkg/tracer"
@@ -179,6 +186,112 @@ func Run(cfg *Config) error {
 		)
 	}
 
+	if cfg.ScrapeCfg.Enabled {
+		scrapeCtx, stopScraper := context.WithCancel(context.Background())
+		defer stopScraper()
+		manager := scrape.NewManager(scrapeCtx, client.Inserter())
+		// The scrape configs are read from the Prometheus configuration of the
+		// rules, which the rules reloader reloads.
+		reloadRules := rulesReloader
+		rulesReloader = func() error {
+			if err := reloadRules(); err != nil {
+				return err
+			}
+			return manager.ApplyConfig(cfg.RulesCfg.PrometheusConfig)
+		}
+		if err = manager.ApplyConfig(cfg.RulesCfg.PrometheusConfig); err != nil {
+			log.Error("msg", "error applying scrape configuration", "err", err.Error())
+			return fmt.Errorf("error applying scrape configuration: %w", err)
+		}
+
+		group.Add(
+			func() error {
+				log.Info("msg", "Started Scrape-Manager")
+				return manager.Run()
+			}, func(error) {
+				log.Info("msg", "Stopping Scrape-Manager")
+				stopScraper()
+			},
+		)
+	}
+
+	if cfg.GraphiteCfg.Enabled() {
+		graphiteCtx, stopGraphite := context.WithCancel(context.Background())
+		defer stopGraphite()
+		server := graphite.NewServer(&cfg.GraphiteCfg, client.Inserter())
+		group.Add(
+			func() error {
+				log.Info("msg", "Started Graphite listeners")
+				return server.Run(graphiteCtx)
+			}, func(error) {
+				log.Info("msg", "Stopping Graphite listeners")
+				stopGraphite()
+			},
+		)
+	}
+
+	if cfg.PromQLCfg.SlowQueryThreshold > 0 && cfg.PromQLCfg.SlowQueryTable {
+		missing, err := pgmodel.MissingRelations(context.Background(), client.ReadOnlyConnection(), query.SlowQueryLogTable)
+		if err != nil {
+			log.Error("msg", "aborting startup due to error", "err", fmt.Sprintf("slow query log: %s", err.Error()))
+			return fmt.Errorf("slow query log: %w", err)
+		}
+		if len(missing) > 0 {
+			log.Warn("msg", "Recording slow queries in the database is disabled since its table doesn't exist, it is created when a connector migrates the database", "missing", strings.Join(missing, ","))
+			cfg.PromQLCfg.SlowQueryTable = false
+		}
+	}
+	slowQueryLog, err := query.NewSlowQueryLog(&cfg.PromQLCfg, client.WriterConnection())
+	if err != nil {
+		log.Error("msg", "aborting startup due to error", "err", fmt.Sprintf("slow query log: %s", err.Error()))
+		return fmt.Errorf("slow query log: %w", err)
+	}
+	defer slowQueryLog.Close()
+	cfg.APICfg.SlowQueryLog = slowQueryLog
+	cfg.APICfg.QueryScheduler = client.QueryScheduler()
+
+	if cfg.LogsCfg.Enabled {
+		missing, err := pgmodel.MissingRelations(context.Background(), client.ReadOnlyConnection(), logs.LogTable)
+		if err != nil {
+			log.Error("msg", "aborting startup due to error", "err", fmt.Sprintf("logs: %s", err.Error()))
+			return fmt.Errorf("logs: %w", err)
+		}
+		if len(missing) > 0 {
+			log.Warn("msg", "Logs are disabled since their table doesn't exist, it is created when a connector migrates the database", "missing", strings.Join(missing, ","))
+			cfg.LogsCfg.Enabled = false
+		}
+	}
+	if cfg.LogsCfg.Enabled {
+		cfg.APICfg.Logs = logs.NewStore(client.ReadOnlyConnection())
+		if !cfg.APICfg.ReadOnly && cfg.LogsCfg.RetentionPeriod > 0 {
+			retentionCtx, stopRetention := context.WithCancel(context.Background())
+			defer stopRetention()
+			retention := logs.NewRetention(client.MaintenanceConnection(), cfg.LogsCfg.RetentionPeriod)
+			group.Add(
+				func() error {
+					log.Info("msg", "Started log retention", "retention-period", cfg.LogsCfg.RetentionPeriod)
+					return retention.Run(retentionCtx)
+				}, func(error) {
+					log.Info("msg", "Stopping log retention")
+					stopRetention()
+				},
+			)
+		}
+	}
+
+	var jaegerArchive *jaegerStore.Archive
+	if cfg.TracingCfg.ArchiveStorage {
+		missing, err := pgmodel.MissingRelations(context.Background(), client.ReadOnlyConnection(), jaegerStore.ArchiveTables...)
+		if err != nil {
+			log.Error("msg", "aborting startup due to error", "err", fmt.Sprintf("jaeger archive storage: %s", err.Error()))
+			return fmt.Errorf("jaeger archive storage: %w", err)
+		}
+		if len(missing) > 0 {
+			log.Warn("msg", "Jaeger archive storage is disabled since its tables don't exist, they are created when a connector migrates the database", "missing", strings.Join(missing, ","))
+		} else {
+			jaegerArchive = jaegerStore.NewArchive(client.ReadOnlyConnection(), client.WriterConnection(), &cfg.TracingCfg)
+		}
+	}
 	jaegerStore := jaegerStore.New(client.ReadOnlyConnection(), client.Inserter(), &cfg.TracingCfg)
 
 	authWrapper := func(h http.Handler) http.Handler {
@@ -243,6 +356,9 @@ func Run(cfg *Config) error {
 	}
 	grpcServer := grpc.NewServer(options...)
 	ptraceotlp.RegisterServer(grpcServer, api.NewTraceServer(client))
+	if cfg.LogsCfg.Enabled {
+		plogotlp.RegisterServer(grpcServer, api.NewLogServer(client))
+	}
 
 	queryPlugin := shared.StorageGRPCPlugin{
 		Impl: jaegerStore,
@@ -253,6 +369,10 @@ func Run(cfg *Config) error {
 	} else {
 		log.Info("msg", "Jaeger StreamingSpanWriter is disabled")
 	}
+	if jaegerArchive != nil {
+		queryPlugin.ArchiveImpl = jaegerArchive
+		log.Info("msg", "Jaeger archive storage is enabled")
+	}
 	if err = queryPlugin.GRPCServer(nil, grpcServer); err != nil {
 		log.Error("msg", "Creating jaeger query GRPC server failed", "err", err)
 		return err
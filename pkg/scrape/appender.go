@@ -0,0 +1,113 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package scrape
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/pgmodel/metrics"
+	"github.com/timescale/promscale/pkg/prompb"
+	"github.com/timescale/promscale/pkg/util"
+)
+
+var samplesIngested = metrics.IngestorItems.With(map[string]string{"type": "metric", "kind": "sample", "subsystem": "scrape"})
+
+// ingestAppendable makes the DBIngestor a storage.Appendable for the scrape
+// manager.
+type ingestAppendable struct {
+	inserter ingestor.DBInserter
+}
+
+// Appender returns an appender for the samples of a single scrape, which are
+// ingested as one write request on Commit. Staleness markers are appended by
+// the scrape loop as regular samples with the stale NaN value.
+func (a ingestAppendable) Appender(ctx context.Context) storage.Appender {
+	return &appender{
+		ctx:      ctx,
+		inserter: a.inserter,
+		series:   make(map[uint64]int),
+	}
+}
+
+type appender struct {
+	ctx      context.Context
+	inserter ingestor.DBInserter
+	// series maps the hash of the labels to the index of the series in
+	// timeseries.
+	series     map[uint64]int
+	timeseries []prompb.TimeSeries
+	closed     bool
+}
+
+func (app *appender) getSeries(l labels.Labels) *prompb.TimeSeries {
+	h := l.Hash()
+	if i, ok := app.series[h]; ok {
+		return &app.timeseries[i]
+	}
+	app.series[h] = len(app.timeseries)
+	app.timeseries = append(app.timeseries, prompb.TimeSeries{Labels: util.LabelToPrompbLabels(l)})
+	return &app.timeseries[len(app.timeseries)-1]
+}
+
+func (app *appender) Append(_ storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	if app.closed {
+		return 0, fmt.Errorf("cannot append: closed appender")
+	}
+	ts := app.getSeries(l)
+	ts.Samples = append(ts.Samples, prompb.Sample{Timestamp: t, Value: v})
+	return 0, nil
+}
+
+func (app *appender) AppendExemplar(_ storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	if app.closed {
+		return 0, fmt.Errorf("cannot append: closed appender")
+	}
+	ts := app.getSeries(l)
+	ts.Exemplars = append(ts.Exemplars, prompb.Exemplar{
+		Labels:    util.LabelToPrompbLabels(e.Labels),
+		Value:     e.Value,
+		Timestamp: e.Ts,
+	})
+	return 0, nil
+}
+
+func (app *appender) UpdateMetadata(_ storage.SeriesRef, _ labels.Labels, _ metadata.Metadata) (storage.SeriesRef, error) {
+	// Metadata is not passed to appenders since the metadata storage of the
+	// scrape manager isn't enabled.
+	return 0, nil
+}
+
+func (app *appender) Commit() error {
+	if app.closed {
+		return fmt.Errorf("cannot commit: closed appender")
+	}
+	app.closed = true
+	if len(app.timeseries) == 0 {
+		return nil
+	}
+	wr := ingestor.NewWriteRequest()
+	wr.Timeseries = app.timeseries
+	app.timeseries = nil
+	numInsertablesIngested, _, err := app.inserter.IngestMetrics(app.ctx, wr)
+	if err == nil {
+		samplesIngested.Add(float64(numInsertablesIngested))
+	}
+	return errors.WithMessage(err, "scrape: error ingesting data into db-ingestor")
+}
+
+func (app *appender) Rollback() error {
+	app.closed = true
+	app.series = nil
+	app.timeseries = nil
+	return nil
+}
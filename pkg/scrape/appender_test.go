@@ -0,0 +1,74 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package scrape
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+type mockInserter struct {
+	requests []*prompb.WriteRequest
+}
+
+func (m *mockInserter) IngestMetrics(_ context.Context, r *prompb.WriteRequest) (uint64, uint64, error) {
+	m.requests = append(m.requests, r)
+	return 0, 0, nil
+}
+
+func (m *mockInserter) IngestTraces(context.Context, ptrace.Traces) error { return nil }
+
+func (m *mockInserter) IngestLogs(context.Context, plog.Logs) error { return nil }
+
+func (m *mockInserter) Close() {}
+
+func TestAppender(t *testing.T) {
+	inserter := &mockInserter{}
+	appendable := ingestAppendable{inserter}
+
+	up := labels.FromStrings("__name__", "up", "job", "a")
+	requests := labels.FromStrings("__name__", "requests_total", "job", "a")
+	app := appendable.Appender(context.Background())
+	_, err := app.Append(0, up, 1000, 1)
+	require.NoError(t, err)
+	_, err = app.Append(0, requests, 1000, 10)
+	require.NoError(t, err)
+	_, err = app.AppendExemplar(0, requests, exemplar.Exemplar{Labels: labels.FromStrings("trace_id", "abc"), Value: 10, Ts: 900, HasTs: true})
+	require.NoError(t, err)
+	_, err = app.Append(0, up, 2000, math.Float64frombits(value.StaleNaN))
+	require.NoError(t, err)
+	require.Empty(t, inserter.requests)
+	require.NoError(t, app.Commit())
+
+	require.Len(t, inserter.requests, 1)
+	ts := inserter.requests[0].Timeseries
+	require.Len(t, ts, 2)
+	require.Equal(t, []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "a"}}, ts[0].Labels)
+	require.Len(t, ts[0].Samples, 2)
+	require.Equal(t, prompb.Sample{Timestamp: 1000, Value: 1}, ts[0].Samples[0])
+	require.True(t, value.IsStaleNaN(ts[0].Samples[1].Value))
+	require.Equal(t, []prompb.Sample{{Timestamp: 1000, Value: 10}}, ts[1].Samples)
+	require.Equal(t, []prompb.Exemplar{{Labels: []prompb.Label{{Name: "trace_id", Value: "abc"}}, Value: 10, Timestamp: 900}}, ts[1].Exemplars)
+
+	_, err = app.Append(0, up, 3000, 1)
+	require.Error(t, err, "appending to a committed appender")
+
+	app = appendable.Appender(context.Background())
+	_, err = app.Append(0, up, 3000, 1)
+	require.NoError(t, err)
+	require.NoError(t, app.Rollback())
+	require.Error(t, app.Commit())
+	require.Len(t, inserter.requests, 1)
+}
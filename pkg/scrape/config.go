@@ -0,0 +1,23 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package scrape
+
+import (
+	"flag"
+)
+
+type Config struct {
+	Enabled bool
+}
+
+func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
+	fs.BoolVar(&cfg.Enabled, "metrics.scrape.enable", false, "Scrape the targets of the scrape_configs in the Prometheus configuration file given by metrics.rules.config-file "+
+		"and ingest the samples directly, without a Prometheus server. Cannot be used in read-only mode.")
+	return cfg
+}
+
+func Validate(cfg *Config) error {
+	return nil
+}
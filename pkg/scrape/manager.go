@@ -0,0 +1,76 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package scrape
+
+import (
+	"context"
+
+	"github.com/oklog/run"
+	"github.com/pkg/errors"
+	prometheus_config "github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/discovery"
+	prom_scrape "github.com/prometheus/prometheus/scrape"
+
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+)
+
+// Manager scrapes the targets of the scrape configs of a Prometheus
+// configuration, found by Prometheus service discovery, and ingests the
+// scraped samples with the DBIngestor.
+type Manager struct {
+	ctx              context.Context
+	scrapeManager    *prom_scrape.Manager
+	discoveryManager *discovery.Manager
+}
+
+func NewManager(ctx context.Context, inserter ingestor.DBInserter) *Manager {
+	return &Manager{
+		ctx:              ctx,
+		scrapeManager:    prom_scrape.NewManager(&prom_scrape.Options{}, log.GetLogger(), ingestAppendable{inserter}),
+		discoveryManager: discovery.NewManager(ctx, log.GetLogger(), discovery.Name("scrape")),
+	}
+}
+
+// ApplyConfig applies the scrape configs of cfg, replacing the ones applied
+// before.
+func (m *Manager) ApplyConfig(cfg *prometheus_config.Config) error {
+	if err := m.scrapeManager.ApplyConfig(cfg); err != nil {
+		return errors.WithMessage(err, "error applying config to scrape manager")
+	}
+	c := make(map[string]discovery.Configs)
+	for _, v := range cfg.ScrapeConfigs {
+		c[v.JobName] = v.ServiceDiscoveryConfigs
+	}
+	return errors.WithMessage(m.discoveryManager.ApplyConfig(c), "error applying config to discovery manager")
+}
+
+// Run runs the managers and blocks on either a graceful exit or on error.
+func (m *Manager) Run() error {
+	var g run.Group
+
+	g.Add(func() error {
+		log.Debug("msg", "Starting scrape discovery manager...")
+		return errors.WithMessage(m.discoveryManager.Run(), "error running scrape discovery manager")
+	}, func(err error) {
+		log.Debug("msg", "Stopping scrape discovery manager")
+	})
+
+	g.Add(func() error {
+		log.Debug("msg", "Starting scrape manager...")
+		return errors.WithMessage(m.scrapeManager.Run(m.discoveryManager.SyncCh()), "error running scrape manager")
+	}, func(error) {
+		log.Debug("msg", "Stopping scrape manager")
+		m.scrapeManager.Stop()
+	})
+
+	g.Add(func() error {
+		// This stops all actors in the group on context done.
+		<-m.ctx.Done()
+		return nil
+	}, func(err error) {})
+
+	return errors.WithMessage(g.Run(), "error running the scrape manager groups")
+}
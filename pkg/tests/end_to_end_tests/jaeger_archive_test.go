@@ -0,0 +1,71 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package end_to_end_tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jaegertracing/jaeger/model"
+	jaeger_integration_tests "github.com/jaegertracing/jaeger/plugin/storage/integration"
+	"github.com/jaegertracing/jaeger/storage/spanstore"
+	"github.com/stretchr/testify/require"
+	jaegerstore "github.com/timescale/promscale/pkg/jaeger/store"
+	"github.com/timescale/promscale/pkg/pgmodel"
+	ingstr "github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+func TestJaegerArchiveStorage(t *testing.T) {
+	withDB(t, "jaeger_archive_e2e", func(db *pgxpool.Pool, t testing.TB) {
+		ctx := context.Background()
+		// The archive tables are created by the connector migrations.
+		missing, err := pgmodel.MissingRelations(ctx, pgxconn.NewPgxConn(db), jaegerstore.ArchiveTables...)
+		require.NoError(t, err)
+		require.Empty(t, missing)
+
+		ingestor, err := ingstr.NewPgxIngestorForTests(pgxconn.NewPgxConn(db), nil)
+		require.NoError(t, err)
+		defer ingestor.Close()
+
+		jaegerStore := jaegerstore.New(pgxconn.NewQueryLoggingPgxConn(db), ingestor, &jaegerstore.DefaultConfig)
+		archive := jaegerstore.NewArchive(pgxconn.NewQueryLoggingPgxConn(db), pgxconn.NewPgxConn(db), &jaegerstore.DefaultConfig)
+
+		fixtures, err := getTracesFixtures()
+		require.NoError(t, err)
+		for _, b := range fixtures.batches {
+			for _, s := range b.Spans {
+				require.NoError(t, jaegerStore.SpanWriter().WriteSpan(ctx, s))
+			}
+		}
+
+		traceID := fixtures.trace1.Spans[0].TraceID
+		_, err = archive.ArchiveSpanReader().GetTrace(ctx, traceID)
+		require.ErrorIs(t, err, spanstore.ErrTraceNotFound)
+
+		// Archiving is idempotent.
+		for i := 0; i < 2; i++ {
+			for _, s := range fixtures.trace1.Spans {
+				require.NoError(t, archive.ArchiveSpanWriter().WriteSpan(ctx, s))
+			}
+		}
+
+		// Archived traces outlive the trace data.
+		for _, table := range []string{"span", "event", "link"} {
+			_, err = db.Exec(ctx, "DELETE FROM _ps_trace."+table)
+			require.NoError(t, err)
+		}
+		_, err = jaegerStore.GetTrace(ctx, traceID)
+		require.ErrorIs(t, err, spanstore.ErrTraceNotFound)
+
+		archived, err := archive.ArchiveSpanReader().GetTrace(ctx, traceID)
+		require.NoError(t, err)
+		jaeger_integration_tests.CompareSliceOfTraces(t.(*testing.T), []*model.Trace{fixtures.trace1}, []*model.Trace{archived})
+
+		// Spans missing from the trace tables can't be archived.
+		require.Error(t, archive.ArchiveSpanWriter().WriteSpan(ctx, fixtures.trace2.Spans[0]))
+	})
+}
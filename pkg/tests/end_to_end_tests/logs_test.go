@@ -0,0 +1,42 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package end_to_end_tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	"github.com/timescale/promscale/pkg/logs"
+	"github.com/timescale/promscale/pkg/pgmodel"
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+func TestLogRetention(t *testing.T) {
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		ctx := context.Background()
+		// The log table is created by the connector migrations.
+		missing, err := pgmodel.MissingRelations(ctx, pgxconn.NewPgxConn(db), logs.LogTable)
+		require.NoError(t, err)
+		require.Empty(t, missing)
+
+		now := time.Now()
+		for _, ts := range []time.Time{now.Add(-48 * time.Hour), now} {
+			_, err = db.Exec(ctx, `INSERT INTO ps_log.log (time, observed_time, body, attributes, resource_tags) VALUES ($1, $1, 'msg', '{}', '{}')`, ts)
+			require.NoError(t, err)
+		}
+
+		dropped, err := logs.NewRetention(pgxconn.NewPgxConn(db), 24*time.Hour).DropExpired(ctx)
+		require.NoError(t, err)
+		require.True(t, dropped)
+
+		var count int
+		require.NoError(t, db.QueryRow(ctx, `SELECT count(*) FROM ps_log.log`).Scan(&count))
+		require.Equal(t, 1, count)
+	})
+}
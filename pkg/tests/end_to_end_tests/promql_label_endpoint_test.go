@@ -5,6 +5,7 @@
 package end_to_end_tests
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -15,10 +16,18 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/stretchr/testify/require"
 	"github.com/timescale/promscale/pkg/clockcache"
 	"github.com/timescale/promscale/pkg/internal/testhelpers"
+	"github.com/timescale/promscale/pkg/pgmodel/cache"
+	ingstr "github.com/timescale/promscale/pkg/pgmodel/ingestor"
 	"github.com/timescale/promscale/pkg/pgmodel/lreader"
+	pgmodel "github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/pgmodel/querier"
 	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/prompb"
 )
 
 type labelsResponse struct {
@@ -40,6 +49,21 @@ func getLabelNamesRequest(apiUrl string) (*http.Request, error) {
 	)
 }
 
+func getLabelsRequestWithParams(apiUrl string, path string, params url.Values) (*http.Request, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s", apiUrl, path))
+
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = params.Encode()
+
+	return http.NewRequest(
+		"GET",
+		u.String(),
+		nil,
+	)
+}
+
 func getLabelValuesRequest(apiUrl string, labelName string) (*http.Request, error) {
 	u, err := url.Parse(fmt.Sprintf("%s/label/%s/values", apiUrl, labelName))
 
@@ -130,6 +154,39 @@ func TestPromQLLabelEndpoint(t *testing.T) {
 		}
 		testMethod = testRequestConcurrent(requestCases, client, labelsResultComparator, true)
 		tester.Run("test label endpoint", testMethod)
+
+		inRange := url.Values{"start": {fmt.Sprint(startTime / 1000)}, "end": {fmt.Sprint(endTime / 1000)}}
+		beforeRange := url.Values{"start": {fmt.Sprint(startTime/1000 - 3600)}, "end": {fmt.Sprint(startTime/1000 - 60)}}
+		paramCases := []struct {
+			path   string
+			params url.Values
+		}{
+			{"labels", url.Values{"match[]": {"metric_1"}}},
+			{"labels", url.Values{"match[]": {`{foo="bat"}`, "metric_3"}}},
+			{"labels", url.Values{"match[]": {`{__name__=~"metric_.*", instance="1"}`}}},
+			{"labels", url.Values{"match[]": {"metric_1"}, "start": inRange["start"], "end": inRange["end"]}},
+			{"labels", url.Values{"match[]": {"metric_1"}, "start": beforeRange["start"], "end": beforeRange["end"]}},
+			{"labels", inRange},
+			{"labels", beforeRange},
+			{"label/instance/values", url.Values{"match[]": {"metric_3"}}},
+			{"label/foo/values", url.Values{"match[]": {`{instance="1"}`}}},
+			{"label/foo/values", url.Values{"match[]": {"metric_2"}, "start": beforeRange["start"], "end": beforeRange["end"]}},
+			{"label/instance/values", inRange},
+		}
+		requestCases = requestCases[:0]
+		for _, c := range paramCases {
+			tsReq, err = getLabelsRequestWithParams(tsURL, c.path, c.params)
+			if err != nil {
+				t.Fatalf("unable to create TS PromQL labels request: %v", err)
+			}
+			promReq, err = getLabelsRequestWithParams(promURL, c.path, c.params)
+			if err != nil {
+				t.Fatalf("unable to create Prometheus PromQL labels request: %v", err)
+			}
+			requestCases = append(requestCases, requestCase{tsReq, promReq, fmt.Sprintf("get %s with %s", c.path, c.params.Encode())})
+		}
+		testMethod = testRequestConcurrent(requestCases, client, labelsResultComparator, true)
+		tester.Run("test label endpoint with params", testMethod)
 	})
 }
 
@@ -152,3 +209,51 @@ func labelsResultComparator(promContent []byte, tsContent []byte, log string) er
 
 	return nil
 }
+
+func TestLabelsQuerierSeriesActivity(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	withDB(t, *testDatabase, func(db *pgxpool.Pool, t testing.TB) {
+		ts := []prompb.TimeSeries{
+			{
+				Labels:  []prompb.Label{{Name: pgmodel.MetricNameLabelName, Value: "activity"}, {Name: "job", Value: "early"}},
+				Samples: []prompb.Sample{{Timestamp: 1000, Value: 1}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: pgmodel.MetricNameLabelName, Value: "activity"}, {Name: "job", Value: "late"}},
+				Samples: []prompb.Sample{{Timestamp: 5000, Value: 1}},
+			},
+		}
+		ingestor, err := ingstr.NewPgxIngestorForTests(pgxconn.NewPgxConn(db), nil)
+		require.NoError(t, err)
+		defer ingestor.Close()
+		_, _, err = ingestor.IngestMetrics(context.Background(), newWriteRequestWithTs(copyMetrics(ts)))
+		require.NoError(t, err)
+
+		dbConn := pgxconn.NewPgxConn(db)
+		labelsReader := lreader.NewLabelsReader(dbConn, clockcache.WithMax(100), noopReadAuthorizer)
+		mCache := &cache.MetricNameCache{Metrics: clockcache.WithMax(cache.DefaultMetricCacheSize)}
+		lq := querier.NewQuerier(dbConn, mCache, labelsReader, nil, nil).LabelsQuerier(context.Background())
+
+		// The series of an active metric which are idle in the range
+		// don't count.
+		values, err := lq.LabelValues("job", 0, 2000)
+		require.NoError(t, err)
+		require.Equal(t, []string{"early"}, values)
+		values, err = lq.LabelValues("job", 4000, 6000)
+		require.NoError(t, err)
+		require.Equal(t, []string{"late"}, values)
+
+		// Series marked for deletion don't count either.
+		_, err = db.Exec(context.Background(), `UPDATE prom_data_series.activity s SET delete_epoch = 1
+			FROM _prom_catalog.label l WHERE l.key = 'job' AND l.value = 'early' AND l.id = ANY(s.labels)`)
+		require.NoError(t, err)
+		values, err = lq.LabelValues("job", timestamp.FromTime(pgmodel.MinTime), timestamp.FromTime(pgmodel.MaxTime))
+		require.NoError(t, err)
+		require.Equal(t, []string{"late"}, values)
+		values, err = lq.LabelValues("job", 0, 2000, labels.MustNewMatcher(labels.MatchEqual, pgmodel.MetricNameLabelName, "activity"))
+		require.NoError(t, err)
+		require.Empty(t, values)
+	})
+}
@@ -0,0 +1,164 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package end_to_end_tests
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/clockcache"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel"
+	"github.com/timescale/promscale/pkg/pgmodel/cache"
+	ingstr "github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/pgmodel/lreader"
+	"github.com/timescale/promscale/pkg/pgmodel/querier"
+	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/prompb"
+	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/query"
+)
+
+func TestSQLMetrics(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	withDB(t, "sql_metrics_e2e", func(db *pgxpool.Pool, t testing.TB) {
+		ctx := context.Background()
+		// The registry is created by the connector migrations.
+		dbConn := pgxconn.NewPgxConn(db)
+		missing, err := pgmodel.MissingRelations(ctx, dbConn, querier.SQLMetricTable)
+		require.NoError(t, err)
+		require.Empty(t, missing)
+
+		start := time.Unix(1600000000, 0).UTC()
+		_, err = db.Exec(ctx, `CREATE SCHEMA shop`)
+		require.NoError(t, err)
+		_, err = db.Exec(ctx, `CREATE TABLE shop.orders (created_at TIMESTAMPTZ, region TEXT, channel TEXT, amount NUMERIC, items INT, note TEXT)`)
+		require.NoError(t, err)
+		_, err = db.Exec(ctx, `INSERT INTO shop.orders VALUES
+			($1, 'eu', 'web', 10, 1, NULL),
+			($1 + interval '30 seconds', 'eu', 'web', 20, 2, NULL),
+			($1 + interval '30 seconds', 'us', NULL, 5, 3, NULL)`, start)
+		require.NoError(t, err)
+
+		// Invalid registrations.
+		for _, stmt := range []string{
+			`SELECT prom_api.register_sql_metric('bad name', 'shop.orders', 'created_at', '{amount}')`,
+			`SELECT prom_api.register_sql_metric('orders', 'shop.orders', 'region', '{amount}')`,
+			`SELECT prom_api.register_sql_metric('orders', 'shop.orders', 'created_at', '{note}')`,
+			`SELECT prom_api.register_sql_metric('orders', 'shop.orders', 'created_at', '{}')`,
+			`SELECT prom_api.register_sql_metric('orders', 'shop.orders', 'created_at', '{amount}', '{missing}')`,
+			`SELECT prom_api.register_sql_metric('orders', 'shop.orders', 'created_at', '{amount}', '{amount}')`,
+		} {
+			_, err = db.Exec(ctx, stmt)
+			require.Error(t, err, stmt)
+		}
+		_, err = db.Exec(ctx, `SELECT prom_api.register_sql_metric('orders', 'shop.orders', 'created_at', '{amount,items}', '{region,channel}')`)
+		require.NoError(t, err)
+
+		mCache := &cache.MetricNameCache{Metrics: clockcache.WithMax(cache.DefaultMetricCacheSize)}
+		labelsReader := lreader.NewLabelsReader(dbConn, clockcache.WithMax(100), noopReadAuthorizer)
+		sqlMetrics := querier.NewSQLMetricRegistry(dbConn, time.Minute)
+		r := querier.NewQuerier(dbConn, mCache, labelsReader, nil, nil, querier.WithSQLMetrics(sqlMetrics))
+		queryable := query.NewQueryable(r, querier.NewSQLMetricLabelsReader(labelsReader, sqlMetrics, nil))
+		queryEngine, err := query.NewEngine(log.GetLogger(), time.Minute, time.Minute*5, time.Minute, 50000000, nil)
+		require.NoError(t, err)
+
+		evalTime := start.Add(time.Minute)
+		testCases := []struct {
+			query  string
+			expect promql.Vector
+		}{
+			{
+				query: `orders`,
+				expect: promql.Vector{
+					{Point: promql.Point{T: evalTime.UnixMilli(), V: 20}, Metric: labels.FromStrings("__name__", "orders", "channel", "web", "region", "eu")},
+					{Point: promql.Point{T: evalTime.UnixMilli(), V: 5}, Metric: labels.FromStrings("__name__", "orders", "region", "us")},
+				},
+			},
+			{
+				query: `sum_over_time(orders{__column__="items", region="eu"}[5m])`,
+				expect: promql.Vector{
+					{Point: promql.Point{T: evalTime.UnixMilli(), V: 3}, Metric: labels.FromStrings("__column__", "items", "channel", "web", "region", "eu")},
+				},
+			},
+			{
+				query: `sum(orders{channel=""})`,
+				expect: promql.Vector{
+					{Point: promql.Point{T: evalTime.UnixMilli(), V: 5}, Metric: labels.EmptyLabels()},
+				},
+			},
+			{
+				query:  `orders{job="shop"}`,
+				expect: promql.Vector{},
+			},
+		}
+		for _, tc := range testCases {
+			qry, err := queryEngine.NewInstantQuery(queryable, nil, tc.query, evalTime)
+			require.NoError(t, err)
+			res := qry.Exec(ctx)
+			require.NoError(t, res.Err, tc.query)
+			// The order of the series of a vector selector is unspecified.
+			require.ElementsMatch(t, tc.expect, res.Value, tc.query)
+		}
+
+		// Selectors without a metric name match the SQL metrics too.
+		qry, err := queryEngine.NewInstantQuery(queryable, nil, `{region="us"}`, evalTime)
+		require.NoError(t, err)
+		res := qry.Exec(ctx)
+		require.NoError(t, res.Err)
+		require.Equal(t, promql.Vector{
+			{Point: promql.Point{T: evalTime.UnixMilli(), V: 5}, Metric: labels.FromStrings("__name__", "orders", "region", "us")},
+		}, res.Value)
+
+		q, err := queryable.SamplesQuerier(ctx, start.UnixMilli(), evalTime.UnixMilli())
+		require.NoError(t, err)
+		defer q.Close()
+		names, _, err := q.LabelNames()
+		require.NoError(t, err)
+		require.Equal(t, []string{"__name__", "channel", "region"}, names)
+		values, _, err := q.LabelValues("region", labels.MustNewMatcher(labels.MatchEqual, "channel", "web"))
+		require.NoError(t, err)
+		require.Equal(t, []string{"eu"}, values)
+
+		// Without a time range, the labels come from the labels reader.
+		all, err := queryable.SamplesQuerier(ctx, math.MinInt64, math.MaxInt64)
+		require.NoError(t, err)
+		defer all.Close()
+		values, _, err = all.LabelValues("__name__")
+		require.NoError(t, err)
+		require.Contains(t, values, "orders")
+
+		// An ingested metric takes precedence over the SQL metric.
+		ts := []prompb.TimeSeries{{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "orders"}, {Name: "job", Value: "shop"}},
+			Samples: []prompb.Sample{{Timestamp: start.UnixMilli(), Value: 42}},
+		}}
+		ingestor, err := ingstr.NewPgxIngestorForTests(dbConn, nil)
+		require.NoError(t, err)
+		defer ingestor.Close()
+		_, _, err = ingestor.IngestMetrics(ctx, newWriteRequestWithTs(copyMetrics(ts)))
+		require.NoError(t, err)
+		qry, err = queryEngine.NewInstantQuery(queryable, nil, `orders`, evalTime)
+		require.NoError(t, err)
+		res = qry.Exec(ctx)
+		require.NoError(t, res.Err)
+		require.Equal(t, promql.Vector{
+			{Point: promql.Point{T: evalTime.UnixMilli(), V: 42}, Metric: labels.FromStrings("__name__", "orders", "job", "shop")},
+		}, res.Value)
+
+		var unregistered bool
+		require.NoError(t, db.QueryRow(ctx, `SELECT prom_api.unregister_sql_metric('orders')`).Scan(&unregistered))
+		require.True(t, unregistered)
+		require.NoError(t, db.QueryRow(ctx, `SELECT prom_api.unregister_sql_metric('orders')`).Scan(&unregistered))
+		require.False(t, unregistered)
+	})
+}
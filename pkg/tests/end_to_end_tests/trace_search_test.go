@@ -0,0 +1,149 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package end_to_end_tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/storage/spanstore"
+	"github.com/stretchr/testify/require"
+	jaegerstore "github.com/timescale/promscale/pkg/jaeger/store"
+	ingstr "github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/traceql"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+func TestTraceSearch(t *testing.T) {
+	withDB(t, "trace_search_e2e", func(db *pgxpool.Pool, t testing.TB) {
+		ctx := context.Background()
+		ingestor, err := ingstr.NewPgxIngestorForTests(pgxconn.NewPgxConn(db), nil)
+		require.NoError(t, err)
+		defer ingestor.Close()
+
+		jaegerStore := jaegerstore.New(pgxconn.NewQueryLoggingPgxConn(db), ingestor, &jaegerstore.DefaultConfig)
+		fixtures, err := getTracesFixtures()
+		require.NoError(t, err)
+		var start, end time.Time
+		for _, b := range fixtures.batches {
+			for _, s := range b.Spans {
+				require.NoError(t, jaegerStore.SpanWriter().WriteSpan(ctx, s))
+				if start.IsZero() || s.StartTime.Before(start) {
+					start = s.StartTime
+				}
+				if s.StartTime.After(end) {
+					end = s.StartTime
+				}
+			}
+		}
+
+		search := func(query string, limit int) []jaegerstore.TraceMatch {
+			q, err := traceql.Parse(query)
+			require.NoError(t, err)
+			matches, err := jaegerStore.SearchTraces(ctx, q, start, end, limit)
+			require.NoError(t, err)
+			return matches
+		}
+
+		matches := search(`{}`, 20)
+		require.Len(t, matches, 2)
+		spanCounts := map[string]int{}
+		for _, m := range matches {
+			spanCounts[m.TraceID.HexString()] = m.SpanCount
+			require.Equal(t, m.SpanCount, len(m.Spans))
+		}
+		require.Equal(t, len(fixtures.trace1.Spans), spanCounts[hexTraceID(fixtures.trace1.Spans[0].TraceID)])
+		require.Equal(t, len(fixtures.trace2.Spans), spanCounts[hexTraceID(fixtures.trace2.Spans[0].TraceID)])
+
+		require.Len(t, search(`{}`, 1), 1)
+		require.Len(t, search(`{} | count() > 4`, 20), 1)
+
+		span := fixtures.trace1.Spans[0]
+		matches = search(fmt.Sprintf(`{ name = %q }`, span.OperationName), 20)
+		require.NotEmpty(t, matches)
+		found := false
+		for _, m := range matches {
+			found = found || m.TraceID.HexString() == hexTraceID(span.TraceID)
+		}
+		require.True(t, found)
+
+		require.Empty(t, search(`{ name = "no such operation" }`, 20))
+		// Children are descendants too.
+		descendants := map[pcommon.TraceID]bool{}
+		for _, m := range search(`{} >> {}`, 20) {
+			descendants[m.TraceID] = true
+		}
+		for _, m := range search(`{} > {}`, 20) {
+			require.True(t, descendants[m.TraceID])
+		}
+		require.Empty(t, search(`{ name = "no such operation" } >> {}`, 20))
+
+		// Trace duration bounds apply to the whole trace, even if the
+		// matching spans are shorter.
+		var traceStart, traceEnd time.Time
+		for _, s := range fixtures.trace1.Spans {
+			if traceStart.IsZero() || s.StartTime.Before(traceStart) {
+				traceStart = s.StartTime
+			}
+			if e := s.StartTime.Add(s.Duration); e.After(traceEnd) {
+				traceEnd = e
+			}
+		}
+		traceDuration := traceEnd.Sub(traceStart)
+		q, err := traceql.Parse(fmt.Sprintf(`{ name = %q }`, span.OperationName))
+		require.NoError(t, err)
+		q.MinTraceDuration = traceDuration
+		traceMatches, err := jaegerStore.SearchTraces(ctx, q, start, end, 20)
+		require.NoError(t, err)
+		found = false
+		for _, m := range traceMatches {
+			found = found || m.TraceID.HexString() == hexTraceID(span.TraceID)
+		}
+		require.True(t, found)
+		q.MinTraceDuration, q.MaxTraceDuration = 0, traceDuration-time.Microsecond
+		traceMatches, err = jaegerStore.SearchTraces(ctx, q, start, end, 20)
+		require.NoError(t, err)
+		for _, m := range traceMatches {
+			require.NotEqual(t, hexTraceID(span.TraceID), m.TraceID.HexString())
+		}
+
+		otlpTrace, err := jaegerStore.GetOTLPTrace(ctx, matches[0].TraceID)
+		require.NoError(t, err)
+		require.Equal(t, matches[0].SpanCount, otlpTrace.SpanCount())
+		_, err = jaegerStore.GetOTLPTrace(ctx, pcommon.TraceID([16]byte{1}))
+		require.ErrorIs(t, err, spanstore.ErrTraceNotFound)
+
+		summaries, err := jaegerStore.GetTraceSummaries(ctx, []jaegerstore.TraceRef{
+			{TraceID: matches[0].TraceID, Time: matches[0].StartTime},
+			{TraceID: pcommon.TraceID([16]byte{1}), Time: matches[0].StartTime},
+		})
+		require.NoError(t, err)
+		require.Len(t, summaries, 2)
+		require.Equal(t, matches[0].TraceID, summaries[0].TraceID)
+		require.Equal(t, matches[0].SpanCount, summaries[0].SpanCount)
+		require.Equal(t, matches[0].RootServiceName, summaries[0].RootServiceName)
+		require.Zero(t, summaries[1].SpanCount)
+
+		names, err := jaegerStore.SearchTagNames(ctx)
+		require.NoError(t, err)
+		require.Contains(t, names.Resource, "service.name")
+
+		values, err := jaegerStore.SearchTagValues(ctx, traceql.Field{Scope: traceql.ScopeResource, Name: "service.name"}, 10)
+		require.NoError(t, err)
+		require.NotEmpty(t, values)
+		values, err = jaegerStore.SearchTagValues(ctx, traceql.Field{Scope: traceql.ScopeIntrinsic, Name: traceql.IntrinsicName}, 10)
+		require.NoError(t, err)
+		require.Contains(t, values, jaegerstore.TagValue{Type: "string", Value: span.OperationName})
+	})
+}
+
+func hexTraceID(id model.TraceID) string {
+	return fmt.Sprintf("%016x%016x", id.High, id.Low)
+}
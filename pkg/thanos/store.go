@@ -95,13 +95,18 @@ func (fc *Storage) Series(req *storepb.SeriesRequest, srv storepb.Store_SeriesSe
 }
 
 func (fc *Storage) LabelNames(ctx context.Context, req *storepb.LabelNamesRequest) (*storepb.LabelNamesResponse, error) {
+	matchers, err := getMatchers(req.Matchers)
+	if err != nil {
+		return nil, err
+	}
+
 	q, err := fc.queryable.SamplesQuerier(ctx, req.Start, req.End)
 	if err != nil {
 		return nil, err
 	}
 	defer q.Close()
 
-	names, warnings, err := q.LabelNames()
+	names, warnings, err := q.LabelNames(matchers...)
 	if err != nil {
 		return nil, err
 	}
@@ -118,13 +123,18 @@ func (fc *Storage) LabelNames(ctx context.Context, req *storepb.LabelNamesReques
 }
 
 func (fc *Storage) LabelValues(ctx context.Context, req *storepb.LabelValuesRequest) (*storepb.LabelValuesResponse, error) {
+	matchers, err := getMatchers(req.Matchers)
+	if err != nil {
+		return nil, err
+	}
+
 	q, err := fc.queryable.SamplesQuerier(ctx, req.Start, req.End)
 	if err != nil {
 		return nil, err
 	}
 	defer q.Close()
 
-	values, warnings, err := q.LabelValues(req.Label)
+	values, warnings, err := q.LabelValues(req.Label, matchers...)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,302 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+// Package traceql implements a TraceQL-style query language for searching
+// traces. A query selects spans with filters such as
+//
+//	{ resource.service.name = "checkout" && span.http.status_code >= 500 }
+//
+// combines span sets with logical and structural operators, and filters the
+// matching traces by aggregates:
+//
+//	{ name = "GET /cart" } >> { status = error } | count() > 2
+//
+// Queries are compiled to SQL using the ps_tag operators on the tag maps and
+// a recursive CTE walking up the ancestors of the spans for the descendant
+// operator.
+package traceql
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Query is a span set expression followed by a pipeline of aggregate filters.
+type Query struct {
+	Spans      SpansetExpr
+	Aggregates []*AggregateFilter
+	// MinTraceDuration and MaxTraceDuration, when not zero, bound the
+	// duration of the whole matching traces, from the start of their first
+	// span to the end of their last one. They have no TraceQL syntax and
+	// are set by the Tempo search API.
+	MinTraceDuration, MaxTraceDuration time.Duration
+}
+
+// SpansetExpr is an expression evaluating to a set of spans.
+type SpansetExpr interface {
+	fmt.Stringer
+	spansetExpr()
+}
+
+// SpansetFilter selects the spans matching a field expression. A nil
+// expression matches every span.
+type SpansetFilter struct {
+	Expr FieldExpr
+}
+
+// SpansetOp is the set operation of two span sets.
+type SpansetOp int
+
+const (
+	// SpansetAnd keeps the spans of both sets from traces containing spans of both.
+	SpansetAnd SpansetOp = iota
+	// SpansetOr keeps the spans of either set.
+	SpansetOr
+	// SpansetChild keeps the spans of the right set whose parent is in the left set.
+	SpansetChild
+	// SpansetDescendant keeps the spans of the right set having an ancestor in the left set.
+	SpansetDescendant
+)
+
+var spansetOpStrings = map[SpansetOp]string{
+	SpansetAnd:        "&&",
+	SpansetOr:         "||",
+	SpansetChild:      ">",
+	SpansetDescendant: ">>",
+}
+
+func (o SpansetOp) String() string {
+	return spansetOpStrings[o]
+}
+
+// SpansetBinary combines two span sets.
+type SpansetBinary struct {
+	Op  SpansetOp
+	LHS SpansetExpr
+	RHS SpansetExpr
+}
+
+func (*SpansetFilter) spansetExpr() {}
+func (*SpansetBinary) spansetExpr() {}
+
+func (f *SpansetFilter) String() string {
+	if f.Expr == nil {
+		return "{}"
+	}
+	return "{ " + f.Expr.String() + " }"
+}
+
+func (b *SpansetBinary) String() string {
+	return "(" + b.LHS.String() + " " + b.Op.String() + " " + b.RHS.String() + ")"
+}
+
+// FieldExpr is a boolean expression on the fields of a span.
+type FieldExpr interface {
+	fmt.Stringer
+	fieldExpr()
+}
+
+// LogicalOp is the operator of a logical expression.
+type LogicalOp int
+
+const (
+	LogicalAnd LogicalOp = iota
+	LogicalOr
+)
+
+func (o LogicalOp) String() string {
+	if o == LogicalAnd {
+		return "&&"
+	}
+	return "||"
+}
+
+// LogicalExpr combines two field expressions.
+type LogicalExpr struct {
+	Op  LogicalOp
+	LHS FieldExpr
+	RHS FieldExpr
+}
+
+// Comparison compares a field of the span to a static value.
+type Comparison struct {
+	Field Field
+	Op    CompareOp
+	Value Value
+}
+
+func (*LogicalExpr) fieldExpr() {}
+func (*Comparison) fieldExpr()  {}
+
+func (e *LogicalExpr) String() string {
+	return "(" + e.LHS.String() + " " + e.Op.String() + " " + e.RHS.String() + ")"
+}
+
+func (c *Comparison) String() string {
+	return c.Field.String() + " " + c.Op.String() + " " + c.Value.String()
+}
+
+// CompareOp is a comparison operator.
+type CompareOp int
+
+const (
+	OpEqual CompareOp = iota
+	OpNotEqual
+	OpRegex
+	OpNotRegex
+	OpLess
+	OpLessEqual
+	OpGreater
+	OpGreaterEqual
+)
+
+var compareOpStrings = map[CompareOp]string{
+	OpEqual:        "=",
+	OpNotEqual:     "!=",
+	OpRegex:        "=~",
+	OpNotRegex:     "!~",
+	OpLess:         "<",
+	OpLessEqual:    "<=",
+	OpGreater:      ">",
+	OpGreaterEqual: ">=",
+}
+
+func (o CompareOp) String() string {
+	return compareOpStrings[o]
+}
+
+func (o CompareOp) isOrdering() bool {
+	return o >= OpLess
+}
+
+func (o CompareOp) isRegex() bool {
+	return o == OpRegex || o == OpNotRegex
+}
+
+// Scope tells where an attribute is looked up.
+type Scope int
+
+const (
+	// ScopeIntrinsic is a built-in field of the span: name, status, kind or duration.
+	ScopeIntrinsic Scope = iota
+	// ScopeSpan is a span attribute.
+	ScopeSpan
+	// ScopeResource is a resource attribute.
+	ScopeResource
+	// ScopeEvent is an attribute of any event of the span.
+	ScopeEvent
+	// ScopeAny is a span or resource attribute.
+	ScopeAny
+)
+
+var scopePrefixes = map[Scope]string{
+	ScopeSpan:     "span.",
+	ScopeResource: "resource.",
+	ScopeEvent:    "event.",
+	ScopeAny:      ".",
+}
+
+// Intrinsic fields.
+const (
+	IntrinsicName     = "name"
+	IntrinsicStatus   = "status"
+	IntrinsicKind     = "kind"
+	IntrinsicDuration = "duration"
+)
+
+// Field is an intrinsic field or an attribute of a span.
+type Field struct {
+	Scope Scope
+	Name  string
+}
+
+func (f Field) String() string {
+	return scopePrefixes[f.Scope] + f.Name
+}
+
+// ValueType is the type of a static value.
+type ValueType int
+
+const (
+	TypeString ValueType = iota
+	TypeNumber
+	TypeDuration
+	TypeBool
+	// TypeKeyword is a bare word, used for the values of status and kind.
+	TypeKeyword
+)
+
+// Value is a static value of a query.
+type Value struct {
+	Type     ValueType
+	Str      string
+	Number   float64
+	Duration time.Duration
+	Bool     bool
+}
+
+func (v Value) String() string {
+	switch v.Type {
+	case TypeString:
+		return strconv.Quote(v.Str)
+	case TypeNumber:
+		return strconv.FormatFloat(v.Number, 'g', -1, 64)
+	case TypeDuration:
+		return v.Duration.String()
+	case TypeBool:
+		return strconv.FormatBool(v.Bool)
+	default:
+		return v.Str
+	}
+}
+
+// AggregateFunc is an aggregation over the matching spans of a trace.
+type AggregateFunc int
+
+const (
+	AggregateCount AggregateFunc = iota
+	AggregateAvg
+	AggregateMin
+	AggregateMax
+	AggregateSum
+)
+
+var aggregateFuncs = map[string]AggregateFunc{
+	"count": AggregateCount,
+	"avg":   AggregateAvg,
+	"min":   AggregateMin,
+	"max":   AggregateMax,
+	"sum":   AggregateSum,
+}
+
+func (a AggregateFunc) String() string {
+	for name, f := range aggregateFuncs {
+		if f == a {
+			return name
+		}
+	}
+	return ""
+}
+
+// AggregateFilter keeps the traces whose aggregate over the matching spans
+// compares true to the value. Field is empty for count().
+type AggregateFilter struct {
+	Func  AggregateFunc
+	Field string
+	Op    CompareOp
+	Value Value
+}
+
+func (a *AggregateFilter) String() string {
+	return a.Func.String() + "(" + a.Field + ") " + a.Op.String() + " " + a.Value.String()
+}
+
+func (q *Query) String() string {
+	s := q.Spans.String()
+	for _, a := range q.Aggregates {
+		s += " | " + a.String()
+	}
+	return s
+}
@@ -0,0 +1,153 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package traceql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokLBrace
+	tokRBrace
+	tokLParen
+	tokRParen
+	tokPipe
+	tokAnd
+	tokOr
+	tokEqual
+	tokNotEqual
+	tokRegex
+	tokNotRegex
+	tokLess
+	tokLessEqual
+	tokGreater
+	tokGreaterEqual
+	tokDescendant
+	tokIdent
+	tokString
+	tokNumber
+	tokDuration
+)
+
+type token struct {
+	typ tokenType
+	val string
+	pos int
+}
+
+func (t token) String() string {
+	if t.typ == tokEOF {
+		return "end of query"
+	}
+	return strconv.Quote(t.val)
+}
+
+// Operators sorted so that the longest match is tried first.
+var operators = []struct {
+	s   string
+	typ tokenType
+}{
+	{"&&", tokAnd},
+	{"||", tokOr},
+	{"!=", tokNotEqual},
+	{"=~", tokRegex},
+	{"!~", tokNotRegex},
+	{"<=", tokLessEqual},
+	{">=", tokGreaterEqual},
+	{">>", tokDescendant},
+	{"{", tokLBrace},
+	{"}", tokRBrace},
+	{"(", tokLParen},
+	{")", tokRParen},
+	{"|", tokPipe},
+	{"=", tokEqual},
+	{"<", tokLess},
+	{">", tokGreater},
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || r == '.' || unicode.IsLetter(r)
+}
+
+func isIdentChar(r rune) bool {
+	return isIdentStart(r) || unicode.IsDigit(r) || r == '-' || r == '/' || r == ':'
+}
+
+func lex(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '"' || r == '`':
+			end := i + 1
+			for ; end < len(runes) && runes[end] != r; end++ {
+				if runes[end] == '\\' && r == '"' {
+					end++
+				}
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			raw := string(runes[i : end+1])
+			s, err := strconv.Unquote(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s at position %d: %w", raw, i, err)
+			}
+			tokens = append(tokens, token{tokString, s, i})
+			i = end + 1
+			continue
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			end := i + 1
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.') {
+				end++
+			}
+			typ := tokNumber
+			// A unit suffix makes it a duration, e.g. 100ms or 1.5s.
+			unitEnd := end
+			for unitEnd < len(runes) && (unicode.IsLetter(runes[unitEnd]) || runes[unitEnd] == 'µ') {
+				unitEnd++
+			}
+			if unitEnd > end {
+				typ = tokDuration
+				end = unitEnd
+			}
+			tokens = append(tokens, token{typ, string(runes[i:end]), i})
+			i = end
+			continue
+		case isIdentStart(r):
+			end := i + 1
+			for end < len(runes) && isIdentChar(runes[end]) {
+				end++
+			}
+			tokens = append(tokens, token{tokIdent, string(runes[i:end]), i})
+			i = end
+			continue
+		}
+		matched := false
+		rest := string(runes[i:])
+		for _, op := range operators {
+			if strings.HasPrefix(rest, op.s) {
+				tokens = append(tokens, token{op.typ, op.s, i})
+				i += len([]rune(op.s))
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+		}
+	}
+	return append(tokens, token{typ: tokEOF, pos: len(runes)}), nil
+}
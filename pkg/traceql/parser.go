@@ -0,0 +1,434 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package traceql
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/regexp"
+)
+
+var (
+	statusValues = map[string]struct{}{"ok": {}, "error": {}, "unset": {}}
+	kindValues   = map[string]struct{}{"unspecified": {}, "internal": {}, "server": {}, "client": {}, "producer": {}, "consumer": {}}
+)
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Parse parses and validates a query.
+func Parse(input string) (*Query, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	q, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.typ != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(typ tokenType, what string) (token, error) {
+	t := p.next()
+	if t.typ != typ {
+		return t, p.errorf(t, "expected %s", what)
+	}
+	return t, nil
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("parse error at position %d near %s: %s", t.pos, t, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseQuery() (*Query, error) {
+	spans, err := p.parseSpansetOr()
+	if err != nil {
+		return nil, err
+	}
+	q := &Query{Spans: spans}
+	for p.peek().typ == tokPipe {
+		p.next()
+		agg, err := p.parseAggregate()
+		if err != nil {
+			return nil, err
+		}
+		q.Aggregates = append(q.Aggregates, agg)
+	}
+	if t := p.peek(); t.typ != tokEOF {
+		return nil, p.errorf(t, "unexpected token")
+	}
+	return q, nil
+}
+
+// Span set operators by increasing precedence: ||, && and the structural > and >>.
+
+func (p *parser) parseSpansetOr() (SpansetExpr, error) {
+	lhs, err := p.parseSpansetAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokOr {
+		p.next()
+		rhs, err := p.parseSpansetAnd()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &SpansetBinary{Op: SpansetOr, LHS: lhs, RHS: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseSpansetAnd() (SpansetExpr, error) {
+	lhs, err := p.parseSpansetStructural()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokAnd {
+		p.next()
+		rhs, err := p.parseSpansetStructural()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &SpansetBinary{Op: SpansetAnd, LHS: lhs, RHS: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseSpansetStructural() (SpansetExpr, error) {
+	lhs, err := p.parseSpansetPrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op SpansetOp
+		switch p.peek().typ {
+		case tokGreater:
+			op = SpansetChild
+		case tokDescendant:
+			op = SpansetDescendant
+		default:
+			return lhs, nil
+		}
+		p.next()
+		rhs, err := p.parseSpansetPrimary()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &SpansetBinary{Op: op, LHS: lhs, RHS: rhs}
+	}
+}
+
+func (p *parser) parseSpansetPrimary() (SpansetExpr, error) {
+	t := p.next()
+	switch t.typ {
+	case tokLParen:
+		e, err := p.parseSpansetOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = p.expect(tokRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return e, nil
+	case tokLBrace:
+		if p.peek().typ == tokRBrace {
+			p.next()
+			return &SpansetFilter{}, nil
+		}
+		e, err := p.parseFieldOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = p.expect(tokRBrace, "'}'"); err != nil {
+			return nil, err
+		}
+		return &SpansetFilter{Expr: e}, nil
+	}
+	return nil, p.errorf(t, "expected span set '{ ... }'")
+}
+
+func (p *parser) parseFieldOr() (FieldExpr, error) {
+	lhs, err := p.parseFieldAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokOr {
+		p.next()
+		rhs, err := p.parseFieldAnd()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &LogicalExpr{Op: LogicalOr, LHS: lhs, RHS: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseFieldAnd() (FieldExpr, error) {
+	lhs, err := p.parseFieldPrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokAnd {
+		p.next()
+		rhs, err := p.parseFieldPrimary()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &LogicalExpr{Op: LogicalAnd, LHS: lhs, RHS: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseFieldPrimary() (FieldExpr, error) {
+	if p.peek().typ == tokLParen {
+		p.next()
+		e, err := p.parseFieldOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = p.expect(tokRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (FieldExpr, error) {
+	t, err := p.expect(tokIdent, "field")
+	if err != nil {
+		return nil, err
+	}
+	field, err := ParseField(t.val)
+	if err != nil {
+		return nil, p.errorf(t, "%s", err)
+	}
+	opTok := p.next()
+	op, ok := compareOp(opTok.typ)
+	if !ok {
+		return nil, p.errorf(opTok, "expected comparison operator")
+	}
+	valTok := p.next()
+	value, err := parseValue(valTok)
+	if err != nil {
+		return nil, p.errorf(valTok, "%s", err)
+	}
+	c := &Comparison{Field: field, Op: op, Value: value}
+	if err = c.validate(); err != nil {
+		return nil, p.errorf(valTok, "%s", err)
+	}
+	return c, nil
+}
+
+func (p *parser) parseAggregate() (*AggregateFilter, error) {
+	t, err := p.expect(tokIdent, "aggregate function")
+	if err != nil {
+		return nil, err
+	}
+	fn, ok := aggregateFuncs[t.val]
+	if !ok {
+		return nil, p.errorf(t, "unknown aggregate function")
+	}
+	if _, err = p.expect(tokLParen, "'('"); err != nil {
+		return nil, err
+	}
+	agg := &AggregateFilter{Func: fn}
+	if fn != AggregateCount {
+		fieldTok, err := p.expect(tokIdent, "field")
+		if err != nil {
+			return nil, err
+		}
+		if fieldTok.val != IntrinsicDuration {
+			return nil, p.errorf(fieldTok, "only duration can be aggregated by %s()", t.val)
+		}
+		agg.Field = fieldTok.val
+	}
+	if _, err = p.expect(tokRParen, "')'"); err != nil {
+		return nil, err
+	}
+	opTok := p.next()
+	op, ok := compareOp(opTok.typ)
+	if !ok || op.isRegex() {
+		return nil, p.errorf(opTok, "expected comparison operator")
+	}
+	agg.Op = op
+	valTok := p.next()
+	if agg.Value, err = parseValue(valTok); err != nil {
+		return nil, p.errorf(valTok, "%s", err)
+	}
+	switch {
+	case fn == AggregateCount && agg.Value.Type != TypeNumber:
+		return nil, p.errorf(valTok, "count() must be compared to a number")
+	case fn != AggregateCount && agg.Value.Type != TypeDuration:
+		return nil, p.errorf(valTok, "%s(duration) must be compared to a duration", t.val)
+	}
+	return agg, nil
+}
+
+func compareOp(typ tokenType) (CompareOp, bool) {
+	switch typ {
+	case tokEqual:
+		return OpEqual, true
+	case tokNotEqual:
+		return OpNotEqual, true
+	case tokRegex:
+		return OpRegex, true
+	case tokNotRegex:
+		return OpNotRegex, true
+	case tokLess:
+		return OpLess, true
+	case tokLessEqual:
+		return OpLessEqual, true
+	case tokGreater:
+		return OpGreater, true
+	case tokGreaterEqual:
+		return OpGreaterEqual, true
+	}
+	return 0, false
+}
+
+// ParseField parses a field name such as span.http.method, .db.system or
+// duration.
+func ParseField(s string) (Field, error) {
+	for scope, prefix := range scopePrefixes {
+		if scope == ScopeAny {
+			continue
+		}
+		if strings.HasPrefix(s, prefix) {
+			if len(s) == len(prefix) {
+				return Field{}, fmt.Errorf("missing attribute name")
+			}
+			return Field{Scope: scope, Name: s[len(prefix):]}, nil
+		}
+	}
+	if strings.HasPrefix(s, ".") {
+		if len(s) == 1 {
+			return Field{}, fmt.Errorf("missing attribute name")
+		}
+		return Field{Scope: ScopeAny, Name: s[1:]}, nil
+	}
+	switch s {
+	case IntrinsicName, IntrinsicStatus, IntrinsicKind, IntrinsicDuration:
+		return Field{Scope: ScopeIntrinsic, Name: s}, nil
+	}
+	return Field{}, fmt.Errorf("unknown field, attributes must be prefixed with span., resource., event. or .")
+}
+
+// IntrinsicValues returns the sorted keyword values status and kind can be
+// compared to, or nil for the other fields.
+func IntrinsicValues(name string) []string {
+	var values map[string]struct{}
+	switch name {
+	case IntrinsicStatus:
+		values = statusValues
+	case IntrinsicKind:
+		values = kindValues
+	default:
+		return nil
+	}
+	res := make([]string, 0, len(values))
+	for v := range values {
+		res = append(res, v)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func parseValue(t token) (Value, error) {
+	switch t.typ {
+	case tokString:
+		return Value{Type: TypeString, Str: t.val}, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return Value{}, fmt.Errorf("invalid number")
+		}
+		return Value{Type: TypeNumber, Number: f}, nil
+	case tokDuration:
+		d, err := time.ParseDuration(t.val)
+		if err != nil {
+			return Value{}, fmt.Errorf("invalid duration")
+		}
+		return Value{Type: TypeDuration, Duration: d}, nil
+	case tokIdent:
+		switch t.val {
+		case "true", "false":
+			return Value{Type: TypeBool, Bool: t.val == "true"}, nil
+		}
+		return Value{Type: TypeKeyword, Str: t.val}, nil
+	}
+	return Value{}, fmt.Errorf("expected value")
+}
+
+func (c *Comparison) validate() error {
+	if c.Op.isRegex() {
+		if c.Value.Type != TypeString {
+			return fmt.Errorf("regular expressions must be strings")
+		}
+		if _, err := regexp.Compile(c.Value.Str); err != nil {
+			return fmt.Errorf("invalid regular expression: %w", err)
+		}
+	}
+	if c.Field.Scope != ScopeIntrinsic {
+		if c.Value.Type == TypeKeyword {
+			return fmt.Errorf("strings must be quoted")
+		}
+		if c.Value.Type == TypeDuration {
+			return fmt.Errorf("attributes can't be compared to durations")
+		}
+		if c.Op.isOrdering() && c.Value.Type == TypeBool {
+			return fmt.Errorf("booleans can only be compared for equality")
+		}
+		return nil
+	}
+	switch c.Field.Name {
+	case IntrinsicName:
+		if c.Value.Type != TypeString {
+			return fmt.Errorf("name must be compared to a string")
+		}
+		if c.Op.isOrdering() {
+			return fmt.Errorf("name can't be compared with %s", c.Op)
+		}
+	case IntrinsicStatus, IntrinsicKind:
+		values := statusValues
+		if c.Field.Name == IntrinsicKind {
+			values = kindValues
+		}
+		if c.Op != OpEqual && c.Op != OpNotEqual {
+			return fmt.Errorf("%s can only be compared with = and !=", c.Field.Name)
+		}
+		if _, ok := values[c.Value.Str]; !ok || c.Value.Type != TypeKeyword {
+			return fmt.Errorf("invalid %s %s", c.Field.Name, c.Value)
+		}
+	case IntrinsicDuration:
+		if c.Value.Type != TypeDuration {
+			return fmt.Errorf("duration must be compared to a duration")
+		}
+		if c.Op.isRegex() {
+			return fmt.Errorf("duration can't be compared with %s", c.Op)
+		}
+	}
+	return nil
+}
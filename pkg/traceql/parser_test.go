@@ -0,0 +1,87 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package traceql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{query: `{}`, expected: `{}`},
+		{query: `{ span.http.status_code >= 500 }`, expected: `{ span.http.status_code >= 500 }`},
+		{query: `{resource.service.name="checkout"}`, expected: `{ resource.service.name = "checkout" }`},
+		{query: `{ .db.system = "postgresql" && event.exception.type =~ "Timeout.*" }`, expected: `{ (.db.system = "postgresql" && event.exception.type =~ "Timeout.*") }`},
+		{
+			query:    `{ name = "a" || name = "b" && status = error }`,
+			expected: `{ (name = "a" || (name = "b" && status = error)) }`,
+		},
+		{
+			query:    `{ (name = "a" || name = "b") && kind = server }`,
+			expected: `{ ((name = "a" || name = "b") && kind = server) }`,
+		},
+		{query: `{ duration > 1.5s }`, expected: `{ duration > 1.5s }`},
+		{query: `{ span.cached = false }`, expected: `{ span.cached = false }`},
+		{
+			query:    `{ name = "a" } >> { status = error } && { kind = client } || {}`,
+			expected: `((({ name = "a" } >> { status = error }) && { kind = client }) || {})`,
+		},
+		{
+			query:    `{ name = "a" } > ({ name = "b" } || { name = "c" })`,
+			expected: `({ name = "a" } > ({ name = "b" } || { name = "c" }))`,
+		},
+		{
+			query:    `{ status = error } | count() > 2 | avg(duration) >= 100ms`,
+			expected: `{ status = error } | count() > 2 | avg(duration) >= 100ms`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			q, err := Parse(tc.query)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, q.String())
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, query := range []string{
+		``,
+		`{`,
+		`{ name = "a" `,
+		`{ name = "a" } }`,
+		`{ foo = "a" }`,
+		`{ a.b = 1 }`,
+		`{ span. = "a" }`,
+		`{ name > "a" }`,
+		`{ name = 1 }`,
+		`{ status = failed }`,
+		`{ status =~ "error" }`,
+		`{ kind = "server" }`,
+		`{ duration > 5 }`,
+		`{ span.a = b }`,
+		`{ span.a > true }`,
+		`{ span.a = 1s }`,
+		`{ span.a =~ "(" }`,
+		`{ span.a = "unterminated }`,
+		`{ span.a = "a" } | count() > 1s`,
+		`{ span.a = "a" } | avg(span.b) > 1s`,
+		`{ span.a = "a" } | avg(duration) > 2`,
+		`{ span.a = "a" } | median(duration) > 2s`,
+		`{ span.a = "a" } | count() =~ 2`,
+		`{ span.a = "a" } >`,
+		`{ span.a # "a" }`,
+	} {
+		t.Run(query, func(t *testing.T) {
+			_, err := Parse(query)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,276 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package traceql
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Every span set is a CTE with these columns.
+const spansetFilterFormat = `
+	SELECT s.trace_id, s.span_id, s.parent_span_id, s.start_time, s.end_time
+	FROM _ps_trace.span s
+	INNER JOIN _ps_trace.operation o ON (s.operation_id = o.id)
+	WHERE s.start_time >= $1 AND s.start_time <= $2 AND %s`
+
+const resultFormat = `
+	WITH RECURSIVE %s
+	SELECT
+		r.trace_id,
+		array_agg(r.span_id ORDER BY r.start_time),
+		array_agg(r.start_time ORDER BY r.start_time),
+		array_agg(r.end_time ORDER BY r.start_time),
+		max(r.start_time) AS start_time_max
+	FROM %s r
+	GROUP BY r.trace_id
+	ORDER BY start_time_max DESC
+	LIMIT %d`
+
+var tagOperators = map[CompareOp]string{
+	OpEqual:        "==",
+	OpNotEqual:     "!==",
+	OpRegex:        "==~",
+	OpNotRegex:     "!=~",
+	OpLess:         "#<",
+	OpLessEqual:    "#<=",
+	OpGreater:      "#>",
+	OpGreaterEqual: "#>=",
+}
+
+var sqlOperators = map[CompareOp]string{
+	OpEqual:        "=",
+	OpNotEqual:     "<>",
+	OpRegex:        "~",
+	OpNotRegex:     "!~",
+	OpLess:         "<",
+	OpLessEqual:    "<=",
+	OpGreater:      ">",
+	OpGreaterEqual: ">=",
+}
+
+type compiler struct {
+	ctes             []string
+	params           []interface{}
+	maxTraceDuration time.Duration
+	marginParam      string
+}
+
+// ToSQL returns the query selecting the traces with spans starting in the
+// time range. Each row holds the trace ID, the IDs, start and end times of the
+// matching spans, and the start time of the latest matching span. The most
+// recent traces are returned first. Spans which aren't matched themselves,
+// like the ancestors of matching spans, are looked up within the max trace
+// duration around the time range.
+func (q *Query) ToSQL(start, end time.Time, maxTraceDuration time.Duration, limit int) (string, []interface{}) {
+	c := &compiler{params: []interface{}{start, end}, maxTraceDuration: maxTraceDuration}
+	name := c.spanset(q.Spans)
+	for _, agg := range q.Aggregates {
+		name = c.aggregate(name, agg)
+	}
+	if q.MinTraceDuration > 0 || q.MaxTraceDuration > 0 {
+		name = c.traceDuration(name, q.MinTraceDuration, q.MaxTraceDuration)
+	}
+	return fmt.Sprintf(resultFormat, strings.Join(c.ctes, ",\n"), name, limit), c.params
+}
+
+func (c *compiler) param(v interface{}) string {
+	c.params = append(c.params, v)
+	return fmt.Sprintf("$%d", len(c.params))
+}
+
+// margin returns the parameter holding the max trace duration.
+func (c *compiler) margin() string {
+	if c.marginParam == "" {
+		c.marginParam = c.param(c.maxTraceDuration)
+	}
+	return c.marginParam
+}
+
+// nextCTE returns the name of the next CTE, for recursive CTEs to refer to
+// themselves.
+func (c *compiler) nextCTE() string {
+	return fmt.Sprintf("spanset_%d", len(c.ctes)+1)
+}
+
+func (c *compiler) addCTE(query string) string {
+	name := c.nextCTE()
+	c.ctes = append(c.ctes, fmt.Sprintf("%s AS (%s\n\t)", name, query))
+	return name
+}
+
+func (c *compiler) spanset(e SpansetExpr) string {
+	switch e := e.(type) {
+	case *SpansetFilter:
+		cond := "TRUE"
+		if e.Expr != nil {
+			cond = c.fieldExpr(e.Expr)
+		}
+		return c.addCTE(fmt.Sprintf(spansetFilterFormat, cond))
+	case *SpansetBinary:
+		lhs, rhs := c.spanset(e.LHS), c.spanset(e.RHS)
+		var query string
+		switch e.Op {
+		case SpansetAnd:
+			query = fmt.Sprintf(`
+	SELECT * FROM %[1]s WHERE trace_id IN (SELECT trace_id FROM %[2]s)
+	UNION
+	SELECT * FROM %[2]s WHERE trace_id IN (SELECT trace_id FROM %[1]s)`, lhs, rhs)
+		case SpansetOr:
+			query = fmt.Sprintf(`
+	SELECT * FROM %s
+	UNION
+	SELECT * FROM %s`, lhs, rhs)
+		case SpansetChild:
+			query = fmt.Sprintf(`
+	SELECT r.* FROM %s r
+	WHERE EXISTS (
+		SELECT 1 FROM %s l
+		WHERE l.trace_id = r.trace_id AND l.span_id = r.parent_span_id
+	)`, rhs, lhs)
+		case SpansetDescendant:
+			// The ancestors of the spans are walked up from their parents,
+			// one row per span and ancestor, only through the spans of the
+			// time range extended by the max trace duration.
+			ancestors := c.addCTE(fmt.Sprintf(`
+	SELECT r.trace_id, r.span_id, r.parent_span_id AS ancestor_id
+	FROM %[1]s r
+	WHERE r.parent_span_id IS NOT NULL
+	UNION
+	SELECT a.trace_id, a.span_id, s.parent_span_id
+	FROM %[2]s a
+	INNER JOIN _ps_trace.span s ON (s.trace_id = a.trace_id AND s.span_id = a.ancestor_id)
+	WHERE s.parent_span_id IS NOT NULL
+		AND s.start_time >= $1::timestamptz - %[3]s::interval AND s.start_time <= $2::timestamptz + %[3]s::interval`, rhs, c.nextCTE(), c.margin()))
+			query = fmt.Sprintf(`
+	SELECT r.* FROM %s r
+	WHERE EXISTS (
+		SELECT 1
+		FROM %s a
+		INNER JOIN %s l ON (l.trace_id = a.trace_id AND l.span_id = a.ancestor_id)
+		WHERE a.trace_id = r.trace_id AND a.span_id = r.span_id
+	)`, rhs, ancestors, lhs)
+		}
+		return c.addCTE(query)
+	}
+	panic(fmt.Sprintf("unexpected span set expression %T", e))
+}
+
+func (c *compiler) aggregate(spanset string, agg *AggregateFilter) string {
+	var fn string
+	var value interface{}
+	switch agg.Func {
+	case AggregateCount:
+		fn, value = "count(*)::float8", agg.Value.Number
+	default:
+		fn, value = agg.Func.String()+"(end_time - start_time)", agg.Value.Duration
+	}
+	return c.addCTE(fmt.Sprintf(`
+	SELECT * FROM %[1]s
+	WHERE trace_id IN (
+		SELECT trace_id FROM %[1]s
+		GROUP BY trace_id
+		HAVING %[2]s %[3]s %[4]s
+	)`, spanset, fn, sqlOperators[agg.Op], c.param(value)))
+}
+
+// traceDuration keeps the traces whose spans, looked up within the max trace
+// duration around the time range, span a duration within the bounds.
+func (c *compiler) traceDuration(spanset string, min, max time.Duration) string {
+	var conds []string
+	if min > 0 {
+		conds = append(conds, "max(s.end_time) - min(s.start_time) >= "+c.param(min)+"::interval")
+	}
+	if max > 0 {
+		conds = append(conds, "max(s.end_time) - min(s.start_time) <= "+c.param(max)+"::interval")
+	}
+	return c.addCTE(fmt.Sprintf(`
+	SELECT * FROM %[1]s
+	WHERE trace_id IN (
+		SELECT s.trace_id FROM _ps_trace.span s
+		WHERE s.trace_id IN (SELECT trace_id FROM %[1]s)
+			AND s.start_time >= $1::timestamptz - %[2]s::interval AND s.start_time <= $2::timestamptz + %[2]s::interval
+		GROUP BY s.trace_id
+		HAVING %[3]s
+	)`, spanset, c.margin(), strings.Join(conds, " AND ")))
+}
+
+func (c *compiler) fieldExpr(e FieldExpr) string {
+	switch e := e.(type) {
+	case *LogicalExpr:
+		op := "AND"
+		if e.Op == LogicalOr {
+			op = "OR"
+		}
+		return "(" + c.fieldExpr(e.LHS) + " " + op + " " + c.fieldExpr(e.RHS) + ")"
+	case *Comparison:
+		if e.Field.Scope == ScopeIntrinsic {
+			return c.intrinsic(e)
+		}
+		return c.attribute(e)
+	}
+	panic(fmt.Sprintf("unexpected field expression %T", e))
+}
+
+func (c *compiler) intrinsic(e *Comparison) string {
+	op := sqlOperators[e.Op]
+	switch e.Field.Name {
+	case IntrinsicName:
+		value := e.Value.Str
+		if e.Op.isRegex() {
+			value = anchorRegex(value)
+		}
+		return fmt.Sprintf("o.span_name %s %s", op, c.param(value))
+	case IntrinsicStatus:
+		return fmt.Sprintf("s.status_code %s %s", op, c.param(e.Value.Str))
+	case IntrinsicKind:
+		return fmt.Sprintf("o.span_kind %s %s", op, c.param(e.Value.Str))
+	case IntrinsicDuration:
+		return fmt.Sprintf("(s.end_time - s.start_time) %s %s", op, c.param(e.Value.Duration))
+	}
+	panic(fmt.Sprintf("unexpected intrinsic field %s", e.Field.Name))
+}
+
+// attribute compiles an attribute comparison to a ps_tag operator applied
+// to the tag maps of the scope.
+func (c *compiler) attribute(e *Comparison) string {
+	key := c.param(e.Field.Name)
+	var value string
+	switch e.Value.Type {
+	case TypeNumber:
+		value = c.param(e.Value.Number) + "::float8"
+	case TypeBool:
+		value = c.param(e.Value.Bool) + "::bool"
+	default:
+		s := e.Value.Str
+		if e.Op.isRegex() {
+			s = anchorRegex(s)
+		}
+		value = c.param(s) + "::text"
+	}
+	tagOp := fmt.Sprintf("(%s::text OPERATOR(ps_tag.%s) %s)", key, tagOperators[e.Op], value)
+	match := func(tagMap string) string {
+		return fmt.Sprintf("%s OPERATOR(ps_trace.?) %s", tagMap, tagOp)
+	}
+
+	switch e.Field.Scope {
+	case ScopeSpan:
+		return match("s.span_tags")
+	case ScopeResource:
+		return match("s.resource_tags")
+	case ScopeEvent:
+		return fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM _ps_trace.event e
+			WHERE e.trace_id = s.trace_id AND e.span_id = s.span_id AND %s
+		)`, match("e.tags"))
+	default:
+		return "(" + match("s.span_tags") + " OR " + match("s.resource_tags") + ")"
+	}
+}
+
+func anchorRegex(re string) string {
+	return "^(?:" + re + ")$"
+}
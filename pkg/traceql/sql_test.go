@@ -0,0 +1,106 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package traceql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToSQL(t *testing.T) {
+	start := time.Unix(1000, 0)
+	end := time.Unix(2000, 0)
+	testCases := []struct {
+		query     string
+		fragments []string
+		params    []interface{}
+	}{
+		{
+			query:     `{}`,
+			fragments: []string{"spanset_1 AS (", "AND TRUE", "FROM spanset_1 r", "LIMIT 20"},
+		},
+		{
+			query:     `{ span.http.status_code >= 500 && resource.service.name = "api" }`,
+			fragments: []string{"(s.span_tags OPERATOR(ps_trace.?) ($3::text OPERATOR(ps_tag.#>=) $4::float8) AND s.resource_tags OPERATOR(ps_trace.?) ($5::text OPERATOR(ps_tag.==) $6::text))"},
+			params:    []interface{}{"http.status_code", 500.0, "service.name", "api"},
+		},
+		{
+			query:     `{ .cached = true }`,
+			fragments: []string{"(s.span_tags OPERATOR(ps_trace.?) ($3::text OPERATOR(ps_tag.==) $4::bool) OR s.resource_tags OPERATOR(ps_trace.?) ($3::text OPERATOR(ps_tag.==) $4::bool))"},
+			params:    []interface{}{"cached", true},
+		},
+		{
+			query:     `{ event.exception.type !~ "Time.*" }`,
+			fragments: []string{"FROM _ps_trace.event e", "e.tags OPERATOR(ps_trace.?) ($3::text OPERATOR(ps_tag.!=~) $4::text)"},
+			params:    []interface{}{"exception.type", "^(?:Time.*)$"},
+		},
+		{
+			query:     `{ name =~ "GET .*" || status = error || kind != server || duration > 2s }`,
+			fragments: []string{"o.span_name ~ $3", "s.status_code = $4", "o.span_kind <> $5", "(s.end_time - s.start_time) > $6"},
+			params:    []interface{}{"^(?:GET .*)$", "error", "server", 2 * time.Second},
+		},
+		{
+			query: `{ name = "a" } > { name = "b" }`,
+			fragments: []string{
+				"spanset_3 AS (\n\tSELECT r.* FROM spanset_2 r",
+				"SELECT 1 FROM spanset_1 l\n\t\tWHERE l.trace_id = r.trace_id AND l.span_id = r.parent_span_id",
+				"FROM spanset_3 r",
+			},
+			params: []interface{}{"a", "b"},
+		},
+		{
+			query: `{ name = "a" } >> { name = "b" }`,
+			fragments: []string{
+				"spanset_3 AS (\n\tSELECT r.trace_id, r.span_id, r.parent_span_id AS ancestor_id\n\tFROM spanset_2 r",
+				"FROM spanset_3 a\n\tINNER JOIN _ps_trace.span s",
+				"s.start_time >= $1::timestamptz - $5::interval AND s.start_time <= $2::timestamptz + $5::interval",
+				"FROM spanset_3 a\n\t\tINNER JOIN spanset_1 l ON (l.trace_id = a.trace_id AND l.span_id = a.ancestor_id)",
+			},
+			params: []interface{}{"a", "b", time.Hour},
+		},
+		{
+			query:     `{ name = "a" } && { name = "b" }`,
+			fragments: []string{"SELECT * FROM spanset_1 WHERE trace_id IN (SELECT trace_id FROM spanset_2)", "SELECT * FROM spanset_2 WHERE trace_id IN (SELECT trace_id FROM spanset_1)"},
+			params:    []interface{}{"a", "b"},
+		},
+		{
+			query:     `{ status = error } | count() > 2 | max(duration) >= 1s`,
+			fragments: []string{"HAVING count(*)::float8 > $4", "HAVING max(end_time - start_time) >= $5", "FROM spanset_3 r"},
+			params:    []interface{}{"error", 2.0, time.Second},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			q, err := Parse(tc.query)
+			require.NoError(t, err)
+			sql, params := q.ToSQL(start, end, time.Hour, 20)
+			for _, f := range tc.fragments {
+				require.Contains(t, sql, f)
+			}
+			require.Equal(t, append([]interface{}{start, end}, tc.params...), params)
+		})
+	}
+}
+
+func TestToSQLTraceDuration(t *testing.T) {
+	start := time.Unix(1000, 0)
+	end := time.Unix(2000, 0)
+	q, err := Parse(`{ name = "a" }`)
+	require.NoError(t, err)
+	q.MinTraceDuration = time.Second
+	q.MaxTraceDuration = time.Minute
+	sql, params := q.ToSQL(start, end, time.Hour, 20)
+	for _, f := range []string{
+		"spanset_2 AS (\n\tSELECT * FROM spanset_1",
+		"WHERE s.trace_id IN (SELECT trace_id FROM spanset_1)\n\t\t\tAND s.start_time >= $1::timestamptz - $6::interval AND s.start_time <= $2::timestamptz + $6::interval",
+		"HAVING max(s.end_time) - min(s.start_time) >= $4::interval AND max(s.end_time) - min(s.start_time) <= $5::interval",
+		"FROM spanset_2 r",
+	} {
+		require.Contains(t, sql, f)
+	}
+	require.Equal(t, []interface{}{start, end, "a", time.Second, time.Minute, time.Hour}, params)
+}
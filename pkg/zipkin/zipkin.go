@@ -0,0 +1,281 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+// Package zipkin converts Zipkin v2 spans, in the JSON or protobuf encoding, to
+// OpenTelemetry traces.
+package zipkin
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	zipkinProto "github.com/jaegertracing/jaeger/proto-gen/zipkin"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	conventions "go.opentelemetry.io/collector/semconv/v1.9.0"
+)
+
+const (
+	tagError             = "error"
+	tagStatusCode        = "otel.status_code"
+	tagStatusDescription = "otel.status_description"
+)
+
+var timeProvider = time.Now
+
+// Span is a Zipkin v2 span, as defined by the Zipkin API.
+type Span struct {
+	TraceID        string            `json:"traceId"`
+	ParentID       string            `json:"parentId,omitempty"`
+	ID             string            `json:"id"`
+	Kind           string            `json:"kind,omitempty"`
+	Name           string            `json:"name,omitempty"`
+	Timestamp      uint64            `json:"timestamp,omitempty"`
+	Duration       uint64            `json:"duration,omitempty"`
+	LocalEndpoint  *Endpoint         `json:"localEndpoint,omitempty"`
+	RemoteEndpoint *Endpoint         `json:"remoteEndpoint,omitempty"`
+	Annotations    []Annotation      `json:"annotations,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	Debug          bool              `json:"debug,omitempty"`
+	Shared         bool              `json:"shared,omitempty"`
+}
+
+type Endpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+	IPv4        string `json:"ipv4,omitempty"`
+	IPv6        string `json:"ipv6,omitempty"`
+	Port        int32  `json:"port,omitempty"`
+}
+
+type Annotation struct {
+	Timestamp uint64 `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+// DecodeJSON decodes a JSON list of Zipkin v2 spans.
+func DecodeJSON(b []byte) ([]*Span, error) {
+	var spans []*Span
+	if err := json.Unmarshal(b, &spans); err != nil {
+		return nil, fmt.Errorf("error decoding Zipkin JSON spans: %w", err)
+	}
+	return spans, nil
+}
+
+// DecodeProto decodes a protobuf ListOfSpans of Zipkin v2 spans.
+func DecodeProto(b []byte) ([]*Span, error) {
+	var list zipkinProto.ListOfSpans
+	if err := proto.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("error decoding Zipkin protobuf spans: %w", err)
+	}
+	spans := make([]*Span, 0, len(list.Spans))
+	for _, s := range list.Spans {
+		span := &Span{
+			TraceID:        hex.EncodeToString(s.TraceId),
+			ParentID:       hex.EncodeToString(s.ParentId),
+			ID:             hex.EncodeToString(s.Id),
+			Name:           s.Name,
+			Timestamp:      s.Timestamp,
+			Duration:       s.Duration,
+			LocalEndpoint:  protoEndpoint(s.LocalEndpoint),
+			RemoteEndpoint: protoEndpoint(s.RemoteEndpoint),
+			Tags:           s.Tags,
+			Debug:          s.Debug,
+			Shared:         s.Shared,
+		}
+		if s.Kind != zipkinProto.Span_SPAN_KIND_UNSPECIFIED {
+			span.Kind = s.Kind.String()
+		}
+		for _, a := range s.Annotations {
+			span.Annotations = append(span.Annotations, Annotation{Timestamp: a.Timestamp, Value: a.Value})
+		}
+		spans = append(spans, span)
+	}
+	return spans, nil
+}
+
+func protoEndpoint(e *zipkinProto.Endpoint) *Endpoint {
+	if e == nil {
+		return nil
+	}
+	endpoint := &Endpoint{ServiceName: e.ServiceName, Port: e.Port}
+	if len(e.Ipv4) > 0 {
+		endpoint.IPv4 = net.IP(e.Ipv4).String()
+	}
+	if len(e.Ipv6) > 0 {
+		endpoint.IPv6 = net.IP(e.Ipv6).String()
+	}
+	return endpoint
+}
+
+// ToTraces converts Zipkin spans to traces, with a resource per local service.
+// Annotations become span events and the remote endpoint the peer attributes.
+// Shared spans, the server side of a client span reported with the same ID,
+// keep that ID like in Jaeger, the start time telling both sides apart.
+func ToTraces(spans []*Span) (ptrace.Traces, error) {
+	traces := ptrace.NewTraces()
+	resources := make(map[string]ptrace.SpanSlice)
+	for _, s := range spans {
+		service := ""
+		if s.LocalEndpoint != nil {
+			service = s.LocalEndpoint.ServiceName
+		}
+		slice, ok := resources[service]
+		if !ok {
+			rs := traces.ResourceSpans().AppendEmpty()
+			if service != "" {
+				rs.Resource().Attributes().PutString(conventions.AttributeServiceName, service)
+			}
+			slice = rs.ScopeSpans().AppendEmpty().Spans()
+			resources[service] = slice
+		}
+		if err := toSpan(s, slice.AppendEmpty()); err != nil {
+			return ptrace.Traces{}, err
+		}
+	}
+	return traces, nil
+}
+
+func toSpan(s *Span, span ptrace.Span) error {
+	traceID, err := parseTraceID(s.TraceID)
+	if err != nil {
+		return err
+	}
+	span.SetTraceID(traceID)
+	spanID, err := parseSpanID(s.ID)
+	if err != nil {
+		return fmt.Errorf("invalid span ID %q: %w", s.ID, err)
+	}
+	span.SetSpanID(spanID)
+	if s.ParentID != "" {
+		parentID, err := parseSpanID(s.ParentID)
+		if err != nil {
+			return fmt.Errorf("invalid parent ID %q: %w", s.ParentID, err)
+		}
+		span.SetParentSpanID(parentID)
+	}
+	span.SetName(s.Name)
+	span.SetKind(spanKind(s))
+
+	start := s.Timestamp
+	if start == 0 {
+		// Zipkin allows spans without timestamp, which are given the time
+		// of their first annotation, or else the time of reception.
+		start = uint64(timeProvider().UnixMicro())
+		for _, a := range s.Annotations {
+			if a.Timestamp < start {
+				start = a.Timestamp
+			}
+		}
+	}
+	span.SetStartTimestamp(microsToTimestamp(start))
+	span.SetEndTimestamp(microsToTimestamp(start + s.Duration))
+
+	attrs := span.Attributes()
+	for k, v := range s.Tags {
+		switch k {
+		case tagStatusCode:
+			switch strings.ToUpper(v) {
+			case "ERROR":
+				span.Status().SetCode(ptrace.StatusCodeError)
+			case "OK":
+				span.Status().SetCode(ptrace.StatusCodeOk)
+			}
+		case tagStatusDescription:
+			span.Status().SetMessage(v)
+		default:
+			attrs.PutString(k, v)
+		}
+	}
+	if msg, ok := s.Tags[tagError]; ok && span.Status().Code() == ptrace.StatusCodeUnset {
+		span.Status().SetCode(ptrace.StatusCodeError)
+		span.Status().SetMessage(msg)
+	}
+	if e := s.LocalEndpoint; e != nil {
+		putEndpoint(attrs, e, conventions.AttributeNetHostIP, conventions.AttributeNetHostPort)
+	}
+	if e := s.RemoteEndpoint; e != nil {
+		if e.ServiceName != "" {
+			attrs.PutString(conventions.AttributePeerService, e.ServiceName)
+		}
+		putEndpoint(attrs, e, conventions.AttributeNetPeerIP, conventions.AttributeNetPeerPort)
+	}
+
+	events := span.Events()
+	for _, a := range s.Annotations {
+		event := events.AppendEmpty()
+		event.SetName(a.Value)
+		event.SetTimestamp(microsToTimestamp(a.Timestamp))
+	}
+	return nil
+}
+
+func putEndpoint(attrs pcommon.Map, e *Endpoint, ipKey, portKey string) {
+	switch {
+	case e.IPv4 != "":
+		attrs.PutString(ipKey, e.IPv4)
+	case e.IPv6 != "":
+		attrs.PutString(ipKey, e.IPv6)
+	}
+	if e.Port != 0 {
+		attrs.PutInt(portKey, int64(e.Port))
+	}
+}
+
+func spanKind(s *Span) ptrace.SpanKind {
+	switch strings.ToUpper(s.Kind) {
+	case "CLIENT":
+		return ptrace.SpanKindClient
+	case "SERVER":
+		return ptrace.SpanKindServer
+	case "PRODUCER":
+		return ptrace.SpanKindProducer
+	case "CONSUMER":
+		return ptrace.SpanKindConsumer
+	}
+	if s.Shared {
+		// Only server spans can be shared.
+		return ptrace.SpanKindServer
+	}
+	return ptrace.SpanKindUnspecified
+}
+
+func microsToTimestamp(us uint64) pcommon.Timestamp {
+	return pcommon.Timestamp(us * uint64(time.Microsecond))
+}
+
+// parseTraceID parses a 64 or 128 bit trace ID in hex.
+func parseTraceID(s string) (pcommon.TraceID, error) {
+	var id [16]byte
+	if err := decodeID(s, id[:]); err != nil {
+		return pcommon.TraceID(id), fmt.Errorf("invalid trace ID %q: %w", s, err)
+	}
+	return pcommon.TraceID(id), nil
+}
+
+func parseSpanID(s string) (pcommon.SpanID, error) {
+	var id [8]byte
+	err := decodeID(s, id[:])
+	return pcommon.SpanID(id), err
+}
+
+// decodeID decodes a hex ID into dst, left padded with zeros.
+func decodeID(s string, dst []byte) error {
+	if s == "" || len(s) > 2*len(dst) {
+		return fmt.Errorf("expected up to %d hex characters", 2*len(dst))
+	}
+	if len(s)%2 != 0 {
+		s = "0" + s
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	copy(dst[len(dst)-len(b):], b)
+	return nil
+}
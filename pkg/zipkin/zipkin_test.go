@@ -0,0 +1,161 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package zipkin
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	zipkinProto "github.com/jaegertracing/jaeger/proto-gen/zipkin"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const spansJSON = `[
+  {
+    "traceId": "5af7183fb1d4cf5f",
+    "id": "6b221d5bc9e6496c",
+    "kind": "CLIENT",
+    "name": "get /api",
+    "timestamp": 1556604172355737,
+    "duration": 1431,
+    "localEndpoint": {"serviceName": "frontend", "ipv4": "192.168.99.1", "port": 3306},
+    "remoteEndpoint": {"serviceName": "backend", "ipv4": "172.19.0.2", "port": 9000},
+    "annotations": [{"timestamp": 1556604172355800, "value": "wire send"}],
+    "tags": {"http.method": "GET", "error": "timeout"}
+  },
+  {
+    "traceId": "5af7183fb1d4cf5f",
+    "id": "6b221d5bc9e6496c",
+    "name": "get /api",
+    "timestamp": 1556604172355900,
+    "duration": 1000,
+    "localEndpoint": {"serviceName": "backend"},
+    "shared": true
+  },
+  {
+    "traceId": "463ac35c9f6413ad48485a3953bb6124",
+    "parentId": "6b221d5bc9e6496c",
+    "id": "a2fb4a1d1a96d312",
+    "name": "query",
+    "timestamp": 1556604172356000,
+    "duration": 10,
+    "localEndpoint": {"serviceName": "backend"},
+    "tags": {"otel.status_code": "OK"}
+  }
+]`
+
+func TestToTraces(t *testing.T) {
+	spans, err := DecodeJSON([]byte(spansJSON))
+	require.NoError(t, err)
+	traces, err := ToTraces(spans)
+	require.NoError(t, err)
+
+	require.Equal(t, 3, traces.SpanCount())
+	require.Equal(t, 2, traces.ResourceSpans().Len())
+
+	frontend := traces.ResourceSpans().At(0)
+	service, _ := frontend.Resource().Attributes().Get("service.name")
+	require.Equal(t, "frontend", service.StringVal())
+	client := frontend.ScopeSpans().At(0).Spans().At(0)
+	require.Equal(t, pcommon.TraceID([16]byte{8: 0x5a, 9: 0xf7, 10: 0x18, 11: 0x3f, 12: 0xb1, 13: 0xd4, 14: 0xcf, 15: 0x5f}), client.TraceID())
+	require.Equal(t, "6b221d5bc9e6496c", client.SpanID().HexString())
+	require.True(t, client.ParentSpanID().IsEmpty())
+	require.Equal(t, ptrace.SpanKindClient, client.Kind())
+	require.Equal(t, time.UnixMicro(1556604172355737).UTC(), client.StartTimestamp().AsTime())
+	require.Equal(t, time.UnixMicro(1556604172357168).UTC(), client.EndTimestamp().AsTime())
+	require.Equal(t, ptrace.StatusCodeError, client.Status().Code())
+	require.Equal(t, "timeout", client.Status().Message())
+	require.Equal(t, map[string]interface{}{
+		"http.method":   "GET",
+		"error":         "timeout",
+		"net.host.ip":   "192.168.99.1",
+		"net.host.port": int64(3306),
+		"peer.service":  "backend",
+		"net.peer.ip":   "172.19.0.2",
+		"net.peer.port": int64(9000),
+	}, client.Attributes().AsRaw())
+	require.Equal(t, 1, client.Events().Len())
+	require.Equal(t, "wire send", client.Events().At(0).Name())
+	require.Equal(t, time.UnixMicro(1556604172355800).UTC(), client.Events().At(0).Timestamp().AsTime())
+
+	backend := traces.ResourceSpans().At(1).ScopeSpans().At(0).Spans()
+	require.Equal(t, 2, backend.Len())
+	shared := backend.At(0)
+	require.Equal(t, client.SpanID(), shared.SpanID())
+	require.Equal(t, ptrace.SpanKindServer, shared.Kind())
+	query := backend.At(1)
+	require.Equal(t, "463ac35c9f6413ad48485a3953bb6124", query.TraceID().HexString())
+	require.Equal(t, client.SpanID(), query.ParentSpanID())
+	require.Equal(t, ptrace.StatusCodeOk, query.Status().Code())
+	require.Equal(t, 0, query.Attributes().Len())
+}
+
+func TestDecodeProto(t *testing.T) {
+	b, err := proto.Marshal(&zipkinProto.ListOfSpans{Spans: []*zipkinProto.Span{{
+		TraceId:       []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		ParentId:      []byte{1, 1, 1, 1, 1, 1, 1, 1},
+		Id:            []byte{2, 2, 2, 2, 2, 2, 2, 2},
+		Kind:          zipkinProto.Span_SERVER,
+		Name:          "handle",
+		Timestamp:     1556604172355737,
+		Duration:      5,
+		LocalEndpoint: &zipkinProto.Endpoint{ServiceName: "backend", Ipv4: net.IPv4(10, 0, 0, 1).To4()},
+		Annotations:   []*zipkinProto.Annotation{{Timestamp: 1556604172355738, Value: "done"}},
+		Tags:          map[string]string{"a": "b"},
+	}}})
+	require.NoError(t, err)
+
+	spans, err := DecodeProto(b)
+	require.NoError(t, err)
+	require.Equal(t, []*Span{{
+		TraceID:       "0102030405060708090a0b0c0d0e0f10",
+		ParentID:      "0101010101010101",
+		ID:            "0202020202020202",
+		Kind:          "SERVER",
+		Name:          "handle",
+		Timestamp:     1556604172355737,
+		Duration:      5,
+		LocalEndpoint: &Endpoint{ServiceName: "backend", IPv4: "10.0.0.1"},
+		Annotations:   []Annotation{{Timestamp: 1556604172355738, Value: "done"}},
+		Tags:          map[string]string{"a": "b"},
+	}}, spans)
+	traces, err := ToTraces(spans)
+	require.NoError(t, err)
+	require.Equal(t, ptrace.SpanKindServer, traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Kind())
+}
+
+func TestToTracesErrors(t *testing.T) {
+	testCases := map[string]*Span{
+		"invalid trace ID":  {TraceID: "xyz", ID: "1"},
+		"too long trace ID": {TraceID: "463ac35c9f6413ad48485a3953bb612400", ID: "1"},
+		"missing span ID":   {TraceID: "1"},
+		"invalid parent ID": {TraceID: "1", ID: "1", ParentID: "z"},
+	}
+	for name, span := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ToTraces([]*Span{span})
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestMissingTimestamp(t *testing.T) {
+	now := time.UnixMicro(2000)
+	timeProvider = func() time.Time { return now }
+	defer func() { timeProvider = time.Now }()
+
+	traces, err := ToTraces([]*Span{
+		{TraceID: "1", ID: "1", Annotations: []Annotation{{Timestamp: 1000, Value: "cs"}}},
+		{TraceID: "1", ID: "2"},
+	})
+	require.NoError(t, err)
+	spans := traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+	require.Equal(t, pcommon.Timestamp(1000*time.Microsecond), spans.At(0).StartTimestamp())
+	require.Equal(t, pcommon.NewTimestampFromTime(now), spans.At(1).StartTimestamp())
+}